@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bit-image/internal/postrges"
+	"bit-image/pkg/config"
+	"bit-image/pkg/services"
+	"bit-image/wire"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runCommand runs a one-shot maintenance command instead of the server, e.g. `go run ./cmd reap-uploads`
+func runCommand(cfg *config.Config, args []string) error {
+	// migrations may have to run before the rest of the app can work against the schema
+	if args[0] == "migrate" {
+		return runMigrate(cfg, args[1:])
+	}
+
+	app, err := wire.InitializeApp(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize the app: %w", err)
+	}
+	defer app.Close()
+
+	// the maintenance commands query the schema as much as the server does, they refuse to run against another version
+	if err = app.Migrator.CheckVersion(context.Background()); err != nil {
+		return fmt.Errorf("failed to check the database schema: %w", err)
+	}
+
+	switch args[0] {
+	case "reap-uploads":
+		report, err := app.UploadReaper.ReapAbandonedUploads()
+		if report != nil {
+			if encodeErr := printJSON(report); encodeErr != nil {
+				return encodeErr
+			}
+		}
+		return err
+	case "recover-uploads":
+		report, err := app.UploadRecovery.RecoverUploads(context.Background())
+		if report != nil {
+			if encodeErr := printJSON(report); encodeErr != nil {
+				return encodeErr
+			}
+		}
+		return err
+	case "reconcile":
+		flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+		repairList := flags.String("repair", "", "comma separated repairs to make: recreate, quarantine, mark-broken")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		repairs, err := services.ParseReconcileRepairs(strings.Split(*repairList, ","))
+		if err != nil {
+			return err
+		}
+		report, err := app.Reconciler.Reconcile(context.Background(), repairs)
+		if report != nil {
+			if encodeErr := printJSON(report); encodeErr != nil {
+				return encodeErr
+			}
+		}
+		return err
+	case "retry-derivatives":
+		generated, err := app.DerivativeGenerator.RetryFailed()
+		if encodeErr := printJSON(map[string]int{"generated": generated}); encodeErr != nil {
+			return encodeErr
+		}
+		return err
+	case "retry-labels":
+		labeled, err := app.AutoLabeler.RetryFailed()
+		if encodeErr := printJSON(map[string]int{"labeled": labeled}); encodeErr != nil {
+			return encodeErr
+		}
+		return err
+	case "backfill-fingerprints":
+		report, err := app.Fingerprints.Backfill()
+		if report != nil {
+			if encodeErr := printJSON(report); encodeErr != nil {
+				return encodeErr
+			}
+		}
+		return err
+	case "dispatch-events":
+		delivered, err := app.Events.DispatchDue(context.Background())
+		pending, countErr := app.Events.OutboxStore.CountPendingEvents()
+		if countErr != nil {
+			return countErr
+		}
+		if encodeErr := printJSON(map[string]int64{"delivered": int64(delivered), "pending": pending}); encodeErr != nil {
+			return encodeErr
+		}
+		return err
+	case "deliver-webhooks":
+		delivered, err := app.Webhooks.DeliverDue(context.Background())
+		if encodeErr := printJSON(map[string]int{"delivered": delivered}); encodeErr != nil {
+			return encodeErr
+		}
+		return err
+	case "backfill-search":
+		indexed, err := app.ImageHandler.ImageService.BackfillSearchDocuments()
+		if encodeErr := printJSON(map[string]int{"indexed": indexed}); encodeErr != nil {
+			return encodeErr
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// runMigrate applies, undoes or lists the schema migrations, `migrate up|down [-steps n]|status`
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate takes up, down or status")
+	}
+	migrator, err := wire.InitializeMigrator(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize the migrator: %w", err)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if encodeErr := printJSON(map[string][]string{"applied": migrationNames(applied)}); encodeErr != nil {
+			return encodeErr
+		}
+		return err
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := flags.Int("steps", 1, "number of migrations to undo, latest first")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return fmt.Errorf("-steps must be at least 1")
+		}
+		undone, err := migrator.Down(ctx, *steps)
+		if encodeErr := printJSON(map[string][]string{"undone": migrationNames(undone)}); encodeErr != nil {
+			return encodeErr
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(map[string]any{"latest": migrator.Latest(), "migrations": statuses})
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
+
+func migrationNames(migrations []postrges.Migration) []string {
+	names := make([]string, 0, len(migrations))
+	for _, migration := range migrations {
+		names = append(names, migration.String())
+	}
+	return names
+}
+
+func printJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bit-image/pkg/services"
+	"bit-image/pkg/storage/image"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+func runImages(args []string, out output) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: images takes list, get, delete, restore or reprocess", errUsage)
+	}
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("images list", flag.ContinueOnError)
+		owner := flags.String("owner", "", "only list the images of this user")
+		deleted := flags.Bool("deleted", false, "list deleted images that weren't purged yet instead")
+		broken := flags.Bool("broken", false, "only list images reconciliation found broken")
+		limit := flags.Int("limit", 50, "images per page, at most 100")
+		cursor := flags.String("cursor", "", "next_cursor of the previous page")
+		if _, err := parseFlags(flags, args[1:], 0, 0); err != nil {
+			return err
+		}
+		svc, err := imageService()
+		if err != nil {
+			return err
+		}
+		list, err := svc.ListAllImages(image.AdminImageFilter{OwnerId: *owner, Deleted: *deleted, Broken: *broken}, *cursor, *limit)
+		if err != nil {
+			return err
+		}
+		return out.print(list, func(w io.Writer) {
+			imageTable(w, list.Images...)
+			nextPage(w, list.NextCursor)
+		})
+	case "get":
+		flags := flag.NewFlagSet("images get", flag.ContinueOnError)
+		imageId, err := parseImageId(flags, args[1:])
+		if err != nil {
+			return err
+		}
+		svc, err := imageService()
+		if err != nil {
+			return err
+		}
+		details, err := svc.InspectImage(imageId)
+		if err != nil {
+			return err
+		}
+		return out.print(details, func(w io.Writer) { imageDetailsTable(w, details) })
+	case "delete":
+		flags := flag.NewFlagSet("images delete", flag.ContinueOnError)
+		purge := flags.Bool("purge", false, "remove the images and their objects right away, they can't be restored")
+		rest, err := parseFlags(flags, args[1:], 1, -1)
+		if err != nil {
+			return err
+		}
+		imageIds := make([]uuid.UUID, 0, len(rest))
+		for _, arg := range rest {
+			imageId, err := uuid.Parse(arg)
+			if err != nil {
+				return fmt.Errorf("%w: %q", services.ErrInvalidImageId, arg)
+			}
+			imageIds = append(imageIds, imageId)
+		}
+		svc, err := imageService()
+		if err != nil {
+			return err
+		}
+		deleted, err := svc.ForceDeleteImages(imageIds)
+		if err != nil {
+			return err
+		}
+		result := struct {
+			Deleted []uuid.UUID `json:"deleted"`
+			Purged  int         `json:"purged"`
+		}{Deleted: deleted}
+		if *purge {
+			if result.Purged, err = app.ImagePurger.PurgeDeleted(deleted); err != nil {
+				return err
+			}
+		}
+		return out.print(result, func(w io.Writer) {
+			row(w, "DELETED", "PURGED")
+			for _, imageId := range result.Deleted {
+				row(w, imageId, *purge)
+			}
+		})
+	case "restore", "reprocess":
+		flags := flag.NewFlagSet("images "+args[0], flag.ContinueOnError)
+		imageId, err := parseImageId(flags, args[1:])
+		if err != nil {
+			return err
+		}
+		svc, err := imageService()
+		if err != nil {
+			return err
+		}
+		var report *services.ReprocessReport
+		if args[0] == "restore" {
+			report, err = svc.RestoreImage(imageId)
+		} else {
+			report, err = svc.ReprocessImage(imageId)
+		}
+		if err != nil {
+			return err
+		}
+		return out.print(report, func(w io.Writer) {
+			row(w, "IMAGE", "DERIVATIVES", "LABELED", "FINGERPRINTED", "ERRORS")
+			row(w, report.ImageId, report.Derivatives, report.Labeled, report.Fingerprinted, strings.Join(report.Errors, "; "))
+		})
+	default:
+		return fmt.Errorf("%w: unknown images command %q", errUsage, args[0])
+	}
+}
+
+// parseImageId parses a command taking a single image id
+func parseImageId(flags *flag.FlagSet, args []string) (uuid.UUID, error) {
+	rest, err := parseFlags(flags, args, 1, 1)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	imageId, err := uuid.Parse(rest[0])
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("%w: %q", services.ErrInvalidImageId, rest[0])
+	}
+	return imageId, nil
+}
+
+func imageTable(w io.Writer, images ...services.AdminImage) {
+	row(w, "ID", "OWNER", "NAME", "FORMAT", "SIZE", "PRIVATE", "CREATED", "DELETED", "BROKEN")
+	for _, listed := range images {
+		row(w, listed.Id, listed.OwnerId, listed.Name, listed.Format, formatBytes(int64(listed.FileSize)), listed.IsPrivate,
+			listed.DateTimeCreated, listed.DateTimeDeleted, listed.BrokenReason)
+	}
+}
+
+func imageDetailsTable(w io.Writer, details *services.AdminImageDetails) {
+	imageTable(w, details.AdminImage)
+
+	fmt.Fprintln(w)
+	row(w, "PATH", "BLOB", "FINGERPRINTED")
+	blobId := "-"
+	if details.BlobId != nil {
+		blobId = details.BlobId.String()
+	}
+	row(w, details.Path, blobId, details.Fingerprinted)
+
+	fmt.Fprintln(w)
+	row(w, "DERIVATIVE", "FORMAT", "STATUS", "ATTEMPTS", "LAST ERROR")
+	for _, derivative := range details.Derivatives {
+		row(w, derivative.Size, derivative.Format, derivative.Status, derivative.Attempts, derivative.LastError)
+	}
+	if details.Labeling != nil {
+		row(w, "labels", "-", details.Labeling.Status, details.Labeling.Attempts, details.Labeling.LastError)
+	}
+}
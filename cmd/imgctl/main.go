@@ -0,0 +1,149 @@
+// imgctl is the operator command line of the image store. It runs against the database and bucket configured in .env,
+// through the same wire injector as the server, e.g. `go run ./cmd/imgctl -o json images get <id>`.
+package main
+
+import (
+	"bit-image/pkg/config"
+	"bit-image/pkg/services"
+	"bit-image/wire"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+const usageText = `usage: imgctl [-o table|json] [-db-log level] <command>
+
+commands:
+  users list [-limit n] [-cursor c]            list users with their quota and usage
+  users get <userId>                           show the quota and usage of a user
+  users quota [-images n] [-bytes n] <userId>  change the limits of a user
+  users suspend [-reason r] <userId>           stop a user from getting upload urls
+  users unsuspend <userId>                     lift the suspension of a user
+  users delete <userId>                        delete a user, their webhooks and their images
+  images list [-owner id] [-deleted] [-broken] [-limit n] [-cursor c]
+                                               list the images of every user, newest first
+  images get <imageId>                         show an image, deleted or not, and its processing
+  images delete [-purge] <imageId>...          delete images whoever owns them, -purge removes them for good
+  images restore <imageId>                     bring back a deleted image that wasn't purged yet
+  images reprocess <imageId>                   generate the derivatives, labels and fingerprints of an image again
+  stats [-objects=false]                       show pool, table and storage stats
+`
+
+// errUsage is returned for a command line that doesn't match any command
+var errUsage = errors.New("invalid command line")
+
+func main() {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("Error loading .env file: %v", err)
+	}
+
+	format := flag.String("o", "table", "output format, table or json")
+	// statements are logged to stdout, where they would get mixed with the output
+	flag.StringVar(&dbLogLevel, "db-log", "silent", "level of the statement log, one of "+
+		strings.Join(config.DatabaseLogLevels, ", "))
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usageText) }
+	flag.Parse()
+
+	out, err := newOutput(*format)
+	if err == nil {
+		err = run(flag.Args(), out)
+	}
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usageText)
+		os.Exit(2)
+	}
+	if app != nil {
+		app.Close()
+	}
+	if err != nil {
+		log.Fatalf("imgctl: %v", err)
+	}
+}
+
+func run(args []string, out output) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "users":
+		return runUsers(args[1:], out)
+	case "images":
+		return runImages(args[1:], out)
+	case "stats":
+		return runStats(args[1:], out)
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+}
+
+// dbLogLevel replaces the statement log level of the configuration
+var dbLogLevel string
+
+// app is built on the first command needing it, so that usage errors don't connect to anything
+var app *wire.App
+
+// application builds the app the way the server does, once
+func application() (*wire.App, error) {
+	if app != nil {
+		return app, nil
+	}
+	if !slices.Contains(config.DatabaseLogLevels, dbLogLevel) {
+		return nil, fmt.Errorf("%w: -db-log must be one of %s", errUsage, strings.Join(config.DatabaseLogLevels, ", "))
+	}
+	cfg, err := wire.InitializeConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Log.Database = dbLogLevel
+	if app, err = wire.InitializeApp(cfg); err != nil {
+		return nil, fmt.Errorf("failed to initialize the app: %w", err)
+	}
+	if err = app.Migrator.CheckVersion(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to check the database schema: %w", err)
+	}
+	return app, nil
+}
+
+// imageService returns the image service of the app
+func imageService() (*services.ImageService, error) {
+	app, err := application()
+	if err != nil {
+		return nil, err
+	}
+	return app.ImageHandler.ImageService, nil
+}
+
+// parseFlags parses the flags of a command, which have to come before its arguments, and checks the number of
+// arguments left
+func parseFlags(flags *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	flags.SetOutput(os.Stderr)
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	rest := flags.Args()
+	if len(rest) < minArgs || (maxArgs >= 0 && len(rest) > maxArgs) {
+		return nil, fmt.Errorf("%w: %s takes %s", errUsage, flags.Name(), argumentCount(minArgs, maxArgs))
+	}
+	return rest, nil
+}
+
+func argumentCount(minArgs, maxArgs int) string {
+	switch {
+	case minArgs == maxArgs && minArgs == 0:
+		return "no arguments"
+	case minArgs == maxArgs:
+		return fmt.Sprintf("%d argument(s)", minArgs)
+	case maxArgs < 0:
+		return fmt.Sprintf("at least %d argument(s)", minArgs)
+	default:
+		return fmt.Sprintf("%d to %d arguments", minArgs, maxArgs)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// output prints results either as aligned tables for people or as JSON for scripts
+type output struct {
+	json bool
+}
+
+func newOutput(format string) (output, error) {
+	switch format {
+	case "table":
+		return output{}, nil
+	case "json":
+		return output{json: true}, nil
+	default:
+		return output{}, fmt.Errorf("%w: unknown output format %q", errUsage, format)
+	}
+}
+
+// print encodes the value as JSON, or hands a tab separated writer to table
+func (out output) print(value any, table func(w io.Writer)) error {
+	if out.json {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// row writes the cells of one table row
+func row(w io.Writer, cells ...any) {
+	formatted := make([]string, 0, len(cells))
+	for _, cell := range cells {
+		switch value := cell.(type) {
+		case time.Time:
+			formatted = append(formatted, formatTime(value))
+		case *time.Time:
+			if value == nil {
+				formatted = append(formatted, "-")
+			} else {
+				formatted = append(formatted, formatTime(*value))
+			}
+		case string:
+			if value == "" {
+				value = "-"
+			}
+			formatted = append(formatted, value)
+		default:
+			formatted = append(formatted, fmt.Sprint(value))
+		}
+	}
+	fmt.Fprintln(w, strings.Join(formatted, "\t"))
+}
+
+func formatTime(value time.Time) string {
+	if value.IsZero() {
+		return "-"
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+// formatBytes prints a size in binary units, e.g. 1.5GiB
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// nextPage tells how to get the next page of a listing
+func nextPage(w io.Writer, cursor string) {
+	if cursor != "" {
+		fmt.Fprintf(w, "\nmore results with -cursor %s\n", cursor)
+	}
+}
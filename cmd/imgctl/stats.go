@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+func runStats(args []string, out output) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	listObjects := flags.Bool("objects", true, "list the bucket to count objects and bytes per folder")
+	if _, err := parseFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+	svc, err := imageService()
+	if err != nil {
+		return err
+	}
+	report, err := svc.CollectStats(*listObjects)
+	if err != nil {
+		return err
+	}
+
+	return out.print(report, func(w io.Writer) {
+		pool := report.Pool
+		row(w, "POOL", "MAX", "TOTAL", "IDLE", "ACQUIRED", "ACQUIRES", "EMPTY ACQUIRES", "ACQUIRE TIME")
+		row(w, "pgx", pool.MaxConns, pool.TotalConns, pool.IdleConns, pool.AcquiredConns, pool.AcquireCount,
+			pool.EmptyAcquireCount, pool.AcquireDuration)
+		row(w, "sql", "-", pool.SQLOpenConns, pool.SQLOpenConns-pool.SQLInUse, pool.SQLInUse, "-", pool.SQLWaitCount, "-")
+
+		fmt.Fprintln(w)
+		row(w, "SERVER CONNECTIONS", "COUNT")
+		countRows(w, report.Pool.ServerConns)
+
+		fmt.Fprintln(w)
+		images := report.Images
+		row(w, "USERS", "IMAGES", "IMAGE BYTES", "DELETED", "DELETED BYTES", "BROKEN", "PENDING EVENTS")
+		row(w, report.Users, images.Live, formatBytes(images.LiveBytes), images.Deleted, formatBytes(images.DeletedBytes),
+			images.Broken, report.PendingEvents)
+
+		fmt.Fprintln(w)
+		row(w, "QUEUE", "STATE", "COUNT")
+		for _, queue := range []struct {
+			name   string
+			counts map[string]int64
+		}{{"uploads", report.Uploads}, {"derivatives", report.Derivatives}, {"labeling", report.Labeling}} {
+			for _, state := range slices.Sorted(maps.Keys(queue.counts)) {
+				row(w, queue.name, state, queue.counts[state])
+			}
+		}
+
+		if len(report.Storage) > 0 {
+			fmt.Fprintln(w)
+			row(w, "FOLDER", "OBJECTS", "BYTES")
+			for _, folder := range report.Storage {
+				row(w, folder.Folder, folder.Objects, formatBytes(folder.Bytes))
+			}
+		}
+	})
+}
+
+func countRows(w io.Writer, counts map[string]int64) {
+	for _, key := range slices.Sorted(maps.Keys(counts)) {
+		row(w, key, counts[key])
+	}
+}
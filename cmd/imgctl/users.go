@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bit-image/pkg/services"
+	"flag"
+	"fmt"
+	"io"
+)
+
+func runUsers(args []string, out output) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: users takes list, get, quota, suspend, unsuspend or delete", errUsage)
+	}
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("users list", flag.ContinueOnError)
+		limit := flags.Int("limit", 50, "users per page, at most 100")
+		cursor := flags.String("cursor", "", "next_cursor of the previous page")
+		if _, err := parseFlags(flags, args[1:], 0, 0); err != nil {
+			return err
+		}
+		users, err := userService()
+		if err != nil {
+			return err
+		}
+		list, err := users.ListUsers(*cursor, *limit)
+		if err != nil {
+			return err
+		}
+		return out.print(list, func(w io.Writer) {
+			userTable(w, list.Users...)
+			nextPage(w, list.NextCursor)
+		})
+	case "get":
+		flags := flag.NewFlagSet("users get", flag.ContinueOnError)
+		rest, err := parseFlags(flags, args[1:], 1, 1)
+		if err != nil {
+			return err
+		}
+		users, err := userService()
+		if err != nil {
+			return err
+		}
+		user, err := users.GetUser(rest[0])
+		if err != nil {
+			return err
+		}
+		return out.print(user, func(w io.Writer) { userTable(w, *user) })
+	case "quota":
+		flags := flag.NewFlagSet("users quota", flag.ContinueOnError)
+		images := flags.Int("images", 0, "number of images the user may keep")
+		bytes := flags.Int64("bytes", 0, "number of bytes the user may keep")
+		rest, err := parseFlags(flags, args[1:], 1, 1)
+		if err != nil {
+			return err
+		}
+		// only the limits given on the command line are changed
+		var imageLimit *int
+		var byteLimit *int64
+		flags.Visit(func(set *flag.Flag) {
+			switch set.Name {
+			case "images":
+				imageLimit = images
+			case "bytes":
+				byteLimit = bytes
+			}
+		})
+		if imageLimit == nil && byteLimit == nil {
+			return fmt.Errorf("%w: users quota needs -images or -bytes", errUsage)
+		}
+		users, err := userService()
+		if err != nil {
+			return err
+		}
+		user, err := users.SetQuota(rest[0], imageLimit, byteLimit)
+		if err != nil {
+			return err
+		}
+		return out.print(user, func(w io.Writer) { userTable(w, *user) })
+	case "suspend":
+		flags := flag.NewFlagSet("users suspend", flag.ContinueOnError)
+		reason := flags.String("reason", "", "why the user is suspended")
+		rest, err := parseFlags(flags, args[1:], 1, 1)
+		if err != nil {
+			return err
+		}
+		users, err := userService()
+		if err != nil {
+			return err
+		}
+		user, err := users.SuspendUser(rest[0], *reason)
+		if err != nil {
+			return err
+		}
+		return out.print(user, func(w io.Writer) { userTable(w, *user) })
+	case "unsuspend":
+		flags := flag.NewFlagSet("users unsuspend", flag.ContinueOnError)
+		rest, err := parseFlags(flags, args[1:], 1, 1)
+		if err != nil {
+			return err
+		}
+		users, err := userService()
+		if err != nil {
+			return err
+		}
+		user, err := users.UnsuspendUser(rest[0])
+		if err != nil {
+			return err
+		}
+		return out.print(user, func(w io.Writer) { userTable(w, *user) })
+	case "delete":
+		flags := flag.NewFlagSet("users delete", flag.ContinueOnError)
+		rest, err := parseFlags(flags, args[1:], 1, 1)
+		if err != nil {
+			return err
+		}
+		users, err := userService()
+		if err != nil {
+			return err
+		}
+		deletion, err := users.DeleteUser(rest[0])
+		if err != nil {
+			return err
+		}
+		return out.print(deletion, func(w io.Writer) {
+			fmt.Fprintf(w, "deleted user %s and %d images\n", deletion.Id, deletion.DeletedImages)
+		})
+	default:
+		return fmt.Errorf("%w: unknown users command %q", errUsage, args[0])
+	}
+}
+
+func userService() (*services.UserService, error) {
+	app, err := application()
+	if err != nil {
+		return nil, err
+	}
+	return app.UserHandler.UserService, nil
+}
+
+func userTable(w io.Writer, users ...services.UserDetails) {
+	row(w, "ID", "IMAGES", "IMAGE LIMIT", "BYTES", "BYTE LIMIT", "SUSPENDED", "CREATED")
+	for _, user := range users {
+		row(w, user.Id, user.ImageUploadCount, user.ImageUploadLimit, formatBytes(user.ByteUploadCount),
+			formatBytes(user.ByteUploadLimit), user.SuspendedAt, user.DateTimeCreated)
+	}
+}
@@ -1,31 +1,53 @@
 package main
 
 import (
-	"bit-image/internal/postrges"
-	"bit-image/pkg/middleware"
+	"bit-image/pkg/common"
+	"bit-image/pkg/rpc"
 	"bit-image/wire"
+	"context"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
-var handler *postrges.ConnectionHandler
-
 func main() {
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
 
-	// Postgres Initialization
-	handler, err := postrges.NewConnectionHandler()
+	// Configuration is checked before anything connects, every invalid value is reported at once
+	cfg, err := wire.InitializeConfig()
 	if err != nil {
-		log.Fatalf("Error initializing database connection: %v", err)
+		log.Fatalf("%v", err)
+	}
+
+	// Maintenance commands run once and exit without starting the server
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1:]); err != nil {
+			log.Fatalf("%s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	// Every component shares the connection pool and object store built here
+	app, err := wire.InitializeApp(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize the app: %v", err)
 	}
-	defer handler.Close()
+	defer app.Close()
 
+	// The schema is only changed by `migrate up`, running against any other version than the embedded one is refused
+	if err = app.Migrator.CheckVersion(context.Background()); err != nil {
+		log.Fatalf("Error checking the database schema: %v", err)
+	}
+
+	gin.SetMode(cfg.Log.Gin)
 	router := gin.Default()
 
 	// healthcheck
@@ -37,27 +59,109 @@ func main() {
 
 	// db healthcheck
 	router.GET("/db-stats", func(c *gin.Context) {
-		handler.PrintConnectionPoolStats()
+		app.DB.PrintConnectionPoolStats()
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Database stats printed",
 		})
 	})
 
-	// Initialize image handler
-	imageHandler, err := wire.InitializeImageHandler()
-	if err != nil {
-		log.Fatalf("Failed to initialize the app: %v", err)
+	imageHandler := app.ImageHandler
+
+	// The similarity index is rebuilt from Postgres before serving, and periodically to pick up other instances' changes
+	if err = app.Fingerprints.Load(); err != nil {
+		log.Fatalf("Failed to load the fingerprint index: %v", err)
 	}
+	go app.Fingerprints.Run(context.Background())
+
+	// Background publication of image events, the image service wakes it up after committing events
+	go app.Events.Run(context.Background())
+
+	// Background webhook deliveries, fed by the event publication above
+	go app.Webhooks.Run(context.Background())
+	webhookHandler := app.WebhookHandler
+
+	// Local object storage, only served when STORAGE_BACKEND=local
+	localStorageHandler := app.LocalStorageHandler
+	if localStorageHandler.Enabled() {
+		router.PUT(common.LOCAL_STORAGE_ROUTE+"/*key", localStorageHandler.PutObject())
+		router.GET(common.LOCAL_STORAGE_ROUTE+"/*key", localStorageHandler.GetObject())
+	}
+
+	// Background purge of soft-deleted images
+	go app.ImagePurger.Run(context.Background())
+
+	// Background cleanup of uploads that were never confirmed
+	go app.UploadReaper.Run(context.Background())
+
+	// Background recovery of confirmations interrupted between moving an upload and committing its image
+	go app.UploadRecovery.Run(context.Background())
+
+	// Scheduled reconciliation of the images table against the bucket, repairing only what RECONCILE_REPAIRS allows
+	go app.Reconciler.Run(context.Background())
+
+	// Background generation of thumbnails and other derivatives
+	go app.DerivativeGenerator.Run(context.Background())
+
+	// Background machine labeling of confirmed images
+	go app.AutoLabeler.Run(context.Background())
+
+	// Users are provisioned by the auth middleware, deleting one deletes their images through the shared image service
+	userHandler := app.UserHandler
 
 	// Protected routes using AuthMiddleware
+	authenticator := app.Authenticator
 	apiGroup := router.Group("/api")
-	apiGroup.Use(middleware.AuthMiddleware())
+	apiGroup.Use(authenticator.AuthMiddleware())
 
 	apiGroup.PUT("/generateUploadUrls", imageHandler.GeneratePresignedURL())
 	apiGroup.POST("/confirmImageUploads", imageHandler.ConfirmImageUploads())
+	apiGroup.POST("/checkImageHashes", imageHandler.CheckImageHashes())
+	apiGroup.GET("/images", imageHandler.ListImages())
+	apiGroup.GET("/images/:id", imageHandler.GetImage())
+	apiGroup.GET("/images/:id/render", imageHandler.RenderImage())
+	apiGroup.DELETE("/images", imageHandler.DeleteImages())
+	apiGroup.DELETE("/images/:id", imageHandler.DeleteImage())
+	apiGroup.POST("/images/tags", imageHandler.TagImages())
+	apiGroup.PUT("/images/:id/tags", imageHandler.AddImageTags())
+	apiGroup.DELETE("/images/:id/tags", imageHandler.RemoveImageTags())
+	apiGroup.PUT("/images/:id/labels", imageHandler.AddImageLabels())
+	apiGroup.DELETE("/images/:id/labels", imageHandler.RemoveImageLabels())
+	apiGroup.GET("/tags", imageHandler.ListTags())
+	apiGroup.GET("/images/:id/similar", imageHandler.FindSimilarImages())
+	apiGroup.GET("/images/duplicates", imageHandler.DuplicateGroups())
+	apiGroup.GET("/search", imageHandler.SearchImages())
+	apiGroup.POST("/webhooks", webhookHandler.CreateWebhook())
+	apiGroup.GET("/webhooks", webhookHandler.ListWebhooks())
+	apiGroup.GET("/webhooks/:id", webhookHandler.GetWebhook())
+	apiGroup.PATCH("/webhooks/:id", webhookHandler.UpdateWebhook())
+	apiGroup.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook())
+	apiGroup.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries())
+	apiGroup.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver())
+	apiGroup.GET("/me", userHandler.Me())
+
+	// Admin routes, for the users listed in AUTH_ADMIN_USER_IDS
+	adminGroup := apiGroup.Group("/admin")
+	adminGroup.Use(authenticator.AdminMiddleware())
+	adminGroup.GET("/users", userHandler.ListUsers())
+	adminGroup.GET("/users/:id", userHandler.GetUser())
+	adminGroup.POST("/users/:id/suspend", userHandler.SuspendUser())
+	adminGroup.POST("/users/:id/unsuspend", userHandler.UnsuspendUser())
+	adminGroup.DELETE("/users/:id", userHandler.DeleteUser())
+
+	// gRPC api for internal callers, served from the same image service as the REST routes
+	grpcListener, err := net.Listen("tcp", cfg.Server.GRPCAddress)
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC: %v", err)
+	}
+	grpcServer := rpc.NewServer(rpc.NewImageServer(imageHandler.ImageService), authenticator)
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("Failed to start the gRPC server: %v", err)
+		}
+	}()
 
 	// Start the server
-	if err := router.Run(); err != nil {
+	if err := router.Run(fmt.Sprintf(":%d", cfg.Server.Port)); err != nil {
 		log.Fatalf("Failed to start the server: %v", err)
 	}
 }
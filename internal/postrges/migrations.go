@@ -0,0 +1,324 @@
+package postrges
+
+import (
+	"cmp"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// migrationFiles are the versioned schema changes, <version>_<name>.up.sql along with the .down.sql undoing it. A
+// migration is never edited once released, a new version is added instead.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationLockKey is the advisory lock serializing the migrations of concurrent instances
+const migrationLockKey int64 = 0x6269742d696d67 // "bit-img"
+
+// ErrSchemaMismatch is returned when the database isn't at the version of the embedded migrations
+var ErrSchemaMismatch = errors.New("database schema version mismatch")
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus is a migration along with when it was applied, AppliedAt is nil while it is pending. Unknown is set
+// for versions applied to the database that this build doesn't embed.
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+	Unknown   bool       `json:"unknown,omitempty"`
+}
+
+// Migrator applies the embedded migrations and records them in schema_migrations. Every migration runs in its own
+// transaction along with its record, the whole run holds an advisory lock so instances starting together don't race.
+type Migrator struct {
+	Pool       *pgxpool.Pool
+	Migrations []Migration
+}
+
+func NewMigrator(handler *ConnectionHandler) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{Pool: handler.Pool, Migrations: migrations}, nil
+}
+
+// loadMigrations reads the migrations of a directory ordered by version, checking every one of them can be undone
+func loadMigrations(files fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(files, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, name := range names {
+		match := migrationFileName.FindStringSubmatch(path.Base(name))
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", name, err)
+		}
+		content, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		migration, found := byVersion[version]
+		if !found {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.up = string(content)
+		} else {
+			migration.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return migrations, nil
+}
+
+func (migration Migration) String() string {
+	return fmt.Sprintf("%04d_%s", migration.Version, migration.Name)
+}
+
+// Latest is the version the embedded migrations bring the database to
+func (migrator *Migrator) Latest() int64 {
+	if len(migrator.Migrations) == 0 {
+		return 0
+	}
+	return migrator.Migrations[len(migrator.Migrations)-1].Version
+}
+
+// Up applies every pending migration in order and returns the ones it applied
+func (migrator *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var ran []Migration
+	err := migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if unknown := migrator.unknownVersions(applied); len(unknown) > 0 {
+			return fmt.Errorf("database has migrations %v this build doesn't know, it is newer than the code", unknown)
+		}
+
+		for _, migration := range migrator.Migrations {
+			if _, found := applied[migration.Version]; found {
+				continue
+			}
+			err = runInTransaction(ctx, conn, migration.up,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %s: %w", migration, err)
+			}
+			log.Printf("applied migration %s", migration)
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down undoes the last steps applied migrations, latest first, and returns the ones it undid
+func (migrator *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := slices.Sorted(maps.Keys(applied))
+		slices.Reverse(versions)
+
+		for _, version := range versions[:min(steps, len(versions))] {
+			index := slices.IndexFunc(migrator.Migrations, func(migration Migration) bool { return migration.Version == version })
+			if index < 0 {
+				return fmt.Errorf("migration %d isn't known to this build and can't be undone", version)
+			}
+			migration := migrator.Migrations[index]
+			err = runInTransaction(ctx, conn, migration.down, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			if err != nil {
+				return fmt.Errorf("failed to undo migration %s: %w", migration, err)
+			}
+			log.Printf("undid migration %s", migration)
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists the embedded migrations with when they were applied, followed by the applied ones this build doesn't
+// know
+func (migrator *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := migrator.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire a connection: %w", err)
+	}
+	defer conn.Release()
+
+	var exists bool
+	if err = conn.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look up schema_migrations: %w", err)
+	}
+	applied := map[int64]MigrationStatus{}
+	if exists {
+		if applied, err = appliedVersions(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrator.Migrations))
+	for _, migration := range migrator.Migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if found, ok := applied[migration.Version]; ok {
+			status.AppliedAt = found.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for _, version := range migrator.unknownVersions(applied) {
+		status := applied[version]
+		status.Unknown = true
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// CheckVersion fails with ErrSchemaMismatch unless every embedded migration, and nothing else, has been applied
+func (migrator *Migrator) CheckVersion(ctx context.Context) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	var pending, unknown []int64
+	for _, status := range statuses {
+		switch {
+		case status.Unknown:
+			unknown = append(unknown, status.Version)
+		case status.AppliedAt == nil:
+			pending = append(pending, status.Version)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: migrations %v are applied but unknown to this build, which expects version %d",
+			ErrSchemaMismatch, unknown, migrator.Latest())
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: migrations %v are pending, run `migrate up` to bring the database to version %d",
+			ErrSchemaMismatch, pending, migrator.Latest())
+	}
+	return nil
+}
+
+func (migrator *Migrator) unknownVersions(applied map[int64]MigrationStatus) []int64 {
+	var unknown []int64
+	for version := range applied {
+		if !slices.ContainsFunc(migrator.Migrations, func(migration Migration) bool { return migration.Version == version }) {
+			unknown = append(unknown, version)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
+}
+
+// withLock runs fn on a connection holding the migration lock, creating schema_migrations first
+func (migrator *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := migrator.Pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire a connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	defer func() {
+		if _, unlockErr := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey); unlockErr != nil {
+			log.Printf("failed to release the migration lock: %v", unlockErr)
+		}
+	}()
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+// runInTransaction runs the statements of a migration and the statement recording it atomically
+func runInTransaction(ctx context.Context, conn *pgxpool.Conn, statements string, record string, args ...any) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+	}()
+
+	// without arguments the statements go through the simple protocol, which runs several of them at once
+	if _, err = tx.Exec(ctx, statements); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// appliedVersions reads the migrations recorded in schema_migrations, by version
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]MigrationStatus, error) {
+	rows, err := conn.Query(ctx, "SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]MigrationStatus{}
+	for rows.Next() {
+		var status MigrationStatus
+		if err = rows.Scan(&status.Version, &status.Name, &status.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[status.Version] = status
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "webhook_deliveries", "webhook_subscriptions", "outbox", "image_search_documents", "labeling_jobs",
+    "image_labels", "labels", "image_tags", "tags", "image_exifs", "derivatives", "uploads", "images", "blobs", "users";
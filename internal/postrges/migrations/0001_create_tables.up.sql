@@ -0,0 +1,71 @@
+-- The tables as gorm created them before migrations existed. Every statement is idempotent so databases set up by
+-- AutoMigrate are adopted as they are, and brought up to the columns the later migrations expect.
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS "users" ("id" uuid DEFAULT uuid_generate_v4(),"date_time_created" timestamptz,"date_time_updated" timestamptz,"image_upload_limit" bigint NOT NULL,"image_upload_count" bigint NOT NULL DEFAULT 0,"byte_upload_limit" bigint NOT NULL,"byte_upload_count" bigint NOT NULL DEFAULT 0,PRIMARY KEY ("id"));
+
+CREATE TABLE IF NOT EXISTS "blobs" ("id" uuid DEFAULT uuid_generate_v4(),"date_time_created" timestamptz,"date_time_updated" timestamptz,"owner_id" text NOT NULL,"hash" text NOT NULL,"path" text NOT NULL,"size" bigint NOT NULL,"ref_count" bigint NOT NULL,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_blobs_ref_count" ON "blobs" ("ref_count");
+
+CREATE TABLE IF NOT EXISTS "images" ("id" uuid DEFAULT uuid_generate_v4(),"date_time_created" timestamptz,"date_time_updated" timestamptz,"owner_id" text NOT NULL DEFAULT '',"name" text NOT NULL,"caption" text NOT NULL DEFAULT '',"is_private" boolean NOT NULL,"path" text NOT NULL,"file_size" decimal,"format" text,"hash" text,"mime_type" text,"width" bigint,"height" bigint,"color_model" text,"d_hash" bigint,"p_hash" bigint,"public_path" text NOT NULL DEFAULT '',"blob_id" uuid,"broken_at" timestamptz,"broken_reason" text NOT NULL DEFAULT '',"date_time_deleted" timestamptz,PRIMARY KEY ("id"));
+-- AutoMigrate only ever created images with its original columns, the ones added since are missing there
+ALTER TABLE "images" ADD COLUMN IF NOT EXISTS "owner_id" text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS "caption" text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS "mime_type" text,
+    ADD COLUMN IF NOT EXISTS "width" bigint,
+    ADD COLUMN IF NOT EXISTS "height" bigint,
+    ADD COLUMN IF NOT EXISTS "color_model" text,
+    ADD COLUMN IF NOT EXISTS "d_hash" bigint,
+    ADD COLUMN IF NOT EXISTS "p_hash" bigint,
+    ADD COLUMN IF NOT EXISTS "public_path" text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS "blob_id" uuid,
+    ADD COLUMN IF NOT EXISTS "broken_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "broken_reason" text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS "date_time_deleted" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_images_date_time_deleted" ON "images" ("date_time_deleted");
+CREATE INDEX IF NOT EXISTS "idx_images_blob_id" ON "images" ("blob_id");
+
+CREATE TABLE IF NOT EXISTS "uploads" ("id" uuid DEFAULT uuid_generate_v4(),"date_time_created" timestamptz,"date_time_updated" timestamptz,"owner_id" text NOT NULL,"checksum_sha256" text NOT NULL,"expires_at" timestamptz NOT NULL,"state" text NOT NULL DEFAULT 'issued',"state_changed_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,"name" text NOT NULL DEFAULT '',"caption" text NOT NULL DEFAULT '',"is_private" boolean NOT NULL DEFAULT false,"destination_key" text NOT NULL DEFAULT '',"attempts" bigint NOT NULL DEFAULT 0,"last_error" text NOT NULL DEFAULT '',PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_uploads_owner_id" ON "uploads" ("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_uploads_state" ON "uploads" ("state");
+CREATE INDEX IF NOT EXISTS "idx_uploads_expires_at" ON "uploads" ("expires_at");
+
+CREATE TABLE IF NOT EXISTS "derivatives" ("id" uuid DEFAULT uuid_generate_v4(),"date_time_created" timestamptz,"date_time_updated" timestamptz,"image_id" uuid NOT NULL,"size" bigint NOT NULL,"format" text NOT NULL,"path" text NOT NULL,"status" text NOT NULL,"width" bigint NOT NULL DEFAULT 0,"height" bigint NOT NULL DEFAULT 0,"file_size" bigint NOT NULL DEFAULT 0,"attempts" bigint NOT NULL DEFAULT 0,"last_error" text NOT NULL DEFAULT '',"next_attempt_at" timestamptz NOT NULL,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_derivatives_next_attempt_at" ON "derivatives" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_derivatives_status" ON "derivatives" ("status");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_derivatives_image_size_format" ON "derivatives" ("image_id","size","format");
+
+CREATE TABLE IF NOT EXISTS "image_exifs" ("id" uuid DEFAULT uuid_generate_v4(),"date_time_created" timestamptz,"date_time_updated" timestamptz,"make" text NOT NULL DEFAULT '',"model" text NOT NULL DEFAULT '',"lens_model" text NOT NULL DEFAULT '',"captured_at" timestamptz,"orientation" bigint NOT NULL DEFAULT 0,"exposure_time" decimal,"f_number" decimal,"iso" bigint,"focal_length" decimal,"latitude" decimal,"longitude" decimal,"altitude" decimal,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_image_exifs_captured_at" ON "image_exifs" ("captured_at");
+CREATE INDEX IF NOT EXISTS "idx_image_exifs_camera" ON "image_exifs" ("make","model");
+
+CREATE TABLE IF NOT EXISTS "tags" ("id" uuid DEFAULT uuid_generate_v4(),"date_time_created" timestamptz,"date_time_updated" timestamptz,"owner_id" text NOT NULL,"name" text NOT NULL,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_tags_owner_name" ON "tags" ("owner_id","name");
+
+CREATE TABLE IF NOT EXISTS "image_tags" ("image_id" uuid,"tag_id" uuid,"date_time_created" timestamptz,PRIMARY KEY ("image_id","tag_id"));
+CREATE INDEX IF NOT EXISTS "idx_image_tags_tag_id" ON "image_tags" ("tag_id");
+
+CREATE TABLE IF NOT EXISTS "labels" ("id" uuid DEFAULT uuid_generate_v4(),"date_time_created" timestamptz,"date_time_updated" timestamptz,"name" text NOT NULL,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_labels_name" ON "labels" ("name");
+
+CREATE TABLE IF NOT EXISTS "image_labels" ("image_id" uuid,"label_id" uuid,"source" text,"confidence" decimal NOT NULL,"date_time_created" timestamptz,PRIMARY KEY ("image_id","label_id","source"));
+CREATE INDEX IF NOT EXISTS "idx_image_labels_label_id" ON "image_labels" ("label_id");
+
+CREATE TABLE IF NOT EXISTS "labeling_jobs" ("image_id" uuid,"status" text NOT NULL,"attempts" bigint NOT NULL DEFAULT 0,"last_error" text NOT NULL DEFAULT '',"next_attempt_at" timestamptz NOT NULL,"date_time_created" timestamptz,"date_time_updated" timestamptz,PRIMARY KEY ("image_id"));
+CREATE INDEX IF NOT EXISTS "idx_labeling_jobs_next_attempt_at" ON "labeling_jobs" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_labeling_jobs_status" ON "labeling_jobs" ("status");
+
+CREATE TABLE IF NOT EXISTS "image_search_documents" ("image_id" uuid,"owner_id" text NOT NULL,"is_private" boolean NOT NULL,"format" text NOT NULL DEFAULT '',"year" bigint NOT NULL,"content" text NOT NULL DEFAULT '',"tag_content" text NOT NULL DEFAULT '',"document" tsvector NOT NULL,"owner_document" tsvector NOT NULL,PRIMARY KEY ("image_id"));
+CREATE INDEX IF NOT EXISTS "idx_image_search_documents_owner_id" ON "image_search_documents" ("owner_id");
+
+CREATE TABLE IF NOT EXISTS "outbox" ("sequence" bigserial,"event_id" uuid NOT NULL,"image_id" uuid NOT NULL,"type" text NOT NULL,"version" bigint NOT NULL,"payload" jsonb NOT NULL,"status" text NOT NULL,"attempts" bigint NOT NULL DEFAULT 0,"last_error" text NOT NULL DEFAULT '',"next_attempt_at" timestamptz NOT NULL,"occurred_at" timestamptz NOT NULL,"delivered_at" timestamptz,PRIMARY KEY ("sequence"));
+CREATE INDEX IF NOT EXISTS "idx_outbox_status" ON "outbox" ("status");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_outbox_event_id" ON "outbox" ("event_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_subscriptions" ("id" uuid DEFAULT uuid_generate_v4(),"date_time_created" timestamptz,"date_time_updated" timestamptz,"owner_id" text NOT NULL,"url" text NOT NULL,"secret" text NOT NULL,"event_types" text NOT NULL DEFAULT '',"active" boolean NOT NULL DEFAULT true,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_webhook_subscriptions_owner_id" ON "webhook_subscriptions" ("owner_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" ("id" uuid DEFAULT uuid_generate_v4(),"date_time_created" timestamptz,"date_time_updated" timestamptz,"subscription_id" uuid NOT NULL,"event_id" uuid NOT NULL,"event_type" text NOT NULL,"image_id" uuid NOT NULL,"payload" jsonb NOT NULL,"status" text NOT NULL,"attempts" bigint NOT NULL DEFAULT 0,"response_status" bigint NOT NULL DEFAULT 0,"last_error" text NOT NULL DEFAULT '',"next_attempt_at" timestamptz NOT NULL,"delivered_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_webhook_deliveries_subscription_event" ON "webhook_deliveries" ("subscription_id","event_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_next_attempt_at" ON "webhook_deliveries" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_status" ON "webhook_deliveries" ("status");
@@ -0,0 +1 @@
+-- backfilled owners can't be told apart from the others and are right anyway, there is nothing to undo
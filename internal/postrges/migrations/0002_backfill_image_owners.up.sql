@@ -0,0 +1,2 @@
+-- images from before owner_id existed only carry the owner in their path, <folder>/<userId>/<imageId>
+UPDATE images SET owner_id = split_part(path, '/', 2) WHERE owner_id = '';
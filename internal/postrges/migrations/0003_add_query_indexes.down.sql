@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_images_owner_created, idx_images_path, idx_image_search_documents_document,
+    idx_image_search_documents_owner_document, idx_outbox_pending_image, idx_webhook_deliveries_subscription_created,
+    idx_blobs_owner_hash;
@@ -0,0 +1,18 @@
+-- listing a user's images walks this index in (date_time_created, id) order
+CREATE INDEX IF NOT EXISTS idx_images_owner_created ON images (owner_id, date_time_created, id);
+
+-- reconciliation pages through images in the bytewise order objects are listed in
+CREATE INDEX IF NOT EXISTS idx_images_path ON images (path COLLATE "C", id);
+
+-- searches match the public document of other users' images and the owner document of the user's own
+CREATE INDEX IF NOT EXISTS idx_image_search_documents_document ON image_search_documents USING GIN (document);
+CREATE INDEX IF NOT EXISTS idx_image_search_documents_owner_document ON image_search_documents USING GIN (owner_document);
+
+-- the dispatcher looks up the oldest pending event of every image
+CREATE INDEX IF NOT EXISTS idx_outbox_pending_image ON outbox (image_id, sequence) WHERE status = 'pending';
+
+-- delivery logs are paged newest first per subscription
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_created ON webhook_deliveries (subscription_id, date_time_created, id);
+
+-- an owner has at most one live blob per content hash, released blobs wait for their object to be deleted
+CREATE UNIQUE INDEX IF NOT EXISTS idx_blobs_owner_hash ON blobs (owner_id, hash) WHERE ref_count > 0;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_date_time_deleted;
+ALTER TABLE users DROP COLUMN date_time_deleted, DROP COLUMN suspended_reason, DROP COLUMN suspended_at;
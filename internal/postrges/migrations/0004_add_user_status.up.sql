@@ -0,0 +1,5 @@
+-- admins can suspend users and delete them, deleted users keep their row so their tokens stay refused
+ALTER TABLE users ADD COLUMN suspended_at timestamptz;
+ALTER TABLE users ADD COLUMN suspended_reason text NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN date_time_deleted timestamptz;
+CREATE INDEX idx_users_date_time_deleted ON users (date_time_deleted);
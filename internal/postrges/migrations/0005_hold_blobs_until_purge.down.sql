@@ -0,0 +1,6 @@
+-- deleted images still holding their blob reference give it up, as every deleted image did before
+UPDATE blobs SET ref_count = blobs.ref_count - held.count
+FROM (SELECT blob_id, COUNT(*) AS count FROM images
+      WHERE blob_id IS NOT NULL AND date_time_deleted IS NOT NULL AND NOT blob_released GROUP BY blob_id) held
+WHERE blobs.id = held.blob_id;
+ALTER TABLE images DROP COLUMN blob_released;
@@ -0,0 +1,4 @@
+-- force-deleted images keep their blob reference until they are purged so they can be restored, every deduplicated
+-- image deleted so far gave it up right away
+ALTER TABLE images ADD COLUMN blob_released boolean NOT NULL DEFAULT false;
+UPDATE images SET blob_released = true WHERE blob_id IS NOT NULL AND date_time_deleted IS NOT NULL;
@@ -0,0 +1,6 @@
+-- the previous version expects a Content-Type in the format of every image
+UPDATE images SET format = 'image/' || format WHERE format IN ('jpeg', 'png', 'gif', 'webp', 'bmp', 'tiff');
+
+UPDATE image_search_documents SET format = images.format
+FROM images
+WHERE images.id = image_search_documents.image_id AND image_search_documents.format <> images.format;
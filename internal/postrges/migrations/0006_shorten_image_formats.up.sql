@@ -0,0 +1,19 @@
+-- images confirmed before their content was inspected stored the Content-Type of the upload as their format, newer ones
+-- the name of the format their content decodes as. Old rows are given the short name so filters, facets and renders
+-- treat every image the same way.
+UPDATE images SET format = CASE trim(lower(split_part(format, ';', 1)))
+    WHEN 'image/jpeg' THEN 'jpeg'
+    WHEN 'image/jpg' THEN 'jpeg'
+    WHEN 'image/pjpeg' THEN 'jpeg'
+    WHEN 'image/png' THEN 'png'
+    WHEN 'image/gif' THEN 'gif'
+    WHEN 'image/webp' THEN 'webp'
+    WHEN 'image/bmp' THEN 'bmp'
+    WHEN 'image/x-ms-bmp' THEN 'bmp'
+    WHEN 'image/tiff' THEN 'tiff'
+    ELSE format END
+WHERE format LIKE '%/%';
+
+UPDATE image_search_documents SET format = images.format
+FROM images
+WHERE images.id = image_search_documents.image_id AND image_search_documents.format <> images.format;
@@ -0,0 +1,124 @@
+package postrges
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func migrationFS(names ...string) fstest.MapFS {
+	files := fstest.MapFS{}
+	for _, name := range names {
+		files["migrations/"+name] = &fstest.MapFile{Data: []byte("-- " + name)}
+	}
+	return files
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "ordered by version, not by name",
+			files: migrationFS(
+				"10_add_labels.up.sql", "10_add_labels.down.sql",
+				"2_add_indexes.up.sql", "2_add_indexes.down.sql",
+				"0001_create_tables.down.sql", "0001_create_tables.up.sql",
+			),
+			want: []string{"0001_create_tables", "0002_add_indexes", "0010_add_labels"},
+		},
+		{
+			name:  "no migrations",
+			files: fstest.MapFS{"migrations/README": &fstest.MapFile{}},
+			want:  []string{},
+		},
+		{
+			name:    "up without down",
+			files:   migrationFS("0001_create_tables.up.sql", "0001_create_tables.down.sql", "0002_add_indexes.up.sql"),
+			wantErr: "migration 0002_add_indexes needs both an up and a down file",
+		},
+		{
+			name:    "down without up",
+			files:   migrationFS("0003_add_labels.down.sql"),
+			wantErr: "migration 0003_add_labels needs both an up and a down file",
+		},
+		{
+			name:    "empty up file",
+			files:   fstest.MapFS{"migrations/0001_noop.up.sql": &fstest.MapFile{}, "migrations/0001_noop.down.sql": &fstest.MapFile{Data: []byte("SELECT 1")}},
+			wantErr: "migration 0001_noop needs both an up and a down file",
+		},
+		{
+			name:    "one version under two names",
+			files:   migrationFS("0001_create_tables.up.sql", "0001_create_images.down.sql"),
+			wantErr: "migration 1 is named both",
+		},
+		{
+			name:    "invalid file name",
+			files:   migrationFS("0001_create_tables.up.sql", "0001_create_tables.down.sql", "create_users.sql"),
+			wantErr: "invalid migration file name migrations/create_users.sql",
+		},
+		{
+			name:    "version out of range",
+			files:   migrationFS("99999999999999999999_huge.up.sql"),
+			wantErr: "invalid migration version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadMigrations() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadMigrations() error = %v", err)
+			}
+			got := make([]string, 0, len(migrations))
+			for _, migration := range migrations {
+				got = append(got, migration.String())
+				if !strings.HasSuffix(migration.up, migration.Name+".up.sql") || !strings.HasSuffix(migration.down, migration.Name+".down.sql") {
+					t.Errorf("migration %s has up %q and down %q from the wrong files", migration, migration.up, migration.down)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("loadMigrations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations are embedded")
+	}
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			t.Errorf("migration %s is at position %d, versions must follow each other from 1", migration, i+1)
+		}
+	}
+	migrator := &Migrator{Migrations: migrations}
+	if latest := migrator.Latest(); latest != int64(len(migrations)) {
+		t.Errorf("Latest() = %d, want %d", latest, len(migrations))
+	}
+}
+
+func TestUnknownVersions(t *testing.T) {
+	migrator := &Migrator{Migrations: []Migration{{Version: 1}, {Version: 2}, {Version: 3}}}
+	applied := map[int64]MigrationStatus{7: {}, 1: {}, 2: {}, 5: {}}
+	if got := migrator.unknownVersions(applied); !slices.Equal(got, []int64{5, 7}) {
+		t.Errorf("unknownVersions() = %v, want [5 7]", got)
+	}
+	if got := (&Migrator{}).Latest(); got != 0 {
+		t.Errorf("Latest() without migrations = %d, want 0", got)
+	}
+}
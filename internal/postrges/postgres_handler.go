@@ -1,13 +1,12 @@
 package postrges
 
 import (
-	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
 	"context"
 	"fmt"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/jackc/pgx/v4/stdlib"
 	"log"
-	"os"
 	"time"
 
 	"gorm.io/driver/postgres"
@@ -15,29 +14,30 @@ import (
 	"gorm.io/gorm/logger"
 )
 
+// gormLogLevels are the values of config.DatabaseLogLevels
+var gormLogLevels = map[string]logger.LogLevel{
+	"silent": logger.Silent,
+	"error":  logger.Error,
+	"warn":   logger.Warn,
+	"info":   logger.Info,
+}
+
 type ConnectionHandler struct {
 	DB   *gorm.DB
 	Pool *pgxpool.Pool
 }
 
-func NewConnectionHandler() (*ConnectionHandler, error) {
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		return nil, fmt.Errorf("database URL is not set")
-	}
-
-	poolConfig, err := pgxpool.ParseConfig(dbURL)
+func NewConnectionHandler(cfg *config.Config) (*ConnectionHandler, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.Database.URL)
 	if err != nil {
-		log.Fatalf("Unable to parse database URL: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("unable to parse database URL: %w", err)
 	}
 
-	// TO-DO -> MOVE THIS INTO .env
-	poolConfig.MaxConns = 20
-	poolConfig.MinConns = 5
-	poolConfig.MaxConnIdleTime = 30 * time.Second
-	poolConfig.MaxConnLifetime = 60 * time.Minute
-	poolConfig.HealthCheckPeriod = 30 * time.Second
+	poolConfig.MaxConns = cfg.Database.MaxConns
+	poolConfig.MinConns = cfg.Database.MinConns
+	poolConfig.MaxConnIdleTime = cfg.Database.MaxConnIdleTime
+	poolConfig.MaxConnLifetime = cfg.Database.MaxConnLifetime
+	poolConfig.HealthCheckPeriod = cfg.Database.HealthCheckPeriod
 
 	pool, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
 	if err != nil {
@@ -45,23 +45,19 @@ func NewConnectionHandler() (*ConnectionHandler, error) {
 		return nil, err
 	}
 
+	logLevel := gormLogLevels[cfg.Log.Database]
+
 	sqlDB := stdlib.OpenDB(*poolConfig.ConnConfig)
 	gormDB, err := gorm.Open(postgres.New(postgres.Config{
 		Conn: sqlDB,
 	}), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info), // Show SQL
+		Logger: logger.Default.LogMode(logLevel),
 	})
 	if err != nil {
 		log.Fatalf("Error setting up GORM: %v", err)
 		return nil, err
 	}
 
-	//ensure tables are created
-	err = gormDB.AutoMigrate(&entities.Image{})
-	if err != nil {
-		log.Fatalf("Error setting up tables in GORM: %v", err)
-	}
-
 	return &ConnectionHandler{
 		DB:   gormDB,
 		Pool: pool,
@@ -89,6 +85,58 @@ func (handler *ConnectionHandler) Close() {
 	handler.Pool.Close()
 }
 
+// PoolStats is a snapshot of the connections of this process along with the connections the server sees to the
+// database from every client, keyed by state
+type PoolStats struct {
+	MaxConns          int32            `json:"max_conns"`
+	TotalConns        int32            `json:"total_conns"`
+	IdleConns         int32            `json:"idle_conns"`
+	AcquiredConns     int32            `json:"acquired_conns"`
+	AcquireCount      int64            `json:"acquire_count"`
+	EmptyAcquireCount int64            `json:"empty_acquire_count"`
+	AcquireDuration   time.Duration    `json:"acquire_duration"`
+	SQLOpenConns      int              `json:"sql_open_conns"`
+	SQLInUse          int              `json:"sql_in_use"`
+	SQLWaitCount      int64            `json:"sql_wait_count"`
+	ServerConns       map[string]int64 `json:"server_conns"`
+}
+
+// PoolStats returns the state of the connection pools and of the server side connections
+func (handler *ConnectionHandler) PoolStats() (*PoolStats, error) {
+	stats := handler.Pool.Stat()
+	poolStats := &PoolStats{
+		MaxConns:          stats.MaxConns(),
+		TotalConns:        stats.TotalConns(),
+		IdleConns:         stats.IdleConns(),
+		AcquiredConns:     stats.AcquiredConns(),
+		AcquireCount:      stats.AcquireCount(),
+		EmptyAcquireCount: stats.EmptyAcquireCount(),
+		AcquireDuration:   stats.AcquireDuration(),
+		ServerConns:       map[string]int64{},
+	}
+
+	sqlDB, err := handler.DB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the sql connection pool: %w", err)
+	}
+	sqlStats := sqlDB.Stats()
+	poolStats.SQLOpenConns, poolStats.SQLInUse, poolStats.SQLWaitCount = sqlStats.OpenConnections, sqlStats.InUse, sqlStats.WaitCount
+
+	var rows []struct {
+		State string
+		Count int64
+	}
+	err = handler.DB.Raw("SELECT COALESCE(state, 'unknown') AS state, count(*) AS count FROM pg_stat_activity WHERE datname = current_database() GROUP BY 1").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count server connections: %w", err)
+	}
+	for _, row := range rows {
+		poolStats.ServerConns[row.State] = row.Count
+	}
+	return poolStats, nil
+}
+
 // PrintConnectionPoolStats is for logging database connection status
 func (handler *ConnectionHandler) PrintConnectionPoolStats() {
 	stats := handler.Pool.Stat()
@@ -3,4 +3,4 @@ package postrges
 import "github.com/google/wire"
 
 // ProviderSet for the postgres package
-var ProviderSet = wire.NewSet(NewConnectionHandler)
+var ProviderSet = wire.NewSet(NewConnectionHandler, NewMigrator)
@@ -4,16 +4,17 @@ import (
 	"bit-image/pkg/common"
 	"bit-image/pkg/storage"
 	"github.com/google/uuid"
+	"io"
 	"time"
 )
 
 type Handler struct {
-	FileSystem *storage.S3FileSystem
+	FileSystem storage.ObjectStore
 }
 
-func (handler *Handler) GeneratePresignedURL(expiry time.Duration, UserId string) (string, uuid.UUID, error) {
+func (handler *Handler) GeneratePresignedURL(expiry time.Duration, UserId string, checksumSHA256 string) (string, uuid.UUID, error) {
 	// Delegate to FileSystem's GeneratePresignedURL method
-	presignedURL, imageId, err := handler.FileSystem.GeneratePresignedURL(expiry, UserId)
+	presignedURL, imageId, err := handler.FileSystem.GeneratePresignedURL(expiry, UserId, checksumSHA256)
 	if err != nil {
 		return "", uuid.UUID{}, err
 	}
@@ -35,3 +36,35 @@ func (handler *Handler) GetImageMetaData(imageId, bucket string) (int64, string,
 	}
 	return imageSize, contentType, nil
 }
+
+func (handler *Handler) GeneratePresignedGetURL(key string, expiry time.Duration, contentDisposition string) (string, error) {
+	return handler.FileSystem.GeneratePresignedGetURL(key, expiry, contentDisposition)
+}
+
+func (handler *Handler) DeleteFilesFromFolder(fileIDs []string, folderName string) ([]string, error) {
+	return handler.FileSystem.DeleteFilesFromFolder(fileIDs, folderName)
+}
+
+func (handler *Handler) ListObjects(prefix string, fn func(storage.ObjectInfo) error) error {
+	return handler.FileSystem.ListObjects(prefix, fn)
+}
+
+func (handler *Handler) HeadObject(key string) (storage.ObjectInfo, error) {
+	return handler.FileSystem.HeadObject(key)
+}
+
+func (handler *Handler) GetObject(key string) (io.ReadCloser, error) {
+	return handler.FileSystem.GetObject(key)
+}
+
+func (handler *Handler) DeleteObject(key string) error {
+	return handler.FileSystem.DeleteObject(key)
+}
+
+func (handler *Handler) CopyObject(srcKey, destKey string) error {
+	return handler.FileSystem.CopyObject(srcKey, destKey)
+}
+
+func (handler *Handler) PutObject(key string, body io.Reader, contentType string) error {
+	return handler.FileSystem.PutObject(key, body, contentType)
+}
@@ -1,17 +1,23 @@
 package s3
 
 import (
+	"bit-image/pkg/config"
 	"bit-image/pkg/storage"
 	"context"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"fmt"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/wire"
 )
 
-// NewS3Client creates an S3 client from the AWS configuration
-func NewS3Client() (*s3.Client, error) {
+// NewS3Client creates an S3 client from the AWS configuration, in the configured region when there is one
+func NewS3Client(region string) (*s3.Client, error) {
+	var options []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		options = append(options, awsconfig.WithRegion(region))
+	}
 	// Load AWS config
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	cfg, err := awsconfig.LoadDefaultConfig(context.TODO(), options...)
 	if err != nil {
 		return nil, err
 	}
@@ -21,16 +27,32 @@ func NewS3Client() (*s3.Client, error) {
 }
 
 // NewS3FileSystem creates a new S3FileSystem with the S3 client
-func NewS3FileSystem(s3Client *s3.Client) *storage.S3FileSystem {
-	return storage.NewS3FileSystem(s3Client, nil) // Assuming s3TransferManager is not required or is nil for now
+func NewS3FileSystem(s3Client *s3.Client, bucket string) *storage.S3FileSystem {
+	return storage.NewS3FileSystem(s3Client, nil, bucket) // Assuming s3TransferManager is not required or is nil for now
+}
+
+// NewObjectStore picks the storage backend from the configuration, S3 unless "local" is requested
+func NewObjectStore(cfg *config.Config) (storage.ObjectStore, error) {
+	switch backend := cfg.Storage.Backend; backend {
+	case config.StorageS3:
+		client, err := NewS3Client(cfg.Storage.Region)
+		if err != nil {
+			return nil, err
+		}
+		return NewS3FileSystem(client, cfg.Storage.Bucket), nil
+	case config.StorageLocal:
+		return storage.NewLocalFileSystem(cfg.Storage.LocalRoot, cfg.Storage.LocalBaseURL, []byte(cfg.Storage.LocalSecret), cfg.Storage.Bucket)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
 }
 
-// NewHandler creates a new Handler with the S3FileSystem
-func NewHandler(fileSystem *storage.S3FileSystem) *Handler {
+// NewHandler creates a new Handler with the configured ObjectStore
+func NewHandler(fileSystem storage.ObjectStore) *Handler {
 	return &Handler{
 		FileSystem: fileSystem,
 	}
 }
 
 // ProviderSet set for wire
-var ProviderSet = wire.NewSet(NewS3Client, NewS3FileSystem, NewHandler)
+var ProviderSet = wire.NewSet(NewObjectStore, NewHandler)
@@ -3,4 +3,9 @@ package common
 const (
 	TEMPORARY_STORAGE_FOLDER = "TEMP_STORAGE"
 	PERMANENT_STORAGE_FOLDER = "PERMANENT_STORAGE"
+	BLOB_STORAGE_FOLDER      = "BLOB_STORAGE"
+	DERIVED_STORAGE_FOLDER   = "DERIVED_STORAGE"
+	// QUARANTINE_STORAGE_FOLDER holds objects reconciliation found without an image, kept until someone looks at them
+	QUARANTINE_STORAGE_FOLDER = "QUARANTINE_STORAGE"
+	LOCAL_STORAGE_ROUTE       = "/storage"
 )
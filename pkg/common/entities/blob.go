@@ -0,0 +1,16 @@
+package entities
+
+import (
+	"bit-image/pkg/common"
+)
+
+// Blob is a stored object shared by every image of its owner with the same content. The object is deleted once
+// RefCount drops to zero, a blob never comes back to life after that.
+type Blob struct {
+	Base     common.Base `gorm:"embedded;not null"`
+	OwnerId  string      `gorm:"not null"`
+	Hash     string      `gorm:"not null"`
+	Path     string      `gorm:"not null"`
+	Size     int64       `gorm:"not null"`
+	RefCount int         `gorm:"not null;index"`
+}
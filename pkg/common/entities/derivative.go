@@ -0,0 +1,31 @@
+package entities
+
+import (
+	"bit-image/pkg/common"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	DerivativePending = "pending"
+	DerivativeReady   = "ready"
+	DerivativeFailed  = "failed"
+)
+
+// Derivative is a downscaled copy of an image fitting a Size x Size box. Rows are inserted as pending when the image is
+// confirmed and generated in the background, a failed generation is retried at NextAttemptAt until it runs out of
+// attempts.
+type Derivative struct {
+	Base          common.Base `gorm:"embedded;not null"`
+	ImageId       uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_derivatives_image_size_format"`
+	Size          int         `gorm:"not null;uniqueIndex:idx_derivatives_image_size_format"`
+	Format        string      `gorm:"not null;uniqueIndex:idx_derivatives_image_size_format"`
+	Path          string      `gorm:"not null"`
+	Status        string      `gorm:"not null;index"`
+	Width         int         `gorm:"not null;default:0"`
+	Height        int         `gorm:"not null;default:0"`
+	FileSize      int64       `gorm:"not null;default:0"`
+	Attempts      int         `gorm:"not null;default:0"`
+	LastError     string      `gorm:"not null;default:''"`
+	NextAttemptAt time.Time   `gorm:"not null;index"`
+}
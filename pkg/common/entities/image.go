@@ -1,12 +1,32 @@
 package entities
 
-import "bit-image/pkg/common"
+import (
+	"bit-image/pkg/common"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
 
-// Image TO DO: refactor the Tags and Content Labels into structs -> easier when querying by those values in the db
+// Image is a confirmed upload. Its tags and labels are linked through ImageTag and ImageLabel.
 type Image struct {
 	Base          common.Base          `gorm:"embedded;not null"`
+	OwnerId       string               `gorm:"not null;default:''"`
 	Name          string               `gorm:"not null"`
+	Caption       string               `gorm:"not null;default:''"`
 	IsPrivate     bool                 `gorm:"not null"`
 	Path          string               `gorm:"not null"`
 	ImageMetaData common.ImageMetaData `gorm:"embedded;not null"`
+	// PublicPath is a copy of the image without its embedded metadata, served to everyone but the owner. It is only
+	// set for public images that carried metadata.
+	PublicPath string `gorm:"not null;default:''"`
+	// BlobId is set for deduplicated images, Path then points at the shared blob object
+	BlobId *uuid.UUID `gorm:"type:uuid;index"`
+	// BlobReleased is set once a deduplicated image gave up its blob reference. Images deleted by their owner give it up
+	// right away, force-deleted ones hold it until they are purged so that they can still be restored.
+	BlobReleased bool `gorm:"not null;default:false"`
+	// BrokenAt is set when reconciliation found the stored object missing or not matching the row, BrokenReason says how
+	BrokenAt     *time.Time
+	BrokenReason string `gorm:"not null;default:''"`
+	// DateTimeDeleted soft-deletes the image, the row is purged once the retention window has passed
+	DateTimeDeleted gorm.DeletedAt `gorm:"index"`
 }
@@ -0,0 +1,27 @@
+package entities
+
+import (
+	"bit-image/pkg/common"
+	"time"
+)
+
+// ImageExif is the camera metadata parsed from an image when it is confirmed. Its id is the id of the image, fields
+// the image doesn't carry are left empty.
+type ImageExif struct {
+	Base        common.Base `gorm:"embedded;not null"`
+	Make        string      `gorm:"not null;default:'';index:idx_image_exifs_camera"`
+	Model       string      `gorm:"not null;default:'';index:idx_image_exifs_camera"`
+	LensModel   string      `gorm:"not null;default:''"`
+	CapturedAt  *time.Time  `gorm:"index"`
+	Orientation int         `gorm:"not null;default:0"`
+	// ExposureTime is in seconds
+	ExposureTime *float64
+	FNumber      *float64
+	ISO          *int
+	// FocalLength is in millimeters
+	FocalLength *float64
+	Latitude    *float64
+	Longitude   *float64
+	// Altitude is in meters above sea level
+	Altitude *float64
+}
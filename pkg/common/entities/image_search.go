@@ -0,0 +1,21 @@
+package entities
+
+import (
+	"github.com/google/uuid"
+)
+
+// ImageSearchDocument is the full-text search entry of a live image, kept in sync by the ImageStore in the
+// transactions changing the image, its tags or its labels. Document covers what anyone who can see the image may
+// search for: its name, caption and labels. OwnerDocument adds the tags, which only the owner sees.
+type ImageSearchDocument struct {
+	ImageId   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	OwnerId   string    `gorm:"not null;index"`
+	IsPrivate bool      `gorm:"not null"`
+	Format    string    `gorm:"not null;default:''"`
+	Year      int       `gorm:"not null"`
+	// Content and TagContent are the searched text, highlight snippets are cut from them
+	Content       string `gorm:"not null;default:''"`
+	TagContent    string `gorm:"not null;default:''"`
+	Document      string `gorm:"type:tsvector;not null"`
+	OwnerDocument string `gorm:"type:tsvector;not null"`
+}
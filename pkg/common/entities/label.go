@@ -0,0 +1,42 @@
+package entities
+
+import (
+	"bit-image/pkg/common"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Label describes what an image shows. Labels are shared by every user, the image they apply to and how certain
+// that is live on ImageLabel.
+type Label struct {
+	Base common.Base `gorm:"embedded;not null"`
+	Name string      `gorm:"not null;uniqueIndex"`
+}
+
+// ImageLabel applies a label to an image. Source is common.LABEL_SOURCE_USER or common.LABEL_SOURCE_MACHINE, the same
+// label can be applied by both. Confidence is between 0 and 1, user labels are always 1.
+type ImageLabel struct {
+	ImageId         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	LabelId         uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+	Source          string    `gorm:"primaryKey"`
+	Confidence      float64   `gorm:"not null"`
+	DateTimeCreated time.Time `gorm:"autoCreateTime"`
+}
+
+const (
+	LabelingPending = "pending"
+	LabelingDone    = "done"
+	LabelingFailed  = "failed"
+)
+
+// LabelingJob queues a confirmed image for machine labeling. Like derivatives, jobs are inserted as pending when the
+// image is confirmed and run in the background, a failed run is retried at NextAttemptAt until it runs out of attempts.
+type LabelingJob struct {
+	ImageId         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Status          string    `gorm:"not null;index"`
+	Attempts        int       `gorm:"not null;default:0"`
+	LastError       string    `gorm:"not null;default:''"`
+	NextAttemptAt   time.Time `gorm:"not null;index"`
+	DateTimeCreated time.Time `gorm:"autoCreateTime"`
+	DateTimeUpdated time.Time `gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,33 @@
+package entities
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+)
+
+// OutboxEvent is a domain event of an image, inserted in the transaction that changed the image and delivered at least
+// once by the outbox dispatcher. Sequence orders the events, those of one image are delivered one at a time in that
+// order. A failed delivery is retried at NextAttemptAt and holds back the later events of its image until it succeeds.
+type OutboxEvent struct {
+	Sequence      int64     `gorm:"primaryKey;autoIncrement"`
+	EventId       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	ImageId       uuid.UUID `gorm:"type:uuid;not null"`
+	Type          string    `gorm:"not null"`
+	Version       int       `gorm:"not null"`
+	Payload       string    `gorm:"type:jsonb;not null"`
+	Status        string    `gorm:"not null;index"`
+	Attempts      int       `gorm:"not null;default:0"`
+	LastError     string    `gorm:"not null;default:''"`
+	NextAttemptAt time.Time `gorm:"not null"`
+	OccurredAt    time.Time `gorm:"not null"`
+	DeliveredAt   *time.Time
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
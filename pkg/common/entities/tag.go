@@ -0,0 +1,21 @@
+package entities
+
+import (
+	"bit-image/pkg/common"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Tag is a name its owner files images under, every user has their own tags
+type Tag struct {
+	Base    common.Base `gorm:"embedded;not null"`
+	OwnerId string      `gorm:"not null;uniqueIndex:idx_tags_owner_name"`
+	Name    string      `gorm:"not null;uniqueIndex:idx_tags_owner_name"`
+}
+
+// ImageTag links an image to one of its owner's tags
+type ImageTag struct {
+	ImageId         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	TagId           uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+	DateTimeCreated time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,36 @@
+package entities
+
+import (
+	"bit-image/pkg/common"
+	"time"
+)
+
+// states an upload goes through. An upload is issued with its presigned url, uploaded once the content was verified
+// on confirmation, moving while the content is copied to its final location and committed in the transaction that
+// inserts its image. Content that is rejected fails the upload for good.
+const (
+	UploadIssued    = "issued"
+	UploadUploaded  = "uploaded"
+	UploadMoving    = "moving"
+	UploadCommitted = "committed"
+	UploadFailed    = "failed"
+)
+
+// Upload is an image that was issued a presigned url, tracked until it is confirmed. Its id is the id of the image.
+type Upload struct {
+	Base           common.Base `gorm:"embedded;not null"`
+	OwnerId        string      `gorm:"not null;index"`
+	ChecksumSHA256 string      `gorm:"not null"`
+	ExpiresAt      time.Time   `gorm:"not null;index"`
+	State          string      `gorm:"not null;default:'issued';index"`
+	StateChangedAt time.Time   `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	// the confirmation request, kept from the uploaded state on so an interrupted confirmation can be finished
+	Name      string `gorm:"not null;default:''"`
+	Caption   string `gorm:"not null;default:''"`
+	IsPrivate bool   `gorm:"not null;default:false"`
+	// DestinationKey is where the content is being copied to while moving
+	DestinationKey string `gorm:"not null;default:''"`
+	// Attempts counts the recoveries of an interrupted confirmation
+	Attempts  int    `gorm:"not null;default:0"`
+	LastError string `gorm:"not null;default:''"`
+}
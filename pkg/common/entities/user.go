@@ -2,10 +2,21 @@ package entities
 
 import (
 	"bit-image/pkg/common"
+	"gorm.io/gorm"
+	"time"
 )
 
+// User holds the upload quota of a user. Counts cover confirmed images that haven't been deleted. Users are created
+// the first time the auth service vouches for them.
 type User struct {
 	Base             common.Base `gorm:"embedded;not null"`
-	imageUploadLimit int         `gorm:"not null"`
-	imageUploadCount int         `gorm:"not null"`
+	ImageUploadLimit int         `gorm:"not null"`
+	ImageUploadCount int         `gorm:"not null;default:0"`
+	ByteUploadLimit  int64       `gorm:"not null"`
+	ByteUploadCount  int64       `gorm:"not null;default:0"`
+	// SuspendedAt is set while an admin has suspended the user, who can't get upload urls until it is lifted
+	SuspendedAt     *time.Time
+	SuspendedReason string `gorm:"not null;default:''"`
+	// DateTimeDeleted is set when an admin deleted the user, the row is kept so their tokens stay refused
+	DateTimeDeleted gorm.DeletedAt `gorm:"index"`
 }
@@ -0,0 +1,43 @@
+package entities
+
+import (
+	"bit-image/pkg/common"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryDead is a delivery that ran out of attempts, it is only sent again when redelivered by hand
+	WebhookDeliveryDead = "dead"
+)
+
+// WebhookSubscription is an endpoint a user wants image events pushed to. EventTypes is a comma separated list of the
+// event types to send, empty means every event. Secret signs the payloads so the endpoint can check they come from us.
+type WebhookSubscription struct {
+	Base       common.Base `gorm:"embedded;not null"`
+	OwnerId    string      `gorm:"not null;index"`
+	URL        string      `gorm:"not null"`
+	Secret     string      `gorm:"not null"`
+	EventTypes string      `gorm:"not null;default:''"`
+	Active     bool        `gorm:"not null;default:true"`
+}
+
+// WebhookDelivery is one event sent to one subscription. Deliveries are retried with exponential backoff at
+// NextAttemptAt until they succeed or run out of attempts, and kept as a delivery log afterwards.
+type WebhookDelivery struct {
+	Base           common.Base `gorm:"embedded;not null"`
+	SubscriptionId uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_webhook_deliveries_subscription_event"`
+	EventId        uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_webhook_deliveries_subscription_event"`
+	EventType      string      `gorm:"not null"`
+	ImageId        uuid.UUID   `gorm:"type:uuid;not null"`
+	Payload        string      `gorm:"type:jsonb;not null"`
+	Status         string      `gorm:"not null;index"`
+	Attempts       int         `gorm:"not null;default:0"`
+	// ResponseStatus is the http status of the last attempt, 0 when it got no response
+	ResponseStatus int       `gorm:"not null;default:0"`
+	LastError      string    `gorm:"not null;default:''"`
+	NextAttemptAt  time.Time `gorm:"not null;index"`
+	DeliveredAt    *time.Time
+}
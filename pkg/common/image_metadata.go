@@ -2,6 +2,16 @@ package common
 
 type ImageMetaData struct {
 	FileSize float64
-	Format   string
-	Hash     string
+	// Format is the decoded image format, e.g. "jpeg" or "png"
+	Format string
+	Hash   string
+	// MimeType is sniffed from the content, never taken from the client
+	MimeType   string
+	Width      int
+	Height     int
+	ColorModel string
+	// DHash and PHash are 64 bit perceptual fingerprints of the pixels, stored as signed bigints. Images whose
+	// fingerprints are a few bits apart look alike. They are nil when the pixels could not be decoded.
+	DHash *int64
+	PHash *int64
 }
@@ -1 +1,7 @@
 package common
+
+// Sources of an image label, labels added by users are kept apart from the ones a labeler infers
+const (
+	LABEL_SOURCE_USER    = "user"
+	LABEL_SOURCE_MACHINE = "machine"
+)
@@ -1 +1,34 @@
 package config
+
+import "net/url"
+
+// StorageConfig picks the object store. S3 takes its credentials from the usual AWS chain, Region only overrides the
+// region found there. The local backend keeps objects on disk and signs its urls with LocalSecret.
+type StorageConfig struct {
+	Backend      string `yaml:"backend" env:"STORAGE_BACKEND"`
+	Bucket       string `yaml:"bucket" env:"DEFAULT_BUCKET_NAME"`
+	Region       string `yaml:"region" env:"AWS_REGION"`
+	LocalRoot    string `yaml:"local_root" env:"LOCAL_STORAGE_ROOT"`
+	LocalBaseURL string `yaml:"local_base_url" env:"LOCAL_STORAGE_BASE_URL"`
+	LocalSecret  string `yaml:"local_secret" env:"LOCAL_STORAGE_SECRET"`
+}
+
+const (
+	StorageS3    = "s3"
+	StorageLocal = "local"
+)
+
+func (storage StorageConfig) validate(problems *problems) {
+	problems.require(storage.Bucket, "DEFAULT_BUCKET_NAME", "storage.bucket")
+	switch storage.Backend {
+	case StorageS3:
+	case StorageLocal:
+		problems.require(storage.LocalRoot, "LOCAL_STORAGE_ROOT", "storage.local_root")
+		problems.require(storage.LocalSecret, "LOCAL_STORAGE_SECRET", "storage.local_secret")
+		if parsed, err := url.Parse(storage.LocalBaseURL); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			problems.add("LOCAL_STORAGE_BASE_URL", "storage.local_base_url", "must be an absolute url, got %q", storage.LocalBaseURL)
+		}
+	default:
+		problems.add("STORAGE_BACKEND", "storage.backend", "must be %s or %s, got %q", StorageS3, StorageLocal, storage.Backend)
+	}
+}
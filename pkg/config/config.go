@@ -0,0 +1,368 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the typed configuration of the server and of imgctl. It starts from the defaults, is overridden by the
+// YAML file CONFIG_FILE points at when set and then by the environment, and is validated as a whole so that every
+// invalid value is reported at once.
+type Config struct {
+	Server       ServerConfig       `yaml:"server"`
+	Database     DatabaseConfig     `yaml:"database"`
+	Storage      StorageConfig      `yaml:"storage"`
+	Auth         AuthConfig         `yaml:"auth"`
+	Presign      PresignConfig      `yaml:"presign"`
+	Log          LogConfig          `yaml:"log"`
+	Images       ImagesConfig       `yaml:"images"`
+	Uploads      UploadsConfig      `yaml:"uploads"`
+	Render       RenderConfig       `yaml:"render"`
+	Derivatives  DerivativesConfig  `yaml:"derivatives"`
+	Labeling     LabelingConfig     `yaml:"labeling"`
+	Fingerprints FingerprintsConfig `yaml:"fingerprints"`
+	Outbox       OutboxConfig       `yaml:"outbox"`
+	Webhooks     WebhooksConfig     `yaml:"webhooks"`
+	Reconcile    ReconcileConfig    `yaml:"reconcile"`
+}
+
+// ServerConfig is where the REST and gRPC apis listen
+type ServerConfig struct {
+	Port        int    `yaml:"port" env:"PORT"`
+	GRPCAddress string `yaml:"grpc_address" env:"GRPC_ADDRESS"`
+}
+
+// AuthConfig is the auth service access tokens are validated against. Admins are the ids of the users allowed on the
+// admin routes. ProvisionedTTL is how long a user found provisioned is trusted without looking at their row again, a
+// user deleted through another instance is refused once it has passed. Zero looks at the row on every request.
+type AuthConfig struct {
+	Endpoint       string        `yaml:"endpoint" env:"AUTH_SERVICE_ENDPOINT_LOCAL"`
+	Timeout        time.Duration `yaml:"timeout" env:"AUTH_TIMEOUT"`
+	Admins         []string      `yaml:"admins" env:"AUTH_ADMIN_USER_IDS"`
+	ProvisionedTTL time.Duration `yaml:"provisioned_ttl" env:"AUTH_PROVISIONED_TTL"`
+}
+
+// PresignConfig is how long presigned urls stay valid. Uploads that aren't confirmed within UploadURLTTL are reaped.
+type PresignConfig struct {
+	UploadURLTTL   time.Duration `yaml:"upload_url_ttl" env:"PRESIGNED_UPLOAD_URL_TTL"`
+	DownloadURLTTL time.Duration `yaml:"download_url_ttl" env:"PRESIGNED_DOWNLOAD_URL_TTL"`
+}
+
+// LogConfig sets how chatty gin and gorm are. Database is one of DatabaseLogLevels, Gin one of GinModes.
+type LogConfig struct {
+	Database string `yaml:"database" env:"DB_LOG_LEVEL"`
+	Gin      string `yaml:"gin" env:"GIN_MODE"`
+}
+
+var (
+	DatabaseLogLevels = []string{"silent", "error", "warn", "info"}
+	GinModes          = []string{"debug", "release", "test"}
+)
+
+// maxPresignTTL is the longest S3 accepts for a SigV4 presigned url
+const maxPresignTTL = 7 * 24 * time.Hour
+
+func defaults() Config {
+	return Config{
+		Server: ServerConfig{Port: 8080, GRPCAddress: ":9090"},
+		Database: DatabaseConfig{
+			MaxConns:          20,
+			MinConns:          5,
+			MaxConnIdleTime:   30 * time.Second,
+			MaxConnLifetime:   60 * time.Minute,
+			HealthCheckPeriod: 30 * time.Second,
+		},
+		Storage: StorageConfig{
+			Backend:      StorageS3,
+			LocalRoot:    "local-storage",
+			LocalBaseURL: "http://localhost:8080",
+		},
+		Auth:    AuthConfig{Timeout: 10 * time.Second, ProvisionedTTL: time.Minute},
+		Presign: PresignConfig{UploadURLTTL: 15 * time.Minute, DownloadURLTTL: 5 * time.Minute},
+		Log:     LogConfig{Database: "info", Gin: "debug"},
+		Images: ImagesConfig{
+			ImageUploadLimit: 10000,
+			ByteUploadLimit:  10 << 30,
+			MaxUploadBytes:   100 << 20,
+			MaxPixels:        100_000_000,
+			DeleteRetention:  7 * 24 * time.Hour,
+			PurgeInterval:    time.Hour,
+		},
+		Uploads: UploadsConfig{
+			ReaperGracePeriod:   time.Hour,
+			ReaperInterval:      30 * time.Minute,
+			RecoveryInterval:    time.Minute,
+			RecoveryStaleAfter:  5 * time.Minute,
+			RecoveryMaxAttempts: 5,
+		},
+		Render: RenderConfig{MaxDimension: 2048, AllowedQualities: []int{50, 75, 85, 95}, DefaultQuality: 85},
+		Derivatives: DerivativesConfig{
+			Sizes:       []int{128, 512, 1024},
+			Formats:     []string{FormatJPEG},
+			JPEGQuality: 85,
+			MaxAttempts: 5,
+			Interval:    30 * time.Second,
+			Workers:     runtime.NumCPU(),
+		},
+		Labeling: LabelingConfig{
+			Labeler:       LabelerLocal,
+			MinConfidence: 0.5,
+			Timeout:       time.Minute,
+			MaxAttempts:   5,
+			Interval:      30 * time.Second,
+			Workers:       runtime.NumCPU(),
+			Local:         LocalLabelerConfig{SampleSize: 512, MaxColors: 3, BlurThreshold: 100},
+			HTTP:          HTTPLabelerConfig{Timeout: 30 * time.Second, SigV4Service: "sagemaker"},
+		},
+		Fingerprints: FingerprintsConfig{RefreshInterval: 10 * time.Minute},
+		Outbox: OutboxConfig{
+			Publisher:      PublisherLog,
+			HTTPTimeout:    10 * time.Second,
+			Interval:       5 * time.Second,
+			Retention:      7 * 24 * time.Hour,
+			PublishTimeout: 30 * time.Second,
+			Workers:        8,
+		},
+		Webhooks: WebhooksConfig{
+			Timeout:           10 * time.Second,
+			Interval:          5 * time.Second,
+			DeliveryRetention: 30 * 24 * time.Hour,
+			MaxAttempts:       8,
+			Workers:           8,
+			MaxSubscriptions:  10,
+		},
+		Reconcile: ReconcileConfig{Interval: 24 * time.Hour, GracePeriod: time.Hour, MaxReported: 10000},
+	}
+}
+
+// Load reads and validates the configuration, failing with every invalid value when there are any
+func Load() (*Config, error) {
+	cfg := defaults()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// loadFile overrides the configuration with a YAML file, keys that don't exist are rejected so typos don't go unnoticed
+func (cfg *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides the fields of a struct with the environment variables named by their env tags, empty variables
+// are treated as unset
+func loadEnv(value reflect.Value) error {
+	var errs []error
+	for i := 0; i < value.NumField(); i++ {
+		field, fieldType := value.Field(i), value.Type().Field(i)
+		name, tagged := fieldType.Tag.Lookup("env")
+		if !tagged {
+			if field.Kind() == reflect.Struct {
+				errs = append(errs, loadEnv(field))
+			}
+			continue
+		}
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		if err := setField(field, raw, fieldType.Tag.Get("unit")); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setField(field reflect.Value, raw string, unit string) error {
+	if field.Type() == durationType {
+		duration, err := parseDuration(raw, unit)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		field.SetInt(parsed)
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		field.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		field.SetBool(parsed)
+	case reflect.Slice:
+		// lists are comma separated, every entry is parsed like a field of the element type
+		values := reflect.MakeSlice(field.Type(), 0, 0)
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value == "" {
+				continue
+			}
+			element := reflect.New(field.Type().Elem()).Elem()
+			if err := setField(element, value, unit); err != nil {
+				return err
+			}
+			values = reflect.Append(values, element)
+		}
+		field.Set(values)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// parseDuration reads a duration such as "15m", or a bare number of units for the variables that were numbers
+func parseDuration(raw string, unit string) (time.Duration, error) {
+	if unit != "" {
+		if count, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			perUnit, err := time.ParseDuration(unit)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(count) * perUnit, nil
+		}
+	}
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", raw)
+	}
+	return duration, nil
+}
+
+func (cfg *Config) validate() error {
+	var problems problems
+	if cfg.Server.Port < 1 || cfg.Server.Port > 65535 {
+		problems.add("PORT", "server.port", "must be between 1 and 65535, got %d", cfg.Server.Port)
+	}
+	problems.require(cfg.Server.GRPCAddress, "GRPC_ADDRESS", "server.grpc_address")
+	cfg.Database.validate(&problems)
+	cfg.Storage.validate(&problems)
+
+	if parsed, err := url.Parse(cfg.Auth.Endpoint); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		problems.add("AUTH_SERVICE_ENDPOINT_LOCAL", "auth.endpoint", "must be an http or https url, got %q", cfg.Auth.Endpoint)
+	}
+	problems.positive(cfg.Auth.Timeout, "AUTH_TIMEOUT", "auth.timeout")
+	if cfg.Auth.ProvisionedTTL < 0 {
+		problems.add("AUTH_PROVISIONED_TTL", "auth.provisioned_ttl", "must not be negative, got %s", cfg.Auth.ProvisionedTTL)
+	}
+	for _, admin := range cfg.Auth.Admins {
+		if _, err := uuid.Parse(admin); err != nil {
+			problems.add("AUTH_ADMIN_USER_IDS", "auth.admins", "must be user ids, got %q", admin)
+		}
+	}
+
+	for _, ttl := range []struct {
+		value     time.Duration
+		env, yaml string
+	}{
+		{cfg.Presign.UploadURLTTL, "PRESIGNED_UPLOAD_URL_TTL", "presign.upload_url_ttl"},
+		{cfg.Presign.DownloadURLTTL, "PRESIGNED_DOWNLOAD_URL_TTL", "presign.download_url_ttl"},
+	} {
+		if ttl.value <= 0 || ttl.value > maxPresignTTL {
+			problems.add(ttl.env, ttl.yaml, "must be between 1s and %s, got %s", maxPresignTTL, ttl.value)
+		}
+	}
+
+	problems.oneOf(cfg.Log.Database, DatabaseLogLevels, "DB_LOG_LEVEL", "log.database")
+	problems.oneOf(cfg.Log.Gin, GinModes, "GIN_MODE", "log.gin")
+
+	cfg.Images.validate(&problems)
+	cfg.Uploads.validate(&problems)
+	cfg.Render.validate(&problems)
+	cfg.Derivatives.validate(&problems)
+	cfg.Labeling.validate(&problems)
+	cfg.Fingerprints.validate(&problems)
+	cfg.Outbox.validate(&problems)
+	cfg.Webhooks.validate(&problems)
+	cfg.Reconcile.validate(&problems)
+	return problems.err()
+}
+
+// problems collects what is wrong with a configuration, naming both the variable and the YAML key of every value
+type problems []string
+
+func (p *problems) add(env, yaml, format string, args ...any) {
+	*p = append(*p, fmt.Sprintf("%s (%s) %s", env, yaml, fmt.Sprintf(format, args...)))
+}
+
+func (p *problems) require(value, env, yaml string) {
+	if value == "" {
+		p.add(env, yaml, "is required")
+	}
+}
+
+func (p *problems) positive(value time.Duration, env, yaml string) {
+	if value <= 0 {
+		p.add(env, yaml, "must be positive, got %s", value)
+	}
+}
+
+func (p *problems) atLeast(value, minimum int64, env, yaml string) {
+	if value < minimum {
+		p.add(env, yaml, "must be at least %d, got %d", minimum, value)
+	}
+}
+
+func (p *problems) between(value, minimum, maximum int64, env, yaml string) {
+	if value < minimum || value > maximum {
+		p.add(env, yaml, "must be between %d and %d, got %d", minimum, maximum, value)
+	}
+}
+
+func (p *problems) oneOf(value string, allowed []string, env, yaml string) {
+	if !slices.Contains(allowed, value) {
+		p.add(env, yaml, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+}
+
+func (p problems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n  %s", strings.Join(p, "\n  "))
+}
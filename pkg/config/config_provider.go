@@ -0,0 +1,6 @@
+package config
+
+import "github.com/google/wire"
+
+// ProviderSet for the config package
+var ProviderSet = wire.NewSet(Load)
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolate clears every variable the configuration reads, empty ones count as unset, and sets the few that have no
+// default
+func isolate(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for _, name := range envNames(reflect.TypeOf(Config{})) {
+		t.Setenv(name, "")
+	}
+	t.Setenv("DB_URL", "postgres://localhost:5432/images")
+	t.Setenv("AUTH_SERVICE_ENDPOINT_LOCAL", "http://localhost:8081")
+	t.Setenv("DEFAULT_BUCKET_NAME", "images")
+}
+
+func envNames(structType reflect.Type) []string {
+	var names []string
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if name, tagged := field.Tag.Lookup("env"); tagged {
+			names = append(names, name)
+		} else if field.Type.Kind() == reflect.Struct {
+			names = append(names, envNames(field.Type)...)
+		}
+	}
+	return names
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	isolate(t)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := defaults()
+	want.Database.URL = "postgres://localhost:5432/images"
+	want.Auth.Endpoint = "http://localhost:8081"
+	want.Storage.Bucket = "images"
+	if !reflect.DeepEqual(*cfg, want) {
+		t.Errorf("Load() = %+v, want %+v", *cfg, want)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(cfg *Config) any
+		want  any
+	}{
+		{
+			name:  "bare number in the unit of the variable",
+			env:   map[string]string{"MAX_CONN_LIFETIME_MINUTE": "90"},
+			check: func(cfg *Config) any { return cfg.Database.MaxConnLifetime },
+			want:  90 * time.Minute,
+		},
+		{
+			name:  "duration in a variable with a unit",
+			env:   map[string]string{"MAX_CONN_IDLE_TIME_SECONDS": "2m"},
+			check: func(cfg *Config) any { return cfg.Database.MaxConnIdleTime },
+			want:  2 * time.Minute,
+		},
+		{
+			name:  "duration",
+			env:   map[string]string{"UPLOAD_REAPER_GRACE_PERIOD": "1h30m"},
+			check: func(cfg *Config) any { return cfg.Uploads.ReaperGracePeriod },
+			want:  90 * time.Minute,
+		},
+		{
+			name:  "int32",
+			env:   map[string]string{"MAX_CONNECTIONS": "40"},
+			check: func(cfg *Config) any { return cfg.Database.MaxConns },
+			want:  int32(40),
+		},
+		{
+			name:  "float",
+			env:   map[string]string{"LABEL_MIN_CONFIDENCE": "0.75"},
+			check: func(cfg *Config) any { return cfg.Labeling.MinConfidence },
+			want:  0.75,
+		},
+		{
+			name:  "bool",
+			env:   map[string]string{"WEBHOOK_ALLOW_HTTP": "true"},
+			check: func(cfg *Config) any { return cfg.Webhooks.AllowHTTP },
+			want:  true,
+		},
+		{
+			name:  "list of ints with spaces and empty entries",
+			env:   map[string]string{"DERIVATIVE_SIZES": " 64, 256 ,,1024,"},
+			check: func(cfg *Config) any { return cfg.Derivatives.Sizes },
+			want:  []int{64, 256, 1024},
+		},
+		{
+			name:  "list of strings",
+			env:   map[string]string{"RECONCILE_REPAIRS": "recreate,quarantine"},
+			check: func(cfg *Config) any { return cfg.Reconcile.Repairs },
+			want:  []string{RepairRecreate, RepairQuarantine},
+		},
+		{
+			name:  "empty variable keeps the default",
+			env:   map[string]string{"PORT": ""},
+			check: func(cfg *Config) any { return cfg.Server.Port },
+			want:  8080,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got := tt.check(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	isolate(t)
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, `
+server:
+  port: 9000
+render:
+  allowed_sizes: [256, 512]
+labeling:
+  labeler: none
+webhooks:
+  timeout: 3s
+`))
+	t.Setenv("PORT", "9100")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Server.Port != 9100 {
+		t.Errorf("server.port = %d, want the environment to override the file", cfg.Server.Port)
+	}
+	if !slices.Equal(cfg.Render.AllowedSizes, []int{256, 512}) {
+		t.Errorf("render.allowed_sizes = %v, want [256 512]", cfg.Render.AllowedSizes)
+	}
+	if cfg.Labeling.Labeler != LabelerNone {
+		t.Errorf("labeling.labeler = %q, want %q", cfg.Labeling.Labeler, LabelerNone)
+	}
+	if cfg.Webhooks.Timeout != 3*time.Second {
+		t.Errorf("webhooks.timeout = %s, want 3s", cfg.Webhooks.Timeout)
+	}
+	if cfg.Webhooks.Interval != 5*time.Second {
+		t.Errorf("webhooks.interval = %s, want the default 5s", cfg.Webhooks.Interval)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "unknown key", content: "server:\n  prot: 9000\n", want: "field prot not found"},
+		{name: "wrong type", content: "server:\n  port: high\n", want: "invalid config file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			t.Setenv("CONFIG_FILE", writeConfigFile(t, tt.content))
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("empty file", func(t *testing.T) {
+		isolate(t)
+		t.Setenv("CONFIG_FILE", writeConfigFile(t, ""))
+		if _, err := Load(); err != nil {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		isolate(t)
+		t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "failed to read config file") {
+			t.Errorf("Load() error = %v, want a read failure", err)
+		}
+	})
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "unparsable values",
+			env:  map[string]string{"MAX_CONNECTIONS": "many", "WEBHOOK_ALLOW_HTTP": "sure", "DERIVATIVE_SIZES": "128,big"},
+			want: []string{
+				`MAX_CONNECTIONS: invalid integer "many"`,
+				`WEBHOOK_ALLOW_HTTP: invalid boolean "sure"`,
+				`DERIVATIVE_SIZES: invalid integer "big"`,
+			},
+		},
+		{
+			name: "missing required values",
+			env:  map[string]string{"DB_URL": "", "DEFAULT_BUCKET_NAME": ""},
+			want: []string{"DB_URL (database.url) is required", "DEFAULT_BUCKET_NAME (storage.bucket) is required"},
+		},
+		{
+			name: "out of range values",
+			env: map[string]string{
+				"PORT":                   "70000",
+				"MIN_CONNECTIONS":        "30",
+				"RENDER_DEFAULT_QUALITY": "0",
+				"RENDER_ALLOWED_SIZES":   "4096",
+				"LABEL_MIN_CONFIDENCE":   "1.5",
+				"DERIVATIVE_WORKERS":     "0",
+			},
+			want: []string{
+				"PORT (server.port) must be between 1 and 65535, got 70000",
+				"MIN_CONNECTIONS (database.min_conns) must be between 0 and MAX_CONNECTIONS (20), got 30",
+				"RENDER_DEFAULT_QUALITY (render.default_quality) must be between 1 and 100, got 0",
+				"RENDER_ALLOWED_SIZES (render.allowed_sizes) must be between 1 and 2048, got 4096",
+				"LABEL_MIN_CONFIDENCE (labeling.min_confidence) must be between 0 and 1, got 1.5",
+				"DERIVATIVE_WORKERS (derivatives.workers) must be at least 1, got 0",
+			},
+		},
+		{
+			name: "values outside their choices",
+			env:  map[string]string{"STORAGE_BACKEND": "gcs", "DB_LOG_LEVEL": "debug", "RECONCILE_REPAIRS": "recreate,ignore"},
+			want: []string{
+				`STORAGE_BACKEND (storage.backend) must be s3 or local, got "gcs"`,
+				`DB_LOG_LEVEL (log.database) must be one of silent, error, warn, info, got "debug"`,
+				`RECONCILE_REPAIRS (reconcile.repairs) must be one of recreate, quarantine, mark-broken, got "ignore"`,
+			},
+		},
+		{
+			name: "presigned url ttl longer than S3 allows",
+			env:  map[string]string{"PRESIGNED_DOWNLOAD_URL_TTL": "200h"},
+			want: []string{"PRESIGNED_DOWNLOAD_URL_TTL (presign.download_url_ttl) must be between 1s and 168h0m0s"},
+		},
+		{
+			name: "backend settings checked only for the chosen backend",
+			env:  map[string]string{"STORAGE_BACKEND": "local", "OUTBOX_PUBLISHER": "http", "LABELER": "http"},
+			want: []string{
+				"LOCAL_STORAGE_SECRET (storage.local_secret) is required",
+				`OUTBOX_HTTP_URL (outbox.http_url) must be an http or https url with OUTBOX_PUBLISHER=http, got ""`,
+				`LABELER_HTTP_URL (labeling.http.url) must be an http or https url with LABELER=http, got ""`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := Load()
+			if err == nil {
+				t.Fatalf("Load() = %+v, want an error", cfg)
+			}
+			for _, problem := range tt.want {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("Load() error = %v\nwant it to contain %q", err, problem)
+				}
+			}
+		})
+	}
+}
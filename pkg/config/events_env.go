@@ -0,0 +1,63 @@
+package config
+
+import (
+	"net/url"
+	"time"
+)
+
+// OutboxConfig is the publication of image events. Publisher is one of Publishers, the HTTP fields are only used by
+// the http publisher.
+type OutboxConfig struct {
+	Publisher      string        `yaml:"publisher" env:"OUTBOX_PUBLISHER"`
+	HTTPURL        string        `yaml:"http_url" env:"OUTBOX_HTTP_URL"`
+	HTTPTimeout    time.Duration `yaml:"http_timeout" env:"OUTBOX_HTTP_TIMEOUT"`
+	HTTPToken      string        `yaml:"http_token" env:"OUTBOX_HTTP_TOKEN"`
+	Interval       time.Duration `yaml:"interval" env:"OUTBOX_INTERVAL"`
+	Retention      time.Duration `yaml:"retention" env:"OUTBOX_RETENTION"`
+	PublishTimeout time.Duration `yaml:"publish_timeout" env:"OUTBOX_PUBLISH_TIMEOUT"`
+	Workers        int           `yaml:"workers" env:"OUTBOX_WORKERS"`
+}
+
+// WebhooksConfig is the delivery of image events to the webhooks of their owners. AllowHTTP and AllowPrivateNetworks
+// are meant for development, they let subscriptions reach plain http and internal endpoints.
+type WebhooksConfig struct {
+	Timeout              time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT"`
+	Interval             time.Duration `yaml:"interval" env:"WEBHOOK_INTERVAL"`
+	DeliveryRetention    time.Duration `yaml:"delivery_retention" env:"WEBHOOK_DELIVERY_RETENTION"`
+	MaxAttempts          int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+	Workers              int           `yaml:"workers" env:"WEBHOOK_WORKERS"`
+	MaxSubscriptions     int64         `yaml:"max_subscriptions" env:"WEBHOOK_MAX_SUBSCRIPTIONS"`
+	AllowHTTP            bool          `yaml:"allow_http" env:"WEBHOOK_ALLOW_HTTP"`
+	AllowPrivateNetworks bool          `yaml:"allow_private_networks" env:"WEBHOOK_ALLOW_PRIVATE_NETWORKS"`
+}
+
+// event publishers selected with OUTBOX_PUBLISHER
+const (
+	PublisherLog  = "log"
+	PublisherHTTP = "http"
+)
+
+var Publishers = []string{PublisherLog, PublisherHTTP}
+
+func (outbox OutboxConfig) validate(problems *problems) {
+	problems.oneOf(outbox.Publisher, Publishers, "OUTBOX_PUBLISHER", "outbox.publisher")
+	if outbox.Publisher == PublisherHTTP {
+		if parsed, err := url.Parse(outbox.HTTPURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problems.add("OUTBOX_HTTP_URL", "outbox.http_url", "must be an http or https url with OUTBOX_PUBLISHER=http, got %q", outbox.HTTPURL)
+		}
+		problems.positive(outbox.HTTPTimeout, "OUTBOX_HTTP_TIMEOUT", "outbox.http_timeout")
+	}
+	problems.positive(outbox.Interval, "OUTBOX_INTERVAL", "outbox.interval")
+	problems.positive(outbox.Retention, "OUTBOX_RETENTION", "outbox.retention")
+	problems.positive(outbox.PublishTimeout, "OUTBOX_PUBLISH_TIMEOUT", "outbox.publish_timeout")
+	problems.atLeast(int64(outbox.Workers), 1, "OUTBOX_WORKERS", "outbox.workers")
+}
+
+func (webhooks WebhooksConfig) validate(problems *problems) {
+	problems.positive(webhooks.Timeout, "WEBHOOK_TIMEOUT", "webhooks.timeout")
+	problems.positive(webhooks.Interval, "WEBHOOK_INTERVAL", "webhooks.interval")
+	problems.positive(webhooks.DeliveryRetention, "WEBHOOK_DELIVERY_RETENTION", "webhooks.delivery_retention")
+	problems.atLeast(int64(webhooks.MaxAttempts), 1, "WEBHOOK_MAX_ATTEMPTS", "webhooks.max_attempts")
+	problems.atLeast(int64(webhooks.Workers), 1, "WEBHOOK_WORKERS", "webhooks.workers")
+	problems.atLeast(webhooks.MaxSubscriptions, 0, "WEBHOOK_MAX_SUBSCRIPTIONS", "webhooks.max_subscriptions")
+}
@@ -0,0 +1,77 @@
+package config
+
+import "time"
+
+// ImagesConfig is what a user may store and how long deleted images are kept. The upload limits are the quota of new
+// users, MaxUploadBytes and MaxPixels reject single images that would take too much memory to inspect and decode.
+type ImagesConfig struct {
+	DedupEnabled     bool          `yaml:"dedup_enabled" env:"IMAGE_DEDUP_ENABLED"`
+	ImageUploadLimit int           `yaml:"image_upload_limit" env:"USER_IMAGE_UPLOAD_LIMIT"`
+	ByteUploadLimit  int64         `yaml:"byte_upload_limit" env:"USER_BYTE_UPLOAD_LIMIT"`
+	MaxUploadBytes   int64         `yaml:"max_upload_bytes" env:"MAX_UPLOAD_BYTES"`
+	MaxPixels        int64         `yaml:"max_pixels" env:"MAX_IMAGE_PIXELS"`
+	DeleteRetention  time.Duration `yaml:"delete_retention" env:"IMAGE_DELETE_RETENTION"`
+	PurgeInterval    time.Duration `yaml:"purge_interval" env:"IMAGE_PURGE_INTERVAL"`
+}
+
+// RenderConfig bounds on the fly renders. AllowedSizes restricts widths and heights when set.
+type RenderConfig struct {
+	MaxDimension     int   `yaml:"max_dimension" env:"RENDER_MAX_DIMENSION"`
+	AllowedSizes     []int `yaml:"allowed_sizes" env:"RENDER_ALLOWED_SIZES"`
+	AllowedQualities []int `yaml:"allowed_qualities" env:"RENDER_ALLOWED_QUALITIES"`
+	DefaultQuality   int   `yaml:"default_quality" env:"RENDER_DEFAULT_QUALITY"`
+}
+
+// DerivativesConfig is the set of derivatives generated for every image, every size in every format
+type DerivativesConfig struct {
+	Sizes       []int         `yaml:"sizes" env:"DERIVATIVE_SIZES"`
+	Formats     []string      `yaml:"formats" env:"DERIVATIVE_FORMATS"`
+	JPEGQuality int           `yaml:"jpeg_quality" env:"DERIVATIVE_JPEG_QUALITY"`
+	MaxAttempts int           `yaml:"max_attempts" env:"DERIVATIVE_MAX_ATTEMPTS"`
+	Interval    time.Duration `yaml:"interval" env:"DERIVATIVE_INTERVAL"`
+	Workers     int           `yaml:"workers" env:"DERIVATIVE_WORKERS"`
+}
+
+// derivative formats
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+var DerivativeFormats = []string{FormatJPEG, FormatPNG}
+
+func (images ImagesConfig) validate(problems *problems) {
+	problems.atLeast(int64(images.ImageUploadLimit), 0, "USER_IMAGE_UPLOAD_LIMIT", "images.image_upload_limit")
+	problems.atLeast(images.ByteUploadLimit, 0, "USER_BYTE_UPLOAD_LIMIT", "images.byte_upload_limit")
+	problems.atLeast(images.MaxUploadBytes, 1, "MAX_UPLOAD_BYTES", "images.max_upload_bytes")
+	problems.atLeast(images.MaxPixels, 1, "MAX_IMAGE_PIXELS", "images.max_pixels")
+	problems.positive(images.DeleteRetention, "IMAGE_DELETE_RETENTION", "images.delete_retention")
+	problems.positive(images.PurgeInterval, "IMAGE_PURGE_INTERVAL", "images.purge_interval")
+}
+
+func (render RenderConfig) validate(problems *problems) {
+	problems.atLeast(int64(render.MaxDimension), 1, "RENDER_MAX_DIMENSION", "render.max_dimension")
+	for _, size := range render.AllowedSizes {
+		problems.between(int64(size), 1, int64(render.MaxDimension), "RENDER_ALLOWED_SIZES", "render.allowed_sizes")
+	}
+	if len(render.AllowedQualities) == 0 {
+		problems.add("RENDER_ALLOWED_QUALITIES", "render.allowed_qualities", "is required")
+	}
+	for _, quality := range render.AllowedQualities {
+		problems.between(int64(quality), 1, 100, "RENDER_ALLOWED_QUALITIES", "render.allowed_qualities")
+	}
+	problems.between(int64(render.DefaultQuality), 1, 100, "RENDER_DEFAULT_QUALITY", "render.default_quality")
+}
+
+func (derivatives DerivativesConfig) validate(problems *problems) {
+	for _, size := range derivatives.Sizes {
+		problems.atLeast(int64(size), 1, "DERIVATIVE_SIZES", "derivatives.sizes")
+	}
+	for _, format := range derivatives.Formats {
+		problems.oneOf(format, DerivativeFormats, "DERIVATIVE_FORMATS", "derivatives.formats")
+	}
+	problems.between(int64(derivatives.JPEGQuality), 1, 100, "DERIVATIVE_JPEG_QUALITY", "derivatives.jpeg_quality")
+	problems.atLeast(int64(derivatives.MaxAttempts), 1, "DERIVATIVE_MAX_ATTEMPTS", "derivatives.max_attempts")
+	problems.positive(derivatives.Interval, "DERIVATIVE_INTERVAL", "derivatives.interval")
+	problems.atLeast(int64(derivatives.Workers), 1, "DERIVATIVE_WORKERS", "derivatives.workers")
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"net/url"
+	"time"
+)
+
+// LabelingConfig is the machine labeling of confirmed images. Labeler is one of Labelers, none turns labeling off.
+type LabelingConfig struct {
+	Labeler       string             `yaml:"labeler" env:"LABELER"`
+	MinConfidence float64            `yaml:"min_confidence" env:"LABEL_MIN_CONFIDENCE"`
+	Timeout       time.Duration      `yaml:"timeout" env:"LABELER_TIMEOUT"`
+	MaxAttempts   int                `yaml:"max_attempts" env:"LABELER_MAX_ATTEMPTS"`
+	Interval      time.Duration      `yaml:"interval" env:"LABELER_INTERVAL"`
+	Workers       int                `yaml:"workers" env:"LABELER_WORKERS"`
+	Local         LocalLabelerConfig `yaml:"local"`
+	HTTP          HTTPLabelerConfig  `yaml:"http"`
+}
+
+// LocalLabelerConfig tunes the labeler analyzing colors, sharpness and orientation in process
+type LocalLabelerConfig struct {
+	SampleSize    int     `yaml:"sample_size" env:"LABELER_LOCAL_SAMPLE_SIZE"`
+	MaxColors     int     `yaml:"max_colors" env:"LABELER_LOCAL_MAX_COLORS"`
+	BlurThreshold float64 `yaml:"blur_threshold" env:"LABELER_LOCAL_BLUR_THRESHOLD"`
+}
+
+// HTTPLabelerConfig is the inference endpoint images are sent to. Requests are SigV4 signed for SigV4Service when
+// SigV4Region is set.
+type HTTPLabelerConfig struct {
+	URL          string        `yaml:"url" env:"LABELER_HTTP_URL"`
+	Timeout      time.Duration `yaml:"timeout" env:"LABELER_HTTP_TIMEOUT"`
+	Token        string        `yaml:"token" env:"LABELER_HTTP_TOKEN"`
+	SigV4Region  string        `yaml:"sigv4_region" env:"LABELER_HTTP_SIGV4_REGION"`
+	SigV4Service string        `yaml:"sigv4_service" env:"LABELER_HTTP_SIGV4_SERVICE"`
+}
+
+// labelers selected with LABELER
+const (
+	LabelerLocal = "local"
+	LabelerHTTP  = "http"
+	LabelerNone  = "none"
+)
+
+var Labelers = []string{LabelerLocal, LabelerHTTP, LabelerNone}
+
+func (labeling LabelingConfig) validate(problems *problems) {
+	problems.oneOf(labeling.Labeler, Labelers, "LABELER", "labeling.labeler")
+	if labeling.MinConfidence < 0 || labeling.MinConfidence > 1 {
+		problems.add("LABEL_MIN_CONFIDENCE", "labeling.min_confidence", "must be between 0 and 1, got %g", labeling.MinConfidence)
+	}
+	problems.positive(labeling.Timeout, "LABELER_TIMEOUT", "labeling.timeout")
+	problems.atLeast(int64(labeling.MaxAttempts), 1, "LABELER_MAX_ATTEMPTS", "labeling.max_attempts")
+	problems.positive(labeling.Interval, "LABELER_INTERVAL", "labeling.interval")
+	problems.atLeast(int64(labeling.Workers), 1, "LABELER_WORKERS", "labeling.workers")
+
+	switch labeling.Labeler {
+	case LabelerLocal:
+		problems.atLeast(int64(labeling.Local.SampleSize), 1, "LABELER_LOCAL_SAMPLE_SIZE", "labeling.local.sample_size")
+		problems.atLeast(int64(labeling.Local.MaxColors), 0, "LABELER_LOCAL_MAX_COLORS", "labeling.local.max_colors")
+		if labeling.Local.BlurThreshold < 0 {
+			problems.add("LABELER_LOCAL_BLUR_THRESHOLD", "labeling.local.blur_threshold", "must not be negative, got %g", labeling.Local.BlurThreshold)
+		}
+	case LabelerHTTP:
+		if parsed, err := url.Parse(labeling.HTTP.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problems.add("LABELER_HTTP_URL", "labeling.http.url", "must be an http or https url with LABELER=http, got %q", labeling.HTTP.URL)
+		}
+		problems.positive(labeling.HTTP.Timeout, "LABELER_HTTP_TIMEOUT", "labeling.http.timeout")
+		if labeling.HTTP.SigV4Region != "" {
+			problems.require(labeling.HTTP.SigV4Service, "LABELER_HTTP_SIGV4_SERVICE", "labeling.http.sigv4_service")
+		}
+	}
+}
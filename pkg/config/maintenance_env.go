@@ -0,0 +1,57 @@
+package config
+
+import "time"
+
+// UploadsConfig is the cleanup of uploads that were never confirmed and the recovery of confirmations that were
+// interrupted. RecoveryStaleAfter has to outlast a confirmation.
+type UploadsConfig struct {
+	ReaperGracePeriod   time.Duration `yaml:"reaper_grace_period" env:"UPLOAD_REAPER_GRACE_PERIOD"`
+	ReaperInterval      time.Duration `yaml:"reaper_interval" env:"UPLOAD_REAPER_INTERVAL"`
+	RecoveryInterval    time.Duration `yaml:"recovery_interval" env:"UPLOAD_RECOVERY_INTERVAL"`
+	RecoveryStaleAfter  time.Duration `yaml:"recovery_stale_after" env:"UPLOAD_RECOVERY_STALE_AFTER"`
+	RecoveryMaxAttempts int           `yaml:"recovery_max_attempts" env:"UPLOAD_RECOVERY_MAX_ATTEMPTS"`
+}
+
+// ReconcileConfig is the scheduled reconciliation of the images table against the bucket. Repairs are ReconcileRepairs
+// names, scheduled runs only report without any.
+type ReconcileConfig struct {
+	Repairs     []string      `yaml:"repairs" env:"RECONCILE_REPAIRS"`
+	Interval    time.Duration `yaml:"interval" env:"RECONCILE_INTERVAL"`
+	GracePeriod time.Duration `yaml:"grace_period" env:"RECONCILE_GRACE_PERIOD"`
+	MaxReported int           `yaml:"max_reported" env:"RECONCILE_MAX_REPORTED"`
+}
+
+// FingerprintsConfig is how often the similarity index is rebuilt to pick up the changes of other instances
+type FingerprintsConfig struct {
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"FINGERPRINT_INDEX_REFRESH"`
+}
+
+// repairs listed in RECONCILE_REPAIRS
+const (
+	RepairRecreate   = "recreate"
+	RepairQuarantine = "quarantine"
+	RepairMarkBroken = "mark-broken"
+)
+
+var ReconcileRepairs = []string{RepairRecreate, RepairQuarantine, RepairMarkBroken}
+
+func (uploads UploadsConfig) validate(problems *problems) {
+	problems.positive(uploads.ReaperGracePeriod, "UPLOAD_REAPER_GRACE_PERIOD", "uploads.reaper_grace_period")
+	problems.positive(uploads.ReaperInterval, "UPLOAD_REAPER_INTERVAL", "uploads.reaper_interval")
+	problems.positive(uploads.RecoveryInterval, "UPLOAD_RECOVERY_INTERVAL", "uploads.recovery_interval")
+	problems.positive(uploads.RecoveryStaleAfter, "UPLOAD_RECOVERY_STALE_AFTER", "uploads.recovery_stale_after")
+	problems.atLeast(int64(uploads.RecoveryMaxAttempts), 1, "UPLOAD_RECOVERY_MAX_ATTEMPTS", "uploads.recovery_max_attempts")
+}
+
+func (reconcile ReconcileConfig) validate(problems *problems) {
+	for _, repair := range reconcile.Repairs {
+		problems.oneOf(repair, ReconcileRepairs, "RECONCILE_REPAIRS", "reconcile.repairs")
+	}
+	problems.positive(reconcile.Interval, "RECONCILE_INTERVAL", "reconcile.interval")
+	problems.positive(reconcile.GracePeriod, "RECONCILE_GRACE_PERIOD", "reconcile.grace_period")
+	problems.atLeast(int64(reconcile.MaxReported), 0, "RECONCILE_MAX_REPORTED", "reconcile.max_reported")
+}
+
+func (fingerprints FingerprintsConfig) validate(problems *problems) {
+	problems.positive(fingerprints.RefreshInterval, "FINGERPRINT_INDEX_REFRESH", "fingerprints.refresh_interval")
+}
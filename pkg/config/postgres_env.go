@@ -1 +1,28 @@
 package config
+
+import "time"
+
+// DatabaseConfig is the connection to Postgres and the sizing of its pool. The durations keep the units of their
+// historical variables, a bare number in MAX_CONN_IDLE_TIME_SECONDS is seconds, one in MAX_CONN_LIFETIME_MINUTE
+// minutes; a duration such as "90s" works for either.
+type DatabaseConfig struct {
+	URL               string        `yaml:"url" env:"DB_URL"`
+	MaxConns          int32         `yaml:"max_conns" env:"MAX_CONNECTIONS"`
+	MinConns          int32         `yaml:"min_conns" env:"MIN_CONNECTIONS"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time" env:"MAX_CONN_IDLE_TIME_SECONDS" unit:"1s"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime" env:"MAX_CONN_LIFETIME_MINUTE" unit:"1m"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period" env:"HEALTH_CHECK_PERIOD_SECOND" unit:"1s"`
+}
+
+func (db DatabaseConfig) validate(problems *problems) {
+	problems.require(db.URL, "DB_URL", "database.url")
+	if db.MaxConns < 1 {
+		problems.add("MAX_CONNECTIONS", "database.max_conns", "must be at least 1, got %d", db.MaxConns)
+	}
+	if db.MinConns < 0 || db.MinConns > db.MaxConns {
+		problems.add("MIN_CONNECTIONS", "database.min_conns", "must be between 0 and MAX_CONNECTIONS (%d), got %d", db.MaxConns, db.MinConns)
+	}
+	problems.positive(db.MaxConnIdleTime, "MAX_CONN_IDLE_TIME_SECONDS", "database.max_conn_idle_time")
+	problems.positive(db.MaxConnLifetime, "MAX_CONN_LIFETIME_MINUTE", "database.max_conn_lifetime")
+	problems.positive(db.HealthCheckPeriod, "HEALTH_CHECK_PERIOD_SECOND", "database.health_check_period")
+}
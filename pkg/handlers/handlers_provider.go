@@ -2,4 +2,4 @@ package handlers
 
 import "github.com/google/wire"
 
-var ProviderSet = wire.NewSet(NewImageHandler)
+var ProviderSet = wire.NewSet(NewImageHandler, NewLocalStorageHandler, NewUserHandler, NewWebhookHandler)
@@ -4,18 +4,32 @@ import (
 	"bit-image/pkg/services"
 	"bit-image/pkg/storage"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"log"
 	"net/http"
+	"strconv"
 )
 
 type PresignedURLRequest struct {
-	NumImages int `json:"num_images"`
+	Images []services.UploadDeclaration `json:"images"`
 }
 
 type ConfirmUploadsRequest struct {
 	ImageUploads []services.ConfirmUploadRequest `json:"image_uploads"`
 }
 
+type CheckImageHashesRequest struct {
+	Hashes []string `json:"hashes"`
+}
+
+type DeleteImagesRequest struct {
+	ImageIds []string `json:"image_ids"`
+}
+
+// maxDeleteBatchSize bounds a single batch delete or tag request
+const maxDeleteBatchSize = 1000
+
 type PresignedURLResponse struct {
 	ImageUploadURLs []services.PresignedURL `json:"image_upload_urls"`
 }
@@ -37,7 +51,7 @@ func (h *ImageHandler) GeneratePresignedURL() gin.HandlerFunc {
 			return
 		}
 
-		if request.NumImages <= 0 {
+		if len(request.Images) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 			return
 		}
@@ -45,8 +59,18 @@ func (h *ImageHandler) GeneratePresignedURL() gin.HandlerFunc {
 		userId, exists := c.Get("userId")
 		if !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+		urls, err := h.ImageService.GeneratePresignedURLs(request.Images, userId.(string))
+		if err != nil {
+			status := imageErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				c.JSON(status, gin.H{"error": "Failed to generate upload urls"})
+				return
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
 		}
-		urls, _ := h.ImageService.GeneratePresignedURLs(request.NumImages, userId.(string))
 
 		response := PresignedURLResponse{
 			ImageUploadURLs: urls,
@@ -57,7 +81,7 @@ func (h *ImageHandler) GeneratePresignedURL() gin.HandlerFunc {
 
 		encoder := json.NewEncoder(c.Writer)
 		encoder.SetEscapeHTML(false)
-		err := encoder.Encode(response)
+		err = encoder.Encode(response)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write response"})
 			return
@@ -76,6 +100,7 @@ func (h *ImageHandler) ConfirmImageUploads() gin.HandlerFunc {
 		userId, exists := c.Get("userId")
 		if !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
 		}
 
 		errors := h.ImageService.ConfirmImageUploads(request.ImageUploads, userId.(string))
@@ -83,11 +108,19 @@ func (h *ImageHandler) ConfirmImageUploads() gin.HandlerFunc {
 		if len(errors) > 0 {
 			var errorMessages []string
 			for _, err := range errors {
-				errorMessages = append(errorMessages, err.Error())
+				errorMessages = append(errorMessages, imageErrorMessage(err))
 			}
 
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Some image uploads failed to confirm",
+			// the uploads that did confirm are committed, an error status would have the client retry them
+			if len(errors) < len(request.ImageUploads) {
+				c.JSON(http.StatusMultiStatus, gin.H{
+					"message": "Some image uploads failed to confirm",
+					"errors":  errorMessages,
+				})
+				return
+			}
+			c.JSON(batchErrorStatus(errors), gin.H{
+				"message": "All image uploads failed to confirm",
 				"errors":  errorMessages,
 			})
 			return
@@ -96,3 +129,214 @@ func (h *ImageHandler) ConfirmImageUploads() gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "All image uploads confirmed successfully"})
 	}
 }
+
+func (h *ImageHandler) CheckImageHashes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request CheckImageHashesRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		existing, err := h.ImageService.ExistingHashes(request.Hashes, userId.(string))
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidChecksum) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up hashes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"existing_hashes": existing})
+	}
+}
+
+func (h *ImageHandler) GetImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		download, err := strconv.ParseBool(c.DefaultQuery("download", "false"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		image, err := h.ImageService.GetImage(c.Param("id"), userId.(string), download)
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, image)
+	}
+}
+
+// RenderImage redirects to the image resized and re-encoded as described by the query string
+func (h *ImageHandler) RenderImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		var query services.RenderQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		rendered, err := h.ImageService.RenderImage(c.Param("id"), userId.(string), query)
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.Redirect(http.StatusFound, rendered.URL)
+	}
+}
+
+func (h *ImageHandler) ListImages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		var query services.ListImagesQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		images, err := h.ImageService.ListImages(userId.(string), query)
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, images)
+	}
+}
+
+func (h *ImageHandler) DeleteImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		_, errs := h.ImageService.DeleteImages([]string{c.Param("id")}, userId.(string))
+		if len(errs) > 0 {
+			writeImageError(c, errs[0])
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+	}
+}
+
+func (h *ImageHandler) DeleteImages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request DeleteImagesRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		if len(request.ImageIds) == 0 || len(request.ImageIds) > maxDeleteBatchSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		deleted, errors := h.ImageService.DeleteImages(request.ImageIds, userId.(string))
+
+		if len(errors) > 0 {
+			var errorMessages []string
+			for _, err := range errors {
+				errorMessages = append(errorMessages, imageErrorMessage(err))
+			}
+
+			c.JSON(http.StatusMultiStatus, gin.H{
+				"message": "Some images failed to delete",
+				"deleted": deleted,
+				"errors":  errorMessages,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "All images deleted successfully", "deleted": deleted})
+	}
+}
+
+// imageErrorStatus maps service errors to the http status returned to the client
+func imageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidImageId), errors.Is(err, services.ErrInvalidCursor),
+		errors.Is(err, services.ErrInvalidChecksum), errors.Is(err, services.ErrInvalidDeclaration),
+		errors.Is(err, services.ErrInvalidUserId), errors.Is(err, services.ErrInvalidRenderQuery),
+		errors.Is(err, services.ErrInvalidTag), errors.Is(err, services.ErrInvalidSimilarityQuery),
+		errors.Is(err, services.ErrInvalidSearchQuery), errors.Is(err, services.ErrInvalidWebhook),
+		errors.Is(err, services.ErrInvalidQuota), errors.Is(err, services.ErrInvalidSuspension):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrImageNotFound), errors.Is(err, services.ErrUploadNotFound),
+		errors.Is(err, services.ErrWebhookNotFound), errors.Is(err, services.ErrDeliveryNotFound),
+		errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrImageAccessDenied), errors.Is(err, services.ErrQuotaExceeded),
+		errors.Is(err, services.ErrWebhookLimit), errors.Is(err, services.ErrUserSuspended),
+		errors.Is(err, services.ErrUserDeleted):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrChecksumMismatch), errors.Is(err, services.ErrInvalidImage):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrUploadInProgress):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// batchErrorStatus is the status shared by all errors of a batch, or a server error when they differ
+func batchErrorStatus(errs []error) int {
+	status := imageErrorStatus(errs[0])
+	for _, err := range errs[1:] {
+		if imageErrorStatus(err) != status {
+			return http.StatusInternalServerError
+		}
+	}
+	return status
+}
+
+// imageErrorMessage is the message returned to the client for a service error. Server errors carry the text of
+// database and storage failures, they are logged and replaced with a generic message.
+func imageErrorMessage(err error) string {
+	if imageErrorStatus(err) == http.StatusInternalServerError {
+		log.Printf("request failed: %v", err)
+		return "Internal server error"
+	}
+	return err.Error()
+}
+
+// writeImageError responds with the status and message of a service error
+func writeImageError(c *gin.Context, err error) {
+	c.JSON(imageErrorStatus(err), gin.H{"error": imageErrorMessage(err)})
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"bit-image/pkg/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// SearchImages finds images by name, caption, tags and labels, with snippets and facet counts
+func (h *ImageHandler) SearchImages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		var query services.SearchQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		results, err := h.ImageService.SearchImages(userId.(string), query)
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, results)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bit-image/pkg/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// FindSimilarImages lists the images that look like the given one
+func (h *ImageHandler) FindSimilarImages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		var query services.SimilarityQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		images, err := h.ImageService.FindSimilarImages(c.Param("id"), userId.(string), query)
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"images": images})
+	}
+}
+
+// DuplicateGroups reports the groups of the user's images that look alike
+func (h *ImageHandler) DuplicateGroups() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		var query services.SimilarityQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		groups, err := h.ImageService.DuplicateGroups(userId.(string), query)
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"groups": groups})
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type ImageTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type TagImagesRequest struct {
+	ImageIds []string `json:"image_ids"`
+	Add      []string `json:"add"`
+	Remove   []string `json:"remove"`
+}
+
+type ImageLabelsRequest struct {
+	Labels []string `json:"labels"`
+}
+
+// AddImageTags tags a single image
+func (h *ImageHandler) AddImageTags() gin.HandlerFunc {
+	return h.tagImage(true)
+}
+
+// RemoveImageTags untags a single image
+func (h *ImageHandler) RemoveImageTags() gin.HandlerFunc {
+	return h.tagImage(false)
+}
+
+func (h *ImageHandler) tagImage(add bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request ImageTagsRequest
+		if err := c.ShouldBindJSON(&request); err != nil || len(request.Tags) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		var errs []error
+		if add {
+			_, errs = h.ImageService.TagImages([]string{c.Param("id")}, request.Tags, nil, userId.(string))
+		} else {
+			_, errs = h.ImageService.TagImages([]string{c.Param("id")}, nil, request.Tags, userId.(string))
+		}
+		if len(errs) > 0 {
+			writeImageError(c, errs[0])
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Image tags updated successfully"})
+	}
+}
+
+// TagImages adds and removes tags of many images at once
+func (h *ImageHandler) TagImages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request TagImagesRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		if len(request.ImageIds) == 0 || len(request.ImageIds) > maxDeleteBatchSize ||
+			len(request.Add)+len(request.Remove) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		tagged, errors := h.ImageService.TagImages(request.ImageIds, request.Add, request.Remove, userId.(string))
+
+		if len(errors) > 0 {
+			var errorMessages []string
+			for _, err := range errors {
+				errorMessages = append(errorMessages, imageErrorMessage(err))
+			}
+
+			status := http.StatusMultiStatus
+			if len(tagged) == 0 {
+				status = batchErrorStatus(errors)
+			}
+			c.JSON(status, gin.H{
+				"message": "Some images failed to update",
+				"tagged":  tagged,
+				"errors":  errorMessages,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "All images updated successfully", "tagged": tagged})
+	}
+}
+
+// ListTags returns the user's tags with their image counts
+func (h *ImageHandler) ListTags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		tags, err := h.ImageService.ListTags(userId.(string))
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"tags": tags})
+	}
+}
+
+// AddImageLabels applies user labels to an image
+func (h *ImageHandler) AddImageLabels() gin.HandlerFunc {
+	return h.labelImage(true)
+}
+
+// RemoveImageLabels removes user labels from an image
+func (h *ImageHandler) RemoveImageLabels() gin.HandlerFunc {
+	return h.labelImage(false)
+}
+
+func (h *ImageHandler) labelImage(add bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request ImageLabelsRequest
+		if err := c.ShouldBindJSON(&request); err != nil || len(request.Labels) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		var err error
+		if add {
+			err = h.ImageService.LabelImage(c.Param("id"), request.Labels, userId.(string))
+		} else {
+			err = h.ImageService.UnlabelImage(c.Param("id"), request.Labels, userId.(string))
+		}
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Image labels updated successfully"})
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// LocalStorageHandler serves the presigned urls issued by storage.LocalFileSystem, standing in for S3
+type LocalStorageHandler struct {
+	Store          *storage.LocalFileSystem
+	MaxUploadBytes int64
+}
+
+// NewLocalStorageHandler only holds a store when the local backend is configured
+func NewLocalStorageHandler(objectStore storage.ObjectStore, cfg *config.Config) *LocalStorageHandler {
+	localStore, _ := objectStore.(*storage.LocalFileSystem)
+	return &LocalStorageHandler{Store: localStore, MaxUploadBytes: cfg.Images.MaxUploadBytes}
+}
+
+// Enabled reports whether the routes should be registered at all
+func (h *LocalStorageHandler) Enabled() bool {
+	return h.Store != nil
+}
+
+func objectKey(c *gin.Context) string {
+	return strings.TrimPrefix(c.Param("key"), "/")
+}
+
+func (h *LocalStorageHandler) PutObject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := objectKey(c)
+		if err := h.Store.VerifySignature(http.MethodPut, key, c.Request.URL.Query()); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		// presigned urls don't carry a size, so the body is bounded by the upload limit the confirmation enforces anyway
+		if c.Request.ContentLength > h.MaxUploadBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Object is larger than the upload limit"})
+			return
+		}
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, h.MaxUploadBytes)
+
+		// same as S3 with a signed checksum header, content that doesn't match the declared checksum isn't stored
+		err := h.Store.PutObjectWithChecksum(key, body, c.GetHeader("Content-Type"), c.Query("checksum_sha256"))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Object is larger than the upload limit"})
+			return
+		}
+		if errors.Is(err, storage.ErrChecksumMismatch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Content does not match the declared checksum"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store object"})
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+}
+
+func (h *LocalStorageHandler) GetObject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := objectKey(c)
+		if err := h.Store.VerifySignature(http.MethodGet, key, c.Request.URL.Query()); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		size, contentType, err := h.Store.GetObjectMetadata(key, h.Store.Bucket())
+		if err != nil {
+			if errors.Is(err, storage.ErrObjectNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Object not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read object"})
+			return
+		}
+
+		body, err := h.Store.GetObject(key)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read object"})
+			return
+		}
+		defer body.Close()
+
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		var extraHeaders map[string]string
+		if disposition := c.Query("response-content-disposition"); disposition != "" {
+			extraHeaders = map[string]string{"Content-Disposition": disposition}
+		}
+		c.DataFromReader(http.StatusOK, size, contentType, body, extraHeaders)
+	}
+}
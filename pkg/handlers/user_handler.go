@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bit-image/pkg/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+// UserHandler serves the account of the calling user and the admin routes managing every user
+type UserHandler struct {
+	UserService *services.UserService
+}
+
+func NewUserHandler(userService *services.UserService) *UserHandler {
+	return &UserHandler{UserService: userService}
+}
+
+// Me returns the profile, usage and limits of the calling user
+func (h *UserHandler) Me() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		profile, err := h.UserService.GetProfile(userId.(string), c.GetBool("isAdmin"))
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, profile)
+	}
+}
+
+// ListUsers returns a page of users ordered by id, for admins
+func (h *UserHandler) ListUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		users, err := h.UserService.ListUsers(c.Query("cursor"), limit)
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, users)
+	}
+}
+
+// GetUser returns the quota and usage of any user, for admins
+func (h *UserHandler) GetUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := h.UserService.GetUser(c.Param("id"))
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// SuspendUser stops a user from getting upload urls, the reason is optional
+func (h *UserHandler) SuspendUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request SuspendUserRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+				return
+			}
+		}
+
+		user, err := h.UserService.SuspendUser(c.Param("id"), request.Reason)
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+func (h *UserHandler) UnsuspendUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := h.UserService.UnsuspendUser(c.Param("id"))
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// DeleteUser deletes a user and their webhooks and soft-deletes their images
+func (h *UserHandler) DeleteUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		deletion, err := h.UserService.DeleteUser(c.Param("id"))
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, deletion)
+	}
+}
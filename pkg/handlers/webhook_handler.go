@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bit-image/pkg/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// WebhookHandler serves the webhook subscriptions of the user and their delivery logs
+type WebhookHandler struct {
+	WebhookService *services.WebhookService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{WebhookService: webhookService}
+}
+
+func (h *WebhookHandler) CreateWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request services.CreateWebhookRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		webhook, err := h.WebhookService.CreateWebhook(userId.(string), request)
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, webhook)
+	}
+}
+
+func (h *WebhookHandler) ListWebhooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		webhooks, err := h.WebhookService.ListWebhooks(userId.(string))
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+	}
+}
+
+func (h *WebhookHandler) GetWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		webhook, err := h.WebhookService.GetWebhook(userId.(string), c.Param("id"))
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, webhook)
+	}
+}
+
+func (h *WebhookHandler) UpdateWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request services.UpdateWebhookRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		webhook, err := h.WebhookService.UpdateWebhook(userId.(string), c.Param("id"), request)
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, webhook)
+	}
+}
+
+func (h *WebhookHandler) DeleteWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		if err := h.WebhookService.DeleteWebhook(userId.(string), c.Param("id")); err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+	}
+}
+
+// ListDeliveries returns the delivery log of a webhook, newest first, optionally only the deliveries with a status
+func (h *WebhookHandler) ListDeliveries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		var query services.DeliveryListQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		deliveries, err := h.WebhookService.ListDeliveries(userId.(string), c.Param("id"), query)
+		if err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, deliveries)
+	}
+}
+
+// Redeliver queues a delivery to be sent again, it is accepted rather than sent right away
+func (h *WebhookHandler) Redeliver() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No user found, userId not found"})
+			return
+		}
+
+		if err := h.WebhookService.Redeliver(userId.(string), c.Param("id"), c.Param("deliveryId")); err != nil {
+			writeImageError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "Delivery queued"})
+	}
+}
@@ -1,46 +1,95 @@
 package middleware
 
 import (
+	"bit-image/pkg/config"
+	"bit-image/pkg/services"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"log"
 	"net/http"
-	"os"
+	"slices"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// Authenticator validates access tokens with the auth service, for the REST routes and the gRPC api alike. The users
+// behind valid tokens are provisioned on their first request.
+type Authenticator struct {
+	Endpoint string
+	Client   *http.Client
+	Users    *services.UserService
+	Admins   []string
+}
+
+func NewAuthenticator(cfg *config.Config, users *services.UserService) *Authenticator {
+	return &Authenticator{
+		Endpoint: cfg.Auth.Endpoint,
+		Client:   &http.Client{Timeout: cfg.Auth.Timeout},
+		Users:    users,
+		Admins:   cfg.Auth.Admins,
+	}
+}
+
+func (auth *Authenticator) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authToken := c.GetHeader("Authorization")
-		userId, valid := isValidToken(authToken)
+		userId, valid := auth.isValidToken(authToken)
 		if !valid {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
 
+		if err := auth.Users.ProvisionUser(userId); err != nil {
+			switch {
+			case errors.Is(err, services.ErrUserDeleted):
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Account deleted"})
+			case errors.Is(err, services.ErrInvalidUserId):
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			default:
+				log.Printf("failed to provision user %s: %v", userId, err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the user"})
+			}
+			return
+		}
+
 		c.Set("userId", userId)
+		c.Set("isAdmin", auth.IsAdmin(userId))
 		c.Next()
 	}
 }
 
-func isValidToken(token string) (string, bool) {
-	validationURL := os.Getenv("AUTH_SERVICE_ENDPOINT_LOCAL")
-	req, err := http.NewRequest("POST", validationURL, nil)
+// AdminMiddleware only lets admins through, it goes after AuthMiddleware
+func (auth *Authenticator) AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("isAdmin") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// IsAdmin reports whether a user is allowed on the admin routes
+func (auth *Authenticator) IsAdmin(userId string) bool {
+	return slices.Contains(auth.Admins, userId)
+}
+
+func (auth *Authenticator) isValidToken(token string) (string, bool) {
+	req, err := http.NewRequest("POST", auth.Endpoint, nil)
 	if err != nil {
-		fmt.Println("Error creating request:", err)
+		log.Printf("failed to create token validation request: %v", err)
 		return "", false
 	}
 
 	req.Header.Set("Access-Token", token)
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := auth.Client.Do(req)
 	if err != nil {
-		fmt.Println("Error executing request:", err)
+		log.Printf("failed to validate token with the auth service: %v", err)
 		return "", false
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		fmt.Println("Unauthorized for this action, invalid token response")
+		log.Printf("auth service refused token with status %d", resp.StatusCode)
 		return "", false
 	}
 
@@ -50,7 +99,7 @@ func isValidToken(token string) (string, bool) {
 
 	err = json.NewDecoder(resp.Body).Decode(&responseBody)
 	if err != nil {
-		fmt.Println("Error decoding response body:", err)
+		log.Printf("failed to decode auth service response: %v", err)
 		return "", false
 	}
 
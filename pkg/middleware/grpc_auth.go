@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"bit-image/pkg/services"
+	"context"
+	"errors"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type userIdKey struct{}
+
+// UserIdFromContext returns the user the auth interceptors validated the call for
+func UserIdFromContext(ctx context.Context) (string, bool) {
+	userId, ok := ctx.Value(userIdKey{}).(string)
+	return userId, ok
+}
+
+// UnaryAuthInterceptor is the gRPC counterpart of AuthMiddleware, the access token is read from the "authorization"
+// metadata and validated with the auth service
+func (auth *Authenticator) UnaryAuthInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := auth.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor is UnaryAuthInterceptor for streaming calls
+func (auth *Authenticator) StreamAuthInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := auth.authenticate(stream.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func (auth *Authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	var authToken string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authToken = values[0]
+		}
+	}
+	userId, valid := auth.isValidToken(authToken)
+	if !valid {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	if err := auth.Users.ProvisionUser(userId); err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserDeleted):
+			return nil, status.Error(codes.PermissionDenied, "Account deleted")
+		case errors.Is(err, services.ErrInvalidUserId):
+			return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+		default:
+			log.Printf("failed to provision user %s: %v", userId, err)
+			return nil, status.Error(codes.Internal, "Failed to load the user")
+		}
+	}
+	return context.WithValue(ctx, userIdKey{}, userId), nil
+}
+
+// authenticatedStream carries the validated user in the context of a stream
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *authenticatedStream) Context() context.Context {
+	return stream.ctx
+}
@@ -0,0 +1,6 @@
+package middleware
+
+import "github.com/google/wire"
+
+// ProviderSet for the middleware package
+var ProviderSet = wire.NewSet(NewAuthenticator)
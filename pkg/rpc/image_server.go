@@ -0,0 +1,196 @@
+package rpc
+
+import (
+	"bit-image/pkg/middleware"
+	"bit-image/pkg/rpc/imagepb"
+	"bit-image/pkg/services"
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxPresignBatch bounds a unary presign call, larger batches go through PresignUploadsStream
+	maxPresignBatch = 1000
+	// presignStreamChunk is how many urls a stream signs and sends at a time
+	presignStreamChunk = 100
+	// maxDeleteBatch bounds a single batch delete, like the REST route
+	maxDeleteBatch = 1000
+)
+
+// ImageServer serves the image gRPC service from the same ImageService as the REST routes
+type ImageServer struct {
+	imagepb.UnimplementedImageServiceServer
+	ImageService *services.ImageService
+}
+
+func NewImageServer(imageService *services.ImageService) *ImageServer {
+	return &ImageServer{ImageService: imageService}
+}
+
+// NewServer returns a gRPC server with the image service registered behind the auth interceptors
+func NewServer(imageServer *ImageServer, auth *middleware.Authenticator) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(auth.UnaryAuthInterceptor()),
+		grpc.StreamInterceptor(auth.StreamAuthInterceptor()),
+	)
+	imagepb.RegisterImageServiceServer(server, imageServer)
+	return server
+}
+
+func (s *ImageServer) PresignUploads(ctx context.Context, request *imagepb.PresignUploadsRequest) (*imagepb.PresignUploadsResponse, error) {
+	userId, err := callerId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Images) == 0 || len(request.Images) > maxPresignBatch {
+		return nil, status.Errorf(codes.InvalidArgument, "between 1 and %d images must be declared, use PresignUploadsStream for larger batches", maxPresignBatch)
+	}
+
+	urls, err := s.ImageService.GeneratePresignedURLs(uploadDeclarations(request.Images), userId)
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	response := &imagepb.PresignUploadsResponse{Uploads: make([]*imagepb.PresignedUpload, 0, len(urls))}
+	for _, url := range urls {
+		response.Uploads = append(response.Uploads, presignedUploadMessage(url))
+	}
+	return response, nil
+}
+
+func (s *ImageServer) PresignUploadsStream(request *imagepb.PresignUploadsRequest, stream grpc.ServerStreamingServer[imagepb.PresignedUpload]) error {
+	userId, err := callerId(stream.Context())
+	if err != nil {
+		return err
+	}
+	if len(request.Images) == 0 {
+		return status.Error(codes.InvalidArgument, "at least one image must be declared")
+	}
+
+	declarations := uploadDeclarations(request.Images)
+	for start := 0; start < len(declarations); start += presignStreamChunk {
+		// stop signing once the client is gone
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		chunk := declarations[start:min(start+presignStreamChunk, len(declarations))]
+		urls, err := s.ImageService.GeneratePresignedURLs(chunk, userId)
+		if err != nil {
+			return errorStatus(err)
+		}
+		for _, url := range urls {
+			if err := stream.Send(presignedUploadMessage(url)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ImageServer) ConfirmUploads(ctx context.Context, request *imagepb.ConfirmUploadsRequest) (*imagepb.ConfirmUploadsResponse, error) {
+	userId, err := callerId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Uploads) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "at least one upload must be confirmed")
+	}
+
+	uploadRequests := make([]services.ConfirmUploadRequest, 0, len(request.Uploads))
+	for _, upload := range request.Uploads {
+		uploadRequests = append(uploadRequests, services.ConfirmUploadRequest{
+			Id:        upload.Id,
+			Name:      upload.Name,
+			Hash:      upload.Hash,
+			IsPrivate: upload.IsPrivate,
+			Caption:   upload.Caption,
+		})
+	}
+	errs := s.ImageService.ConfirmImageUploads(uploadRequests, userId)
+	if len(errs) == len(uploadRequests) {
+		return nil, batchErrorStatus(errs)
+	}
+	return &imagepb.ConfirmUploadsResponse{Errors: batchErrors(errs)}, nil
+}
+
+func (s *ImageServer) GetImage(ctx context.Context, request *imagepb.GetImageRequest) (*imagepb.Image, error) {
+	userId, err := callerId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	image, err := s.ImageService.GetImage(request.Id, userId, request.Download)
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return imageMessage(*image), nil
+}
+
+func (s *ImageServer) ListImages(ctx context.Context, request *imagepb.ListImagesRequest) (*imagepb.ListImagesResponse, error) {
+	userId, err := callerId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := services.ListImagesQuery{
+		Limit:         int(request.Limit),
+		Cursor:        request.Cursor,
+		Format:        request.Format,
+		MinSize:       request.MinSize,
+		MaxSize:       request.MaxSize,
+		IsPrivate:     request.IsPrivate,
+		CreatedAfter:  optionalTime(request.CreatedAfter.AsTime(), request.CreatedAfter != nil),
+		CreatedBefore: optionalTime(request.CreatedBefore.AsTime(), request.CreatedBefore != nil),
+		Tags:          request.Tags,
+		TagMatch:      request.TagMatch,
+	}
+	images, err := s.ImageService.ListImages(userId, query)
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	response := &imagepb.ListImagesResponse{
+		Images:     make([]*imagepb.Image, 0, len(images.Images)),
+		NextCursor: images.NextCursor,
+	}
+	for _, image := range images.Images {
+		response.Images = append(response.Images, imageMessage(image))
+	}
+	return response, nil
+}
+
+func (s *ImageServer) DeleteImages(ctx context.Context, request *imagepb.DeleteImagesRequest) (*imagepb.DeleteImagesResponse, error) {
+	userId, err := callerId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Ids) == 0 || len(request.Ids) > maxDeleteBatch {
+		return nil, status.Errorf(codes.InvalidArgument, "between 1 and %d images can be deleted at once", maxDeleteBatch)
+	}
+
+	deleted, errs := s.ImageService.DeleteImages(request.Ids, userId)
+	if len(deleted) == 0 && len(errs) > 0 {
+		return nil, batchErrorStatus(errs)
+	}
+	response := &imagepb.DeleteImagesResponse{Deleted: make([]string, 0, len(deleted)), Errors: batchErrors(errs)}
+	for _, imageId := range deleted {
+		response.Deleted = append(response.Deleted, imageId.String())
+	}
+	return response, nil
+}
+
+// callerId returns the user validated by the auth interceptors
+func callerId(ctx context.Context) (string, error) {
+	userId, ok := middleware.UserIdFromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "No user found, userId not found")
+	}
+	return userId, nil
+}
+
+func optionalTime(value time.Time, set bool) *time.Time {
+	if !set {
+		return nil
+	}
+	return &value
+}
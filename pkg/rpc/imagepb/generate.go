@@ -0,0 +1,4 @@
+// Package imagepb holds the protobuf messages and gRPC stubs of the image service, generated from image.proto
+package imagepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative image.proto
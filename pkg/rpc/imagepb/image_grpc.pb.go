@@ -0,0 +1,331 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: image.proto
+
+package imagepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ImageService_PresignUploads_FullMethodName       = "/bitimage.v1.ImageService/PresignUploads"
+	ImageService_PresignUploadsStream_FullMethodName = "/bitimage.v1.ImageService/PresignUploadsStream"
+	ImageService_ConfirmUploads_FullMethodName       = "/bitimage.v1.ImageService/ConfirmUploads"
+	ImageService_GetImage_FullMethodName             = "/bitimage.v1.ImageService/GetImage"
+	ImageService_ListImages_FullMethodName           = "/bitimage.v1.ImageService/ListImages"
+	ImageService_DeleteImages_FullMethodName         = "/bitimage.v1.ImageService/DeleteImages"
+)
+
+// ImageServiceClient is the client API for ImageService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ImageService is the gRPC counterpart of the /api REST routes. Calls carry the same access token as REST, in the
+// "authorization" metadata.
+type ImageServiceClient interface {
+	// PresignUploads issues one upload url per declared image, up to 1000 per call
+	PresignUploads(ctx context.Context, in *PresignUploadsRequest, opts ...grpc.CallOption) (*PresignUploadsResponse, error)
+	// PresignUploadsStream issues upload urls for batches of any size, sending them in chunks as they are signed. The
+	// quota is checked per chunk, so a stream may end with RESOURCE_EXHAUSTED after its first urls were sent.
+	PresignUploadsStream(ctx context.Context, in *PresignUploadsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PresignedUpload], error)
+	// ConfirmUploads accepts uploaded images. Failures are reported per upload unless every upload failed.
+	ConfirmUploads(ctx context.Context, in *ConfirmUploadsRequest, opts ...grpc.CallOption) (*ConfirmUploadsResponse, error)
+	GetImage(ctx context.Context, in *GetImageRequest, opts ...grpc.CallOption) (*Image, error)
+	ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc.CallOption) (*ListImagesResponse, error)
+	// DeleteImages soft-deletes the user's images. Failures are reported per image unless every image failed.
+	DeleteImages(ctx context.Context, in *DeleteImagesRequest, opts ...grpc.CallOption) (*DeleteImagesResponse, error)
+}
+
+type imageServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewImageServiceClient(cc grpc.ClientConnInterface) ImageServiceClient {
+	return &imageServiceClient{cc}
+}
+
+func (c *imageServiceClient) PresignUploads(ctx context.Context, in *PresignUploadsRequest, opts ...grpc.CallOption) (*PresignUploadsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PresignUploadsResponse)
+	err := c.cc.Invoke(ctx, ImageService_PresignUploads_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) PresignUploadsStream(ctx context.Context, in *PresignUploadsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PresignedUpload], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ImageService_ServiceDesc.Streams[0], ImageService_PresignUploadsStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PresignUploadsRequest, PresignedUpload]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ImageService_PresignUploadsStreamClient = grpc.ServerStreamingClient[PresignedUpload]
+
+func (c *imageServiceClient) ConfirmUploads(ctx context.Context, in *ConfirmUploadsRequest, opts ...grpc.CallOption) (*ConfirmUploadsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConfirmUploadsResponse)
+	err := c.cc.Invoke(ctx, ImageService_ConfirmUploads_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) GetImage(ctx context.Context, in *GetImageRequest, opts ...grpc.CallOption) (*Image, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Image)
+	err := c.cc.Invoke(ctx, ImageService_GetImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc.CallOption) (*ListImagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListImagesResponse)
+	err := c.cc.Invoke(ctx, ImageService_ListImages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) DeleteImages(ctx context.Context, in *DeleteImagesRequest, opts ...grpc.CallOption) (*DeleteImagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteImagesResponse)
+	err := c.cc.Invoke(ctx, ImageService_DeleteImages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ImageServiceServer is the server API for ImageService service.
+// All implementations must embed UnimplementedImageServiceServer
+// for forward compatibility.
+//
+// ImageService is the gRPC counterpart of the /api REST routes. Calls carry the same access token as REST, in the
+// "authorization" metadata.
+type ImageServiceServer interface {
+	// PresignUploads issues one upload url per declared image, up to 1000 per call
+	PresignUploads(context.Context, *PresignUploadsRequest) (*PresignUploadsResponse, error)
+	// PresignUploadsStream issues upload urls for batches of any size, sending them in chunks as they are signed. The
+	// quota is checked per chunk, so a stream may end with RESOURCE_EXHAUSTED after its first urls were sent.
+	PresignUploadsStream(*PresignUploadsRequest, grpc.ServerStreamingServer[PresignedUpload]) error
+	// ConfirmUploads accepts uploaded images. Failures are reported per upload unless every upload failed.
+	ConfirmUploads(context.Context, *ConfirmUploadsRequest) (*ConfirmUploadsResponse, error)
+	GetImage(context.Context, *GetImageRequest) (*Image, error)
+	ListImages(context.Context, *ListImagesRequest) (*ListImagesResponse, error)
+	// DeleteImages soft-deletes the user's images. Failures are reported per image unless every image failed.
+	DeleteImages(context.Context, *DeleteImagesRequest) (*DeleteImagesResponse, error)
+	mustEmbedUnimplementedImageServiceServer()
+}
+
+// UnimplementedImageServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedImageServiceServer struct{}
+
+func (UnimplementedImageServiceServer) PresignUploads(context.Context, *PresignUploadsRequest) (*PresignUploadsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PresignUploads not implemented")
+}
+func (UnimplementedImageServiceServer) PresignUploadsStream(*PresignUploadsRequest, grpc.ServerStreamingServer[PresignedUpload]) error {
+	return status.Errorf(codes.Unimplemented, "method PresignUploadsStream not implemented")
+}
+func (UnimplementedImageServiceServer) ConfirmUploads(context.Context, *ConfirmUploadsRequest) (*ConfirmUploadsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmUploads not implemented")
+}
+func (UnimplementedImageServiceServer) GetImage(context.Context, *GetImageRequest) (*Image, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetImage not implemented")
+}
+func (UnimplementedImageServiceServer) ListImages(context.Context, *ListImagesRequest) (*ListImagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListImages not implemented")
+}
+func (UnimplementedImageServiceServer) DeleteImages(context.Context, *DeleteImagesRequest) (*DeleteImagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteImages not implemented")
+}
+func (UnimplementedImageServiceServer) mustEmbedUnimplementedImageServiceServer() {}
+func (UnimplementedImageServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeImageServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ImageServiceServer will
+// result in compilation errors.
+type UnsafeImageServiceServer interface {
+	mustEmbedUnimplementedImageServiceServer()
+}
+
+func RegisterImageServiceServer(s grpc.ServiceRegistrar, srv ImageServiceServer) {
+	// If the following call pancis, it indicates UnimplementedImageServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ImageService_ServiceDesc, srv)
+}
+
+func _ImageService_PresignUploads_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PresignUploadsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageServiceServer).PresignUploads(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ImageService_PresignUploads_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageServiceServer).PresignUploads(ctx, req.(*PresignUploadsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ImageService_PresignUploadsStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PresignUploadsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ImageServiceServer).PresignUploadsStream(m, &grpc.GenericServerStream[PresignUploadsRequest, PresignedUpload]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ImageService_PresignUploadsStreamServer = grpc.ServerStreamingServer[PresignedUpload]
+
+func _ImageService_ConfirmUploads_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmUploadsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageServiceServer).ConfirmUploads(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ImageService_ConfirmUploads_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageServiceServer).ConfirmUploads(ctx, req.(*ConfirmUploadsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ImageService_GetImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageServiceServer).GetImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ImageService_GetImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageServiceServer).GetImage(ctx, req.(*GetImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ImageService_ListImages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListImagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageServiceServer).ListImages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ImageService_ListImages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageServiceServer).ListImages(ctx, req.(*ListImagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ImageService_DeleteImages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteImagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageServiceServer).DeleteImages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ImageService_DeleteImages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageServiceServer).DeleteImages(ctx, req.(*DeleteImagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ImageService_ServiceDesc is the grpc.ServiceDesc for ImageService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ImageService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bitimage.v1.ImageService",
+	HandlerType: (*ImageServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PresignUploads",
+			Handler:    _ImageService_PresignUploads_Handler,
+		},
+		{
+			MethodName: "ConfirmUploads",
+			Handler:    _ImageService_ConfirmUploads_Handler,
+		},
+		{
+			MethodName: "GetImage",
+			Handler:    _ImageService_GetImage_Handler,
+		},
+		{
+			MethodName: "ListImages",
+			Handler:    _ImageService_ListImages_Handler,
+		},
+		{
+			MethodName: "DeleteImages",
+			Handler:    _ImageService_DeleteImages_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PresignUploadsStream",
+			Handler:       _ImageService_PresignUploadsStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "image.proto",
+}
@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"bit-image/pkg/rpc/imagepb"
+	"bit-image/pkg/services"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func uploadDeclarations(images []*imagepb.UploadDeclaration) []services.UploadDeclaration {
+	declarations := make([]services.UploadDeclaration, 0, len(images))
+	for _, image := range images {
+		declarations = append(declarations, services.UploadDeclaration{ChecksumSHA256: image.ChecksumSha256, Size: image.Size})
+	}
+	return declarations
+}
+
+func presignedUploadMessage(url services.PresignedURL) *imagepb.PresignedUpload {
+	return &imagepb.PresignedUpload{
+		Url:            url.URL,
+		Method:         url.Method,
+		Headers:        url.Headers,
+		ImageId:        url.ImageId.String(),
+		ChecksumSha256: url.ChecksumSHA256,
+	}
+}
+
+func imageMessage(image services.ImageDetails) *imagepb.Image {
+	message := &imagepb.Image{
+		Id:              image.Id.String(),
+		OwnerId:         image.OwnerId,
+		Name:            image.Name,
+		Caption:         image.Caption,
+		IsPrivate:       image.IsPrivate,
+		FileSize:        image.FileSize,
+		Format:          image.Format,
+		MimeType:        image.MimeType,
+		Width:           int32(image.Width),
+		Height:          int32(image.Height),
+		ColorModel:      image.ColorModel,
+		Hash:            image.Hash,
+		DateTimeCreated: timestamppb.New(image.DateTimeCreated),
+		DateTimeUpdated: timestamppb.New(image.DateTimeUpdated),
+		Url:             image.URL,
+		UrlExpiresAt:    timestamppb.New(image.URLExpiresAt),
+		Derivatives:     make([]*imagepb.Derivative, 0, len(image.Derivatives)),
+		Tags:            image.Tags,
+		Labels:          make([]*imagepb.Label, 0, len(image.Labels)),
+	}
+	for _, derivative := range image.Derivatives {
+		message.Derivatives = append(message.Derivatives, &imagepb.Derivative{
+			Size:   int32(derivative.Size),
+			Format: derivative.Format,
+			Width:  int32(derivative.Width),
+			Height: int32(derivative.Height),
+			Url:    derivative.URL,
+		})
+	}
+	for _, label := range image.Labels {
+		message.Labels = append(message.Labels, &imagepb.Label{Name: label.Name, Source: label.Source, Confidence: label.Confidence})
+	}
+	if image.Exif != nil {
+		message.Exif = exifMessage(*image.Exif)
+	}
+	return message
+}
+
+func exifMessage(exif services.ExifDetails) *imagepb.Exif {
+	message := &imagepb.Exif{
+		Make:         exif.Make,
+		Model:        exif.Model,
+		LensModel:    exif.LensModel,
+		CapturedAt:   optionalTimestamp(exif.CapturedAt),
+		Orientation:  int32(exif.Orientation),
+		ExposureTime: exif.ExposureTime,
+		FNumber:      exif.FNumber,
+		FocalLength:  exif.FocalLength,
+		Latitude:     exif.Latitude,
+		Longitude:    exif.Longitude,
+		Altitude:     exif.Altitude,
+	}
+	if exif.ISO != nil {
+		iso := int32(*exif.ISO)
+		message.Iso = &iso
+	}
+	return message
+}
+
+func optionalTimestamp(value *time.Time) *timestamppb.Timestamp {
+	if value == nil {
+		return nil
+	}
+	return timestamppb.New(*value)
+}
@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"bit-image/pkg/rpc/imagepb"
+	"bit-image/pkg/services"
+	"errors"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorCode maps service errors to the gRPC code returned to the client, like imageErrorStatus does for REST
+func errorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, services.ErrInvalidImageId), errors.Is(err, services.ErrInvalidCursor),
+		errors.Is(err, services.ErrInvalidChecksum), errors.Is(err, services.ErrInvalidDeclaration),
+		errors.Is(err, services.ErrInvalidUserId), errors.Is(err, services.ErrInvalidRenderQuery),
+		errors.Is(err, services.ErrInvalidTag), errors.Is(err, services.ErrInvalidSimilarityQuery),
+		errors.Is(err, services.ErrInvalidSearchQuery):
+		return codes.InvalidArgument
+	case errors.Is(err, services.ErrImageNotFound), errors.Is(err, services.ErrUploadNotFound):
+		return codes.NotFound
+	case errors.Is(err, services.ErrImageAccessDenied), errors.Is(err, services.ErrUserSuspended),
+		errors.Is(err, services.ErrUserDeleted):
+		return codes.PermissionDenied
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return codes.ResourceExhausted
+	case errors.Is(err, services.ErrChecksumMismatch), errors.Is(err, services.ErrInvalidImage):
+		// the stored object doesn't match the declaration, retrying won't help until it is uploaded again
+		return codes.FailedPrecondition
+	case errors.Is(err, services.ErrUploadInProgress):
+		return codes.Aborted
+	default:
+		return codes.Internal
+	}
+}
+
+// errorMessage is the message returned to the client for a service error. Internal errors carry the text of database
+// and storage failures, they are logged and replaced with a generic message.
+func errorMessage(err error) string {
+	if errorCode(err) == codes.Internal {
+		log.Printf("rpc failed: %v", err)
+		return "internal error"
+	}
+	return err.Error()
+}
+
+func errorStatus(err error) error {
+	return status.Error(errorCode(err), errorMessage(err))
+}
+
+// batchErrorStatus is the status of a batch where every item failed: the code they share, Internal when they differ
+func batchErrorStatus(errs []error) error {
+	code := errorCode(errs[0])
+	for _, err := range errs[1:] {
+		if errorCode(err) != code {
+			code = codes.Internal
+			break
+		}
+	}
+	if code == codes.Internal {
+		log.Printf("rpc failed: %v", errors.Join(errs...))
+		return status.Error(code, "internal error")
+	}
+	return status.Error(code, errors.Join(errs...).Error())
+}
+
+// batchErrors reports the failed items of a partially successful batch
+func batchErrors(errs []error) []*imagepb.BatchError {
+	batchErrs := make([]*imagepb.BatchError, 0, len(errs))
+	for _, err := range errs {
+		batchErrs = append(batchErrs, &imagepb.BatchError{Message: errorMessage(err), Code: int32(errorCode(err))})
+	}
+	return batchErrs
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"bit-image/internal/s3"
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage/exif"
+	"bit-image/pkg/storage/image"
+	"bit-image/pkg/storage/label"
+	"bit-image/pkg/storage/outbox"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	labelingBatchSize = 100
+	// labelingLease is how long a claimed job is left alone before another labeler may retry it
+	labelingLease = 10 * time.Minute
+	// labelingMaxBackoff caps the exponential backoff between attempts
+	labelingMaxBackoff = time.Hour
+)
+
+// AutoLabeler runs the Labeler on confirmed images and stores what it finds as machine labels. Confirmation queues a
+// labeling job in its transaction and schedules it right away, Run picks up whatever is left over and retries
+// failures with exponential backoff until MaxAttempts. Labeling an image again replaces its machine labels, user
+// labels are never touched.
+type AutoLabeler struct {
+	S3Handler  *s3.Handler
+	ImageStore *image.ImageStore
+	ExifStore  *exif.ExifStore
+	LabelStore *label.LabelStore
+	// OutboxStore records an image.updated event along with new machine labels
+	OutboxStore *outbox.OutboxStore
+	// Labeler is nil when machine labeling is turned off
+	Labeler Labeler
+	// MinConfidence drops labels the Labeler isn't sure enough about
+	MinConfidence float64
+	Timeout       time.Duration
+	MaxAttempts   int
+	Interval      time.Duration
+	// workers bounds how many images are labeled at once from Schedule
+	workers chan struct{}
+}
+
+func NewAutoLabeler(store *image.ImageStore, s3Handler *s3.Handler, exifStore *exif.ExifStore, labelStore *label.LabelStore, outboxStore *outbox.OutboxStore, labeler Labeler, cfg *config.Config) *AutoLabeler {
+	return &AutoLabeler{
+		S3Handler:     s3Handler,
+		ImageStore:    store,
+		ExifStore:     exifStore,
+		LabelStore:    labelStore,
+		OutboxStore:   outboxStore,
+		Labeler:       labeler,
+		MinConfidence: cfg.Labeling.MinConfidence,
+		Timeout:       cfg.Labeling.Timeout,
+		MaxAttempts:   cfg.Labeling.MaxAttempts,
+		Interval:      cfg.Labeling.Interval,
+		workers:       make(chan struct{}, cfg.Labeling.Workers),
+	}
+}
+
+// Enabled reports whether a Labeler is configured, no jobs are queued otherwise
+func (labeler *AutoLabeler) Enabled() bool {
+	return labeler.Labeler != nil
+}
+
+// Schedule labels an image in the background. When every worker is busy the image is left to the next Run tick
+// instead of piling up goroutines.
+func (labeler *AutoLabeler) Schedule(imageId uuid.UUID) {
+	if !labeler.Enabled() {
+		return
+	}
+	select {
+	case labeler.workers <- struct{}{}:
+	default:
+		return
+	}
+	go func() {
+		defer func() { <-labeler.workers }()
+		if _, err := labeler.LabelImage(imageId); err != nil {
+			log.Printf("failed to label image %s: %v", imageId.String(), err)
+		}
+	}()
+}
+
+// Run labels due images on every tick until the context is cancelled
+func (labeler *AutoLabeler) Run(ctx context.Context) {
+	if !labeler.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(labeler.Interval)
+	defer ticker.Stop()
+
+	for {
+		labeled, err := labeler.LabelDue()
+		if err != nil {
+			log.Printf("image labeling failed: %v", err)
+		} else if labeled > 0 {
+			log.Printf("labeled %d images", labeled)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LabelDue runs every labeling job that is due and returns how many images were labeled
+func (labeler *AutoLabeler) LabelDue() (int, error) {
+	labeled := 0
+	for {
+		claimed, err := labeler.LabelStore.ClaimLabelingJobs(nil, labelingBatchSize, time.Now().Add(labelingLease))
+		if err != nil {
+			return labeled, err
+		}
+		for _, job := range claimed {
+			if labeler.run(job) {
+				labeled++
+			}
+		}
+		if len(claimed) < labelingBatchSize {
+			return labeled, nil
+		}
+	}
+}
+
+// LabelImage runs the labeling job of one image when it is due and reports whether the image was labeled
+func (labeler *AutoLabeler) LabelImage(imageId uuid.UUID) (bool, error) {
+	claimed, err := labeler.LabelStore.ClaimLabelingJobs(&imageId, 1, time.Now().Add(labelingLease))
+	if err != nil {
+		return false, err
+	}
+	if len(claimed) == 0 {
+		return false, nil
+	}
+	return labeler.run(claimed[0]), nil
+}
+
+// RetryFailed queues the jobs that ran out of attempts again and runs them
+func (labeler *AutoLabeler) RetryFailed() (int, error) {
+	requeued, err := labeler.LabelStore.RetryFailedLabelingJobs()
+	if err != nil {
+		return 0, err
+	}
+	if requeued == 0 {
+		return 0, nil
+	}
+	return labeler.LabelDue()
+}
+
+// run labels the image of a claimed job, a failure is recorded on the job
+func (labeler *AutoLabeler) run(job entities.LabelingJob) bool {
+	if err := labeler.label(job.ImageId); err != nil {
+		labeler.recordFailure(job, err)
+		return false
+	}
+	return true
+}
+
+// label calls the Labeler with the original and replaces the machine labels of the image with its results
+func (labeler *AutoLabeler) label(imageId uuid.UUID) error {
+	input, ownerId, err := labeler.labelInput(imageId)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), labeler.Timeout)
+	defer cancel()
+	suggested, err := labeler.Labeler.Label(ctx, *input)
+	if err != nil {
+		return err
+	}
+	confidences := labeler.confidences(imageId, suggested)
+
+	tx, commit, rollback, err := labeler.LabelStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	err = labeler.LabelStore.ClearImageLabelsWithTransaction(tx, imageId, common.LABEL_SOURCE_MACHINE)
+	if err == nil {
+		err = setImageLabelsWithTransaction(tx, labeler.LabelStore, imageId, common.LABEL_SOURCE_MACHINE, confidences)
+	}
+	if err == nil {
+		err = labeler.ImageStore.RefreshSearchDocumentsWithTransaction(tx, []uuid.UUID{imageId})
+	}
+	if err == nil {
+		err = addImageUpdatedEventsWithTransaction(tx, labeler.OutboxStore, ownerId, []uuid.UUID{imageId}, ImageChangeLabels)
+	}
+	if err == nil {
+		err = labeler.LabelStore.MarkLabelingJobDoneWithTransaction(tx, imageId)
+	}
+	if err == nil {
+		err = commit()
+	}
+	if err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// labelInput reads the original of the image along with what is known about it, and returns its owner
+func (labeler *AutoLabeler) labelInput(imageId uuid.UUID) (*LabelInput, string, error) {
+	storedImage, err := labeler.ImageStore.GetImageById(imageId)
+	if err != nil {
+		return nil, "", err
+	}
+	orientation := 0
+	imageExif, err := labeler.ExifStore.GetExif(imageId)
+	if err == nil {
+		orientation = imageExif.Orientation
+	} else if !errors.Is(err, exif.ErrExifNotFound) {
+		return nil, "", err
+	}
+
+	body, err := labeler.S3Handler.GetObject(storedImage.Path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image %s: %w", imageId.String(), err)
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image %s: %w", imageId.String(), err)
+	}
+
+	return &LabelInput{
+		ImageId:     imageId,
+		Format:      storedImage.ImageMetaData.Format,
+		MimeType:    storedImage.ImageMetaData.MimeType,
+		Width:       storedImage.ImageMetaData.Width,
+		Height:      storedImage.ImageMetaData.Height,
+		Orientation: orientation,
+		Content:     content,
+	}, storedImage.OwnerId, nil
+}
+
+// confidences keeps the suggested labels that are confident enough, normalized like user labels. Names that
+// wouldn't be accepted from a user are skipped, a name suggested twice keeps its highest confidence.
+func (labeler *AutoLabeler) confidences(imageId uuid.UUID, suggested []MachineLabel) map[string]float64 {
+	confidences := make(map[string]float64, len(suggested))
+	for _, suggestion := range suggested {
+		if math.IsNaN(suggestion.Confidence) || suggestion.Confidence < labeler.MinConfidence {
+			continue
+		}
+		names, err := normalizeNames([]string{suggestion.Name})
+		if err != nil {
+			log.Printf("skipping machine label of image %s: %v", imageId.String(), err)
+			continue
+		}
+		confidence := min(suggestion.Confidence, 1)
+		if confidence > confidences[names[0]] {
+			confidences[names[0]] = confidence
+		}
+	}
+	return confidences
+}
+
+// recordFailure schedules the next attempt of a job with exponential backoff, or gives up on it. Jobs of deleted
+// images are never retried.
+func (labeler *AutoLabeler) recordFailure(failed entities.LabelingJob, cause error) {
+	var retryAt *time.Time
+	if failed.Attempts < labeler.MaxAttempts && !errors.Is(cause, image.ErrImageNotFound) {
+		backoff := min(time.Minute<<min(failed.Attempts-1, 10), labelingMaxBackoff)
+		next := time.Now().Add(backoff)
+		retryAt = &next
+	}
+	if err := labeler.LabelStore.MarkLabelingJobFailed(failed.ImageId, cause.Error(), retryAt); err != nil {
+		log.Printf("failed to record failure of labeling image %s: %v", failed.ImageId.String(), err)
+	}
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"bit-image/internal/s3"
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage/derivative"
+	"bit-image/pkg/storage/image"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	goimage "image"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	derivativeBatchSize = 100
+	// derivativeLease is how long a claimed derivative is left alone before another generator may retry it
+	derivativeLease = 10 * time.Minute
+	// derivativeMaxBackoff caps the exponential backoff between attempts
+	derivativeMaxBackoff = time.Hour
+)
+
+// DerivativeSpec is one derivative generated for every image, a Size x Size bounding box in Format
+type DerivativeSpec struct {
+	Size   int
+	Format string
+}
+
+// DerivativeGenerator produces the downscaled copies of confirmed images listed in Specs. Confirmation queues pending
+// derivative rows in its transaction and schedules their generation right away, Run picks up whatever is left over
+// and retries failures with exponential backoff until MaxAttempts.
+type DerivativeGenerator struct {
+	S3Handler       *s3.Handler
+	ImageStore      *image.ImageStore
+	DerivativeStore *derivative.DerivativeStore
+	Specs           []DerivativeSpec
+	JPEGQuality     int
+	MaxAttempts     int
+	Interval        time.Duration
+	// workers bounds how many images are generated at once from Schedule
+	workers chan struct{}
+}
+
+func NewDerivativeGenerator(store *image.ImageStore, s3Handler *s3.Handler, derivativeStore *derivative.DerivativeStore, cfg *config.Config) *DerivativeGenerator {
+	return &DerivativeGenerator{
+		S3Handler:       s3Handler,
+		ImageStore:      store,
+		DerivativeStore: derivativeStore,
+		Specs:           derivativeSpecs(cfg.Derivatives.Sizes, cfg.Derivatives.Formats),
+		JPEGQuality:     cfg.Derivatives.JPEGQuality,
+		MaxAttempts:     cfg.Derivatives.MaxAttempts,
+		Interval:        cfg.Derivatives.Interval,
+		workers:         make(chan struct{}, cfg.Derivatives.Workers),
+	}
+}
+
+// derivativeSpecs generates every size in every format
+func derivativeSpecs(sizes []int, formats []string) []DerivativeSpec {
+	var specs []DerivativeSpec
+	for _, format := range formats {
+		for _, size := range sizes {
+			specs = append(specs, DerivativeSpec{Size: size, Format: format})
+		}
+	}
+	return specs
+}
+
+// derivedPrefix is the folder holding every object derived from an image, DERIVED_STORAGE/<ownerId>/<imageId>/
+func derivedPrefix(ownerId string, imageId uuid.UUID) string {
+	return common.DERIVED_STORAGE_FOLDER + "/" + ownerId + "/" + imageId.String() + "/"
+}
+
+// derivativePath is where a derivative of an image is stored, <derivedPrefix>/<size>.<ext>
+func derivativePath(ownerId string, imageId uuid.UUID, spec DerivativeSpec) string {
+	return fmt.Sprintf("%s%d.%s", derivedPrefix(ownerId, imageId), spec.Size, formatExtensions[spec.Format])
+}
+
+// newDerivatives are the pending rows queued for a newly confirmed image
+func (generator *DerivativeGenerator) newDerivatives(newImage entities.Image) []entities.Derivative {
+	derivatives := make([]entities.Derivative, 0, len(generator.Specs))
+	for _, spec := range generator.Specs {
+		derivatives = append(derivatives, entities.Derivative{
+			ImageId:       newImage.Base.Id,
+			Size:          spec.Size,
+			Format:        spec.Format,
+			Path:          derivativePath(newImage.OwnerId, newImage.Base.Id, spec),
+			Status:        entities.DerivativePending,
+			NextAttemptAt: time.Now(),
+		})
+	}
+	return derivatives
+}
+
+// Schedule generates the derivatives of an image in the background. When every worker is busy the image is left to
+// the next Run tick instead of piling up goroutines.
+func (generator *DerivativeGenerator) Schedule(imageId uuid.UUID) {
+	select {
+	case generator.workers <- struct{}{}:
+	default:
+		return
+	}
+	go func() {
+		defer func() { <-generator.workers }()
+		if _, err := generator.GenerateImage(imageId); err != nil {
+			log.Printf("failed to generate derivatives of image %s: %v", imageId.String(), err)
+		}
+	}()
+}
+
+// Run generates due derivatives on every tick until the context is cancelled
+func (generator *DerivativeGenerator) Run(ctx context.Context) {
+	ticker := time.NewTicker(generator.Interval)
+	defer ticker.Stop()
+
+	for {
+		generated, err := generator.GenerateDue()
+		if err != nil {
+			log.Printf("derivative generation failed: %v", err)
+		} else if generated > 0 {
+			log.Printf("generated %d derivatives", generated)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GenerateDue generates every derivative that is due and returns how many were stored
+func (generator *DerivativeGenerator) GenerateDue() (int, error) {
+	generated := 0
+	for {
+		claimed, err := generator.DerivativeStore.ClaimDerivatives(nil, derivativeBatchSize, time.Now().Add(derivativeLease))
+		if err != nil {
+			return generated, err
+		}
+
+		byImage := make(map[uuid.UUID][]entities.Derivative)
+		for _, claimedDerivative := range claimed {
+			byImage[claimedDerivative.ImageId] = append(byImage[claimedDerivative.ImageId], claimedDerivative)
+		}
+		for imageId, derivatives := range byImage {
+			generated += generator.generate(imageId, derivatives)
+		}
+
+		if len(claimed) < derivativeBatchSize {
+			return generated, nil
+		}
+	}
+}
+
+// GenerateImage generates the due derivatives of one image and returns how many were stored
+func (generator *DerivativeGenerator) GenerateImage(imageId uuid.UUID) (int, error) {
+	claimed, err := generator.DerivativeStore.ClaimDerivatives(&imageId, len(generator.Specs)+1, time.Now().Add(derivativeLease))
+	if err != nil {
+		return 0, err
+	}
+	if len(claimed) == 0 {
+		return 0, nil
+	}
+	return generator.generate(imageId, claimed), nil
+}
+
+// RetryFailed queues the derivatives that ran out of attempts again and generates them
+func (generator *DerivativeGenerator) RetryFailed() (int, error) {
+	requeued, err := generator.DerivativeStore.RetryFailedDerivatives()
+	if err != nil {
+		return 0, err
+	}
+	if requeued == 0 {
+		return 0, nil
+	}
+	return generator.GenerateDue()
+}
+
+// generate decodes the original once and stores every claimed derivative of it, failures are recorded per derivative
+func (generator *DerivativeGenerator) generate(imageId uuid.UUID, claimed []entities.Derivative) int {
+	original, err := generator.decodeOriginal(imageId)
+	if err != nil {
+		for _, claimedDerivative := range claimed {
+			generator.recordFailure(claimedDerivative, err)
+		}
+		return 0
+	}
+
+	generated := 0
+	for _, claimedDerivative := range claimed {
+		width, height, size, err := generator.store(original, claimedDerivative)
+		if err != nil {
+			generator.recordFailure(claimedDerivative, err)
+			continue
+		}
+		if err = generator.DerivativeStore.MarkDerivativeReady(claimedDerivative.Base.Id, width, height, size); err != nil {
+			log.Printf("failed to record derivative %s: %v", claimedDerivative.Path, err)
+			continue
+		}
+		generated++
+	}
+	return generated
+}
+
+func (generator *DerivativeGenerator) decodeOriginal(imageId uuid.UUID) (goimage.Image, error) {
+	storedImage, err := generator.ImageStore.GetImageById(imageId)
+	if err != nil {
+		return nil, err
+	}
+	body, err := generator.S3Handler.GetObject(storedImage.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %w", imageId.String(), err)
+	}
+	defer body.Close()
+
+	// the content was checked against MAX_IMAGE_PIXELS on confirmation, so decoding it is safe
+	original, _, err := goimage.Decode(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %w", imageId.String(), err)
+	}
+	return original, nil
+}
+
+// store resizes the original to the derivative's box and uploads it, returning the stored dimensions and size
+func (generator *DerivativeGenerator) store(original goimage.Image, target entities.Derivative) (int, int, int64, error) {
+	bounds := original.Bounds()
+	width, height := fitWithin(bounds.Dx(), bounds.Dy(), target.Size, target.Size)
+	encoded, err := encodeImage(resizeImage(original, bounds, width, height, target.Format), target.Format, generator.JPEGQuality)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if err = generator.S3Handler.PutObject(target.Path, bytes.NewReader(encoded), formatContentTypes[target.Format]); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to store derivative %s: %w", target.Path, err)
+	}
+	return width, height, int64(len(encoded)), nil
+}
+
+// recordFailure schedules the next attempt of a derivative with exponential backoff, or gives up on it. Derivatives
+// of deleted images are never retried.
+func (generator *DerivativeGenerator) recordFailure(failed entities.Derivative, cause error) {
+	var retryAt *time.Time
+	if failed.Attempts < generator.MaxAttempts && !errors.Is(cause, image.ErrImageNotFound) {
+		backoff := min(time.Minute<<min(failed.Attempts-1, 10), derivativeMaxBackoff)
+		next := time.Now().Add(backoff)
+		retryAt = &next
+	}
+	if err := generator.DerivativeStore.MarkDerivativeFailed(failed.Base.Id, cause.Error(), retryAt); err != nil {
+		log.Printf("failed to record failure of derivative %s: %v", failed.Path, err)
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"bit-image/pkg/storage"
+	"bit-image/pkg/storage/image"
+	"bit-image/pkg/storage/upload"
+	"bit-image/pkg/storage/webhook"
+	"errors"
+)
+
+var (
+	ErrInvalidImageId         = errors.New("invalid image id")
+	ErrImageNotFound          = image.ErrImageNotFound
+	ErrImageAccessDenied      = errors.New("access to image denied")
+	ErrInvalidChecksum        = errors.New("invalid sha256 checksum")
+	ErrInvalidDeclaration     = errors.New("invalid upload declaration")
+	ErrChecksumMismatch       = errors.New("uploaded content does not match the declared checksum")
+	ErrInvalidImage           = errors.New("uploaded content is not a supported image")
+	ErrInvalidRenderQuery     = errors.New("invalid render parameters")
+	ErrInvalidTag             = errors.New("invalid tag or label name")
+	ErrInvalidSimilarityQuery = errors.New("invalid similarity parameters")
+	ErrInvalidSearchQuery     = errors.New("invalid search parameters")
+	ErrInvalidUserId          = errors.New("invalid user id")
+	ErrQuotaExceeded          = storage.ErrQuotaExceeded
+	ErrInvalidQuota           = errors.New("invalid quota")
+	ErrUserNotFound           = storage.ErrUserNotFound
+	ErrUserSuspended          = errors.New("user is suspended")
+	ErrUserDeleted            = storage.ErrUserDeleted
+	ErrInvalidSuspension      = errors.New("invalid suspension reason")
+	ErrUploadNotFound         = upload.ErrUploadNotFound
+	ErrUploadInProgress       = errors.New("upload is already being confirmed")
+	ErrInvalidWebhook         = errors.New("invalid webhook")
+	ErrWebhookLimit           = errors.New("webhook subscription limit reached")
+	ErrWebhookNotFound        = webhook.ErrSubscriptionNotFound
+	ErrDeliveryNotFound       = webhook.ErrDeliveryNotFound
+	ErrImageNotDeleted        = errors.New("image is not deleted")
+	ErrImageContentGone       = errors.New("stored content of the image is gone")
+)
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"bit-image/pkg/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// event publishers selected with OUTBOX_PUBLISHER
+const (
+	PublisherLog  = config.PublisherLog
+	PublisherHTTP = config.PublisherHTTP
+)
+
+// EventPublisher hands image events to downstream systems. It is called by the OutboxDispatcher, an error is retried
+// later with backoff and holds back the later events of the same image.
+type EventPublisher interface {
+	Publish(ctx context.Context, envelope EventEnvelope) error
+}
+
+// NewEventPublisher returns the EventPublisher selected with OUTBOX_PUBLISHER
+func NewEventPublisher(cfg *config.Config) (EventPublisher, error) {
+	switch kind := cfg.Outbox.Publisher; kind {
+	case PublisherLog:
+		return LogPublisher{}, nil
+	case PublisherHTTP:
+		return NewHTTPPublisher(cfg.Outbox), nil
+	default:
+		return nil, fmt.Errorf("unknown OUTBOX_PUBLISHER %q, expected %s or %s", kind, PublisherLog, PublisherHTTP)
+	}
+}
+
+// LogPublisher writes events to the log, for development and for deployments without consumers
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, envelope EventEnvelope) error {
+	log.Printf("event %s %s v%d for image %s: %s", envelope.Id.String(), envelope.Type, envelope.Version, envelope.ImageId.String(), envelope.Data)
+	return nil
+}
+
+// HTTPPublisher POSTs every event envelope as JSON to an endpoint, any 2xx acknowledges it. The event id is also sent
+// as Idempotency-Key so consumers can drop redeliveries.
+type HTTPPublisher struct {
+	Endpoint string
+	Client   *http.Client
+	// Token is sent as a bearer token when set
+	Token string
+}
+
+// NewHTTPPublisher posts events to the endpoint of the configuration
+func NewHTTPPublisher(cfg config.OutboxConfig) *HTTPPublisher {
+	return &HTTPPublisher{
+		Endpoint: cfg.HTTPURL,
+		Client:   &http.Client{Timeout: cfg.HTTPTimeout},
+		Token:    cfg.HTTPToken,
+	}
+}
+
+func (publisher *HTTPPublisher) Publish(ctx context.Context, envelope EventEnvelope) error {
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, publisher.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create event request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Idempotency-Key", envelope.Id.String())
+	if publisher.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+publisher.Token)
+	}
+
+	response, err := publisher.Client.Do(request)
+	if err != nil {
+		return fmt.Errorf("event request failed: %w", err)
+	}
+	defer response.Body.Close()
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("event endpoint returned %s", response.Status)
+	}
+	return nil
+}
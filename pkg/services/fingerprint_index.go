@@ -0,0 +1,422 @@
+package services
+
+import (
+	"bit-image/internal/s3"
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage/image"
+	"context"
+	"fmt"
+	goimage "image"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// fingerprintLoadBatchSize is how many fingerprints are read from Postgres at once when the index is rebuilt
+	fingerprintLoadBatchSize = 5000
+	// maxHashDistance is the largest distance a search accepts, half of the bits of a fingerprint
+	maxHashDistance = 32
+)
+
+// hash kinds a similarity search can use
+const (
+	HashDHash = "dhash"
+	HashPHash = "phash"
+)
+
+// fingerprintEntry is an image in the index
+type fingerprintEntry struct {
+	Id              uuid.UUID
+	OwnerId         string
+	IsPrivate       bool
+	DateTimeCreated time.Time
+}
+
+// fingerprintMatch is an image found by a search along with its distance from the searched fingerprint
+type fingerprintMatch struct {
+	Entry    fingerprintEntry
+	Distance int
+}
+
+// bkNode is a node of a BK-tree over Hamming distance. Images sharing a fingerprint share a node, the children of a
+// node are keyed by their distance from it.
+type bkNode struct {
+	hash     uint64
+	entries  []fingerprintEntry
+	children map[int]*bkNode
+}
+
+// bkTree finds every fingerprint within a distance of another without comparing it to all of them. Removing an image
+// leaves its node in place to keep the tree valid, an emptied node only routes searches.
+type bkTree struct {
+	root *bkNode
+}
+
+func (tree *bkTree) add(hash uint64, entry fingerprintEntry) {
+	if tree.root == nil {
+		tree.root = &bkNode{hash: hash, entries: []fingerprintEntry{entry}}
+		return
+	}
+	node := tree.root
+	for {
+		distance := hammingDistance(node.hash, hash)
+		if distance == 0 {
+			node.entries = append(node.entries, entry)
+			return
+		}
+		child, ok := node.children[distance]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[distance] = &bkNode{hash: hash, entries: []fingerprintEntry{entry}}
+			return
+		}
+		node = child
+	}
+}
+
+func (tree *bkTree) remove(hash uint64, imageId uuid.UUID) {
+	node := tree.root
+	for node != nil {
+		distance := hammingDistance(node.hash, hash)
+		if distance == 0 {
+			for i, entry := range node.entries {
+				if entry.Id == imageId {
+					node.entries = append(node.entries[:i], node.entries[i+1:]...)
+					return
+				}
+			}
+			return
+		}
+		node = node.children[distance]
+	}
+}
+
+// search calls fn with every entry whose fingerprint is within maxDistance of hash. By the triangle inequality, only
+// children whose distance from their parent is within maxDistance of the parent's own distance can hold matches.
+func (tree *bkTree) search(hash uint64, maxDistance int, fn func(entry fingerprintEntry, distance int)) {
+	if tree.root == nil {
+		return
+	}
+	pending := []*bkNode{tree.root}
+	for len(pending) > 0 {
+		node := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		distance := hammingDistance(node.hash, hash)
+		if distance <= maxDistance {
+			for _, entry := range node.entries {
+				fn(entry, distance)
+			}
+		}
+		for childDistance, child := range node.children {
+			if childDistance >= distance-maxDistance && childDistance <= distance+maxDistance {
+				pending = append(pending, child)
+			}
+		}
+	}
+}
+
+// FingerprintIndex keeps the fingerprints of every live image in memory, one BK-tree per hash kind. It is rebuilt
+// from Postgres on startup and every RefreshInterval, which also picks up changes made by other instances, and kept
+// up to date with the images confirmed and deleted in between.
+type FingerprintIndex struct {
+	S3Handler       *s3.Handler
+	ImageStore      *image.ImageStore
+	RefreshInterval time.Duration
+
+	mu     sync.RWMutex
+	loaded bool
+	trees  map[string]*bkTree
+	// images are the entries and fingerprints of every indexed image, byOwner their ids grouped by owner
+	images  map[uuid.UUID]indexedImage
+	byOwner map[string]map[uuid.UUID]struct{}
+}
+
+type indexedImage struct {
+	entry       fingerprintEntry
+	fingerprint imageFingerprint
+}
+
+func NewFingerprintIndex(store *image.ImageStore, s3Handler *s3.Handler, cfg *config.Config) *FingerprintIndex {
+	return &FingerprintIndex{
+		S3Handler:       s3Handler,
+		ImageStore:      store,
+		RefreshInterval: cfg.Fingerprints.RefreshInterval,
+	}
+}
+
+// Load rebuilds the index from the fingerprints stored in Postgres. Searches keep using the previous index until the
+// new one is complete.
+func (index *FingerprintIndex) Load() error {
+	rebuilt := &FingerprintIndex{
+		trees:   map[string]*bkTree{HashDHash: {}, HashPHash: {}},
+		images:  make(map[uuid.UUID]indexedImage),
+		byOwner: make(map[string]map[uuid.UUID]struct{}),
+	}
+
+	after := uuid.Nil
+	for {
+		fingerprints, err := index.ImageStore.ListFingerprints(after, fingerprintLoadBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, stored := range fingerprints {
+			rebuilt.addLocked(stored)
+		}
+		if len(fingerprints) < fingerprintLoadBatchSize {
+			break
+		}
+		after = fingerprints[len(fingerprints)-1].Id
+	}
+
+	index.mu.Lock()
+	index.trees, index.images, index.byOwner, index.loaded = rebuilt.trees, rebuilt.images, rebuilt.byOwner, true
+	index.mu.Unlock()
+	return nil
+}
+
+// Run rebuilds the index on every tick until the context is cancelled
+func (index *FingerprintIndex) Run(ctx context.Context) {
+	ticker := time.NewTicker(index.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := index.Load(); err != nil {
+			log.Printf("failed to rebuild fingerprint index: %v", err)
+		}
+	}
+}
+
+// ensureLoaded builds the index on first use when it wasn't loaded on startup
+func (index *FingerprintIndex) ensureLoaded() error {
+	index.mu.RLock()
+	loaded := index.loaded
+	index.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+	return index.Load()
+}
+
+// Add indexes an image, replacing what was indexed for it before
+func (index *FingerprintIndex) Add(stored image.Fingerprint) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if !index.loaded {
+		// the image is picked up by the first Load
+		return
+	}
+	index.removeLocked(stored.Id)
+	index.addLocked(stored)
+}
+
+func (index *FingerprintIndex) addLocked(stored image.Fingerprint) {
+	indexed := indexedImage{
+		entry:       fingerprintEntry{Id: stored.Id, OwnerId: stored.OwnerId, IsPrivate: stored.IsPrivate, DateTimeCreated: stored.DateTimeCreated},
+		fingerprint: imageFingerprint{DHash: uint64(stored.DHash), PHash: uint64(stored.PHash)},
+	}
+	index.trees[HashDHash].add(indexed.fingerprint.DHash, indexed.entry)
+	index.trees[HashPHash].add(indexed.fingerprint.PHash, indexed.entry)
+	index.images[stored.Id] = indexed
+	if index.byOwner[stored.OwnerId] == nil {
+		index.byOwner[stored.OwnerId] = make(map[uuid.UUID]struct{})
+	}
+	index.byOwner[stored.OwnerId][stored.Id] = struct{}{}
+}
+
+// Remove drops images from the index
+func (index *FingerprintIndex) Remove(imageIds []uuid.UUID) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	for _, imageId := range imageIds {
+		index.removeLocked(imageId)
+	}
+}
+
+func (index *FingerprintIndex) removeLocked(imageId uuid.UUID) {
+	indexed, ok := index.images[imageId]
+	if !ok {
+		return
+	}
+	index.trees[HashDHash].remove(indexed.fingerprint.DHash, imageId)
+	index.trees[HashPHash].remove(indexed.fingerprint.PHash, imageId)
+	delete(index.images, imageId)
+	delete(index.byOwner[indexed.entry.OwnerId], imageId)
+	if len(index.byOwner[indexed.entry.OwnerId]) == 0 {
+		delete(index.byOwner, indexed.entry.OwnerId)
+	}
+}
+
+func (indexed indexedImage) hash(kind string) uint64 {
+	if kind == HashDHash {
+		return indexed.fingerprint.DHash
+	}
+	return indexed.fingerprint.PHash
+}
+
+// Search returns the indexed images within maxDistance of the image's fingerprint that keep returns true for, the
+// image itself left out. ok is false when the image isn't indexed.
+func (index *FingerprintIndex) Search(imageId uuid.UUID, kind string, maxDistance int, keep func(entry fingerprintEntry) bool) ([]fingerprintMatch, bool, error) {
+	if err := index.ensureLoaded(); err != nil {
+		return nil, false, err
+	}
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	indexed, ok := index.images[imageId]
+	if !ok {
+		return nil, false, nil
+	}
+
+	var matches []fingerprintMatch
+	index.trees[kind].search(indexed.hash(kind), maxDistance, func(entry fingerprintEntry, distance int) {
+		if entry.Id != imageId && keep(entry) {
+			matches = append(matches, fingerprintMatch{Entry: entry, Distance: distance})
+		}
+	})
+	return matches, true, nil
+}
+
+// DuplicateGroups groups the user's images that are within maxDistance of each other, directly or through other
+// images of the group. Only groups of two images or more are returned, every group oldest first.
+func (index *FingerprintIndex) DuplicateGroups(UserId string, kind string, maxDistance int) ([][]fingerprintEntry, error) {
+	if err := index.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	// union-find over the user's images, parents point towards the representative of a group
+	parents := make(map[uuid.UUID]uuid.UUID, len(index.byOwner[UserId]))
+	var find func(id uuid.UUID) uuid.UUID
+	find = func(id uuid.UUID) uuid.UUID {
+		parent, ok := parents[id]
+		if !ok || parent == id {
+			return id
+		}
+		root := find(parent)
+		parents[id] = root
+		return root
+	}
+
+	for imageId := range index.byOwner[UserId] {
+		index.trees[kind].search(index.images[imageId].hash(kind), maxDistance, func(entry fingerprintEntry, _ int) {
+			if entry.OwnerId != UserId || entry.Id == imageId {
+				return
+			}
+			if first, second := find(imageId), find(entry.Id); first != second {
+				parents[first] = second
+			}
+		})
+	}
+
+	// only images that were merged into a group have a parent, representatives are added to their group separately
+	members := make(map[uuid.UUID][]fingerprintEntry)
+	for imageId := range parents {
+		root := find(imageId)
+		members[root] = append(members[root], index.images[imageId].entry)
+	}
+	for root := range members {
+		members[root] = append(members[root], index.images[root].entry)
+	}
+
+	groups := make([][]fingerprintEntry, 0, len(members))
+	for _, group := range members {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].DateTimeCreated.Before(group[j].DateTimeCreated)
+		})
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0].DateTimeCreated.Before(groups[j][0].DateTimeCreated)
+	})
+	return groups, nil
+}
+
+// BackfillReport is the outcome of fingerprinting the images stored before fingerprints existed
+type BackfillReport struct {
+	Fingerprinted int      `json:"fingerprinted"`
+	Failed        []string `json:"failed"`
+}
+
+// Backfill fingerprints the images that don't have fingerprints yet. Images whose pixels can't be decoded are
+// reported and left without, they are tried again on the next backfill.
+func (index *FingerprintIndex) Backfill() (*BackfillReport, error) {
+	report := &BackfillReport{Failed: []string{}}
+	attempted := make(map[uuid.UUID]struct{})
+	for {
+		pending, err := index.ImageStore.ListUnfingerprintedImages(len(attempted) + fingerprintLoadBatchSize)
+		if err != nil {
+			return report, err
+		}
+		progressed := false
+		for _, pendingImage := range pending {
+			if _, ok := attempted[pendingImage.Base.Id]; ok {
+				continue
+			}
+			attempted[pendingImage.Base.Id] = struct{}{}
+			progressed = true
+
+			if err = index.fingerprintStored(pendingImage); err != nil {
+				log.Printf("failed to fingerprint image %s: %v", pendingImage.Base.Id.String(), err)
+				report.Failed = append(report.Failed, pendingImage.Base.Id.String())
+				continue
+			}
+			report.Fingerprinted++
+		}
+		if !progressed {
+			return report, nil
+		}
+	}
+}
+
+// fingerprintStored decodes a stored image, records its fingerprints and indexes it
+func (index *FingerprintIndex) fingerprintStored(storedImage entities.Image) error {
+	body, err := index.S3Handler.GetObject(storedImage.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read image %s: %w", storedImage.Base.Id.String(), err)
+	}
+	defer body.Close()
+	decoded, _, err := goimage.Decode(body)
+	if err != nil {
+		return fmt.Errorf("failed to decode image %s: %w", storedImage.Base.Id.String(), err)
+	}
+
+	fingerprint := fingerprintImage(decoded)
+	dHash, pHash := int64(fingerprint.DHash), int64(fingerprint.PHash)
+	if err = index.ImageStore.SetFingerprint(storedImage.Base.Id, dHash, pHash); err != nil {
+		return err
+	}
+	storedImage.ImageMetaData.DHash, storedImage.ImageMetaData.PHash = &dHash, &pHash
+	if stored, ok := imageFingerprintOf(storedImage); ok {
+		index.Add(stored)
+	}
+	return nil
+}
+
+// imageFingerprintOf is what the index keeps of an image, ok is false when the image has no fingerprints
+func imageFingerprintOf(storedImage entities.Image) (image.Fingerprint, bool) {
+	if storedImage.ImageMetaData.DHash == nil || storedImage.ImageMetaData.PHash == nil {
+		return image.Fingerprint{}, false
+	}
+	return image.Fingerprint{
+		Id:              storedImage.Base.Id,
+		OwnerId:         storedImage.OwnerId,
+		IsPrivate:       storedImage.IsPrivate,
+		DHash:           *storedImage.ImageMetaData.DHash,
+		PHash:           *storedImage.ImageMetaData.PHash,
+		DateTimeCreated: storedImage.Base.DateTimeCreated,
+	}, true
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bit-image/pkg/storage/image"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// searchTree returns the ids found by a search along with their distances
+func searchTree(tree *bkTree, hash uint64, maxDistance int) map[uuid.UUID]int {
+	found := make(map[uuid.UUID]int)
+	tree.search(hash, maxDistance, func(entry fingerprintEntry, distance int) {
+		found[entry.Id] = distance
+	})
+	return found
+}
+
+func TestBKTreeSearchMatchesBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	base := random.Uint64()
+	hashes := make(map[uuid.UUID]uint64)
+	tree := &bkTree{}
+	for i := 0; i < 500; i++ {
+		// near copies of a few bases make the tree deep, random hashes make it wide
+		hash := random.Uint64()
+		if i%2 == 0 {
+			hash = base ^ (uint64(1) << random.Intn(64)) ^ (uint64(1) << random.Intn(64))
+		}
+		id := uuid.New()
+		hashes[id] = hash
+		tree.add(hash, fingerprintEntry{Id: id})
+	}
+
+	tests := []struct {
+		name        string
+		hash        uint64
+		maxDistance int
+	}{
+		{"exact", base, 0},
+		{"near copies", base, 4},
+		{"random hash", random.Uint64(), 10},
+		{"half of the bits", base, maxHashDistance},
+		{"everything", base, 64},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := make(map[uuid.UUID]int)
+			for id, hash := range hashes {
+				if distance := hammingDistance(hash, test.hash); distance <= test.maxDistance {
+					want[id] = distance
+				}
+			}
+			got := searchTree(tree, test.hash, test.maxDistance)
+			if len(got) != len(want) {
+				t.Fatalf("search() found %d images, want %d", len(got), len(want))
+			}
+			for id, distance := range want {
+				if got[id] != distance {
+					t.Errorf("search() distance of %s = %d, want %d", id, got[id], distance)
+				}
+			}
+		})
+	}
+}
+
+func TestBKTreeRemove(t *testing.T) {
+	root, child, grandchild := uuid.New(), uuid.New(), uuid.New()
+	twin := uuid.New()
+	tests := []struct {
+		name   string
+		remove []uuid.UUID
+		want   []uuid.UUID
+	}{
+		{"nothing removed", nil, []uuid.UUID{root, twin, child, grandchild}},
+		{"one of two images sharing a fingerprint", []uuid.UUID{twin}, []uuid.UUID{root, child, grandchild}},
+		{"emptied root still routes searches", []uuid.UUID{root, twin}, []uuid.UUID{child, grandchild}},
+		{"emptied inner node still routes searches", []uuid.UUID{child}, []uuid.UUID{root, twin, grandchild}},
+		{"unknown image", []uuid.UUID{uuid.New()}, []uuid.UUID{root, twin, child, grandchild}},
+	}
+	hashes := map[uuid.UUID]uint64{root: 0, twin: 0, child: 0b1, grandchild: 0b11}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree := &bkTree{}
+			for _, id := range []uuid.UUID{root, twin, child, grandchild} {
+				tree.add(hashes[id], fingerprintEntry{Id: id})
+			}
+			for _, id := range test.remove {
+				tree.remove(hashes[id], id)
+			}
+			got := searchTree(tree, 0, 64)
+			if len(got) != len(test.want) {
+				t.Fatalf("search() found %v, want %v", got, test.want)
+			}
+			for _, id := range test.want {
+				if _, ok := got[id]; !ok {
+					t.Errorf("search() didn't find %s", id)
+				}
+			}
+		})
+	}
+}
+
+func TestBKTreeSearchEmpty(t *testing.T) {
+	if got := searchTree(&bkTree{}, 42, 64); len(got) != 0 {
+		t.Errorf("search() on an empty tree found %v", got)
+	}
+}
+
+func TestDuplicateGroups(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ids := make([]uuid.UUID, 6)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+	index := &FingerprintIndex{
+		loaded:  true,
+		trees:   map[string]*bkTree{HashDHash: {}, HashPHash: {}},
+		images:  make(map[uuid.UUID]indexedImage),
+		byOwner: make(map[string]map[uuid.UUID]struct{}),
+	}
+	for i, stored := range []struct {
+		owner string
+		hash  int64
+	}{
+		// 0 and 2 are too far apart but both close to 1, so the three form one group
+		{"owner", 0b0000},
+		{"owner", 0b0011},
+		{"owner", 0b1111},
+		{"owner", 0x7f00000000000000},
+		// a copy belonging to another user doesn't group with the owner's images
+		{"other", 0x7f00000000000000},
+		{"other", 0x7f00000000000001},
+	} {
+		index.addLocked(image.Fingerprint{
+			Id: ids[i], OwnerId: stored.owner, DHash: stored.hash, PHash: stored.hash,
+			DateTimeCreated: start.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	tests := []struct {
+		name        string
+		owner       string
+		maxDistance int
+		want        [][]uuid.UUID
+	}{
+		{"chained through a middle image", "owner", 2, [][]uuid.UUID{{ids[0], ids[1], ids[2]}}},
+		{"too strict to group anything", "owner", 1, [][]uuid.UUID{}},
+		{"other user", "other", 1, [][]uuid.UUID{{ids[4], ids[5]}}},
+		{"unknown user", "nobody", 64, [][]uuid.UUID{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			groups, err := index.DuplicateGroups(test.owner, HashDHash, test.maxDistance)
+			if err != nil {
+				t.Fatalf("DuplicateGroups() error = %v", err)
+			}
+			got := make([][]uuid.UUID, 0, len(groups))
+			for _, group := range groups {
+				var groupIds []uuid.UUID
+				for _, entry := range group {
+					groupIds = append(groupIds, entry.Id)
+				}
+				got = append(got, groupIds)
+			}
+			if !slices.EqualFunc(got, test.want, slices.Equal[[]uuid.UUID]) {
+				t.Errorf("DuplicateGroups() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
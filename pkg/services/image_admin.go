@@ -0,0 +1,413 @@
+package services
+
+import (
+	"bit-image/internal/postrges"
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/storage"
+	"bit-image/pkg/storage/image"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AdminImage is what operators see of an image of any owner, soft-deleted and broken ones included
+type AdminImage struct {
+	Id              uuid.UUID  `json:"id"`
+	OwnerId         string     `json:"owner_id"`
+	Name            string     `json:"name"`
+	Format          string     `json:"format"`
+	FileSize        float64    `json:"file_size"`
+	IsPrivate       bool       `json:"is_private"`
+	Path            string     `json:"path"`
+	BlobId          *uuid.UUID `json:"blob_id,omitempty"`
+	Broken          bool       `json:"broken,omitempty"`
+	BrokenReason    string     `json:"broken_reason,omitempty"`
+	DateTimeCreated time.Time  `json:"date_time_created"`
+	DateTimeDeleted *time.Time `json:"date_time_deleted,omitempty"`
+}
+
+// AdminImageList is a page of images of any owner, NextCursor is empty on the last page
+type AdminImageList struct {
+	Images     []AdminImage `json:"images"`
+	NextCursor string       `json:"next_cursor"`
+}
+
+// AdminImageDetails is an image along with the state of its background processing
+type AdminImageDetails struct {
+	AdminImage
+	Derivatives   []AdminDerivative `json:"derivatives"`
+	Labeling      *AdminLabelingJob `json:"labeling,omitempty"`
+	Fingerprinted bool              `json:"fingerprinted"`
+}
+
+// AdminDerivative is a derivative row whatever its status
+type AdminDerivative struct {
+	Size      int    `json:"size"`
+	Format    string `json:"format"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// AdminLabelingJob is the machine labeling job of an image
+type AdminLabelingJob struct {
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// ReprocessReport is what running the background processing of an image again did
+type ReprocessReport struct {
+	ImageId       uuid.UUID `json:"image_id"`
+	Derivatives   int       `json:"derivatives"`
+	Labeled       bool      `json:"labeled"`
+	Fingerprinted bool      `json:"fingerprinted"`
+	Errors        []string  `json:"errors"`
+}
+
+// StatsReport is a snapshot of the database pool, the tables and, when listed, the bucket
+type StatsReport struct {
+	Pool          *postrges.PoolStats `json:"pool"`
+	Users         int64               `json:"users"`
+	Images        *image.ImageCounts  `json:"images"`
+	Uploads       map[string]int64    `json:"uploads"`
+	Derivatives   map[string]int64    `json:"derivatives"`
+	Labeling      map[string]int64    `json:"labeling"`
+	PendingEvents int64               `json:"pending_events"`
+	Storage       []FolderStats       `json:"storage,omitempty"`
+}
+
+// FolderStats is how many objects a storage folder holds and their total size
+type FolderStats struct {
+	Folder  string `json:"folder"`
+	Objects int64  `json:"objects"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// storageFolders are the folders listed by CollectStats
+var storageFolders = []string{
+	common.TEMPORARY_STORAGE_FOLDER,
+	common.PERMANENT_STORAGE_FOLDER,
+	common.BLOB_STORAGE_FOLDER,
+	common.DERIVED_STORAGE_FOLDER,
+	common.QUARANTINE_STORAGE_FOLDER,
+}
+
+func adminImage(storedImage entities.Image) AdminImage {
+	listed := AdminImage{
+		Id:              storedImage.Base.Id,
+		OwnerId:         storedImage.OwnerId,
+		Name:            storedImage.Name,
+		Format:          storedImage.ImageMetaData.Format,
+		FileSize:        storedImage.ImageMetaData.FileSize,
+		IsPrivate:       storedImage.IsPrivate,
+		Path:            storedImage.Path,
+		BlobId:          storedImage.BlobId,
+		Broken:          storedImage.BrokenAt != nil,
+		BrokenReason:    storedImage.BrokenReason,
+		DateTimeCreated: storedImage.Base.DateTimeCreated,
+	}
+	if storedImage.DateTimeDeleted.Valid {
+		listed.DateTimeDeleted = &storedImage.DateTimeDeleted.Time
+	}
+	return listed
+}
+
+// ListAllImages returns a page of the images of every owner matching the filter, newest first
+func (svc *ImageService) ListAllImages(filter image.AdminImageFilter, cursor string, limit int) (*AdminImageList, error) {
+	var after *image.ImageCursor
+	if cursor != "" {
+		decoded, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = decoded
+	}
+
+	limit = pageSize(limit)
+	storedImages, err := svc.ImageStore.ListAllImages(filter, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &AdminImageList{Images: make([]AdminImage, 0, len(storedImages))}
+	for _, storedImage := range storedImages {
+		list.Images = append(list.Images, adminImage(storedImage))
+	}
+	if len(storedImages) == limit {
+		last := storedImages[len(storedImages)-1]
+		list.NextCursor = encodeCursor(image.ImageCursor{DateTimeCreated: last.Base.DateTimeCreated, Id: last.Base.Id})
+	}
+	return list, nil
+}
+
+// InspectImage returns an image whoever owns it, soft-deleted or not, with the state of its derivatives and labeling
+func (svc *ImageService) InspectImage(imageId uuid.UUID) (*AdminImageDetails, error) {
+	storedImage, err := svc.ImageStore.GetImageIncludingDeleted(imageId)
+	if err != nil {
+		return nil, err
+	}
+	derivatives, err := svc.DerivativeStore.ListDerivatives(imageId)
+	if err != nil {
+		return nil, err
+	}
+	job, err := svc.LabelStore.GetLabelingJob(imageId)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &AdminImageDetails{AdminImage: adminImage(*storedImage), Derivatives: make([]AdminDerivative, 0, len(derivatives))}
+	for _, derivative := range derivatives {
+		details.Derivatives = append(details.Derivatives, AdminDerivative{
+			Size:      derivative.Size,
+			Format:    derivative.Format,
+			Status:    derivative.Status,
+			Attempts:  derivative.Attempts,
+			LastError: derivative.LastError,
+		})
+	}
+	if job != nil {
+		details.Labeling = &AdminLabelingJob{Status: job.Status, Attempts: job.Attempts, LastError: job.LastError}
+	}
+	_, details.Fingerprinted = imageFingerprintOf(*storedImage)
+	return details, nil
+}
+
+// ForceDeleteImages soft-deletes images whoever owns them and returns the ids of the ones that were deleted. Unlike
+// DeleteImages the stored objects are left to the ImagePurger, so the images can be restored until the retention
+// window has passed. Deduplicated images hold on to their blob reference until they are purged for the same reason.
+func (svc *ImageService) ForceDeleteImages(imageIds []uuid.UUID) ([]uuid.UUID, error) {
+	storedImages, err := svc.ImageStore.GetImagesByIds(imageIds)
+	if err != nil {
+		return nil, err
+	}
+	if len(storedImages) == 0 {
+		return nil, ErrImageNotFound
+	}
+
+	deletedIds := make([]uuid.UUID, 0, len(storedImages))
+	byOwner := make(map[string][]entities.Image)
+	for _, storedImage := range storedImages {
+		deletedIds = append(deletedIds, storedImage.Base.Id)
+		byOwner[storedImage.OwnerId] = append(byOwner[storedImage.OwnerId], storedImage)
+	}
+
+	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	err = svc.ImageStore.SoftDeleteImagesWithTransaction(tx, deletedIds)
+	for ownerId, owned := range byOwner {
+		if err != nil {
+			break
+		}
+		err = svc.releaseQuotaWithTransaction(tx, ownerId, owned)
+	}
+	if err == nil {
+		err = addImageDeletedEventsWithTransaction(tx, svc.OutboxStore, storedImages)
+	}
+	if err == nil {
+		err = commit()
+	}
+	if err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return nil, err
+	}
+
+	svc.Fingerprints.Remove(deletedIds)
+	svc.Events.Notify()
+	return deletedIds, nil
+}
+
+// RestoreImage brings back a soft-deleted image that hasn't been purged yet, charging it to its owner's quota again,
+// and runs its background processing again since its derivatives may have been removed along with it. Restored
+// images are announced with a new image.confirmed event.
+func (svc *ImageService) RestoreImage(imageId uuid.UUID) (*ReprocessReport, error) {
+	storedImage, err := svc.ImageStore.GetImageIncludingDeleted(imageId)
+	if err != nil {
+		return nil, err
+	}
+	if !storedImage.DateTimeDeleted.Valid {
+		return nil, fmt.Errorf("failed to restore image %s: %w", imageId.String(), ErrImageNotDeleted)
+	}
+	if storedImage.BlobId == nil {
+		exists, err := svc.objectExists(storedImage.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("failed to restore image %s: %w", imageId.String(), ErrImageContentGone)
+		}
+	}
+
+	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	err = svc.restoreImageWithTransaction(tx, *storedImage)
+	if err == nil {
+		err = commit()
+	}
+	if err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return nil, err
+	}
+
+	svc.Events.Notify()
+	return svc.process(*storedImage), nil
+}
+
+func (svc *ImageService) restoreImageWithTransaction(tx *gorm.DB, storedImage entities.Image) error {
+	imageId := storedImage.Base.Id
+	restored, err := svc.ImageStore.RestoreImageWithTransaction(tx, imageId)
+	if err != nil {
+		return err
+	}
+	if !restored {
+		return fmt.Errorf("failed to restore image %s: %w", imageId.String(), ErrImageNotFound)
+	}
+	// images deleted by their owner gave up their blob, it can only be taken again while another image references it
+	if storedImage.BlobId != nil && storedImage.BlobReleased {
+		acquired, err := svc.BlobStore.AcquireBlobWithTransaction(tx, *storedImage.BlobId)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			return fmt.Errorf("failed to restore image %s: %w", imageId.String(), ErrImageContentGone)
+		}
+	}
+	userId, err := parseUserId(storedImage.OwnerId)
+	if err != nil {
+		return err
+	}
+	if err = svc.UserStore.ConsumeQuotaWithTransaction(tx, userId, 1, int64(storedImage.ImageMetaData.FileSize)); err != nil {
+		return fmt.Errorf("failed to restore image %s: %w", imageId.String(), err)
+	}
+	if err = svc.requeueProcessingWithTransaction(tx, storedImage); err != nil {
+		return err
+	}
+	confirmed, err := imageConfirmedEvent(storedImage)
+	if err != nil {
+		return err
+	}
+	return svc.OutboxStore.AddEventsWithTransaction(tx, []entities.OutboxEvent{confirmed})
+}
+
+// ReprocessImage generates the derivatives of an image from scratch, labels it again and recomputes its fingerprints.
+// The work is done before returning rather than left to the background workers.
+func (svc *ImageService) ReprocessImage(imageId uuid.UUID) (*ReprocessReport, error) {
+	storedImage, err := svc.ImageStore.GetImageById(imageId)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	err = svc.requeueProcessingWithTransaction(tx, *storedImage)
+	if err == nil {
+		err = commit()
+	}
+	if err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return nil, err
+	}
+	return svc.process(*storedImage), nil
+}
+
+// requeueProcessingWithTransaction queues the derivatives and machine labeling of an image again
+func (svc *ImageService) requeueProcessingWithTransaction(tx *gorm.DB, storedImage entities.Image) error {
+	err := svc.DerivativeStore.ReplaceDerivativesWithTransaction(tx, storedImage.Base.Id, svc.Derivatives.newDerivatives(storedImage))
+	if err != nil {
+		return err
+	}
+	if svc.AutoLabeler.Enabled() {
+		return svc.LabelStore.RequeueLabelingJobWithTransaction(tx, storedImage.Base.Id)
+	}
+	return nil
+}
+
+// process runs the queued background work of an image right away, failures are reported and left to the workers
+func (svc *ImageService) process(storedImage entities.Image) *ReprocessReport {
+	imageId := storedImage.Base.Id
+	report := &ReprocessReport{ImageId: imageId, Errors: []string{}}
+
+	generated, err := svc.Derivatives.GenerateImage(imageId)
+	report.Derivatives = generated
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	} else if expected := len(svc.Derivatives.newDerivatives(storedImage)); generated < expected {
+		report.Errors = append(report.Errors, fmt.Sprintf("%d of %d derivatives failed, they will be retried", expected-generated, expected))
+	}
+
+	if svc.AutoLabeler.Enabled() {
+		if report.Labeled, err = svc.AutoLabeler.LabelImage(imageId); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		} else if !report.Labeled {
+			report.Errors = append(report.Errors, "labeling failed, it will be retried")
+		}
+	}
+
+	if err = svc.Fingerprints.fingerprintStored(storedImage); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	} else {
+		report.Fingerprinted = true
+	}
+	return report
+}
+
+// CollectStats gathers the state of the database pool and the row counts of every table worth watching. Listing the
+// bucket walks every object, so it is only done when listObjects is set.
+func (svc *ImageService) CollectStats(listObjects bool) (*StatsReport, error) {
+	var report StatsReport
+	var err error
+	if report.Pool, err = svc.ImageStore.DBHandler.PoolStats(); err != nil {
+		return nil, err
+	}
+	if report.Users, err = svc.UserStore.CountUsers(); err != nil {
+		return nil, err
+	}
+	if report.Images, err = svc.ImageStore.CountImages(); err != nil {
+		return nil, err
+	}
+	if report.Uploads, err = svc.UploadStore.CountUploadsByState(); err != nil {
+		return nil, err
+	}
+	if report.Derivatives, err = svc.DerivativeStore.CountDerivativesByStatus(); err != nil {
+		return nil, err
+	}
+	if report.Labeling, err = svc.LabelStore.CountLabelingJobsByStatus(); err != nil {
+		return nil, err
+	}
+	if report.PendingEvents, err = svc.OutboxStore.CountPendingEvents(); err != nil {
+		return nil, err
+	}
+	if !listObjects {
+		return &report, nil
+	}
+
+	for _, folder := range storageFolders {
+		stats := FolderStats{Folder: folder}
+		err = svc.S3Handler.ListObjects(folder+"/", func(object storage.ObjectInfo) error {
+			stats.Objects++
+			stats.Bytes += object.Size
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", folder, err)
+		}
+		report.Storage = append(report.Storage, stats)
+	}
+	return &report, nil
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"bit-image/internal/s3"
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/storage/blob"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+)
+
+// Deduplication is scoped to a single owner. Sharing blobs across users would let anyone who knows the hash of a
+// file claim a copy of it without ever uploading the content.
+
+// maxHashLookups bounds a single "which of these do you already have" request
+const maxHashLookups = 1000
+
+// ExistingHashes returns which of the hex-encoded SHA-256 hashes the user has already stored. Uploads of those can
+// skip the PUT and go straight to confirmation.
+func (svc *ImageService) ExistingHashes(hashes []string, UserId string) ([]string, error) {
+	if len(hashes) > maxHashLookups {
+		return nil, fmt.Errorf("%w: at most %d hashes per lookup", ErrInvalidChecksum, maxHashLookups)
+	}
+	if !svc.DedupEnabled {
+		return []string{}, nil
+	}
+
+	normalized := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		checksum, err := parseChecksum(hash)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, checksum)
+	}
+	return svc.BlobStore.ExistingHashes(UserId, normalized)
+}
+
+// confirmWithoutUpload links an image whose content was never uploaded to the blob the owner already has for the
+// declared checksum. It returns blob.ErrBlobNotFound when there is no such blob.
+func (svc *ImageService) confirmWithoutUpload(newImage entities.Image, pendingUpload *entities.Upload) error {
+	existingBlob, err := svc.BlobStore.FindBlob(newImage.OwnerId, pendingUpload.ChecksumSHA256)
+	if err != nil {
+		return err
+	}
+
+	// there is no object to inspect, the metadata comes from an image already stored in the blob
+	storedImage, err := svc.ImageStore.GetImageByBlobId(existingBlob.Base.Id)
+	if err != nil {
+		return fmt.Errorf("failed to find metadata for blob %s: %w", existingBlob.Base.Id.String(), err)
+	}
+	newImage.ImageMetaData = storedImage.ImageMetaData
+	imageExif, err := svc.extractMetadata(&newImage, existingBlob.Path)
+	if err != nil {
+		return err
+	}
+
+	linked, err := svc.linkImageToBlob(newImage, existingBlob, imageExif)
+	if err != nil || !linked {
+		svc.discardPublicCopy(newImage)
+	}
+	if err != nil {
+		return err
+	}
+	if !linked {
+		return blob.ErrBlobNotFound
+	}
+	return nil
+}
+
+// confirmDeduplicatedImage stores the uploaded content as a blob of its owner, or links the image to the blob that
+// already holds the same content
+func (svc *ImageService) confirmDeduplicatedImage(newImage entities.Image, tempPath string, imageExif *entities.ImageExif) error {
+	// two attempts cover a blob being released or created concurrently between the lookup and the transaction
+	for attempt := 0; attempt < 2; attempt++ {
+		existingBlob, err := svc.BlobStore.FindBlob(newImage.OwnerId, newImage.ImageMetaData.Hash)
+		if err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			return err
+		}
+
+		var confirmed bool
+		if existingBlob != nil {
+			confirmed, err = svc.linkImageToBlob(newImage, existingBlob, imageExif)
+		} else {
+			confirmed, err = svc.storeImageAsBlob(newImage, tempPath, imageExif)
+		}
+		if err != nil {
+			return err
+		}
+		if confirmed {
+			if err = svc.S3Handler.DeleteObject(tempPath); err != nil {
+				log.Printf("failed to delete confirmed upload %s, it will be reaped: %v", tempPath, err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to deduplicate image with ID %s: blob changed concurrently", newImage.Base.Id.String())
+}
+
+// linkImageToBlob inserts the image as another reference to the blob. It reports false when the blob was released
+// before the reference could be taken.
+func (svc *ImageService) linkImageToBlob(newImage entities.Image, existingBlob *entities.Blob, imageExif *entities.ImageExif) (bool, error) {
+	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	acquired, err := svc.BlobStore.AcquireBlobWithTransaction(tx, existingBlob.Base.Id)
+	if err == nil && acquired {
+		newImage.Path = existingBlob.Path
+		newImage.BlobId = &existingBlob.Base.Id
+		err = svc.recordImageWithTransaction(tx, newImage, imageExif)
+	}
+	if err != nil || !acquired {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return false, err
+	}
+
+	if err = commit(); err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// storeImageAsBlob copies the upload to a new blob object and inserts both rows. It reports false when a
+// concurrent upload of the same content created the blob first.
+func (svc *ImageService) storeImageAsBlob(newImage entities.Image, tempPath string, imageExif *entities.ImageExif) (bool, error) {
+	blobId := uuid.New()
+	newBlob := entities.Blob{
+		Base:    common.Base{Id: blobId},
+		OwnerId: newImage.OwnerId,
+		Hash:    newImage.ImageMetaData.Hash,
+		Path:    common.BLOB_STORAGE_FOLDER + "/" + newImage.OwnerId + "/" + blobId.String(),
+		Size:    int64(newImage.ImageMetaData.FileSize),
+	}
+
+	// blob keys are unique per blob row, so the copy can always be removed again if the rows don't make it in
+	moving, err := svc.UploadStore.BeginUploadMove(newImage.Base.Id, newBlob.Path)
+	if err != nil {
+		return false, err
+	}
+	if !moving {
+		return false, fmt.Errorf("failed to confirm image with ID %s: %w", newImage.Base.Id.String(), ErrUploadInProgress)
+	}
+	if err = svc.S3Handler.CopyObject(tempPath, newBlob.Path); err != nil {
+		err = fmt.Errorf("failed to store blob for image with ID %s: %w", newImage.Base.Id.String(), err)
+		svc.abortUploadMove(newImage.OwnerId, newImage.Base.Id, newBlob.Path, err)
+		return false, err
+	}
+
+	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	if err != nil {
+		err = fmt.Errorf("failed to start transaction: %w", err)
+		svc.abortUploadMove(newImage.OwnerId, newImage.Base.Id, newBlob.Path, err)
+		return false, err
+	}
+
+	inserted, err := svc.BlobStore.InsertBlobWithTransaction(tx, &newBlob)
+	if err == nil && inserted {
+		newImage.Path = newBlob.Path
+		newImage.BlobId = &blobId
+		err = svc.recordImageWithTransaction(tx, newImage, imageExif)
+	}
+	if err == nil && inserted {
+		err = commit()
+	}
+	if err != nil || !inserted {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to save image metadata to database: %w", err)
+			svc.abortUploadMove(newImage.OwnerId, newImage.Base.Id, newBlob.Path, err)
+			return false, err
+		}
+		svc.abortUploadMove(newImage.OwnerId, newImage.Base.Id, newBlob.Path, errors.New("blob was created concurrently"))
+		return false, nil
+	}
+	return true, nil
+}
+
+// deleteReleasedBlobs removes the objects of blobs that lost their last reference, then their rows, and returns
+// how many blobs were removed
+func deleteReleasedBlobs(s3Handler *s3.Handler, blobStore *blob.BlobStore) (int, error) {
+	released, err := blobStore.ListReleasedBlobs(purgeBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted []uuid.UUID
+	for _, releasedBlob := range released {
+		if err = s3Handler.DeleteObject(releasedBlob.Path); err != nil {
+			log.Printf("failed to delete blob object %s: %v", releasedBlob.Path, err)
+			continue
+		}
+		deleted = append(deleted, releasedBlob.Base.Id)
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	if err = blobStore.DeleteReleasedBlobs(deleted); err != nil {
+		return 0, err
+	}
+	return len(deleted), nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/storage/outbox"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// image event types, the data of each is described by the version recorded with it
+const (
+	EventImageConfirmed = "image.confirmed"
+	EventImageUpdated   = "image.updated"
+	EventImageDeleted   = "image.deleted"
+)
+
+// imageEventVersion is the version of the data of every image event. It is bumped for a type when its data changes in
+// a way consumers could trip on, such as a removed or retyped field; adding fields keeps the version.
+const imageEventVersion = 1
+
+// what an image.updated event says was changed
+const (
+	ImageChangeTags   = "tags"
+	ImageChangeLabels = "labels"
+)
+
+// EventEnvelope is how events are published. Consumers dispatch on Type and Version before decoding Data, and may see
+// an event more than once: Id is stable across deliveries. Sequence grows with every event, the events of an image are
+// delivered in Sequence order.
+type EventEnvelope struct {
+	Id         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	Sequence   int64           `json:"sequence"`
+	ImageId    uuid.UUID       `json:"image_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// ImageConfirmedData is the data of image.confirmed
+type ImageConfirmedData struct {
+	Id        uuid.UUID `json:"id"`
+	OwnerId   string    `json:"owner_id"`
+	Name      string    `json:"name"`
+	Caption   string    `json:"caption,omitempty"`
+	IsPrivate bool      `json:"is_private"`
+	FileSize  float64   `json:"file_size"`
+	Format    string    `json:"format"`
+	MimeType  string    `json:"mime_type"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Hash      string    `json:"hash"`
+}
+
+// ImageUpdatedData is the data of image.updated, Changes lists what changed so consumers can fetch only that
+type ImageUpdatedData struct {
+	Id      uuid.UUID `json:"id"`
+	OwnerId string    `json:"owner_id"`
+	Changes []string  `json:"changes"`
+}
+
+// ImageDeletedData is the data of image.deleted
+type ImageDeletedData struct {
+	Id      uuid.UUID `json:"id"`
+	OwnerId string    `json:"owner_id"`
+}
+
+func imageConfirmedEvent(newImage entities.Image) (entities.OutboxEvent, error) {
+	return newImageEvent(EventImageConfirmed, newImage.Base.Id, ImageConfirmedData{
+		Id:        newImage.Base.Id,
+		OwnerId:   newImage.OwnerId,
+		Name:      newImage.Name,
+		Caption:   newImage.Caption,
+		IsPrivate: newImage.IsPrivate,
+		FileSize:  newImage.ImageMetaData.FileSize,
+		Format:    newImage.ImageMetaData.Format,
+		MimeType:  newImage.ImageMetaData.MimeType,
+		Width:     newImage.ImageMetaData.Width,
+		Height:    newImage.ImageMetaData.Height,
+		Hash:      newImage.ImageMetaData.Hash,
+	})
+}
+
+// addImageUpdatedEventsWithTransaction records that the images of an owner changed, in the transaction changing them
+func addImageUpdatedEventsWithTransaction(tx *gorm.DB, outboxStore *outbox.OutboxStore, ownerId string, imageIds []uuid.UUID, changes ...string) error {
+	events := make([]entities.OutboxEvent, 0, len(imageIds))
+	for _, imageId := range imageIds {
+		event, err := newImageEvent(EventImageUpdated, imageId, ImageUpdatedData{Id: imageId, OwnerId: ownerId, Changes: changes})
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+	return outboxStore.AddEventsWithTransaction(tx, events)
+}
+
+// addImageDeletedEventsWithTransaction records that images were deleted, in the transaction deleting them
+func addImageDeletedEventsWithTransaction(tx *gorm.DB, outboxStore *outbox.OutboxStore, deleted []entities.Image) error {
+	events := make([]entities.OutboxEvent, 0, len(deleted))
+	for _, deletedImage := range deleted {
+		event, err := newImageEvent(EventImageDeleted, deletedImage.Base.Id, ImageDeletedData{Id: deletedImage.Base.Id, OwnerId: deletedImage.OwnerId})
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+	return outboxStore.AddEventsWithTransaction(tx, events)
+}
+
+func newImageEvent(eventType string, imageId uuid.UUID, data any) (entities.OutboxEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return entities.OutboxEvent{}, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	now := time.Now()
+	return entities.OutboxEvent{
+		EventId:       uuid.New(),
+		ImageId:       imageId,
+		Type:          eventType,
+		Version:       imageEventVersion,
+		Payload:       string(payload),
+		Status:        entities.OutboxPending,
+		NextAttemptAt: now,
+		OccurredAt:    now,
+	}, nil
+}
+
+// eventEnvelope is how a stored event is published
+func eventEnvelope(event entities.OutboxEvent) EventEnvelope {
+	return EventEnvelope{
+		Id:         event.EventId,
+		Type:       event.Type,
+		Version:    event.Version,
+		Sequence:   event.Sequence,
+		ImageId:    event.ImageId,
+		OccurredAt: event.OccurredAt,
+		Data:       json.RawMessage(event.Payload),
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"bytes"
+	"fmt"
+	goimage "image"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	goexif "github.com/rwcarlsen/goexif/exif"
+)
+
+// exifTimeLayout is how EXIF writes capture times, without a zone
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// ExifDetails is the camera metadata of an image, only ever shown to its owner
+type ExifDetails struct {
+	Make         string     `json:"make,omitempty"`
+	Model        string     `json:"model,omitempty"`
+	LensModel    string     `json:"lens_model,omitempty"`
+	CapturedAt   *time.Time `json:"captured_at,omitempty"`
+	Orientation  int        `json:"orientation,omitempty"`
+	ExposureTime *float64   `json:"exposure_time,omitempty"`
+	FNumber      *float64   `json:"f_number,omitempty"`
+	ISO          *int       `json:"iso,omitempty"`
+	FocalLength  *float64   `json:"focal_length,omitempty"`
+	Latitude     *float64   `json:"latitude,omitempty"`
+	Longitude    *float64   `json:"longitude,omitempty"`
+	Altitude     *float64   `json:"altitude,omitempty"`
+}
+
+// embeddedMetadata is what confirmation learns from the metadata embedded in an upload
+type embeddedMetadata struct {
+	Exif *entities.ImageExif
+	// Stripped is the content without its metadata, nil when there was nothing to strip
+	Stripped []byte
+	// Fingerprint is nil when the pixels could not be decoded
+	Fingerprint *imageFingerprint
+}
+
+// readMetadata parses the EXIF of the object, fingerprints its pixels and, for images shown to other users, strips
+// every embedded metadata from a copy of it. Metadata that can't be parsed is skipped rather than failing the upload.
+func (svc *ImageService) readMetadata(key string, format string, strip bool) (*embeddedMetadata, error) {
+	body, err := svc.S3Handler.GetObject(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+
+	metadata := &embeddedMetadata{}
+	block, err := findExif(content, format)
+	if err != nil {
+		log.Printf("failed to find exif metadata of %s: %v", key, err)
+	}
+	if block != nil {
+		metadata.Exif = parseExif(block)
+	}
+
+	// the header was checked against MAX_IMAGE_PIXELS on confirmation, so decoding the pixels is safe
+	if decoded, _, err := goimage.Decode(bytes.NewReader(content)); err != nil {
+		log.Printf("failed to decode %s for its fingerprint: %v", key, err)
+	} else {
+		fingerprint := fingerprintImage(decoded)
+		metadata.Fingerprint = &fingerprint
+	}
+
+	if strip {
+		orientation := 0
+		if metadata.Exif != nil {
+			orientation = metadata.Exif.Orientation
+		}
+		stripped, changed, err := stripMetadata(content, format, orientation)
+		if err != nil {
+			// the copy can't be served without knowing that nothing leaks
+			return nil, fmt.Errorf("%w: failed to strip metadata of %s: %v", ErrInvalidImage, key, err)
+		}
+		if changed {
+			metadata.Stripped = stripped
+		}
+	}
+	return metadata, nil
+}
+
+// extractMetadata parses the EXIF of the content stored at key for the new image and sets its fingerprints. Public
+// images that carry metadata get a stripped copy under their derived prefix, which becomes their PublicPath.
+func (svc *ImageService) extractMetadata(newImage *entities.Image, key string) (*entities.ImageExif, error) {
+	metadata, err := svc.readMetadata(key, newImage.ImageMetaData.Format, !newImage.IsPrivate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata of image with ID %s: %w", newImage.Base.Id.String(), err)
+	}
+
+	if metadata.Fingerprint != nil {
+		dHash, pHash := int64(metadata.Fingerprint.DHash), int64(metadata.Fingerprint.PHash)
+		newImage.ImageMetaData.DHash, newImage.ImageMetaData.PHash = &dHash, &pHash
+	}
+	if metadata.Stripped != nil {
+		publicPath := derivedPrefix(newImage.OwnerId, newImage.Base.Id) + "public." + newImage.ImageMetaData.Format
+		if err = svc.S3Handler.PutObject(publicPath, bytes.NewReader(metadata.Stripped), newImage.ImageMetaData.MimeType); err != nil {
+			return nil, fmt.Errorf("failed to store public copy of image with ID %s: %w", newImage.Base.Id.String(), err)
+		}
+		newImage.PublicPath = publicPath
+	}
+	if metadata.Exif != nil {
+		metadata.Exif.Base = common.Base{Id: newImage.Base.Id}
+	}
+	return metadata.Exif, nil
+}
+
+// discardPublicCopy removes the stripped copy of an image that didn't make it in
+func (svc *ImageService) discardPublicCopy(newImage entities.Image) {
+	if newImage.PublicPath == "" {
+		return
+	}
+	if err := svc.S3Handler.DeleteObject(newImage.PublicPath); err != nil {
+		log.Printf("failed to delete unused public copy %s: %v", newImage.PublicPath, err)
+	}
+}
+
+// parseExif reads the camera metadata out of a raw EXIF block, nil when it can't be parsed at all
+func parseExif(block []byte) *entities.ImageExif {
+	parsed, err := goexif.Decode(bytes.NewReader(block))
+	if parsed == nil {
+		log.Printf("failed to parse exif metadata: %v", err)
+		return nil
+	}
+
+	imageExif := &entities.ImageExif{
+		Make:         exifString(parsed, goexif.Make),
+		Model:        exifString(parsed, goexif.Model),
+		LensModel:    exifString(parsed, goexif.LensModel),
+		ExposureTime: exifFloat(parsed, goexif.ExposureTime),
+		FNumber:      exifFloat(parsed, goexif.FNumber),
+		FocalLength:  exifFloat(parsed, goexif.FocalLength),
+		Altitude:     exifFloat(parsed, goexif.GPSAltitude),
+	}
+	if orientation, err := parsed.Get(goexif.Orientation); err == nil {
+		imageExif.Orientation, _ = orientation.Int(0)
+	}
+	if iso, err := parsed.Get(goexif.ISOSpeedRatings); err == nil {
+		if value, err := iso.Int(0); err == nil {
+			imageExif.ISO = &value
+		}
+	}
+	if imageExif.Altitude != nil {
+		// GPSAltitudeRef 1 means below sea level
+		if ref, err := parsed.Get(goexif.GPSAltitudeRef); err == nil {
+			if below, _ := ref.Int(0); below == 1 {
+				*imageExif.Altitude = -*imageExif.Altitude
+			}
+		}
+	}
+	if latitude, longitude, err := parsed.LatLong(); err == nil {
+		imageExif.Latitude, imageExif.Longitude = &latitude, &longitude
+	}
+	imageExif.CapturedAt = exifCaptureTime(parsed)
+	return imageExif
+}
+
+func exifString(parsed *goexif.Exif, name goexif.FieldName) string {
+	tag, err := parsed.Get(name)
+	if err != nil {
+		return ""
+	}
+	value, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(value, "\x00"))
+}
+
+// exifFloat reads a rational tag
+func exifFloat(parsed *goexif.Exif, name goexif.FieldName) *float64 {
+	tag, err := parsed.Get(name)
+	if err != nil {
+		return nil
+	}
+	numerator, denominator, err := tag.Rat2(0)
+	if err != nil || denominator == 0 {
+		return nil
+	}
+	value := float64(numerator) / float64(denominator)
+	return &value
+}
+
+// exifCaptureTime prefers the time the photo was taken over the time the file was written. EXIF times carry no zone,
+// the wall clock time of the camera is stored as UTC.
+func exifCaptureTime(parsed *goexif.Exif) *time.Time {
+	value := exifString(parsed, goexif.DateTimeOriginal)
+	if value == "" {
+		value = exifString(parsed, goexif.DateTime)
+	}
+	if value == "" {
+		return nil
+	}
+	captured, err := time.Parse(exifTimeLayout, value)
+	if err != nil {
+		return nil
+	}
+	return &captured
+}
+
+func exifDetails(imageExif *entities.ImageExif) *ExifDetails {
+	if imageExif == nil {
+		return nil
+	}
+	return &ExifDetails{
+		Make:         imageExif.Make,
+		Model:        imageExif.Model,
+		LensModel:    imageExif.LensModel,
+		CapturedAt:   imageExif.CapturedAt,
+		Orientation:  imageExif.Orientation,
+		ExposureTime: imageExif.ExposureTime,
+		FNumber:      imageExif.FNumber,
+		ISO:          imageExif.ISO,
+		FocalLength:  imageExif.FocalLength,
+		Latitude:     imageExif.Latitude,
+		Longitude:    imageExif.Longitude,
+		Altitude:     imageExif.Altitude,
+	}
+}
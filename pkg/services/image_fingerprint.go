@@ -0,0 +1,101 @@
+package services
+
+import (
+	goimage "image"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// fingerprintSize is the side of the grayscale thumbnail pHash is computed from, only its lowest 8x8 frequencies are kept
+const fingerprintSize = 32
+
+// imageFingerprint holds the perceptual hashes of an image's pixels
+type imageFingerprint struct {
+	DHash uint64
+	PHash uint64
+}
+
+// fingerprintImage computes the dHash and pHash of an image. Transparent pixels are laid on white first, so a PNG and
+// a JPEG of the same picture end up with close fingerprints.
+func fingerprintImage(img goimage.Image) imageFingerprint {
+	return imageFingerprint{DHash: dHash(img), PHash: pHash(img)}
+}
+
+// dHash compares every pixel of a 9x8 grayscale thumbnail to its right neighbour, one bit per comparison
+func dHash(img goimage.Image) uint64 {
+	grey := greyscale(img, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if grey[y*9+x] < grey[y*9+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// pHash takes the discrete cosine transform of a 32x32 grayscale thumbnail and sets one bit for every one of its 8x8
+// lowest frequencies that is above their median. The DC term only reflects the overall brightness and is left out of
+// the median.
+func pHash(img goimage.Image) uint64 {
+	grey := greyscale(img, fingerprintSize, fingerprintSize)
+
+	// the 2D DCT is separable, rows first and then the 8 lowest frequencies of every column
+	rows := make([]float64, fingerprintSize*8)
+	for y := 0; y < fingerprintSize; y++ {
+		for u := 0; u < 8; u++ {
+			rows[y*8+u] = dctCoefficient(func(x int) float64 { return grey[y*fingerprintSize+x] }, u)
+		}
+	}
+	coefficients := make([]float64, 64)
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			coefficients[v*8+u] = dctCoefficient(func(y int) float64 { return rows[y*8+u] }, v)
+		}
+	}
+
+	sorted := append([]float64{}, coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for _, coefficient := range coefficients {
+		hash <<= 1
+		if coefficient > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// dctCoefficient is the k-th DCT-II coefficient of fingerprintSize samples, unnormalized as only the ordering matters
+func dctCoefficient(sample func(int) float64, k int) float64 {
+	sum := 0.0
+	for n := 0; n < fingerprintSize; n++ {
+		sum += sample(n) * math.Cos(math.Pi/fingerprintSize*(float64(n)+0.5)*float64(k))
+	}
+	return sum
+}
+
+// greyscale downscales the image to width x height and returns the luma of every pixel, row by row
+func greyscale(img goimage.Image, width, height int) []float64 {
+	// JPEG lays the image on white
+	thumbnail := resizeImage(img, img.Bounds(), width, height, FormatJPEG)
+	grey := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			offset := thumbnail.PixOffset(x, y)
+			pixel := thumbnail.Pix[offset : offset+3]
+			grey[y*width+x] = luma(float64(pixel[0]), float64(pixel[1]), float64(pixel[2]))
+		}
+	}
+	return grey
+}
+
+// hammingDistance is the number of bits two fingerprints differ in
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
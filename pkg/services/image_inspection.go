@@ -0,0 +1,137 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// imageMimeTypes are the content types of the formats registered with the image package, by the name DecodeConfig
+// reports them under
+var imageMimeTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"bmp":  "image/bmp",
+	"tiff": "image/tiff",
+	"webp": "image/webp",
+}
+
+// imageProperties is what confirmation learns about an upload from its content
+type imageProperties struct {
+	ChecksumSHA256 string
+	MimeType       string
+	Format         string
+	Width          int
+	Height         int
+	ColorModel     string
+}
+
+// inspectImage reads the magic bytes and the header of the content, rejecting anything that isn't a supported
+// image or that would decode to more than maxPixels. With hash set the whole content is also read and hashed in
+// the same pass, otherwise only the header is consumed.
+func inspectImage(content io.Reader, hash bool, maxPixels int64) (*imageProperties, error) {
+	hasher := sha256.New()
+	if hash {
+		content = io.TeeReader(content, hasher)
+	}
+	source := &readErrorRecorder{Reader: content}
+	buffered := bufio.NewReader(source)
+
+	// the format is sniffed from the magic bytes by the decoders themselves, http.DetectContentType doesn't know TIFF
+	config, format, err := image.DecodeConfig(buffered)
+	if source.err != nil {
+		return nil, fmt.Errorf("failed to read content: %w", source.err)
+	}
+	if errors.Is(err, image.ErrFormat) {
+		return nil, fmt.Errorf("%w: content is not a supported image", ErrInvalidImage)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	mimeType, ok := imageMimeTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported format %s", ErrInvalidImage, format)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, fmt.Errorf("%w: empty image", ErrInvalidImage)
+	}
+	// header dimensions are checked before anything decodes pixels, a small file can claim a huge canvas
+	if int64(config.Width)*int64(config.Height) > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d exceeds the limit of %d pixels", ErrInvalidImage, config.Width, config.Height, maxPixels)
+	}
+
+	properties := &imageProperties{
+		MimeType:   mimeType,
+		Format:     format,
+		Width:      config.Width,
+		Height:     config.Height,
+		ColorModel: colorModelName(config.ColorModel),
+	}
+
+	if hash {
+		if _, err = io.Copy(io.Discard, buffered); err != nil {
+			return nil, fmt.Errorf("failed to hash content: %w", err)
+		}
+		properties.ChecksumSHA256 = hex.EncodeToString(hasher.Sum(nil))
+	}
+	return properties, nil
+}
+
+// readErrorRecorder keeps the first read error other than EOF, so that failing to read the content isn't mistaken for
+// content that doesn't decode
+type readErrorRecorder struct {
+	io.Reader
+	err error
+}
+
+func (recorder *readErrorRecorder) Read(p []byte) (int, error) {
+	n, err := recorder.Reader.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) && recorder.err == nil {
+		recorder.err = err
+	}
+	return n, err
+}
+
+func colorModelName(model color.Model) string {
+	switch model {
+	case color.RGBAModel:
+		return "RGBA"
+	case color.RGBA64Model:
+		return "RGBA64"
+	case color.NRGBAModel:
+		return "NRGBA"
+	case color.NRGBA64Model:
+		return "NRGBA64"
+	case color.AlphaModel:
+		return "Alpha"
+	case color.Alpha16Model:
+		return "Alpha16"
+	case color.GrayModel:
+		return "Gray"
+	case color.Gray16Model:
+		return "Gray16"
+	case color.YCbCrModel:
+		return "YCbCr"
+	case color.NYCbCrAModel:
+		return "NYCbCrA"
+	case color.CMYKModel:
+		return "CMYK"
+	}
+	if _, ok := model.(color.Palette); ok {
+		return "Paletted"
+	}
+	return "Unknown"
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bit-image/internal/s3"
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/storage"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// encodedImage is a width x height image encoded with encode
+func encodedImage(t *testing.T, width, height int, encode func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		canvas.Set(x, x%height, color.RGBA{R: 200, A: 255})
+	}
+	var buffer bytes.Buffer
+	if err := encode(&buffer, canvas); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func encodePNG(w io.Writer, m image.Image) error  { return png.Encode(w, m) }
+func encodeJPEG(w io.Writer, m image.Image) error { return jpeg.Encode(w, m, nil) }
+func encodeGIF(w io.Writer, m image.Image) error  { return gif.Encode(w, m, nil) }
+func encodeTIFF(w io.Writer, m image.Image) error { return tiff.Encode(w, m, nil) }
+func encodeBMP(w io.Writer, m image.Image) error  { return bmp.Encode(w, m) }
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// failingReader returns its content and then fails
+type failingReader struct {
+	content io.Reader
+}
+
+func (reader *failingReader) Read(p []byte) (int, error) {
+	n, err := reader.content.Read(p)
+	if errors.Is(err, io.EOF) {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func TestInspectImage(t *testing.T) {
+	pngContent := encodedImage(t, 10, 10, encodePNG)
+	tests := []struct {
+		name      string
+		content   []byte
+		maxPixels int64
+		mimeType  string
+		format    string
+		wantErr   error
+	}{
+		{name: "png", content: pngContent, maxPixels: 1000, mimeType: "image/png", format: "png"},
+		{name: "jpeg", content: encodedImage(t, 10, 10, encodeJPEG), maxPixels: 1000, mimeType: "image/jpeg", format: "jpeg"},
+		{name: "gif", content: encodedImage(t, 10, 10, encodeGIF), maxPixels: 1000, mimeType: "image/gif", format: "gif"},
+		{name: "tiff", content: encodedImage(t, 10, 10, encodeTIFF), maxPixels: 1000, mimeType: "image/tiff", format: "tiff"},
+		{name: "bmp", content: encodedImage(t, 10, 10, encodeBMP), maxPixels: 1000, mimeType: "image/bmp", format: "bmp"},
+		{name: "exactly the pixel limit", content: pngContent, maxPixels: 100, mimeType: "image/png", format: "png"},
+		{name: "over the pixel limit", content: pngContent, maxPixels: 99, wantErr: ErrInvalidImage},
+		{name: "not an image", content: []byte("definitely not an image"), maxPixels: 1000, wantErr: ErrInvalidImage},
+		{name: "truncated header", content: pngContent[:20], maxPixels: 1000, wantErr: ErrInvalidImage},
+		{name: "empty", content: nil, maxPixels: 1000, wantErr: ErrInvalidImage},
+	}
+	for _, test := range tests {
+		for _, hash := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s hash=%t", test.name, hash), func(t *testing.T) {
+				properties, err := inspectImage(bytes.NewReader(test.content), hash, test.maxPixels)
+				if test.wantErr != nil {
+					if !errors.Is(err, test.wantErr) {
+						t.Fatalf("inspectImage() error = %v, want %v", err, test.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("inspectImage() error = %v", err)
+				}
+				if properties.MimeType != test.mimeType || properties.Format != test.format {
+					t.Errorf("inspectImage() = %s %s, want %s %s", properties.MimeType, properties.Format, test.mimeType, test.format)
+				}
+				if properties.Width != 10 || properties.Height != 10 {
+					t.Errorf("inspectImage() = %dx%d, want 10x10", properties.Width, properties.Height)
+				}
+				wantChecksum := ""
+				if hash {
+					wantChecksum = sha256Hex(test.content)
+				}
+				if properties.ChecksumSHA256 != wantChecksum {
+					t.Errorf("inspectImage() checksum = %q, want %q", properties.ChecksumSHA256, wantChecksum)
+				}
+			})
+		}
+	}
+}
+
+func TestInspectImageReadFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		hash    bool
+	}{
+		{"while sniffing", encodedImage(t, 10, 10, encodePNG)[:20], false},
+		{"while hashing", encodedImage(t, 10, 10, encodePNG), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := inspectImage(&failingReader{content: bytes.NewReader(test.content)}, test.hash, 1000)
+			if err == nil || errors.Is(err, ErrInvalidImage) {
+				t.Errorf("inspectImage() error = %v, want a read error", err)
+			}
+		})
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	checksum := sha256Hex([]byte("content"))
+	tests := []struct {
+		name     string
+		checksum string
+		want     string
+		wantErr  error
+	}{
+		{name: "lower case", checksum: checksum, want: checksum},
+		{name: "upper case is normalized", checksum: strings.ToUpper(checksum), want: checksum},
+		{name: "too short", checksum: checksum[:62], wantErr: ErrInvalidChecksum},
+		{name: "not hex", checksum: strings.Repeat("z", 64), wantErr: ErrInvalidChecksum},
+		{name: "empty", checksum: "", wantErr: ErrInvalidChecksum},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseChecksum(test.checksum)
+			if !errors.Is(err, test.wantErr) || got != test.want {
+				t.Errorf("parseChecksum(%q) = %q, %v, want %q, %v", test.checksum, got, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestInspectUploadChecksum(t *testing.T) {
+	objectStore, err := storage.NewLocalFileSystem(t.TempDir(), "http://localhost", []byte("secret"), "bucket")
+	if err != nil {
+		t.Fatalf("NewLocalFileSystem() error = %v", err)
+	}
+	content := encodedImage(t, 10, 10, encodePNG)
+	if err = objectStore.PutObject("temp/upload", bytes.NewReader(content), "image/png"); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	svc := &ImageService{S3Handler: s3.NewHandler(objectStore), MaxUploadBytes: int64(len(content)), MaxImagePixels: 1000}
+	checksum := sha256Hex(content)
+	otherChecksum := sha256Hex([]byte("other content"))
+
+	tests := []struct {
+		name       string
+		declared   string
+		stored     string
+		clientHash string
+		size       int64
+		wantErr    error
+	}{
+		{name: "hashed while inspecting", declared: checksum},
+		{name: "checksum of the backend", declared: checksum, stored: checksum},
+		{name: "client hash matching", declared: checksum, clientHash: strings.ToUpper(checksum)},
+		{name: "content doesn't match", declared: otherChecksum, wantErr: ErrChecksumMismatch},
+		{name: "backend checksum doesn't match", declared: checksum, stored: otherChecksum, wantErr: ErrChecksumMismatch},
+		{name: "client hash doesn't match", declared: checksum, clientHash: otherChecksum, wantErr: ErrChecksumMismatch},
+		{name: "larger than the upload limit", declared: checksum, size: int64(len(content)) + 1, wantErr: ErrInvalidImage},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			size := test.size
+			if size == 0 {
+				size = int64(len(content))
+			}
+			objectInfo := storage.ObjectInfo{Key: "temp/upload", Size: size, ChecksumSHA256: test.stored}
+			pendingUpload := &entities.Upload{ChecksumSHA256: test.declared}
+			properties, err := svc.inspectUpload(objectInfo, pendingUpload, test.clientHash)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("inspectUpload() error = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && properties.Format != "png" {
+				t.Errorf("inspectUpload() format = %q, want png", properties.Format)
+			}
+		})
+	}
+}
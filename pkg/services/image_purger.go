@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bit-image/internal/s3"
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage"
+	"bit-image/pkg/storage/blob"
+	"bit-image/pkg/storage/derivative"
+	"bit-image/pkg/storage/exif"
+	"bit-image/pkg/storage/image"
+	"bit-image/pkg/storage/label"
+	"bit-image/pkg/storage/tag"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"strings"
+	"time"
+)
+
+const purgeBatchSize = 500
+
+// ImagePurger hard-deletes images once they have been soft-deleted for longer than the retention window. It also
+// retries removing objects whose deletion failed when the image was first deleted, including released blobs and
+// derivatives.
+type ImagePurger struct {
+	S3Handler       *s3.Handler
+	ImageStore      *image.ImageStore
+	BlobStore       *blob.BlobStore
+	DerivativeStore *derivative.DerivativeStore
+	ExifStore       *exif.ExifStore
+	TagStore        *tag.TagStore
+	LabelStore      *label.LabelStore
+	Retention       time.Duration
+	Interval        time.Duration
+}
+
+func NewImagePurger(store *image.ImageStore, s3Handler *s3.Handler, blobStore *blob.BlobStore, derivativeStore *derivative.DerivativeStore, exifStore *exif.ExifStore, tagStore *tag.TagStore, labelStore *label.LabelStore, cfg *config.Config) *ImagePurger {
+	return &ImagePurger{
+		S3Handler:       s3Handler,
+		ImageStore:      store,
+		BlobStore:       blobStore,
+		DerivativeStore: derivativeStore,
+		ExifStore:       exifStore,
+		TagStore:        tagStore,
+		LabelStore:      labelStore,
+		Retention:       cfg.Images.DeleteRetention,
+		Interval:        cfg.Images.PurgeInterval,
+	}
+}
+
+// Run purges on every tick until the context is cancelled
+func (purger *ImagePurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(purger.Interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := purger.PurgeExpired()
+		if err != nil {
+			log.Printf("image purge failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d deleted images", purged)
+		}
+
+		if blobs, err := deleteReleasedBlobs(purger.S3Handler, purger.BlobStore); err != nil {
+			log.Printf("blob purge failed: %v", err)
+		} else if blobs > 0 {
+			log.Printf("purged %d released blobs", blobs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeExpired removes every image deleted before the retention window and returns how many rows were purged
+func (purger *ImagePurger) PurgeExpired() (int, error) {
+	cutoff := time.Now().Add(-purger.Retention)
+	purged := 0
+
+	for {
+		expired, err := purger.ImageStore.ListPurgeableImages(cutoff, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		if len(expired) == 0 {
+			return purged, nil
+		}
+
+		count, err := purger.purge(expired)
+		purged += count
+		if err != nil {
+			return purged, err
+		}
+
+		if len(expired) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// PurgeDeleted removes soft-deleted images right away instead of waiting for the retention window and returns how
+// many rows were purged. Ids of images that aren't deleted are ignored.
+func (purger *ImagePurger) PurgeDeleted(imageIds []uuid.UUID) (int, error) {
+	deleted, err := purger.ImageStore.GetDeletedImagesByIds(imageIds)
+	if err != nil {
+		return 0, err
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	purged, err := purger.purge(deleted)
+	if err != nil {
+		return purged, err
+	}
+	if _, err = deleteReleasedBlobs(purger.S3Handler, purger.BlobStore); err != nil {
+		log.Printf("failed to delete released blobs, they will be retried on purge: %v", err)
+	}
+	return purged, nil
+}
+
+// purge removes the objects of soft-deleted images and then their rows, returning how many rows were purged
+func (purger *ImagePurger) purge(expired []entities.Image) (int, error) {
+	// rows are only dropped once their object is gone, otherwise the object would be orphaned
+	failed := deleteImageObjects(purger.S3Handler, expired)
+	for imageId := range deleteDerivedObjects(purger.S3Handler, expired) {
+		failed[imageId] = struct{}{}
+	}
+	var purgeable []uuid.UUID
+	for _, expiredImage := range expired {
+		if _, ok := failed[expiredImage.Base.Id]; !ok {
+			purgeable = append(purgeable, expiredImage.Base.Id)
+		}
+	}
+	if len(purgeable) == 0 {
+		return 0, fmt.Errorf("failed to delete objects of %d expired images", len(expired))
+	}
+
+	if err := purger.DerivativeStore.DeleteDerivatives(purgeable); err != nil {
+		return 0, err
+	}
+	if err := purger.ExifStore.DeleteExifs(purgeable); err != nil {
+		return 0, err
+	}
+	if err := purger.TagStore.DeleteImageTags(purgeable); err != nil {
+		return 0, err
+	}
+	if err := purger.LabelStore.DeleteImageLabels(purgeable); err != nil {
+		return 0, err
+	}
+	// force-deleted images held on to their blob so they could be restored, it is released along with their rows and
+	// its object deleted with the other released blobs
+	tx, commit, rollback, err := purger.ImageStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	heldBlobIds, err := purger.ImageStore.PurgeImagesWithTransaction(tx, purgeable)
+	if err == nil {
+		err = purger.BlobStore.ReleaseBlobsWithTransaction(tx, heldBlobIds)
+	}
+	if err == nil {
+		err = commit()
+	}
+	if err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return 0, err
+	}
+	return len(purgeable), nil
+}
+
+// deleteImageObjects removes the stored objects of the images, batched per folder, and returns the ids of the images
+// whose object could not be removed. Deduplicated images are skipped, their blob owns the object.
+func deleteImageObjects(s3Handler *s3.Handler, images []entities.Image) map[uuid.UUID]struct{} {
+	fileIdsByFolder := make(map[string][]string)
+	imageIdByFile := make(map[string]uuid.UUID)
+	for _, storedImage := range images {
+		if storedImage.BlobId != nil {
+			continue
+		}
+		folder, fileId, found := strings.Cut(storedImage.Path, "/")
+		if !found {
+			continue
+		}
+		fileIdsByFolder[folder] = append(fileIdsByFolder[folder], fileId)
+		imageIdByFile[storedImage.Path] = storedImage.Base.Id
+	}
+
+	failed := make(map[uuid.UUID]struct{})
+	for folder, fileIds := range fileIdsByFolder {
+		failedIds, err := s3Handler.DeleteFilesFromFolder(fileIds, folder)
+		if err != nil {
+			log.Printf("failed to delete %d objects from %s: %v", len(failedIds), folder, err)
+		}
+		for _, fileId := range failedIds {
+			failed[imageIdByFile[folder+"/"+fileId]] = struct{}{}
+		}
+	}
+	return failed
+}
+
+// deleteDerivedObjects removes everything stored under the derived prefix of the images, derivatives and rendered
+// variants alike, and returns the ids of the images with objects that could not be removed. Derivative rows are kept,
+// they are dropped when the images are purged.
+func deleteDerivedObjects(s3Handler *s3.Handler, images []entities.Image) map[uuid.UUID]struct{} {
+	failed := make(map[uuid.UUID]struct{})
+	var fileIds []string
+	imageIdByFile := make(map[string]uuid.UUID)
+	for _, storedImage := range images {
+		prefix := derivedPrefix(storedImage.OwnerId, storedImage.Base.Id)
+		err := s3Handler.ListObjects(prefix, func(object storage.ObjectInfo) error {
+			fileId := strings.TrimPrefix(object.Key, common.DERIVED_STORAGE_FOLDER+"/")
+			fileIds = append(fileIds, fileId)
+			imageIdByFile[fileId] = storedImage.Base.Id
+			return nil
+		})
+		if err != nil {
+			log.Printf("failed to list derived objects under %s: %v", prefix, err)
+			failed[storedImage.Base.Id] = struct{}{}
+		}
+	}
+	if len(fileIds) == 0 {
+		return failed
+	}
+
+	failedIds, err := s3Handler.DeleteFilesFromFolder(fileIds, common.DERIVED_STORAGE_FOLDER)
+	if err != nil {
+		log.Printf("failed to delete %d derived objects: %v", len(failedIds), err)
+	}
+	for _, fileId := range failedIds {
+		failed[imageIdByFile[fileId]] = struct{}{}
+	}
+	return failed
+}
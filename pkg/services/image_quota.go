@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// parseUserId turns the id handed out by the auth service into the key of the users table. The auth service is required
+// to hand out UUIDs, tokens of any other id are refused by the middlewares.
+func parseUserId(UserId string) (uuid.UUID, error) {
+	userId, err := uuid.Parse(UserId)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("%w: %q", ErrInvalidUserId, UserId)
+	}
+	return userId, nil
+}
+
+// newUser is the row created for a user the first time they upload, with the default limits
+func (svc *ImageService) newUser(userId uuid.UUID) entities.User {
+	return entities.User{
+		Base:             common.Base{Id: userId},
+		ImageUploadLimit: svc.ImageUploadLimit,
+		ByteUploadLimit:  svc.ByteUploadLimit,
+	}
+}
+
+// checkRemainingQuota rejects a presign request of a suspended user or one that can't fit in what is left of the
+// user's quota. Declared sizes are optional, only the ones given count against the byte limit. The limits are enforced
+// again on confirmation.
+func (svc *ImageService) checkRemainingQuota(declarations []UploadDeclaration, UserId string) error {
+	userId, err := parseUserId(UserId)
+	if err != nil {
+		return err
+	}
+	if err = svc.UserStore.EnsureUser(svc.newUser(userId)); err != nil {
+		return err
+	}
+	user, err := svc.UserStore.GetUser(userId)
+	if err != nil {
+		return err
+	}
+	if user.SuspendedAt != nil {
+		return ErrUserSuspended
+	}
+
+	var declaredBytes int64
+	for _, declaration := range declarations {
+		if declaration.Size < 0 {
+			return fmt.Errorf("%w: negative size", ErrInvalidDeclaration)
+		}
+		if declaration.Size > svc.MaxUploadBytes {
+			return fmt.Errorf("%w: %d bytes, at most %d are accepted", ErrInvalidDeclaration, declaration.Size, svc.MaxUploadBytes)
+		}
+		declaredBytes += declaration.Size
+	}
+
+	if remaining := user.ImageUploadLimit - user.ImageUploadCount; len(declarations) > remaining {
+		return fmt.Errorf("%w: %d images requested, %d remaining", ErrQuotaExceeded, len(declarations), max(remaining, 0))
+	}
+	if remaining := user.ByteUploadLimit - user.ByteUploadCount; declaredBytes > remaining {
+		return fmt.Errorf("%w: %d bytes requested, %d remaining", ErrQuotaExceeded, declaredBytes, max(remaining, 0))
+	}
+	return nil
+}
+
+// recordImageWithTransaction inserts a confirmed image and closes its upload, see insertImageWithTransaction.
+// Everything happens in the caller's transaction, so a confirmation over quota leaves no trace.
+func (svc *ImageService) recordImageWithTransaction(tx *gorm.DB, newImage entities.Image, imageExif *entities.ImageExif) error {
+	if err := svc.insertImageWithTransaction(tx, newImage, imageExif); err != nil {
+		return err
+	}
+	committed, err := svc.UploadStore.CommitUploadWithTransaction(tx, newImage.Base.Id)
+	if err != nil {
+		return err
+	}
+	if !committed {
+		return fmt.Errorf("failed to commit upload for image with ID %s: %w", newImage.Base.Id.String(), ErrUploadNotFound)
+	}
+	return nil
+}
+
+// insertImageWithTransaction inserts an image along with its exif metadata when it has any, charges it to its owner's
+// quota, queues its derivatives and machine labeling and records its image.confirmed event
+func (svc *ImageService) insertImageWithTransaction(tx *gorm.DB, newImage entities.Image, imageExif *entities.ImageExif) error {
+	userId, err := parseUserId(newImage.OwnerId)
+	if err != nil {
+		return err
+	}
+	if err = svc.UserStore.EnsureUserWithTransaction(tx, svc.newUser(userId)); err != nil {
+		return err
+	}
+	if err = svc.UserStore.ConsumeQuotaWithTransaction(tx, userId, 1, int64(newImage.ImageMetaData.FileSize)); err != nil {
+		return err
+	}
+	if err = svc.ImageStore.AddImageWithTransaction(tx, newImage); err != nil {
+		return err
+	}
+	if imageExif != nil {
+		if err = svc.ExifStore.AddExifWithTransaction(tx, imageExif); err != nil {
+			return err
+		}
+	}
+	if err = svc.DerivativeStore.AddDerivativesWithTransaction(tx, svc.Derivatives.newDerivatives(newImage)); err != nil {
+		return err
+	}
+	if svc.AutoLabeler.Enabled() {
+		if err = svc.LabelStore.AddLabelingJobWithTransaction(tx, newImage.Base.Id); err != nil {
+			return err
+		}
+	}
+	confirmed, err := imageConfirmedEvent(newImage)
+	if err != nil {
+		return err
+	}
+	return svc.OutboxStore.AddEventsWithTransaction(tx, []entities.OutboxEvent{confirmed})
+}
+
+// releaseQuotaWithTransaction gives the quota used by deleted images back to their owner
+func (svc *ImageService) releaseQuotaWithTransaction(tx *gorm.DB, UserId string, deleted []entities.Image) error {
+	userId, err := parseUserId(UserId)
+	if err != nil {
+		return err
+	}
+
+	var bytes int64
+	for _, deletedImage := range deleted {
+		bytes += int64(deletedImage.ImageMetaData.FileSize)
+	}
+	return svc.UserStore.ReleaseQuotaWithTransaction(tx, userId, len(deleted), bytes)
+}
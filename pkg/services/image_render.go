@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage"
+	"bytes"
+	"errors"
+	"fmt"
+	goimage "image"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fit modes of a render, how the image is placed in the requested box
+const (
+	// FitContain scales the image to fit within the box, keeping its aspect ratio
+	FitContain = "contain"
+	// FitCover scales the image to cover the box and crops whatever sticks out, centered
+	FitCover = "cover"
+	// FitFill stretches the image to the box
+	FitFill = "fill"
+)
+
+// RenderQuery are the parameters of an on the fly rendering of an image, bound from the query string. A zero width or
+// height leaves that side to the aspect ratio, the format defaults to the one of the original when it can be encoded.
+type RenderQuery struct {
+	Width   int    `form:"w"`
+	Height  int    `form:"h"`
+	Fit     string `form:"fit"`
+	Format  string `form:"format"`
+	Quality int    `form:"q"`
+}
+
+// RenderedImage is a presigned url to a rendered variant of an image
+type RenderedImage struct {
+	URL          string    `json:"url"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+}
+
+// RenderLimits bound the parameters of renders. Every distinct set of parameters is cached as its own object, so
+// they are kept to a small set rather than just capped.
+type RenderLimits struct {
+	MaxDimension int
+	// AllowedSizes are the only widths and heights accepted when set
+	AllowedSizes []int
+	// AllowedQualities are the only JPEG qualities accepted
+	AllowedQualities []int
+	DefaultQuality   int
+}
+
+func newRenderLimits(render config.RenderConfig) RenderLimits {
+	return RenderLimits{
+		MaxDimension:     render.MaxDimension,
+		AllowedSizes:     render.AllowedSizes,
+		AllowedQualities: render.AllowedQualities,
+		DefaultQuality:   render.DefaultQuality,
+	}
+}
+
+// validate fills in the defaults of a query and rejects anything outside of the limits
+func (limits RenderLimits) validate(query *RenderQuery, originalFormat string) error {
+	if query.Width == 0 && query.Height == 0 {
+		return fmt.Errorf("%w: w or h is required", ErrInvalidRenderQuery)
+	}
+	for _, dimension := range []int{query.Width, query.Height} {
+		if dimension < 0 || dimension > limits.MaxDimension {
+			return fmt.Errorf("%w: dimensions must be between 1 and %d", ErrInvalidRenderQuery, limits.MaxDimension)
+		}
+		if dimension > 0 && len(limits.AllowedSizes) > 0 && !slices.Contains(limits.AllowedSizes, dimension) {
+			return fmt.Errorf("%w: dimensions must be one of %v", ErrInvalidRenderQuery, limits.AllowedSizes)
+		}
+	}
+
+	switch query.Fit {
+	case "":
+		query.Fit = FitContain
+	case FitContain:
+	case FitCover, FitFill:
+		if query.Width == 0 || query.Height == 0 {
+			return fmt.Errorf("%w: fit=%s needs both w and h", ErrInvalidRenderQuery, query.Fit)
+		}
+	default:
+		return fmt.Errorf("%w: fit must be %s, %s or %s", ErrInvalidRenderQuery, FitContain, FitCover, FitFill)
+	}
+
+	if query.Format == "" {
+		query.Format = FormatJPEG
+		if _, ok := formatContentTypes[originalFormat]; ok {
+			query.Format = originalFormat
+		}
+	}
+	if _, ok := formatContentTypes[query.Format]; !ok {
+		return fmt.Errorf("%w: format must be %s or %s", ErrInvalidRenderQuery, FormatJPEG, FormatPNG)
+	}
+
+	// quality means nothing to PNG, leaving it out keeps a single cached variant
+	if query.Format != FormatJPEG {
+		query.Quality = 0
+	} else if query.Quality == 0 {
+		query.Quality = limits.DefaultQuality
+	} else if !slices.Contains(limits.AllowedQualities, query.Quality) {
+		return fmt.Errorf("%w: q must be one of %v", ErrInvalidRenderQuery, limits.AllowedQualities)
+	}
+	return nil
+}
+
+// variantPath is where a rendered variant is cached, every parameter is part of the key so the same query always
+// maps to the same object. Variants live under the derived prefix of the image and are removed along with it.
+func variantPath(storedImage entities.Image, query RenderQuery) string {
+	return fmt.Sprintf("%srender/%dx%d-%s-q%d.%s", derivedPrefix(storedImage.OwnerId, storedImage.Base.Id),
+		query.Width, query.Height, query.Fit, query.Quality, formatExtensions[query.Format])
+}
+
+// RenderImage returns a presigned url to the image rendered with the query. Variants are rendered once and cached in
+// object storage, concurrent requests for a variant that isn't cached yet share a single rendering.
+func (svc *ImageService) RenderImage(imageId string, UserId string, query RenderQuery) (*RenderedImage, error) {
+	id, err := uuid.Parse(imageId)
+	if err != nil {
+		return nil, ErrInvalidImageId
+	}
+
+	storedImage, err := svc.ImageStore.GetImageById(id)
+	if err != nil {
+		return nil, err
+	}
+	if storedImage.IsPrivate && storedImage.OwnerId != UserId {
+		return nil, ErrImageNotFound
+	}
+
+	if err = svc.RenderLimits.validate(&query, storedImage.ImageMetaData.Format); err != nil {
+		return nil, err
+	}
+
+	key := variantPath(*storedImage, query)
+	if _, err = svc.S3Handler.HeadObject(key); err != nil {
+		if !errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, fmt.Errorf("failed to look up variant %s: %w", key, err)
+		}
+		_, err, _ = svc.renders.Do(key, func() (any, error) {
+			return nil, svc.renderVariant(*storedImage, query, key)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	expiresAt := time.Now().Add(svc.DownloadURLTTL)
+	url, err := svc.S3Handler.GeneratePresignedGetURL(key, svc.DownloadURLTTL, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate download url for variant %s: %w", key, err)
+	}
+	return &RenderedImage{URL: url, URLExpiresAt: expiresAt}, nil
+}
+
+// renderVariant renders the original with the query and stores the result under key
+func (svc *ImageService) renderVariant(storedImage entities.Image, query RenderQuery, key string) error {
+	body, err := svc.S3Handler.GetObject(storedImage.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read image %s: %w", storedImage.Base.Id.String(), err)
+	}
+	defer body.Close()
+
+	original, _, err := goimage.Decode(body)
+	if err != nil {
+		return fmt.Errorf("failed to decode image %s: %w", storedImage.Base.Id.String(), err)
+	}
+
+	src, width, height := renderGeometry(original.Bounds(), query)
+	encoded, err := encodeImage(resizeImage(original, src, width, height, query.Format), query.Format, query.Quality)
+	if err != nil {
+		return err
+	}
+	if err = svc.S3Handler.PutObject(key, bytes.NewReader(encoded), formatContentTypes[query.Format]); err != nil {
+		return fmt.Errorf("failed to store variant %s: %w", key, err)
+	}
+	return nil
+}
+
+// renderGeometry works out which part of the original is drawn and how large the result is. Like derivatives,
+// renders never scale the image up, a box larger than the original shrinks to it.
+func renderGeometry(bounds goimage.Rectangle, query RenderQuery) (goimage.Rectangle, int, int) {
+	width, height := bounds.Dx(), bounds.Dy()
+	switch query.Fit {
+	case FitFill:
+		return bounds, min(query.Width, width), min(query.Height, height)
+	case FitCover:
+		// crop the largest centered area with the aspect ratio of the box
+		cropWidth, cropHeight := width, width*query.Height/query.Width
+		if cropHeight > height {
+			cropWidth, cropHeight = height*query.Width/query.Height, height
+		}
+		cropWidth, cropHeight = max(cropWidth, 1), max(cropHeight, 1)
+		offset := goimage.Pt(bounds.Min.X+(width-cropWidth)/2, bounds.Min.Y+(height-cropHeight)/2)
+		src := goimage.Rectangle{Min: offset, Max: offset.Add(goimage.Pt(cropWidth, cropHeight))}
+		outWidth, outHeight := fitWithin(cropWidth, cropHeight, query.Width, query.Height)
+		return src, outWidth, outHeight
+	default:
+		outWidth, outHeight := fitWithin(width, height, query.Width, query.Height)
+		return bounds, outWidth, outHeight
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/storage/image"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SearchScopeAll  = "all"
+	SearchScopeMine = "mine"
+
+	maxSearchQueryLength = 256
+	defaultSearchLimit   = 20
+	maxSearchLimit       = 100
+	// maxSearchOffset keeps deep pages from ranking the whole index
+	maxSearchOffset = 10000
+	// searchBackfillBatch is how many images get a search document per statement when backfilling
+	searchBackfillBatch = 500
+)
+
+// SearchQuery are the parameters of a full-text search, bound from the query string. Q uses web search syntax:
+// quoted phrases, "or" and "-" to exclude words. Scope is all (default), the user's images and the public images of
+// others, or mine.
+type SearchQuery struct {
+	Q         string `form:"q"`
+	Scope     string `form:"scope"`
+	Format    string `form:"format"`
+	Year      *int   `form:"year"`
+	IsPrivate *bool  `form:"is_private"`
+	Limit     int    `form:"limit"`
+	Offset    int    `form:"offset"`
+}
+
+// SearchHit is a matching image with its rank and the matched text, matched words are wrapped in <mark> and the rest
+// is HTML escaped
+type SearchHit struct {
+	Image   ImageDetails `json:"image"`
+	Rank    float64      `json:"rank"`
+	Snippet string       `json:"snippet"`
+}
+
+// FacetCount is how many matching images have a value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// SearchFacets count every match by format, year of upload and privacy, regardless of the filters on those
+type SearchFacets struct {
+	Format    []FacetCount `json:"format"`
+	Year      []FacetCount `json:"year"`
+	IsPrivate []FacetCount `json:"is_private"`
+}
+
+// SearchResults is a page of hits, best first
+type SearchResults struct {
+	Hits   []SearchHit  `json:"hits"`
+	Total  int64        `json:"total"`
+	Facets SearchFacets `json:"facets"`
+}
+
+// validate fills in the defaults of a query and rejects anything out of range
+func (query *SearchQuery) validate() error {
+	query.Q = strings.TrimSpace(query.Q)
+	if query.Q == "" {
+		return fmt.Errorf("%w: q is required", ErrInvalidSearchQuery)
+	}
+	if utf8.RuneCountInString(query.Q) > maxSearchQueryLength {
+		return fmt.Errorf("%w: q must be at most %d characters", ErrInvalidSearchQuery, maxSearchQueryLength)
+	}
+	switch query.Scope {
+	case "":
+		query.Scope = SearchScopeAll
+	case SearchScopeAll, SearchScopeMine:
+	default:
+		return fmt.Errorf("%w: scope must be %s or %s", ErrInvalidSearchQuery, SearchScopeAll, SearchScopeMine)
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultSearchLimit
+	}
+	if query.Limit < 0 || query.Limit > maxSearchLimit {
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSearchQuery, maxSearchLimit)
+	}
+	if query.Offset < 0 || query.Offset > maxSearchOffset {
+		return fmt.Errorf("%w: offset must be between 0 and %d", ErrInvalidSearchQuery, maxSearchOffset)
+	}
+	return nil
+}
+
+// SearchImages finds images by name, caption, tags and labels. Like retrieval, the user sees their own images and the
+// public images of others; tags are private to their owner and only match the user's own images.
+func (svc *ImageService) SearchImages(UserId string, query SearchQuery) (*SearchResults, error) {
+	if err := query.validate(); err != nil {
+		return nil, err
+	}
+
+	found, err := svc.ImageStore.SearchImages(image.SearchFilter{
+		UserId:    UserId,
+		Query:     query.Q,
+		OnlyOwned: query.Scope == SearchScopeMine,
+		Format:    query.Format,
+		Year:      query.Year,
+		IsPrivate: query.IsPrivate,
+	}, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	hitIds := make([]uuid.UUID, 0, len(found.Hits))
+	for _, hit := range found.Hits {
+		hitIds = append(hitIds, hit.ImageId)
+	}
+	var storedImages []entities.Image
+	if len(hitIds) > 0 {
+		storedImages, err = svc.ImageStore.GetImagesByIds(hitIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+	details, err := svc.imageDetailsList(storedImages, UserId)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[uuid.UUID]ImageDetails, len(details))
+	for _, imageDetails := range details {
+		byId[imageDetails.Id] = imageDetails
+	}
+
+	results := &SearchResults{
+		Hits:  make([]SearchHit, 0, len(found.Hits)),
+		Total: found.Total,
+		Facets: SearchFacets{
+			Format:    facetCounts(found.Formats),
+			Year:      facetCounts(found.Years),
+			IsPrivate: facetCounts(found.Privacy),
+		},
+	}
+	for _, hit := range found.Hits {
+		// deleted between the search and the fetch
+		imageDetails, ok := byId[hit.ImageId]
+		if !ok {
+			continue
+		}
+		results.Hits = append(results.Hits, SearchHit{Image: imageDetails, Rank: hit.Rank, Snippet: highlightSnippet(hit.Snippet)})
+	}
+	return results, nil
+}
+
+// BackfillSearchDocuments builds the search documents of the images stored before search existed and returns how many
+// were built
+func (svc *ImageService) BackfillSearchDocuments() (int, error) {
+	indexed := 0
+	for {
+		refreshed, err := svc.ImageStore.RefreshMissingSearchDocuments(searchBackfillBatch)
+		indexed += refreshed
+		if err != nil || refreshed < searchBackfillBatch {
+			return indexed, err
+		}
+	}
+}
+
+// highlightSnippet escapes a snippet and marks its matched words, which the store delimits with control characters
+func highlightSnippet(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	return strings.NewReplacer(image.SnippetStart, "<mark>", image.SnippetStop, "</mark>").Replace(escaped)
+}
+
+func facetCounts(counts []image.FacetCount) []FacetCount {
+	facets := make([]FacetCount, 0, len(counts))
+	for _, count := range counts {
+		facets = append(facets, FacetCount{Value: count.Value, Count: count.Count})
+	}
+	return facets
+}
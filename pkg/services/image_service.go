@@ -4,51 +4,209 @@ import (
 	"bit-image/internal/s3"
 	"bit-image/pkg/common"
 	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage"
+	"bit-image/pkg/storage/blob"
+	"bit-image/pkg/storage/derivative"
+	"bit-image/pkg/storage/exif"
 	"bit-image/pkg/storage/image"
+	"bit-image/pkg/storage/label"
+	"bit-image/pkg/storage/outbox"
+	"bit-image/pkg/storage/tag"
+	"bit-image/pkg/storage/upload"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
 	"log"
-	"os"
+	"mime"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 type ImageService struct {
-	S3Handler  *s3.Handler
-	ImageStore *image.ImageStore
+	S3Handler   *s3.Handler
+	ImageStore  *image.ImageStore
+	UploadStore *upload.UploadStore
+	BlobStore   *blob.BlobStore
+	UserStore   *storage.UserStore
+	// DerivativeStore and Derivatives keep the downscaled copies of images, see derivative_generator.go
+	DerivativeStore *derivative.DerivativeStore
+	Derivatives     *DerivativeGenerator
+	ExifStore       *exif.ExifStore
+	// AutoLabeler adds machine labels to confirmed images, see auto_labeler.go
+	AutoLabeler *AutoLabeler
+	// Fingerprints finds images that look alike, see image_similarity.go
+	Fingerprints *FingerprintIndex
+	// OutboxStore records the events of image changes in their transaction, Events publishes them, see image_events.go
+	OutboxStore *outbox.OutboxStore
+	Events      *OutboxDispatcher
+	// TagStore and LabelStore keep the tags users file their images under and the labels describing them, see
+	// image_tags.go
+	TagStore   *tag.TagStore
+	LabelStore *label.LabelStore
+	// DedupEnabled stores identical content of a user once, see image_dedup.go
+	DedupEnabled bool
+	// default quota of new users, see image_quota.go
+	ImageUploadLimit int
+	ByteUploadLimit  int64
+	// MaxUploadBytes rejects larger uploads before they are read, MaxImagePixels the ones whose header claims more
+	// pixels, see image_inspection.go
+	MaxUploadBytes int64
+	MaxImagePixels int64
+	// UploadURLTTL and DownloadURLTTL are how long presigned urls stay valid
+	UploadURLTTL   time.Duration
+	DownloadURLTTL time.Duration
+	// RenderLimits bound the parameters of RenderImage, renders coalesces concurrent renderings of a variant
+	RenderLimits RenderLimits
+	renders      singleflight.Group
 }
 
+// UploadDeclaration is what the client declares about an image before uploading it. Size is optional.
+type UploadDeclaration struct {
+	ChecksumSHA256 string `json:"checksum_sha256"`
+	Size           int64  `json:"size"`
+}
+
+// PresignedURL is an upload url for one declared image. Headers have to be sent along with the PUT, the url is
+// signed for them.
 type PresignedURL struct {
-	URL     string    `json:"url"`
-	Method  string    `json:"method"`
-	ImageId uuid.UUID `json:"image_id"`
+	URL            string            `json:"url"`
+	Method         string            `json:"method"`
+	Headers        map[string]string `json:"headers"`
+	ImageId        uuid.UUID         `json:"image_id"`
+	ChecksumSHA256 string            `json:"checksum_sha256"`
 }
 
+// maxCaptionLength bounds the caption of an image, in characters
+const maxCaptionLength = 2000
+
 type ConfirmUploadRequest struct {
 	Id        string `json:"id"`
 	Name      string `json:"name"`
 	Hash      string `json:"hash"`
 	IsPrivate bool   `json:"is_private"`
+	// Caption is an optional description, searched along with the name
+	Caption string `json:"caption"`
+}
+
+// ImageDetails is the stored metadata of an image along with a short-lived url to fetch it
+type ImageDetails struct {
+	Id              uuid.UUID `json:"id"`
+	OwnerId         string    `json:"owner_id"`
+	Name            string    `json:"name"`
+	Caption         string    `json:"caption,omitempty"`
+	IsPrivate       bool      `json:"is_private"`
+	FileSize        float64   `json:"file_size"`
+	Format          string    `json:"format"`
+	MimeType        string    `json:"mime_type"`
+	Width           int       `json:"width"`
+	Height          int       `json:"height"`
+	ColorModel      string    `json:"color_model"`
+	Hash            string    `json:"hash"`
+	DateTimeCreated time.Time `json:"date_time_created"`
+	DateTimeUpdated time.Time `json:"date_time_updated"`
+	URL             string    `json:"url"`
+	URLExpiresAt    time.Time `json:"url_expires_at"`
+	// Broken is set when the stored content was found missing or damaged, the url may not work
+	Broken bool `json:"broken,omitempty"`
+	// Derivatives only lists the sizes generated so far
+	Derivatives []DerivativeDetails `json:"derivatives"`
+	// Exif is only returned to the owner of the image
+	Exif *ExifDetails `json:"exif,omitempty"`
+	// Tags are only returned to the owner of the image, labels to anyone who can see it
+	Tags   []string       `json:"tags,omitempty"`
+	Labels []LabelDetails `json:"labels,omitempty"`
+}
+
+// DerivativeDetails is a generated derivative of an image with a presigned url to fetch it, expiring along with the
+// url of the image
+type DerivativeDetails struct {
+	Size   int    `json:"size"`
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url"`
 }
 
-func NewImageService(store *image.ImageStore, s3Handler *s3.Handler) *ImageService {
+// ListImagesQuery are the filters and paging options of a listing, bound from the query string
+type ListImagesQuery struct {
+	Limit         int        `form:"limit"`
+	Cursor        string     `form:"cursor"`
+	Format        string     `form:"format"`
+	MinSize       *float64   `form:"min_size"`
+	MaxSize       *float64   `form:"max_size"`
+	IsPrivate     *bool      `form:"is_private"`
+	CreatedAfter  *time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore *time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Tags keeps images carrying any of the tags, or all of them when TagMatch is "all"
+	Tags     []string `form:"tags"`
+	TagMatch string   `form:"tag_match"`
+}
+
+// ImageList is a page of images, NextCursor is empty on the last page
+type ImageList struct {
+	Images     []ImageDetails `json:"images"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+func NewImageService(store *image.ImageStore, s3Handler *s3.Handler, uploadStore *upload.UploadStore, blobStore *blob.BlobStore, userStore *storage.UserStore, derivativeStore *derivative.DerivativeStore, derivatives *DerivativeGenerator, exifStore *exif.ExifStore, tagStore *tag.TagStore, labelStore *label.LabelStore, autoLabeler *AutoLabeler, fingerprints *FingerprintIndex, outboxStore *outbox.OutboxStore, events *OutboxDispatcher, cfg *config.Config) *ImageService {
 	return &ImageService{
-		ImageStore: store,
-		S3Handler:  s3Handler,
+		ImageStore:       store,
+		S3Handler:        s3Handler,
+		UploadStore:      uploadStore,
+		BlobStore:        blobStore,
+		UserStore:        userStore,
+		DerivativeStore:  derivativeStore,
+		Derivatives:      derivatives,
+		ExifStore:        exifStore,
+		TagStore:         tagStore,
+		LabelStore:       labelStore,
+		AutoLabeler:      autoLabeler,
+		Fingerprints:     fingerprints,
+		OutboxStore:      outboxStore,
+		Events:           events,
+		DedupEnabled:     cfg.Images.DedupEnabled,
+		ImageUploadLimit: cfg.Images.ImageUploadLimit,
+		ByteUploadLimit:  cfg.Images.ByteUploadLimit,
+		MaxUploadBytes:   cfg.Images.MaxUploadBytes,
+		MaxImagePixels:   cfg.Images.MaxPixels,
+		UploadURLTTL:     cfg.Presign.UploadURLTTL,
+		DownloadURLTTL:   cfg.Presign.DownloadURLTTL,
+		RenderLimits:     newRenderLimits(cfg.Render),
 	}
 }
 
-func (svc *ImageService) GeneratePresignedURLs(NumImages int, UserId string) ([]PresignedURL, error) {
+// GeneratePresignedURLs issues one upload url per declared image and records the declared checksums, which are
+// verified again when the uploads are confirmed
+func (svc *ImageService) GeneratePresignedURLs(declarations []UploadDeclaration, UserId string) ([]PresignedURL, error) {
 	if svc == nil {
 		return nil, fmt.Errorf("s3 handler not set")
 	}
+	for i := range declarations {
+		checksum, err := parseChecksum(declarations[i].ChecksumSHA256)
+		if err != nil {
+			return nil, err
+		}
+		declarations[i].ChecksumSHA256 = checksum
+	}
+	if err := svc.checkRemainingQuota(declarations, UserId); err != nil {
+		return nil, err
+	}
+
+	NumImages := len(declarations)
 	numCores := runtime.NumCPU()
 	numWorkers := min(NumImages, numCores)
 
 	urls := make(chan PresignedURL, NumImages)
 	errors := make(chan error, NumImages)
-	tasks := make(chan struct{}, NumImages)
+	tasks := make(chan UploadDeclaration, NumImages)
 
 	var wg sync.WaitGroup
 
@@ -57,23 +215,28 @@ func (svc *ImageService) GeneratePresignedURLs(NumImages int, UserId string) ([]
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for range tasks {
-				url, imageId, err := svc.S3Handler.GeneratePresignedURL(15*time.Minute, UserId)
+			for declaration := range tasks {
+				url, imageId, err := svc.S3Handler.GeneratePresignedURL(svc.UploadURLTTL, UserId, declaration.ChecksumSHA256)
 				if err != nil {
 					errors <- err
 					return
 				}
+				checksum, _ := hex.DecodeString(declaration.ChecksumSHA256)
 				urls <- PresignedURL{
-					URL:     url,
-					Method:  "PUT",
-					ImageId: imageId,
+					URL:    url,
+					Method: "PUT",
+					Headers: map[string]string{
+						"x-amz-checksum-sha256": base64.StdEncoding.EncodeToString(checksum),
+					},
+					ImageId:        imageId,
+					ChecksumSHA256: declaration.ChecksumSHA256,
 				}
 			}
 		}()
 	}
 
-	for i := 0; i < NumImages; i++ {
-		tasks <- struct{}{}
+	for _, declaration := range declarations {
+		tasks <- declaration
 	}
 	close(tasks)
 
@@ -92,6 +255,22 @@ func (svc *ImageService) GeneratePresignedURLs(NumImages int, UserId string) ([]
 		return nil, fmt.Errorf("failed to generate some presigned URLs")
 	}
 
+	expiresAt := time.Now().Add(svc.UploadURLTTL)
+	uploads := make([]entities.Upload, 0, len(presignedURLs))
+	for _, presignedURL := range presignedURLs {
+		uploads = append(uploads, entities.Upload{
+			Base:           common.Base{Id: presignedURL.ImageId},
+			OwnerId:        UserId,
+			ChecksumSHA256: presignedURL.ChecksumSHA256,
+			ExpiresAt:      expiresAt,
+			State:          entities.UploadIssued,
+			StateChangedAt: time.Now(),
+		})
+	}
+	if err := svc.UploadStore.AddUploads(uploads); err != nil {
+		return nil, err
+	}
+
 	return presignedURLs, nil
 }
 
@@ -132,70 +311,529 @@ func (svc *ImageService) ConfirmImageUploads(uploadRequests []ConfirmUploadReque
 	return nil
 }
 
+// ConfirmImage accepts an uploaded image and schedules the generation of its derivatives
 func (svc *ImageService) ConfirmImage(uploadRequest ConfirmUploadRequest, UserId string) error {
+	if err := svc.confirmImage(uploadRequest, UserId); err != nil {
+		return err
+	}
+	// confirmImage only succeeds with a valid id
+	svc.scheduleConfirmed(uuid.MustParse(uploadRequest.Id))
+	return nil
+}
+
+// scheduleConfirmed starts the background work on a newly recorded image instead of waiting for the workers to tick
+func (svc *ImageService) scheduleConfirmed(imageId uuid.UUID) {
+	svc.Derivatives.Schedule(imageId)
+	svc.AutoLabeler.Schedule(imageId)
+	svc.indexFingerprint(imageId)
+	svc.Events.Notify()
+}
+
+func (svc *ImageService) confirmImage(uploadRequest ConfirmUploadRequest, UserId string) error {
 	imageID, err := uuid.Parse(uploadRequest.Id)
 	if err != nil {
 		return fmt.Errorf("failed to parse UUID from request ID %s: %w", uploadRequest.Id, err)
 	}
+	if utf8.RuneCountInString(uploadRequest.Caption) > maxCaptionLength {
+		return fmt.Errorf("%w: caption is longer than %d characters", ErrInvalidDeclaration, maxCaptionLength)
+	}
 
 	userKey := UserId + "/" + imageID.String()
-	path := common.TEMPORARY_STORAGE_FOLDER + "/" + userKey
-	fmt.Println("Constructed Key:", path)
-	imageSize, contentType, err := svc.S3Handler.GetImageMetaData(path, os.Getenv("DEFAULT_BUCKET_NAME"))
+	tempPath := common.TEMPORARY_STORAGE_FOLDER + "/" + userKey
+	path := common.PERMANENT_STORAGE_FOLDER + "/" + userKey
+	fmt.Println("Constructed Key:", tempPath)
+
+	pendingUpload, err := svc.UploadStore.GetUpload(imageID, UserId)
 	if err != nil {
+		return fmt.Errorf("failed to find upload for image with ID %s: %w", imageID.String(), err)
+	}
+	switch pendingUpload.State {
+	case entities.UploadMoving:
+		return fmt.Errorf("failed to confirm image with ID %s: %w", imageID.String(), ErrUploadInProgress)
+	case entities.UploadCommitted:
+		return fmt.Errorf("failed to find upload for image with ID %s: %w", imageID.String(), ErrUploadNotFound)
+	case entities.UploadFailed:
+		return fmt.Errorf("failed to find upload for image with ID %s, it was rejected (%s): %w", imageID.String(), pendingUpload.LastError, ErrUploadNotFound)
+	}
+
+	newImage := entities.Image{
+		Base: common.Base{
+			Id: imageID,
+		},
+		OwnerId:   UserId,
+		Name:      uploadRequest.Name,
+		Caption:   uploadRequest.Caption,
+		IsPrivate: uploadRequest.IsPrivate,
+		Path:      path,
+	}
+
+	objectInfo, err := svc.S3Handler.HeadObject(tempPath)
+	if err != nil {
+		// with deduplication the client skips the PUT for content it has already stored
+		if svc.DedupEnabled && errors.Is(err, storage.ErrObjectNotFound) {
+			if linkErr := svc.confirmWithoutUpload(newImage, pendingUpload); !errors.Is(linkErr, blob.ErrBlobNotFound) {
+				return linkErr
+			}
+		}
 		return fmt.Errorf("failed to get metadata for image with ID %s: %w", imageID.String(), err)
 	}
-	fmt.Printf("Image metadata retrieved - Size: %d bytes, Content-Type: %s\n", imageSize, contentType)
+	imageSize := objectInfo.Size
 
-	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	properties, err := svc.inspectUpload(objectInfo, pendingUpload, uploadRequest.Hash)
 	if err != nil {
-		return fmt.Errorf("failed to start transaction: %w", err)
+		// a failed read can be retried, rejected content is removed right away instead of waiting for the reaper
+		if !errors.Is(err, ErrChecksumMismatch) && !errors.Is(err, ErrInvalidImage) {
+			return fmt.Errorf("failed to inspect image with ID %s: %w", imageID.String(), err)
+		}
+		if deleteErr := svc.S3Handler.DeleteObject(tempPath); deleteErr != nil {
+			log.Printf("failed to delete rejected upload %s: %v", tempPath, deleteErr)
+		}
+		if failErr := svc.UploadStore.MarkUploadFailed(imageID, err.Error()); failErr != nil {
+			log.Printf("failed to record rejected upload %s: %v", imageID.String(), failErr)
+		}
+		return fmt.Errorf("failed to verify image with ID %s: %w", imageID.String(), err)
+	}
+
+	// the client declared Content-Type is ignored, everything is taken from the content itself
+	newImage.ImageMetaData = common.ImageMetaData{
+		Hash:       pendingUpload.ChecksumSHA256,
+		FileSize:   float64(imageSize),
+		Format:     properties.Format,
+		MimeType:   properties.MimeType,
+		Width:      properties.Width,
+		Height:     properties.Height,
+		ColorModel: properties.ColorModel,
+	}
+
+	// the request is kept from here on so the confirmation can be finished if it is interrupted
+	verified, err := svc.UploadStore.MarkUploadVerified(imageID, newImage.Name, newImage.Caption, newImage.IsPrivate)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return fmt.Errorf("failed to confirm image with ID %s: %w", imageID.String(), ErrUploadInProgress)
+	}
+
+	imageExif, err := svc.extractMetadata(&newImage, tempPath)
+	if err != nil {
+		return err
+	}
+	if err = svc.storeUpload(newImage, imageExif, tempPath, userKey); err != nil {
+		svc.discardPublicCopy(newImage)
+		return err
+	}
+	return nil
+}
+
+// storeUpload moves a verified upload to its final location and records it. The upload is moving in between, a
+// failure puts the content back and a crash leaves it to the UploadRecovery.
+func (svc *ImageService) storeUpload(newImage entities.Image, imageExif *entities.ImageExif, tempPath string, userKey string) error {
+	if svc.DedupEnabled {
+		return svc.confirmDeduplicatedImage(newImage, tempPath, imageExif)
+	}
+
+	moving, err := svc.UploadStore.BeginUploadMove(newImage.Base.Id, newImage.Path)
+	if err != nil {
+		return err
+	}
+	if !moving {
+		return fmt.Errorf("failed to confirm image with ID %s: %w", newImage.Base.Id.String(), ErrUploadInProgress)
 	}
 
 	file := common.File{
 		Id:   userKey,
-		Hash: uploadRequest.Hash,
+		Hash: newImage.ImageMetaData.Hash,
 	}
 	if err = svc.S3Handler.MoveFileToFolder(file, common.TEMPORARY_STORAGE_FOLDER, common.PERMANENT_STORAGE_FOLDER); err != nil {
-		return fmt.Errorf("failed to move file with ID %s to folder %s: %w", file.Id, common.PERMANENT_STORAGE_FOLDER, err)
+		err = fmt.Errorf("failed to move file with ID %s to folder %s: %w", file.Id, common.PERMANENT_STORAGE_FOLDER, err)
+		svc.abortUploadMove(newImage.OwnerId, newImage.Base.Id, newImage.Path, err)
+		return err
 	}
 	fmt.Printf("Image with ID %s successfully moved to permanent storage folder.\n", file.Id)
 
-	newImage := entities.Image{
-		Base: common.Base{
-			Id: imageID,
-		},
-		Name:      uploadRequest.Name,
-		IsPrivate: uploadRequest.IsPrivate,
-		Path:      path,
-		ImageMetaData: common.ImageMetaData{
-			Hash:     uploadRequest.Hash,
-			FileSize: float64(imageSize),
-			Format:   contentType,
-		},
+	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	if err == nil {
+		if err = svc.recordImageWithTransaction(tx, newImage, imageExif); err == nil {
+			err = commit()
+		}
+		if err != nil {
+			if rollbackErr := rollback(); rollbackErr != nil {
+				log.Printf("failed to rollback transaction: %v", rollbackErr)
+			}
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to save image metadata to database: %w", err)
+		svc.abortUploadMove(newImage.OwnerId, newImage.Base.Id, newImage.Path, err)
+		return err
 	}
+	return nil
+}
 
-	if err = svc.ImageStore.AddImageWithTransaction(tx, newImage); err != nil {
-		if rollbackErr := rollback(); rollbackErr != nil {
-			log.Printf("failed to rollback transaction: %v", rollbackErr)
+// GetImage returns the metadata of an image with a presigned GET url. Private images are only visible to their owner,
+// download sets the Content-Disposition of the url so browsers save the file under its stored name.
+func (svc *ImageService) GetImage(imageId string, UserId string, download bool) (*ImageDetails, error) {
+	id, err := uuid.Parse(imageId)
+	if err != nil {
+		return nil, ErrInvalidImageId
+	}
+
+	storedImage, err := svc.ImageStore.GetImageById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// private images of other users are reported missing, so that their ids can't be probed
+	if storedImage.IsPrivate && storedImage.OwnerId != UserId {
+		return nil, ErrImageNotFound
+	}
+
+	derivatives, err := svc.DerivativeStore.ListReadyDerivatives([]uuid.UUID{id})
+	if err != nil {
+		return nil, err
+	}
+
+	contentDisposition := ""
+	if download {
+		contentDisposition = mime.FormatMediaType("attachment", map[string]string{"filename": storedImage.Name})
+	}
+
+	tags, labels, err := svc.imageAnnotations([]uuid.UUID{id})
+	if err != nil {
+		return nil, err
+	}
+
+	// everyone but the owner gets the copy without metadata, the owner gets the original along with its metadata
+	if storedImage.OwnerId != UserId {
+		if storedImage.PublicPath != "" {
+			storedImage.Path = storedImage.PublicPath
+		}
+		details, err := svc.imageDetails(*storedImage, contentDisposition, derivatives)
+		if err != nil {
+			return nil, err
 		}
+		details.Labels = labels[id]
+		return details, nil
+	}
+
+	imageExif, err := svc.ExifStore.GetExif(id)
+	if err != nil && !errors.Is(err, exif.ErrExifNotFound) {
+		return nil, err
+	}
+	details, err := svc.imageDetails(*storedImage, contentDisposition, derivatives)
+	if err != nil {
+		return nil, err
+	}
+	details.Exif = exifDetails(imageExif)
+	details.Tags, details.Labels = tags[id], labels[id]
+	return details, nil
+}
+
+// ListImages pages through the images owned by the user, newest first
+func (svc *ImageService) ListImages(UserId string, query ListImagesQuery) (*ImageList, error) {
+	var after *image.ImageCursor
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = cursor
+	}
+
+	tags, err := normalizeNames(splitTagFilter(query.Tags))
+	if err != nil {
+		return nil, err
+	}
+	matchAllTags := false
+	switch query.TagMatch {
+	case "", TagMatchAny:
+	case TagMatchAll:
+		matchAllTags = true
+	default:
+		return nil, fmt.Errorf("%w: tag_match must be %s or %s", ErrInvalidTag, TagMatchAny, TagMatchAll)
+	}
+
+	filter := image.ImageFilter{
+		OwnerId:       UserId,
+		Format:        query.Format,
+		MinSize:       query.MinSize,
+		MaxSize:       query.MaxSize,
+		IsPrivate:     query.IsPrivate,
+		CreatedAfter:  query.CreatedAfter,
+		CreatedBefore: query.CreatedBefore,
+		Tags:          tags,
+		MatchAllTags:  matchAllTags,
+	}
+
+	// fetch one extra row to find out whether there is a next page
+	limit := pageSize(query.Limit)
+	storedImages, err := svc.ImageStore.ListImages(filter, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	nextCursor := ""
+	if len(storedImages) > limit {
+		last := storedImages[limit-1]
+		storedImages = storedImages[:limit]
+		nextCursor = encodeCursor(image.ImageCursor{DateTimeCreated: last.Base.DateTimeCreated, Id: last.Base.Id})
+	}
+
+	images, err := svc.imageDetailsList(storedImages, UserId)
+	if err != nil {
+		return nil, err
+	}
+	return &ImageList{Images: images, NextCursor: nextCursor}, nil
+}
+
+// imageDetailsList returns the details of many images as seen by the user, fetching their derivatives, tags and
+// labels at once. Like GetImage, images of other users point at their copy without metadata and carry no tags.
+func (svc *ImageService) imageDetailsList(storedImages []entities.Image, UserId string) ([]ImageDetails, error) {
+	imageIds := make([]uuid.UUID, 0, len(storedImages))
+	for _, storedImage := range storedImages {
+		imageIds = append(imageIds, storedImage.Base.Id)
+	}
+	derivativesByImage := make(map[uuid.UUID][]entities.Derivative, len(storedImages))
+	if len(imageIds) > 0 {
+		derivatives, err := svc.DerivativeStore.ListReadyDerivatives(imageIds)
+		if err != nil {
+			return nil, err
+		}
+		for _, storedDerivative := range derivatives {
+			derivativesByImage[storedDerivative.ImageId] = append(derivativesByImage[storedDerivative.ImageId], storedDerivative)
+		}
+	}
+
+	tagsByImage, labelsByImage, err := svc.imageAnnotations(imageIds)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]ImageDetails, 0, len(storedImages))
+	for _, storedImage := range storedImages {
+		owned := storedImage.OwnerId == UserId
+		if !owned && storedImage.PublicPath != "" {
+			storedImage.Path = storedImage.PublicPath
+		}
+		details, err := svc.imageDetails(storedImage, "", derivativesByImage[storedImage.Base.Id])
+		if err != nil {
+			return nil, err
+		}
+		details.Labels = labelsByImage[storedImage.Base.Id]
+		if owned {
+			details.Tags = tagsByImage[storedImage.Base.Id]
+		}
+		images = append(images, *details)
+	}
+	return images, nil
+}
+
+// imageDetails presigns GET urls for the image and its derivatives and flattens its metadata for the api
+func (svc *ImageService) imageDetails(storedImage entities.Image, contentDisposition string, derivatives []entities.Derivative) (*ImageDetails, error) {
+	expiresAt := time.Now().Add(svc.DownloadURLTTL)
+	url, err := svc.S3Handler.GeneratePresignedGetURL(storedImage.Path, svc.DownloadURLTTL, contentDisposition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate download url for image %s: %w", storedImage.Base.Id.String(), err)
+	}
+
+	derivativeDetails := make([]DerivativeDetails, 0, len(derivatives))
+	for _, storedDerivative := range derivatives {
+		derivativeURL, err := svc.S3Handler.GeneratePresignedGetURL(storedDerivative.Path, svc.DownloadURLTTL, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate download url for derivative %s: %w", storedDerivative.Path, err)
+		}
+		derivativeDetails = append(derivativeDetails, DerivativeDetails{
+			Size:   storedDerivative.Size,
+			Format: storedDerivative.Format,
+			Width:  storedDerivative.Width,
+			Height: storedDerivative.Height,
+			URL:    derivativeURL,
+		})
+	}
+
+	return &ImageDetails{
+		Id:              storedImage.Base.Id,
+		OwnerId:         storedImage.OwnerId,
+		Name:            storedImage.Name,
+		Caption:         storedImage.Caption,
+		IsPrivate:       storedImage.IsPrivate,
+		FileSize:        storedImage.ImageMetaData.FileSize,
+		Format:          storedImage.ImageMetaData.Format,
+		MimeType:        storedImage.ImageMetaData.MimeType,
+		Width:           storedImage.ImageMetaData.Width,
+		Height:          storedImage.ImageMetaData.Height,
+		ColorModel:      storedImage.ImageMetaData.ColorModel,
+		Hash:            storedImage.ImageMetaData.Hash,
+		DateTimeCreated: storedImage.Base.DateTimeCreated,
+		DateTimeUpdated: storedImage.Base.DateTimeUpdated,
+		URL:             url,
+		URLExpiresAt:    expiresAt,
+		Broken:          storedImage.BrokenAt != nil,
+		Derivatives:     derivativeDetails,
+	}, nil
+}
 
-		if moveBackErr := svc.S3Handler.MoveFileToFolder(file, common.PERMANENT_STORAGE_FOLDER, common.TEMPORARY_STORAGE_FOLDER); moveBackErr != nil {
-			fmt.Printf("Warning: failed to move file back to temporary folder after DB insert failure: %v\n", moveBackErr)
+// DeleteImages soft-deletes the user's images and then removes their objects. Objects that fail to delete are
+// retried by the ImagePurger, so the request still succeeds. The ids of the deleted images are returned along with
+// one error per image that could not be deleted.
+func (svc *ImageService) DeleteImages(imageIds []string, UserId string) ([]uuid.UUID, []error) {
+	owned, errs := svc.ownedImages(imageIds, UserId, "delete")
+	if len(owned) == 0 {
+		return nil, errs
+	}
+	ownedIds := make([]uuid.UUID, 0, len(owned))
+	for _, ownedImage := range owned {
+		ownedIds = append(ownedIds, ownedImage.Base.Id)
+	}
+
+	// deduplicated images give up their blob reference in the same transaction
+	var blobIds []uuid.UUID
+	for _, ownedImage := range owned {
+		if ownedImage.BlobId != nil {
+			blobIds = append(blobIds, *ownedImage.BlobId)
 		}
-		return fmt.Errorf("failed to save image metadata to database: %w", err)
 	}
 
-	if err = commit(); err != nil {
+	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return nil, append(errs, fmt.Errorf("failed to start transaction: %w", err))
+	}
+	err = svc.ImageStore.SoftDeleteImagesWithTransaction(tx, ownedIds)
+	if err == nil {
+		err = svc.BlobStore.ReleaseBlobsWithTransaction(tx, blobIds)
+	}
+	if err == nil && len(blobIds) > 0 {
+		err = svc.ImageStore.MarkBlobsReleasedWithTransaction(tx, ownedIds)
+	}
+	if err == nil {
+		err = svc.releaseQuotaWithTransaction(tx, UserId, owned)
+	}
+	if err == nil {
+		err = addImageDeletedEventsWithTransaction(tx, svc.OutboxStore, owned)
+	}
+	if err == nil {
+		err = commit()
+	}
+	if err != nil {
 		if rollbackErr := rollback(); rollbackErr != nil {
 			log.Printf("failed to rollback transaction: %v", rollbackErr)
 		}
+		return nil, append(errs, err)
+	}
+
+	svc.Fingerprints.Remove(ownedIds)
+	svc.Events.Notify()
+	if failed := deleteImageObjects(svc.S3Handler, owned); len(failed) > 0 {
+		log.Printf("%d objects left behind after deleting images, they will be retried on purge", len(failed))
+	}
+	if failed := deleteDerivedObjects(svc.S3Handler, owned); len(failed) > 0 {
+		log.Printf("derivatives of %d images left behind after deleting them, they will be retried on purge", len(failed))
+	}
+	if len(blobIds) > 0 {
+		if _, err = deleteReleasedBlobs(svc.S3Handler, svc.BlobStore); err != nil {
+			log.Printf("failed to delete released blobs, they will be retried on purge: %v", err)
+		}
+	}
+
+	return ownedIds, errs
+}
+
+// ownedImages looks up the images of a batch request and returns the ones owned by the user, along with one error per
+// id that is invalid, missing or owned by someone else. action names the request in the errors.
+func (svc *ImageService) ownedImages(imageIds []string, UserId string, action string) ([]entities.Image, []error) {
+	var errs []error
+	var ids []uuid.UUID
+	seen := make(map[uuid.UUID]struct{}, len(imageIds))
+	for _, imageId := range imageIds {
+		id, err := uuid.Parse(imageId)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to %s image %s: %w", action, imageId, ErrInvalidImageId))
+			continue
+		}
+		// an image listed twice would otherwise be counted twice, e.g. when releasing quota
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, errs
+	}
+
+	storedImages, err := svc.ImageStore.GetImagesByIds(ids)
+	if err != nil {
+		return nil, append(errs, err)
+	}
+
+	found := make(map[uuid.UUID]entities.Image, len(storedImages))
+	for _, storedImage := range storedImages {
+		found[storedImage.Base.Id] = storedImage
+	}
 
-		if moveBackErr := svc.S3Handler.MoveFileToFolder(file, common.PERMANENT_STORAGE_FOLDER, common.TEMPORARY_STORAGE_FOLDER); moveBackErr != nil {
-			fmt.Printf("Warning: failed to move file back to temporary folder after commit failure: %v\n", moveBackErr)
+	var owned []entities.Image
+	for _, id := range ids {
+		storedImage, ok := found[id]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("failed to %s image %s: %w", action, id.String(), ErrImageNotFound))
+		case storedImage.OwnerId != UserId && storedImage.IsPrivate:
+			errs = append(errs, fmt.Errorf("failed to %s image %s: %w", action, id.String(), ErrImageNotFound))
+		case storedImage.OwnerId != UserId:
+			errs = append(errs, fmt.Errorf("failed to %s image %s: %w", action, id.String(), ErrImageAccessDenied))
+		default:
+			owned = append(owned, storedImage)
 		}
-		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	return owned, errs
+}
+
+// parseChecksum normalizes a hex-encoded SHA-256 declared by a client
+func parseChecksum(checksumSHA256 string) (string, error) {
+	checksum, err := hex.DecodeString(checksumSHA256)
+	if err != nil || len(checksum) != sha256.Size {
+		return "", fmt.Errorf("%w: %q", ErrInvalidChecksum, checksumSHA256)
+	}
+	return hex.EncodeToString(checksum), nil
+}
 
+// inspectUpload checks the uploaded content before it is confirmed. The content has to match the checksum declared
+// at presign time and decode as a supported image within MaxUploadBytes and MaxImagePixels. The checksum stored by the
+// backend is used when there is one, otherwise the content is hashed while it is inspected.
+func (svc *ImageService) inspectUpload(objectInfo storage.ObjectInfo, pendingUpload *entities.Upload, clientHash string) (*imageProperties, error) {
+	if clientHash != "" && !strings.EqualFold(clientHash, pendingUpload.ChecksumSHA256) {
+		return nil, ErrChecksumMismatch
+	}
+	if err := svc.checkObjectSize(objectInfo); err != nil {
+		return nil, err
+	}
+
+	body, err := svc.S3Handler.GetObject(objectInfo.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	properties, err := inspectImage(body, objectInfo.ChecksumSHA256 == "", svc.MaxImagePixels)
+	if err != nil {
+		return nil, err
+	}
+
+	actual := objectInfo.ChecksumSHA256
+	if actual == "" {
+		actual = properties.ChecksumSHA256
+	}
+	if actual != pendingUpload.ChecksumSHA256 {
+		return nil, ErrChecksumMismatch
+	}
+	return properties, nil
+}
+
+// checkObjectSize rejects objects larger than MaxUploadBytes before anything reads them, some decoders hold the whole
+// content in memory
+func (svc *ImageService) checkObjectSize(objectInfo storage.ObjectInfo) error {
+	if objectInfo.Size > svc.MaxUploadBytes {
+		return fmt.Errorf("%w: %d bytes, at most %d are accepted", ErrInvalidImage, objectInfo.Size, svc.MaxUploadBytes)
+	}
 	return nil
 }
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bit-image/pkg/common/entities"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultSimilarDistance is how many bits similar images may differ in when the query doesn't say
+	defaultSimilarDistance = 10
+	// defaultDuplicateDistance is tighter, only near identical copies are reported as duplicates
+	defaultDuplicateDistance = 4
+	defaultSimilarLimit      = 20
+	maxSimilarLimit          = 100
+)
+
+// SimilarityQuery are the parameters of a similarity search, bound from the query string. Hash is phash (default) or
+// dhash, MaxDistance the number of bits fingerprints may differ in.
+type SimilarityQuery struct {
+	MaxDistance *int   `form:"maxDistance"`
+	Hash        string `form:"hash"`
+	Limit       int    `form:"limit"`
+}
+
+// SimilarImage is an image that looks like the searched one, Distance is how many bits their fingerprints differ in
+type SimilarImage struct {
+	ImageDetails
+	Distance int `json:"distance"`
+}
+
+// DuplicateGroup is a set of the user's images that look alike, oldest first
+type DuplicateGroup struct {
+	Images []DuplicateImage `json:"images"`
+}
+
+// DuplicateImage is an image of a DuplicateGroup with what is needed to pick the copy to keep
+type DuplicateImage struct {
+	Id              uuid.UUID `json:"id"`
+	Name            string    `json:"name"`
+	FileSize        float64   `json:"file_size"`
+	Format          string    `json:"format"`
+	Width           int       `json:"width"`
+	Height          int       `json:"height"`
+	Hash            string    `json:"hash"`
+	DateTimeCreated time.Time `json:"date_time_created"`
+}
+
+// validate fills in the defaults of a query and rejects anything out of range
+func (query *SimilarityQuery) validate(defaultDistance int) error {
+	if query.MaxDistance == nil {
+		query.MaxDistance = &defaultDistance
+	}
+	if *query.MaxDistance < 0 || *query.MaxDistance > maxHashDistance {
+		return fmt.Errorf("%w: maxDistance must be between 0 and %d", ErrInvalidSimilarityQuery, maxHashDistance)
+	}
+	switch query.Hash {
+	case "":
+		query.Hash = HashPHash
+	case HashPHash, HashDHash:
+	default:
+		return fmt.Errorf("%w: hash must be %s or %s", ErrInvalidSimilarityQuery, HashPHash, HashDHash)
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultSimilarLimit
+	}
+	if query.Limit < 0 || query.Limit > maxSimilarLimit {
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSimilarityQuery, maxSimilarLimit)
+	}
+	return nil
+}
+
+// FindSimilarImages returns the images that look like the given one, closest first. Only images the user can see are
+// searched: their own and the public images of other users.
+func (svc *ImageService) FindSimilarImages(imageId string, UserId string, query SimilarityQuery) ([]SimilarImage, error) {
+	if err := query.validate(defaultSimilarDistance); err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(imageId)
+	if err != nil {
+		return nil, ErrInvalidImageId
+	}
+
+	storedImage, err := svc.ImageStore.GetImageById(id)
+	if err != nil {
+		return nil, err
+	}
+	if storedImage.IsPrivate && storedImage.OwnerId != UserId {
+		return nil, ErrImageNotFound
+	}
+
+	matches, indexed, err := svc.Fingerprints.Search(id, query.Hash, *query.MaxDistance, func(entry fingerprintEntry) bool {
+		return entry.OwnerId == UserId || !entry.IsPrivate
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !indexed {
+		// images confirmed before fingerprints existed, or whose pixels couldn't be decoded
+		return []SimilarImage{}, nil
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].Entry.DateTimeCreated.Before(matches[j].Entry.DateTimeCreated)
+	})
+	// the index may lag behind deletions made by other instances, fetch a few more than needed
+	distances := make(map[uuid.UUID]int, len(matches))
+	matchIds := make([]uuid.UUID, 0, min(len(matches), 2*query.Limit))
+	for _, match := range matches[:min(len(matches), 2*query.Limit)] {
+		distances[match.Entry.Id] = match.Distance
+		matchIds = append(matchIds, match.Entry.Id)
+	}
+	if len(matchIds) == 0 {
+		return []SimilarImage{}, nil
+	}
+
+	// the database is authoritative on what still exists and who may see it
+	found, err := svc.ImageStore.GetImagesByIds(matchIds)
+	if err != nil {
+		return nil, err
+	}
+	visible := make([]entities.Image, 0, len(found))
+	for _, foundImage := range found {
+		if foundImage.OwnerId == UserId || !foundImage.IsPrivate {
+			visible = append(visible, foundImage)
+		}
+	}
+	sort.Slice(visible, func(i, j int) bool {
+		first, second := distances[visible[i].Base.Id], distances[visible[j].Base.Id]
+		if first != second {
+			return first < second
+		}
+		return visible[i].Base.DateTimeCreated.Before(visible[j].Base.DateTimeCreated)
+	})
+	visible = visible[:min(len(visible), query.Limit)]
+
+	details, err := svc.imageDetailsList(visible, UserId)
+	if err != nil {
+		return nil, err
+	}
+	similar := make([]SimilarImage, 0, len(details))
+	for _, imageDetails := range details {
+		similar = append(similar, SimilarImage{ImageDetails: imageDetails, Distance: distances[imageDetails.Id]})
+	}
+	return similar, nil
+}
+
+// DuplicateGroups reports the groups of the user's images that look alike, so they can be reviewed and merged
+func (svc *ImageService) DuplicateGroups(UserId string, query SimilarityQuery) ([]DuplicateGroup, error) {
+	if err := query.validate(defaultDuplicateDistance); err != nil {
+		return nil, err
+	}
+
+	entryGroups, err := svc.Fingerprints.DuplicateGroups(UserId, query.Hash, *query.MaxDistance)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uuid.UUID
+	for _, group := range entryGroups {
+		for _, entry := range group {
+			ids = append(ids, entry.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return []DuplicateGroup{}, nil
+	}
+
+	found, err := svc.ImageStore.GetImagesByIds(ids)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[uuid.UUID]entities.Image, len(found))
+	for _, foundImage := range found {
+		byId[foundImage.Base.Id] = foundImage
+	}
+
+	groups := make([]DuplicateGroup, 0, len(entryGroups))
+	for _, group := range entryGroups {
+		var images []DuplicateImage
+		for _, entry := range group {
+			// deleted since the index was built
+			storedImage, ok := byId[entry.Id]
+			if !ok || storedImage.OwnerId != UserId {
+				continue
+			}
+			images = append(images, DuplicateImage{
+				Id:              storedImage.Base.Id,
+				Name:            storedImage.Name,
+				FileSize:        storedImage.ImageMetaData.FileSize,
+				Format:          storedImage.ImageMetaData.Format,
+				Width:           storedImage.ImageMetaData.Width,
+				Height:          storedImage.ImageMetaData.Height,
+				Hash:            storedImage.ImageMetaData.Hash,
+				DateTimeCreated: storedImage.Base.DateTimeCreated,
+			})
+		}
+		if len(images) > 1 {
+			groups = append(groups, DuplicateGroup{Images: images})
+		}
+	}
+	return groups, nil
+}
+
+// indexFingerprint adds a newly confirmed image to the similarity index
+func (svc *ImageService) indexFingerprint(imageId uuid.UUID) {
+	storedImage, err := svc.ImageStore.GetImageById(imageId)
+	if err != nil {
+		log.Printf("failed to index fingerprint of image %s: %v", imageId.String(), err)
+		return
+	}
+	if fingerprint, ok := imageFingerprintOf(*storedImage); ok {
+		svc.Fingerprints.Add(fingerprint)
+	}
+}
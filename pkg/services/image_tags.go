@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/storage/label"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxNameLength bounds tag and label names, in characters
+const maxNameLength = 64
+
+// tag matching modes of ListImagesQuery
+const (
+	TagMatchAny = "any"
+	TagMatchAll = "all"
+)
+
+// TagCount is a tag of the user with the number of images carrying it
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// LabelDetails is a label applied to an image
+type LabelDetails struct {
+	Name       string  `json:"name"`
+	Source     string  `json:"source"`
+	Confidence float64 `json:"confidence"`
+}
+
+// normalizeNames trims and lowercases tag or label names and drops duplicates, so "Beach" and "beach " are one tag
+func normalizeNames(names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTag, name)
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		normalized = append(normalized, name)
+	}
+	return normalized, nil
+}
+
+// splitTagFilter accepts tags both as repeated query parameters and as a comma separated list
+func splitTagFilter(tags []string) []string {
+	var split []string
+	for _, tag := range tags {
+		split = append(split, strings.Split(tag, ",")...)
+	}
+	return split
+}
+
+// TagImages adds and removes tags of the user's images in one transaction. The ids of the updated images are returned
+// along with one error per image that could not be updated.
+func (svc *ImageService) TagImages(imageIds []string, add []string, remove []string, UserId string) ([]uuid.UUID, []error) {
+	add, err := normalizeNames(add)
+	if err != nil {
+		return nil, []error{err}
+	}
+	remove, err = normalizeNames(remove)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	owned, errs := svc.ownedImages(imageIds, UserId, "tag")
+	if len(owned) == 0 {
+		return nil, errs
+	}
+	ownedIds := make([]uuid.UUID, 0, len(owned))
+	for _, ownedImage := range owned {
+		ownedIds = append(ownedIds, ownedImage.Base.Id)
+	}
+
+	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return nil, append(errs, fmt.Errorf("failed to start transaction: %w", err))
+	}
+	if len(add) > 0 {
+		tags, tagErr := svc.TagStore.EnsureTagsWithTransaction(tx, UserId, add)
+		if tagErr == nil {
+			tagErr = svc.TagStore.AddImageTagsWithTransaction(tx, ownedIds, tags)
+		}
+		err = tagErr
+	}
+	if err == nil && len(remove) > 0 {
+		err = svc.TagStore.RemoveImageTagsWithTransaction(tx, ownedIds, UserId, remove)
+	}
+	if err == nil {
+		err = svc.ImageStore.RefreshSearchDocumentsWithTransaction(tx, ownedIds)
+	}
+	if err == nil {
+		err = addImageUpdatedEventsWithTransaction(tx, svc.OutboxStore, UserId, ownedIds, ImageChangeTags)
+	}
+	if err == nil {
+		err = commit()
+	}
+	if err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return nil, append(errs, err)
+	}
+	svc.Events.Notify()
+	return ownedIds, errs
+}
+
+// ListTags returns the user's tags with how many of their images carry each, most used first
+func (svc *ImageService) ListTags(UserId string) ([]TagCount, error) {
+	counts, err := svc.TagStore.ListTagCounts(UserId)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]TagCount, 0, len(counts))
+	for _, count := range counts {
+		tags = append(tags, TagCount{Name: count.Name, Count: count.Count})
+	}
+	return tags, nil
+}
+
+// LabelImage applies user labels to one of the user's images
+func (svc *ImageService) LabelImage(imageId string, names []string, UserId string) error {
+	storedImage, names, err := svc.labelTarget(imageId, names, UserId)
+	if err != nil {
+		return err
+	}
+
+	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	confidences := make(map[string]float64, len(names))
+	for _, name := range names {
+		confidences[name] = 1
+	}
+	err = setImageLabelsWithTransaction(tx, svc.LabelStore, storedImage.Base.Id, common.LABEL_SOURCE_USER, confidences)
+	if err == nil {
+		err = svc.ImageStore.RefreshSearchDocumentsWithTransaction(tx, []uuid.UUID{storedImage.Base.Id})
+	}
+	if err == nil {
+		err = addImageUpdatedEventsWithTransaction(tx, svc.OutboxStore, storedImage.OwnerId, []uuid.UUID{storedImage.Base.Id}, ImageChangeLabels)
+	}
+	if err == nil {
+		err = commit()
+	}
+	if err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return err
+	}
+	svc.Events.Notify()
+	return nil
+}
+
+// UnlabelImage removes user labels from one of the user's images, machine labels stay
+func (svc *ImageService) UnlabelImage(imageId string, names []string, UserId string) error {
+	storedImage, names, err := svc.labelTarget(imageId, names, UserId)
+	if err != nil {
+		return err
+	}
+
+	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	err = svc.LabelStore.RemoveImageLabelsWithTransaction(tx, storedImage.Base.Id, common.LABEL_SOURCE_USER, names)
+	if err == nil {
+		err = svc.ImageStore.RefreshSearchDocumentsWithTransaction(tx, []uuid.UUID{storedImage.Base.Id})
+	}
+	if err == nil {
+		err = addImageUpdatedEventsWithTransaction(tx, svc.OutboxStore, storedImage.OwnerId, []uuid.UUID{storedImage.Base.Id}, ImageChangeLabels)
+	}
+	if err == nil {
+		err = commit()
+	}
+	if err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return err
+	}
+	svc.Events.Notify()
+	return nil
+}
+
+// setImageLabelsWithTransaction applies labels from one source to an image, keyed by name with their confidence
+func setImageLabelsWithTransaction(tx *gorm.DB, labelStore *label.LabelStore, imageId uuid.UUID, source string, confidences map[string]float64) error {
+	if len(confidences) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(confidences))
+	for name := range confidences {
+		names = append(names, name)
+	}
+	labels, err := labelStore.EnsureLabelsWithTransaction(tx, names)
+	if err != nil {
+		return err
+	}
+	imageLabels := make([]entities.ImageLabel, 0, len(labels))
+	for _, storedLabel := range labels {
+		imageLabels = append(imageLabels, entities.ImageLabel{
+			ImageId:    imageId,
+			LabelId:    storedLabel.Base.Id,
+			Source:     source,
+			Confidence: confidences[storedLabel.Name],
+		})
+	}
+	return labelStore.SetImageLabelsWithTransaction(tx, imageLabels)
+}
+
+// labelTarget checks that the user owns the image whose labels are changed and normalizes the label names
+func (svc *ImageService) labelTarget(imageId string, names []string, UserId string) (*entities.Image, []string, error) {
+	names, err := normalizeNames(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := uuid.Parse(imageId)
+	if err != nil {
+		return nil, nil, ErrInvalidImageId
+	}
+	storedImage, err := svc.ImageStore.GetImageById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if storedImage.OwnerId != UserId && storedImage.IsPrivate {
+		return nil, nil, ErrImageNotFound
+	}
+	if storedImage.OwnerId != UserId {
+		return nil, nil, ErrImageAccessDenied
+	}
+	return storedImage, names, nil
+}
+
+// imageAnnotations returns the tags and labels of the images, keyed by image id
+func (svc *ImageService) imageAnnotations(imageIds []uuid.UUID) (map[uuid.UUID][]string, map[uuid.UUID][]LabelDetails, error) {
+	tags := make(map[uuid.UUID][]string, len(imageIds))
+	labels := make(map[uuid.UUID][]LabelDetails, len(imageIds))
+	if len(imageIds) == 0 {
+		return tags, labels, nil
+	}
+
+	tagNames, err := svc.TagStore.ListImageTags(imageIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tagName := range tagNames {
+		tags[tagName.ImageId] = append(tags[tagName.ImageId], tagName.Name)
+	}
+
+	entries, err := svc.LabelStore.ListImageLabels(imageIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		labels[entry.ImageId] = append(labels[entry.ImageId], labelDetails(entry))
+	}
+	return tags, labels, nil
+}
+
+func labelDetails(entry label.LabelEntry) LabelDetails {
+	return LabelDetails{Name: entry.Name, Source: entry.Source, Confidence: entry.Confidence}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"bit-image/pkg/config"
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// output formats derived images can be encoded to
+const (
+	FormatJPEG = config.FormatJPEG
+	FormatPNG  = config.FormatPNG
+)
+
+// formatContentTypes maps every supported output format to the Content-Type it is stored with
+var formatContentTypes = map[string]string{
+	FormatJPEG: "image/jpeg",
+	FormatPNG:  "image/png",
+}
+
+// formatExtensions maps every supported output format to the extension of its object key
+var formatExtensions = map[string]string{
+	FormatJPEG: "jpg",
+	FormatPNG:  "png",
+}
+
+// fitWithin scales width x height down to fit a box of maxWidth x maxHeight keeping the aspect ratio, images are
+// never scaled up. A zero bound leaves that side unconstrained.
+func fitWithin(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight {
+		scale = min(scale, float64(maxHeight)/float64(height))
+	}
+	return max(1, int(float64(width)*scale+0.5)), max(1, int(float64(height)*scale+0.5))
+}
+
+// resizeImage scales the src rectangle of an image to width x height. JPEG has no alpha channel, so for it transparent
+// areas are flattened onto white instead of turning black.
+func resizeImage(img image.Image, src image.Rectangle, width, height int, format string) *image.RGBA {
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	if format == FormatJPEG {
+		draw.Draw(resized, resized.Bounds(), image.White, image.Point{}, draw.Src)
+	}
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, src, draw.Over, nil)
+	return resized
+}
+
+// encodeImage encodes an image to one of the output formats, quality only applies to JPEG
+func encodeImage(img image.Image, format string, quality int) ([]byte, error) {
+	var encoded bytes.Buffer
+	var err error
+	switch format {
+	case FormatJPEG:
+		err = jpeg.Encode(&encoded, img, &jpeg.Options{Quality: quality})
+	case FormatPNG:
+		err = png.Encode(&encoded, img)
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", format, err)
+	}
+	return encoded.Bytes(), nil
+}
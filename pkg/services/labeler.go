@@ -0,0 +1,54 @@
+package services
+
+import (
+	"bit-image/pkg/config"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// labeler implementations selected with LABELER
+const (
+	LabelerLocal = config.LabelerLocal
+	LabelerHTTP  = config.LabelerHTTP
+	LabelerNone  = config.LabelerNone
+)
+
+// LabelInput is the image handed to a Labeler. Width and Height are those of the stored pixels, Orientation the EXIF
+// orientation they are displayed with, 0 when unknown.
+type LabelInput struct {
+	ImageId     uuid.UUID
+	Format      string
+	MimeType    string
+	Width       int
+	Height      int
+	Orientation int
+	Content     []byte
+}
+
+// MachineLabel is a label suggested by a Labeler, Confidence is between 0 and 1
+type MachineLabel struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Labeler describes what an image shows. It is called in the background after an image is confirmed, see
+// AutoLabeler, and its results are stored as machine labels. An error is retried later with backoff.
+type Labeler interface {
+	Label(ctx context.Context, input LabelInput) ([]MachineLabel, error)
+}
+
+// NewLabeler returns the Labeler selected with LABELER, nil when machine labeling is turned off
+func NewLabeler(cfg *config.Config) (Labeler, error) {
+	switch kind := cfg.Labeling.Labeler; kind {
+	case LabelerLocal:
+		return NewLocalLabeler(cfg.Labeling.Local), nil
+	case LabelerHTTP:
+		return NewHTTPLabeler(context.Background(), cfg.Labeling.HTTP)
+	case LabelerNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown LABELER %q, expected %s, %s or %s", kind, LabelerLocal, LabelerHTTP, LabelerNone)
+	}
+}
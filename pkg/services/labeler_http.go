@@ -0,0 +1,104 @@
+package services
+
+import (
+	"bit-image/pkg/config"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// maxLabelerResponse bounds how much of a labeling response is read
+const maxLabelerResponse = 1 << 20
+
+// HTTPLabeler sends the image to an inference endpoint, such as a SageMaker endpoint's invocations url, and reads the
+// labels back. The request body is the image itself with its MIME type as Content-Type, the response has to be JSON
+// of the form {"labels": [{"name": "dog", "confidence": 0.93}]}.
+type HTTPLabeler struct {
+	Endpoint string
+	Client   *http.Client
+	// Token is sent as a bearer token when set
+	Token string
+	// Region turns on SigV4 signing for Service with the default AWS credentials, as SageMaker runtime expects
+	Region      string
+	Service     string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+}
+
+// httpLabelerResponse is the body expected back from the endpoint
+type httpLabelerResponse struct {
+	Labels []MachineLabel `json:"labels"`
+}
+
+// NewHTTPLabeler sends images to the configured endpoint, loading the AWS credentials when requests are signed
+func NewHTTPLabeler(ctx context.Context, cfg config.HTTPLabelerConfig) (*HTTPLabeler, error) {
+	labeler := &HTTPLabeler{
+		Endpoint: cfg.URL,
+		Client:   &http.Client{Timeout: cfg.Timeout},
+		Token:    cfg.Token,
+		Region:   cfg.SigV4Region,
+		Service:  cfg.SigV4Service,
+	}
+	if labeler.Region != "" {
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(labeler.Region))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load aws credentials for the labeler: %w", err)
+		}
+		labeler.credentials = awsCfg.Credentials
+		labeler.signer = v4.NewSigner()
+	}
+	return labeler, nil
+}
+
+func (labeler *HTTPLabeler) Label(ctx context.Context, input LabelInput) ([]MachineLabel, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, labeler.Endpoint, bytes.NewReader(input.Content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create labeling request: %w", err)
+	}
+	request.Header.Set("Content-Type", input.MimeType)
+	request.Header.Set("Accept", "application/json")
+	if labeler.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+labeler.Token)
+	}
+	if labeler.signer != nil {
+		credentials, err := labeler.credentials.Retrieve(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve aws credentials: %w", err)
+		}
+		payloadHash := sha256.Sum256(input.Content)
+		err = labeler.signer.SignHTTP(ctx, credentials, request, hex.EncodeToString(payloadHash[:]), labeler.Service, labeler.Region, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign labeling request: %w", err)
+		}
+	}
+
+	response, err := labeler.Client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call labeler: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxLabelerResponse))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read labeler response: %w", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("labeler responded with %s: %s", response.Status, bytes.TrimSpace(body[:min(len(body), 512)]))
+	}
+
+	var decoded httpLabelerResponse
+	if err = json.Unmarshal(body, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode labeler response: %w", err)
+	}
+	return decoded.Labels, nil
+}
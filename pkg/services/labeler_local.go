@@ -0,0 +1,241 @@
+package services
+
+import (
+	"bit-image/pkg/config"
+	"bytes"
+	"context"
+	"fmt"
+	goimage "image"
+	"math"
+	"sort"
+)
+
+// LocalLabeler labels images with heuristics computed from their pixels: orientation, dominant colors, brightness and
+// sharpness. It needs no external service, which keeps the labeling pipeline usable offline and in development.
+type LocalLabeler struct {
+	// SampleSize is the box images are downscaled to before they are analyzed
+	SampleSize int
+	// MaxColors is how many dominant colors are reported at most
+	MaxColors int
+	// BlurThreshold is the variance of the Laplacian of the sample below which an image is considered blurry
+	BlurThreshold float64
+}
+
+func NewLocalLabeler(cfg config.LocalLabelerConfig) *LocalLabeler {
+	return &LocalLabeler{
+		SampleSize:    cfg.SampleSize,
+		MaxColors:     cfg.MaxColors,
+		BlurThreshold: cfg.BlurThreshold,
+	}
+}
+
+// minColorShare is the share of the pixels a color needs to count as dominant
+const minColorShare = 0.1
+
+func (labeler *LocalLabeler) Label(ctx context.Context, input LabelInput) ([]MachineLabel, error) {
+	decoded, _, err := goimage.Decode(bytes.NewReader(input.Content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %w", input.ImageId.String(), err)
+	}
+
+	bounds := decoded.Bounds()
+	width, height := fitWithin(bounds.Dx(), bounds.Dy(), labeler.SampleSize, labeler.SampleSize)
+	// PNG keeps transparent pixels transparent, they are left out of the statistics
+	sample := resizeImage(decoded, bounds, width, height, FormatPNG)
+
+	labels := orientationLabels(bounds.Dx(), bounds.Dy(), input.Orientation)
+	labels = append(labels, labeler.colorLabels(sample)...)
+	labels = append(labels, brightnessLabels(sample)...)
+	labels = append(labels, labeler.sharpnessLabels(sample)...)
+	return labels, nil
+}
+
+// orientationLabels tells landscape, portrait, square and panorama images apart, as they are displayed. EXIF
+// orientations 5 to 8 rotate the image by a quarter turn.
+func orientationLabels(width, height, orientation int) []MachineLabel {
+	if width == 0 || height == 0 {
+		return nil
+	}
+	if orientation >= 5 && orientation <= 8 {
+		width, height = height, width
+	}
+	ratio := float64(width) / float64(height)
+	switch {
+	case ratio >= 2.5 || ratio <= 0.4:
+		return []MachineLabel{{Name: "orientation:panorama", Confidence: 1}}
+	case math.Abs(ratio-1) <= 0.05:
+		return []MachineLabel{{Name: "orientation:square", Confidence: 1}}
+	case ratio > 1:
+		return []MachineLabel{{Name: "orientation:landscape", Confidence: 1}}
+	default:
+		return []MachineLabel{{Name: "orientation:portrait", Confidence: 1}}
+	}
+}
+
+// colorLabels names the colors covering the largest shares of the sample. A color covering half of the image or more
+// is reported with full confidence.
+func (labeler *LocalLabeler) colorLabels(sample *goimage.RGBA) []MachineLabel {
+	counts := make(map[string]int)
+	total := 0
+	forEachOpaquePixel(sample, func(r, g, b float64) {
+		counts[colorName(r, g, b)]++
+		total++
+	})
+	if total == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var labels []MachineLabel
+	for _, name := range names[:min(len(names), labeler.MaxColors)] {
+		share := float64(counts[name]) / float64(total)
+		if share < minColorShare {
+			break
+		}
+		labels = append(labels, MachineLabel{Name: "color:" + name, Confidence: min(1, 0.5+share)})
+	}
+	return labels
+}
+
+// colorName buckets a color by its hue, saturation and value into one of a dozen names
+func colorName(r, g, b float64) string {
+	maxC, minC := max(r, g, b), min(r, g, b)
+	value, chroma := maxC, maxC-minC
+	saturation := 0.0
+	if maxC > 0 {
+		saturation = chroma / maxC
+	}
+	switch {
+	case value < 0.2:
+		return "black"
+	case saturation < 0.15 && value > 0.85:
+		return "white"
+	case saturation < 0.15:
+		return "gray"
+	}
+
+	var hue float64
+	switch maxC {
+	case r:
+		hue = math.Mod((g-b)/chroma+6, 6)
+	case g:
+		hue = (b-r)/chroma + 2
+	default:
+		hue = (r-g)/chroma + 4
+	}
+	hue *= 60
+
+	switch {
+	case hue < 15 || hue >= 345:
+		return "red"
+	case hue < 45 && value < 0.6:
+		return "brown"
+	case hue < 45:
+		return "orange"
+	case hue < 70:
+		return "yellow"
+	case hue < 165:
+		return "green"
+	case hue < 195:
+		return "cyan"
+	case hue < 255:
+		return "blue"
+	case hue < 290:
+		return "purple"
+	default:
+		return "pink"
+	}
+}
+
+// brightnessLabels reports images whose mean luma is far from the middle as dark or bright
+func brightnessLabels(sample *goimage.RGBA) []MachineLabel {
+	sum, total := 0.0, 0
+	forEachOpaquePixel(sample, func(r, g, b float64) {
+		sum += luma(r, g, b)
+		total++
+	})
+	if total == 0 {
+		return nil
+	}
+	mean := sum / float64(total)
+	switch {
+	case mean < 0.3:
+		return []MachineLabel{{Name: "brightness:dark", Confidence: 0.5 + 0.5*(0.3-mean)/0.3}}
+	case mean > 0.7:
+		return []MachineLabel{{Name: "brightness:bright", Confidence: 0.5 + 0.5*(mean-0.7)/0.3}}
+	}
+	return nil
+}
+
+// sharpnessLabels uses the variance of the Laplacian of the luma, which is low when there are few sharp edges. Images
+// between BlurThreshold and three times it are left unlabeled.
+func (labeler *LocalLabeler) sharpnessLabels(sample *goimage.RGBA) []MachineLabel {
+	bounds := sample.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 3 || height < 3 || labeler.BlurThreshold <= 0 {
+		return nil
+	}
+
+	grey := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			offset := sample.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+			pixel := sample.Pix[offset : offset+3]
+			grey[y*width+x] = 255 * luma(float64(pixel[0])/255, float64(pixel[1])/255, float64(pixel[2])/255)
+		}
+	}
+
+	var sum, sumSquares float64
+	count := 0
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			i := y*width + x
+			laplacian := grey[i-width] + grey[i+width] + grey[i-1] + grey[i+1] - 4*grey[i]
+			sum += laplacian
+			sumSquares += laplacian * laplacian
+			count++
+		}
+	}
+	mean := sum / float64(count)
+	variance := sumSquares/float64(count) - mean*mean
+
+	switch {
+	case variance < labeler.BlurThreshold:
+		return []MachineLabel{{Name: "sharpness:blurry", Confidence: 1 - 0.5*variance/labeler.BlurThreshold}}
+	case variance > 3*labeler.BlurThreshold:
+		return []MachineLabel{{Name: "sharpness:sharp", Confidence: min(1, 0.5+0.5*(variance-3*labeler.BlurThreshold)/(3*labeler.BlurThreshold))}}
+	}
+	return nil
+}
+
+// forEachOpaquePixel calls fn with the straight color of every pixel that is mostly opaque, components between 0 and 1
+func forEachOpaquePixel(sample *goimage.RGBA, fn func(r, g, b float64)) {
+	bounds := sample.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			offset := sample.PixOffset(x, y)
+			pixel := sample.Pix[offset : offset+4]
+			if pixel[3] < 128 {
+				continue
+			}
+			// RGBA is premultiplied
+			alpha := float64(pixel[3])
+			fn(float64(pixel[0])/alpha, float64(pixel[1])/alpha, float64(pixel[2])/alpha)
+		}
+	}
+}
+
+// luma is the perceived brightness of a color, Rec. 601 weights
+func luma(r, g, b float64) float64 {
+	return 0.299*r + 0.587*g + 0.114*b
+}
@@ -0,0 +1,462 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"slices"
+
+	"golang.org/x/image/tiff"
+)
+
+var errMalformedContainer = errors.New("malformed image container")
+
+// exifHeader prefixes the TIFF structure holding EXIF in JPEG APP1 segments, and sometimes in WebP
+var exifHeader = []byte("Exif\x00\x00")
+
+// findExif returns the raw EXIF block embedded in the content, nil when there is none
+func findExif(content []byte, format string) ([]byte, error) {
+	switch format {
+	case "jpeg":
+		segments, _, err := jpegSegments(content)
+		if err != nil {
+			return nil, err
+		}
+		for _, segment := range segments {
+			if segment.marker == 0xDA {
+				// EXIF is only read from the header, the way viewers do
+				break
+			}
+			if segment.marker == 0xE1 && bytes.HasPrefix(segment.payload(), exifHeader) {
+				return segment.payload(), nil
+			}
+		}
+	case "png":
+		chunks, err := pngChunks(content)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			if chunk.kind == "eXIf" {
+				return chunk.data, nil
+			}
+		}
+	case "webp":
+		chunks, err := riffChunks(content)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			if chunk.kind == "EXIF" {
+				return chunk.data, nil
+			}
+		}
+	case "tiff":
+		// the whole file is a TIFF structure, camera tags live next to the image ones
+		return content, nil
+	}
+	return nil, nil
+}
+
+// stripMetadata removes EXIF, XMP, IPTC and text metadata from the content and reports whether there was anything to
+// remove. The orientation is kept whenever it isn't the default, so the stripped copy is displayed the same way.
+// BMP carries no such metadata, TIFF is re-encoded with nothing but its pixels.
+func stripMetadata(content []byte, format string, orientation int) ([]byte, bool, error) {
+	switch format {
+	case "jpeg":
+		return stripJPEG(content, orientation)
+	case "png":
+		return stripPNG(content, orientation)
+	case "webp":
+		return stripWebP(content, orientation)
+	case "gif":
+		return stripGIF(content)
+	case "tiff":
+		decoded, err := tiff.Decode(bytes.NewReader(content))
+		if err != nil {
+			return nil, false, err
+		}
+		var encoded bytes.Buffer
+		if err = tiff.Encode(&encoded, decoded, &tiff.Options{Compression: tiff.Deflate}); err != nil {
+			return nil, false, err
+		}
+		return encoded.Bytes(), true, nil
+	}
+	return content, false, nil
+}
+
+// orientationExif is a minimal EXIF block holding nothing but the orientation tag
+func orientationExif(orientation int) []byte {
+	block := make([]byte, 0, 26)
+	block = append(block, "MM\x00\x2a"...)
+	block = binary.BigEndian.AppendUint32(block, 8)
+	block = binary.BigEndian.AppendUint16(block, 1)
+	// tag 0x0112, type SHORT, count 1, value left-aligned in the 4 byte field
+	block = binary.BigEndian.AppendUint16(block, 0x0112)
+	block = binary.BigEndian.AppendUint16(block, 3)
+	block = binary.BigEndian.AppendUint32(block, 1)
+	block = binary.BigEndian.AppendUint16(block, uint16(orientation))
+	block = binary.BigEndian.AppendUint16(block, 0)
+	return binary.BigEndian.AppendUint32(block, 0)
+}
+
+type jpegSegment struct {
+	marker byte
+	// raw is the whole segment, including its marker and length
+	raw []byte
+}
+
+func (segment jpegSegment) payload() []byte {
+	return segment.raw[4:]
+}
+
+// jpegSegments splits a JPEG into its segments up to EOI, which isn't part of them. The entropy-coded data of a scan
+// is kept in the SOS segment it follows. What comes after EOI, such as the secondary images of an MPF file, is returned
+// as the trailer.
+func jpegSegments(content []byte) ([]jpegSegment, []byte, error) {
+	if len(content) < 4 || content[0] != 0xFF || content[1] != 0xD8 {
+		return nil, nil, errMalformedContainer
+	}
+	var segments []jpegSegment
+	offset := 2
+	for offset+2 <= len(content) {
+		if content[offset] != 0xFF {
+			return nil, nil, errMalformedContainer
+		}
+		marker := content[offset+1]
+		if marker == 0xFF {
+			// fill byte before a marker
+			offset++
+			continue
+		}
+		if marker == 0xD9 {
+			return segments, content[offset+2:], nil
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			segments = append(segments, jpegSegment{marker: marker, raw: content[offset : offset+2]})
+			offset += 2
+			continue
+		}
+		if offset+4 > len(content) {
+			return nil, nil, errMalformedContainer
+		}
+		length := int(binary.BigEndian.Uint16(content[offset+2:]))
+		if length < 2 || offset+2+length > len(content) {
+			return nil, nil, errMalformedContainer
+		}
+		end := offset + 2 + length
+		if marker == 0xDA {
+			end = scanEnd(content, end)
+		}
+		segments = append(segments, jpegSegment{marker: marker, raw: content[offset:end]})
+		offset = end
+	}
+	// truncated files without EOI still decode, their image data runs to the end
+	if offset == len(content) {
+		return segments, nil, nil
+	}
+	return nil, nil, errMalformedContainer
+}
+
+// scanEnd returns where the entropy-coded data starting at offset ends, at the first marker that isn't a stuffed byte
+// or a restart marker
+func scanEnd(content []byte, offset int) int {
+	for ; offset+1 < len(content); offset++ {
+		if content[offset] != 0xFF {
+			continue
+		}
+		next := content[offset+1]
+		if next != 0x00 && next != 0xFF && (next < 0xD0 || next > 0xD7) {
+			return offset
+		}
+	}
+	return len(content)
+}
+
+var mpfHeader = []byte("MPF\x00")
+
+// strippedJPEGSegment reports whether the segment carries metadata: APP1 (EXIF and XMP), APP2 when it is the MPF index
+// of secondary images, APP13 (IPTC) and comments. JFIF, ICC profiles and the Adobe segment are needed to display the
+// image correctly and are kept, but only tables are needed between scans.
+func strippedJPEGSegment(segment jpegSegment, betweenScans bool) bool {
+	switch segment.marker {
+	case 0xE1, 0xED, 0xFE:
+		return true
+	case 0xE2:
+		if bytes.HasPrefix(segment.payload(), mpfHeader) {
+			return true
+		}
+	}
+	return betweenScans && segment.marker >= 0xE0 && segment.marker <= 0xEF
+}
+
+// stripJPEG drops the metadata segments anywhere in the file and everything after EOI
+func stripJPEG(content []byte, orientation int) ([]byte, bool, error) {
+	segments, trailer, err := jpegSegments(content)
+	if err != nil {
+		return nil, false, err
+	}
+
+	stripped := make([]byte, 0, len(content))
+	stripped = append(stripped, 0xFF, 0xD8)
+	changed, orientationWritten, betweenScans := len(trailer) > 0, orientation <= 1, false
+	for _, segment := range segments {
+		if strippedJPEGSegment(segment, betweenScans) {
+			changed = true
+			continue
+		}
+		// the EXIF segment has to follow JFIF when there is one
+		if !orientationWritten && segment.marker != 0xE0 {
+			stripped = appendJPEGExif(stripped, orientation)
+			orientationWritten = true
+		}
+		stripped = append(stripped, segment.raw...)
+		if segment.marker == 0xDA {
+			betweenScans = true
+		}
+	}
+	if !orientationWritten {
+		stripped = appendJPEGExif(stripped, orientation)
+	}
+	return append(stripped, 0xFF, 0xD9), changed, nil
+}
+
+func appendJPEGExif(content []byte, orientation int) []byte {
+	exif := append(append([]byte{}, exifHeader...), orientationExif(orientation)...)
+	content = append(content, 0xFF, 0xE1)
+	content = binary.BigEndian.AppendUint16(content, uint16(len(exif)+2))
+	return append(content, exif...)
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+type pngChunk struct {
+	kind string
+	data []byte
+	// raw is the whole chunk, including its length, type and crc
+	raw []byte
+}
+
+func pngChunks(content []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(content, pngSignature) {
+		return nil, errMalformedContainer
+	}
+	var chunks []pngChunk
+	offset := len(pngSignature)
+	for offset < len(content) {
+		if offset+12 > len(content) {
+			return nil, errMalformedContainer
+		}
+		length := int(binary.BigEndian.Uint32(content[offset:]))
+		if offset+12+length > len(content) {
+			return nil, errMalformedContainer
+		}
+		chunks = append(chunks, pngChunk{
+			kind: string(content[offset+4 : offset+8]),
+			data: content[offset+8 : offset+8+length],
+			raw:  content[offset : offset+12+length],
+		})
+		offset += 12 + length
+	}
+	return chunks, nil
+}
+
+// stripPNG drops the eXIf chunk and every text chunk, tools put XMP and GPS comments in those
+func stripPNG(content []byte, orientation int) ([]byte, bool, error) {
+	chunks, err := pngChunks(content)
+	if err != nil {
+		return nil, false, err
+	}
+
+	stripped := make([]byte, 0, len(content))
+	stripped = append(stripped, pngSignature...)
+	changed := false
+	for _, chunk := range chunks {
+		switch chunk.kind {
+		case "eXIf", "tEXt", "zTXt", "iTXt":
+			changed = true
+			continue
+		}
+		stripped = append(stripped, chunk.raw...)
+		if chunk.kind == "IHDR" && orientation > 1 {
+			stripped = appendPNGChunk(stripped, "eXIf", orientationExif(orientation))
+		}
+	}
+	return stripped, changed, nil
+}
+
+func appendPNGChunk(content []byte, kind string, data []byte) []byte {
+	content = binary.BigEndian.AppendUint32(content, uint32(len(data)))
+	start := len(content)
+	content = append(content, kind...)
+	content = append(content, data...)
+	return binary.BigEndian.AppendUint32(content, crc32.ChecksumIEEE(content[start:]))
+}
+
+type riffChunk struct {
+	kind string
+	data []byte
+}
+
+// riffChunks splits a WebP file into its chunks
+func riffChunks(content []byte) ([]riffChunk, error) {
+	if len(content) < 12 || string(content[:4]) != "RIFF" || string(content[8:12]) != "WEBP" {
+		return nil, errMalformedContainer
+	}
+	var chunks []riffChunk
+	offset := 12
+	for offset < len(content) {
+		if offset+8 > len(content) {
+			return nil, errMalformedContainer
+		}
+		length := int(binary.LittleEndian.Uint32(content[offset+4:]))
+		if offset+8+length > len(content) {
+			return nil, errMalformedContainer
+		}
+		chunks = append(chunks, riffChunk{kind: string(content[offset : offset+4]), data: content[offset+8 : offset+8+length]})
+		// chunks are padded to an even size
+		offset += 8 + length + length%2
+	}
+	return chunks, nil
+}
+
+// VP8X flags announcing the metadata chunks of an extended WebP
+const (
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+// stripWebP drops the EXIF and XMP chunks, updating the flags of the extended header to match
+func stripWebP(content []byte, orientation int) ([]byte, bool, error) {
+	chunks, err := riffChunks(content)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// metadata chunks can only be present in the extended format, which is also needed to add the orientation
+	extended := len(chunks) > 0 && chunks[0].kind == "VP8X" && len(chunks[0].data) >= 10
+	keepOrientation := extended && orientation > 1
+
+	body := make([]byte, 0, len(content))
+	changed := false
+	for _, chunk := range chunks {
+		data := chunk.data
+		switch chunk.kind {
+		case "EXIF", "XMP ":
+			changed = true
+			continue
+		case "VP8X":
+			data = append([]byte{}, data...)
+			data[0] &^= webpFlagXMP | webpFlagEXIF
+			if keepOrientation {
+				data[0] |= webpFlagEXIF
+			}
+		}
+		body = appendRIFFChunk(body, chunk.kind, data)
+	}
+	if keepOrientation {
+		body = appendRIFFChunk(body, "EXIF", orientationExif(orientation))
+	}
+	if !changed {
+		return content, false, nil
+	}
+
+	stripped := make([]byte, 0, len(body)+12)
+	stripped = append(stripped, "RIFF"...)
+	stripped = binary.LittleEndian.AppendUint32(stripped, uint32(len(body)+4))
+	stripped = append(stripped, "WEBP"...)
+	return append(stripped, body...), true, nil
+}
+
+func appendRIFFChunk(content []byte, kind string, data []byte) []byte {
+	content = append(content, kind...)
+	content = binary.LittleEndian.AppendUint32(content, uint32(len(data)))
+	content = append(content, data...)
+	if len(data)%2 == 1 {
+		content = append(content, 0)
+	}
+	return content
+}
+
+// gifKeptApplications are the application extensions needed to play the image, the loop count of animations
+var gifKeptApplications = []string{"NETSCAPE2.0", "ANIMEXTS1.0"}
+
+// stripGIF drops comment extensions and application extensions other than the animation loop count, XMP is stored in
+// one of those. Everything after the trailer is dropped as well.
+func stripGIF(content []byte) ([]byte, bool, error) {
+	if len(content) < 13 || (string(content[:6]) != "GIF87a" && string(content[:6]) != "GIF89a") {
+		return nil, false, errMalformedContainer
+	}
+	offset := 13
+	if flags := content[10]; flags&0x80 != 0 {
+		offset += 3 << (flags&0x07 + 1)
+	}
+	if offset > len(content) {
+		return nil, false, errMalformedContainer
+	}
+
+	stripped := make([]byte, 0, len(content))
+	stripped = append(stripped, content[:offset]...)
+	changed := false
+	for offset < len(content) {
+		start := offset
+		switch content[offset] {
+		case 0x3B:
+			return append(stripped, 0x3B), changed || offset+1 < len(content), nil
+		case 0x21:
+			if offset+2 > len(content) {
+				return nil, false, errMalformedContainer
+			}
+			label := content[offset+1]
+			end, err := gifSubBlocksEnd(content, offset+2)
+			if err != nil {
+				return nil, false, err
+			}
+			offset = end
+			if label == 0xFE || (label == 0xFF && !gifKeptApplication(content[start+2:end])) {
+				changed = true
+				continue
+			}
+		case 0x2C:
+			offset += 10
+			if offset > len(content) {
+				return nil, false, errMalformedContainer
+			}
+			if flags := content[offset-1]; flags&0x80 != 0 {
+				offset += 3 << (flags&0x07 + 1)
+			}
+			// the LZW minimum code size precedes the image data
+			end, err := gifSubBlocksEnd(content, offset+1)
+			if err != nil {
+				return nil, false, err
+			}
+			offset = end
+		default:
+			return nil, false, errMalformedContainer
+		}
+		stripped = append(stripped, content[start:offset]...)
+	}
+	// a GIF cut before its trailer still displays
+	return append(stripped, 0x3B), changed, nil
+}
+
+// gifSubBlocksEnd returns where the sub-blocks starting at offset end, past their zero length terminator
+func gifSubBlocksEnd(content []byte, offset int) (int, error) {
+	for offset < len(content) {
+		size := int(content[offset])
+		offset += 1 + size
+		if size == 0 {
+			return offset, nil
+		}
+	}
+	return 0, errMalformedContainer
+}
+
+// gifKeptApplication reports whether the sub-blocks of an application extension identify one that is kept
+func gifKeptApplication(blocks []byte) bool {
+	if len(blocks) < 12 || blocks[0] != 11 {
+		return false
+	}
+	return slices.Contains(gifKeptApplications, string(blocks[1:12]))
+}
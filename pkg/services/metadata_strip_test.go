@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+)
+
+// secret is put in every metadata block, none of it may be left in a stripped copy
+const secret = "gps 48.8584 2.2945"
+
+func rawJPEGSegment(marker byte, payload string) []byte {
+	segment := []byte{0xFF, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withJPEGSegments inserts header segments after SOI and trailing segments before EOI, then appends the trailer
+func withJPEGSegments(content []byte, header, trailing [][]byte, trailer []byte) []byte {
+	var result []byte
+	result = append(result, content[:2]...)
+	result = append(result, bytes.Join(header, nil)...)
+	result = append(result, content[2:len(content)-2]...)
+	result = append(result, bytes.Join(trailing, nil)...)
+	result = append(result, content[len(content)-2:]...)
+	return append(result, trailer...)
+}
+
+func gifBlocks(label byte, blocks ...string) []byte {
+	extension := []byte{0x21, label}
+	for _, block := range blocks {
+		extension = append(extension, byte(len(block)))
+		extension = append(extension, block...)
+	}
+	return append(extension, 0)
+}
+
+func TestStripJPEG(t *testing.T) {
+	plain := encodedImage(t, 16, 16, encodeJPEG)
+	icc := rawJPEGSegment(0xE2, "ICC_PROFILE\x00\x01\x01profile")
+	secondImage := withJPEGSegments(plain, [][]byte{rawJPEGSegment(0xE1, "Exif\x00\x00"+secret)}, nil, nil)
+	tests := []struct {
+		name        string
+		content     []byte
+		wantChanged bool
+		wantKept    []string
+	}{
+		{name: "nothing to strip", content: plain},
+		{
+			name:        "exif, xmp, iptc and comments in the header",
+			content:     withJPEGSegments(plain, [][]byte{rawJPEGSegment(0xE1, "Exif\x00\x00"+secret), rawJPEGSegment(0xE1, "http://ns.adobe.com/xap/1.0/\x00"+secret), rawJPEGSegment(0xED, "Photoshop 3.0\x00"+secret), rawJPEGSegment(0xFE, secret)}, nil, nil),
+			wantChanged: true,
+		},
+		{
+			name:        "mpf index and secondary images after EOI",
+			content:     withJPEGSegments(plain, [][]byte{icc, rawJPEGSegment(0xE2, "MPF\x00"+secret)}, nil, secondImage),
+			wantChanged: true,
+			wantKept:    []string{"ICC_PROFILE"},
+		},
+		{
+			name:        "segments between scans",
+			content:     withJPEGSegments(plain, nil, [][]byte{rawJPEGSegment(0xFE, secret), rawJPEGSegment(0xE5, secret)}, nil),
+			wantChanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, changed, err := stripMetadata(tt.content, "jpeg", 0)
+			if err != nil {
+				t.Fatalf("stripMetadata() error = %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("stripMetadata() changed = %t, want %t", changed, tt.wantChanged)
+			}
+			if bytes.Contains(stripped, []byte(secret)) {
+				t.Errorf("stripped copy still holds the metadata")
+			}
+			for _, kept := range tt.wantKept {
+				if !bytes.Contains(stripped, []byte(kept)) {
+					t.Errorf("stripped copy lost %q", kept)
+				}
+			}
+			if _, err = jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+				t.Errorf("stripped copy doesn't decode: %v", err)
+			}
+		})
+	}
+}
+
+func TestStripJPEGKeepsOrientation(t *testing.T) {
+	content := withJPEGSegments(encodedImage(t, 16, 16, encodeJPEG), [][]byte{rawJPEGSegment(0xE1, "Exif\x00\x00"+secret)}, nil, nil)
+	stripped, _, err := stripMetadata(content, "jpeg", 6)
+	if err != nil {
+		t.Fatalf("stripMetadata() error = %v", err)
+	}
+	block, err := findExif(stripped, "jpeg")
+	if err != nil || block == nil {
+		t.Fatalf("findExif() = %v, %v, want the orientation block", block, err)
+	}
+	if exif := parseExif(block); exif == nil || exif.Orientation != 6 {
+		t.Errorf("parseExif() = %+v, want orientation 6", exif)
+	}
+}
+
+func TestStripGIF(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	var animated bytes.Buffer
+	err := gif.EncodeAll(&animated, &gif.GIF{
+		Image: []*image.Paletted{
+			image.NewPaletted(image.Rect(0, 0, 4, 4), palette),
+			image.NewPaletted(image.Rect(0, 0, 4, 4), palette),
+		},
+		Delay:     []int{10, 10},
+		LoopCount: 3,
+	})
+	if err != nil {
+		t.Fatalf("failed to encode test gif: %v", err)
+	}
+	plain := animated.Bytes()
+	body := plain[:len(plain)-1]
+	withMetadata := func(blocks ...[]byte) []byte {
+		content := append(append([]byte{}, body...), bytes.Join(blocks, nil)...)
+		return append(content, 0x3B)
+	}
+
+	tests := []struct {
+		name        string
+		content     []byte
+		wantChanged bool
+	}{
+		{name: "nothing to strip", content: plain},
+		{name: "comment", content: withMetadata(gifBlocks(0xFE, secret)), wantChanged: true},
+		{name: "xmp", content: withMetadata(gifBlocks(0xFF, "XMP DataXMP", secret, secret)), wantChanged: true},
+		{name: "data after the trailer", content: append(append([]byte{}, plain...), secret...), wantChanged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, changed, err := stripMetadata(tt.content, "gif", 0)
+			if err != nil {
+				t.Fatalf("stripMetadata() error = %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("stripMetadata() changed = %t, want %t", changed, tt.wantChanged)
+			}
+			if bytes.Contains(stripped, []byte(secret)) {
+				t.Errorf("stripped copy still holds the metadata")
+			}
+			decoded, err := gif.DecodeAll(bytes.NewReader(stripped))
+			if err != nil {
+				t.Fatalf("stripped copy doesn't decode: %v", err)
+			}
+			if len(decoded.Image) != 2 || decoded.LoopCount != 3 {
+				t.Errorf("stripped copy has %d frames looping %d times, want 2 frames looping 3 times", len(decoded.Image), decoded.LoopCount)
+			}
+		})
+	}
+}
+
+func TestStripGIFMalformed(t *testing.T) {
+	plain := encodedImage(t, 4, 4, encodeGIF)
+	for name, content := range map[string][]byte{
+		"not a gif":            []byte("GIF00a definitely not"),
+		"unterminated block":   append(append([]byte{}, plain[:len(plain)-1]...), 0x21, 0xFE, 10, 'a'),
+		"unknown block":        append(append([]byte{}, plain[:len(plain)-1]...), 0x42),
+		"truncated descriptor": append(append([]byte{}, plain[:len(plain)-1]...), 0x2C, 0, 0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := stripMetadata(content, "gif", 0); err == nil {
+				t.Errorf("stripMetadata() error = nil, want errMalformedContainer")
+			}
+		})
+	}
+}
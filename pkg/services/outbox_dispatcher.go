@@ -0,0 +1,151 @@
+package services
+
+import (
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage/outbox"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	outboxBatchSize = 100
+	// outboxLease is how long a claimed event is left alone before another dispatcher may deliver it again, it has to
+	// outlast a publish
+	outboxLease = 2 * time.Minute
+	// outboxMaxBackoff caps the exponential backoff between attempts, a failing event holds back the later events of its
+	// image so retries don't back off as far as the other workers do
+	outboxMaxBackoff = 15 * time.Minute
+)
+
+// OutboxDispatcher publishes the image events recorded in the outbox. Every event is delivered at least once: it is
+// only marked delivered after the publisher acknowledged it, and failures are retried with exponential backoff until
+// they succeed. The events of an image are published one at a time in the order they were recorded, events of
+// different images concurrently. Delivered events are kept for Retention so they can be looked into, then deleted.
+// Every event is also queued for the webhooks of the owner of its image before it is published.
+type OutboxDispatcher struct {
+	OutboxStore *outbox.OutboxStore
+	Publisher   EventPublisher
+	Webhooks    *WebhookDispatcher
+	Interval    time.Duration
+	Retention   time.Duration
+	Timeout     time.Duration
+	Workers     int
+	// wake cuts the wait for the next tick short after events were committed
+	wake chan struct{}
+}
+
+func NewOutboxDispatcher(outboxStore *outbox.OutboxStore, publisher EventPublisher, webhooks *WebhookDispatcher, cfg *config.Config) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		OutboxStore: outboxStore,
+		Publisher:   publisher,
+		Webhooks:    webhooks,
+		Interval:    cfg.Outbox.Interval,
+		Retention:   cfg.Outbox.Retention,
+		Timeout:     cfg.Outbox.PublishTimeout,
+		Workers:     cfg.Outbox.Workers,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Notify tells a running dispatcher that events were committed, they are then published without waiting for the tick
+func (dispatcher *OutboxDispatcher) Notify() {
+	select {
+	case dispatcher.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes due events on every tick and whenever notified, until the context is cancelled
+func (dispatcher *OutboxDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(dispatcher.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := dispatcher.DispatchDue(ctx); err != nil {
+			log.Printf("outbox dispatch failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if deleted, err := dispatcher.OutboxStore.DeleteDeliveredEvents(time.Now().Add(-dispatcher.Retention)); err != nil {
+				log.Printf("failed to delete delivered events: %v", err)
+			} else if deleted > 0 {
+				log.Printf("deleted %d delivered events", deleted)
+			}
+		case <-dispatcher.wake:
+		}
+	}
+}
+
+// DispatchDue publishes every event that is due and returns how many were delivered. Each claim only holds the oldest
+// pending event of every image, so it keeps claiming until nothing is left.
+func (dispatcher *OutboxDispatcher) DispatchDue(ctx context.Context) (int, error) {
+	delivered := 0
+	for ctx.Err() == nil {
+		claimed, err := dispatcher.OutboxStore.ClaimEvents(outboxBatchSize, time.Now().Add(outboxLease))
+		if err != nil {
+			return delivered, err
+		}
+		if len(claimed) == 0 {
+			return delivered, nil
+		}
+		delivered += dispatcher.publish(ctx, claimed)
+	}
+	return delivered, ctx.Err()
+}
+
+// publish delivers claimed events, they all belong to different images and go out concurrently
+func (dispatcher *OutboxDispatcher) publish(ctx context.Context, claimed []entities.OutboxEvent) int {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		delivered int
+	)
+	workers := make(chan struct{}, dispatcher.Workers)
+	for _, event := range claimed {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(event entities.OutboxEvent) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			if dispatcher.deliver(ctx, event) {
+				mu.Lock()
+				delivered++
+				mu.Unlock()
+			}
+		}(event)
+	}
+	wg.Wait()
+	return delivered
+}
+
+func (dispatcher *OutboxDispatcher) deliver(ctx context.Context, event entities.OutboxEvent) bool {
+	publishCtx, cancel := context.WithTimeout(ctx, dispatcher.Timeout)
+	defer cancel()
+
+	// queueing webhooks is idempotent, so a failure of either step retries both
+	envelope := eventEnvelope(event)
+	err := dispatcher.Webhooks.Enqueue(envelope)
+	if err == nil {
+		err = dispatcher.Publisher.Publish(publishCtx, envelope)
+	}
+	if err != nil {
+		backoff := min(5*time.Second<<min(event.Attempts-1, 10), outboxMaxBackoff)
+		if markErr := dispatcher.OutboxStore.MarkEventFailed(event.Sequence, err.Error(), time.Now().Add(backoff)); markErr != nil {
+			log.Printf("failed to record failure of event %d: %v", event.Sequence, markErr)
+		}
+		return false
+	}
+	// when this fails the event is published again once its lease runs out, consumers dedupe on the event id
+	if err := dispatcher.OutboxStore.MarkEventDelivered(event.Sequence); err != nil {
+		log.Printf("failed to record delivery of event %d: %v", event.Sequence, err)
+		return false
+	}
+	dispatcher.Webhooks.Notify()
+	return true
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"bit-image/pkg/storage/image"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor turns the position of the last image of a page into an opaque token for the client
+func encodeCursor(cursor image.ImageCursor) string {
+	raw := cursor.DateTimeCreated.UTC().Format(time.RFC3339Nano) + "|" + cursor.Id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(token string) (*image.ImageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	created, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	dateTimeCreated, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	imageId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &image.ImageCursor{DateTimeCreated: dateTimeCreated, Id: imageId}, nil
+}
+
+// pageSize clamps the requested page size, zero picks the default
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	return min(limit, maxPageSize)
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"bit-image/pkg/storage/image"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor image.ImageCursor
+	}{
+		{"utc", image.ImageCursor{DateTimeCreated: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), Id: uuid.New()}},
+		{"nanoseconds are kept", image.ImageCursor{DateTimeCreated: time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC), Id: uuid.New()}},
+		{"other zone", image.ImageCursor{DateTimeCreated: time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600)), Id: uuid.New()}},
+		{"zero id", image.ImageCursor{DateTimeCreated: time.Unix(0, 0), Id: uuid.Nil}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoded, err := decodeCursor(encodeCursor(test.cursor))
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if !decoded.DateTimeCreated.Equal(test.cursor.DateTimeCreated) || decoded.Id != test.cursor.Id {
+				t.Errorf("decodeCursor() = %+v, want %+v", *decoded, test.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsInvalidTokens(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not base64", "not a cursor!"},
+		{"no separator", encode("2024-03-01T12:30:00Z")},
+		{"invalid time", encode("yesterday|" + uuid.NewString())},
+		{"invalid id", encode("2024-03-01T12:30:00Z|42")},
+		{"empty", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := decodeCursor(test.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor(%q) error = %v, want %v", test.token, err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	tests := []struct {
+		limit, want int
+	}{
+		{-1, defaultPageSize},
+		{0, defaultPageSize},
+		{1, 1},
+		{maxPageSize, maxPageSize},
+		{maxPageSize + 1, maxPageSize},
+	}
+	for _, test := range tests {
+		if got := pageSize(test.limit); got != test.want {
+			t.Errorf("pageSize(%d) = %d, want %d", test.limit, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package services
+
+import (
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage"
+	"bit-image/pkg/storage/image"
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const reconcileBatchSize = 1000
+
+// kinds of discrepancies found by reconciliation
+const (
+	// DiscrepancyMissingObject is an image whose object is gone
+	DiscrepancyMissingObject = "missing_object"
+	// DiscrepancyOrphanObject is an object no image points at
+	DiscrepancyOrphanObject = "orphan_object"
+	// DiscrepancySizeMismatch is an image whose object doesn't have the size recorded for it
+	DiscrepancySizeMismatch = "size_mismatch"
+	// DiscrepancyInFlight counts objects and images of confirmations that may still be running, they are left alone
+	DiscrepancyInFlight = "in_flight"
+)
+
+// repair actions taken on discrepancies
+const (
+	RepairRecreate   = config.RepairRecreate
+	RepairQuarantine = config.RepairQuarantine
+	RepairMarkBroken = config.RepairMarkBroken
+)
+
+// ReconcileRepairs are the repairs a reconciliation may make, without any it only reports. Orphan objects are
+// recreated as private images of the owner in their key when Recreate is set and quarantined when that isn't possible
+// or only Quarantine is set. Images with a missing or mismatched object are flagged broken with MarkBroken.
+type ReconcileRepairs struct {
+	Recreate   bool `json:"recreate"`
+	Quarantine bool `json:"quarantine"`
+	MarkBroken bool `json:"mark_broken"`
+}
+
+// ParseReconcileRepairs reads repair names as listed in RECONCILE_REPAIRS
+func ParseReconcileRepairs(names []string) (ReconcileRepairs, error) {
+	var repairs ReconcileRepairs
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case RepairRecreate:
+			repairs.Recreate = true
+		case RepairQuarantine:
+			repairs.Quarantine = true
+		case RepairMarkBroken:
+			repairs.MarkBroken = true
+		case "":
+		default:
+			return repairs, fmt.Errorf("unknown repair %q, expected %s, %s or %s", name, RepairRecreate, RepairQuarantine, RepairMarkBroken)
+		}
+	}
+	return repairs, nil
+}
+
+// Discrepancy is one difference between the images table and the bucket, Action is the repair made for it if any
+type Discrepancy struct {
+	Kind       string     `json:"kind"`
+	Key        string     `json:"key"`
+	ImageId    *uuid.UUID `json:"image_id,omitempty"`
+	OwnerId    string     `json:"owner_id,omitempty"`
+	RowSize    *int64     `json:"row_size,omitempty"`
+	ObjectSize *int64     `json:"object_size,omitempty"`
+	// Broken is set for images that were already flagged broken
+	Broken bool   `json:"broken,omitempty"`
+	Action string `json:"action,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ReconcileReport is the outcome of a reconciliation. Counts has every discrepancy by kind and Repaired every repair by
+// action, Discrepancies lists at most MaxReported of them and is Truncated beyond that.
+type ReconcileReport struct {
+	Prefix         string           `json:"prefix"`
+	StartedAt      time.Time        `json:"started_at"`
+	FinishedAt     time.Time        `json:"finished_at"`
+	Repairs        ReconcileRepairs `json:"repairs"`
+	ScannedRows    int              `json:"scanned_rows"`
+	ScannedObjects int              `json:"scanned_objects"`
+	Counts         map[string]int   `json:"counts"`
+	Repaired       map[string]int   `json:"repaired"`
+	Discrepancies  []Discrepancy    `json:"discrepancies"`
+	Truncated      bool             `json:"truncated"`
+}
+
+// Reconciler finds drift between the images table and the objects under PERMANENT_STORAGE, and repairs it when asked
+// to. Both sides are streamed in key order and merged, so neither has to fit in memory. Objects younger than Grace may
+// belong to a confirmation that hasn't committed yet and are skipped.
+type Reconciler struct {
+	ImageService *ImageService
+	Interval     time.Duration
+	Grace        time.Duration
+	// Repairs are made by scheduled runs, the command line picks its own
+	Repairs     ReconcileRepairs
+	MaxReported int
+}
+
+func NewReconciler(imageService *ImageService, cfg *config.Config) (*Reconciler, error) {
+	repairs, err := ParseReconcileRepairs(cfg.Reconcile.Repairs)
+	if err != nil {
+		return nil, err
+	}
+	return &Reconciler{
+		ImageService: imageService,
+		Interval:     cfg.Reconcile.Interval,
+		Grace:        cfg.Reconcile.GracePeriod,
+		Repairs:      repairs,
+		MaxReported:  cfg.Reconcile.MaxReported,
+	}, nil
+}
+
+// Run reconciles on every tick until the context is cancelled. The first run waits for a tick, listing the whole
+// bucket on every start would be too much.
+func (reconciler *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(reconciler.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := reconciler.Reconcile(ctx, reconciler.Repairs)
+		if err != nil {
+			log.Printf("reconciliation failed: %v", err)
+		}
+		if report != nil {
+			log.Printf("reconciliation scanned %d images and %d objects, found %v, repaired %v",
+				report.ScannedRows, report.ScannedObjects, report.Counts, report.Repaired)
+		}
+	}
+}
+
+// Reconcile compares every image stored under PERMANENT_STORAGE with the objects there. Objects have to be listed in
+// bytewise key order, the way S3 lists them, rows are read in the same order.
+func (reconciler *Reconciler) Reconcile(ctx context.Context, repairs ReconcileRepairs) (*ReconcileReport, error) {
+	prefix := common.PERMANENT_STORAGE_FOLDER + "/"
+	report := &ReconcileReport{
+		Prefix:        prefix,
+		StartedAt:     time.Now(),
+		Repairs:       repairs,
+		Counts:        map[string]int{},
+		Repaired:      map[string]int{},
+		Discrepancies: []Discrepancy{},
+	}
+	cutoff := report.StartedAt.Add(-reconciler.Grace)
+
+	var listErr error
+	nextObject, stop := iter.Pull(func(yield func(storage.ObjectInfo) bool) {
+		listErr = reconciler.ImageService.S3Handler.ListObjects(prefix, func(object storage.ObjectInfo) error {
+			if !yield(object) {
+				return errStopListing
+			}
+			return nil
+		})
+	})
+	defer stop()
+	nextRow := reconciler.rows(prefix)
+
+	var (
+		object    storage.ObjectInfo
+		hasObject bool
+		err       error
+	)
+	// a listing that fails ends like a complete one, the rows past it must not be taken for missing objects
+	advanceObject := func() {
+		if object, hasObject = nextObject(); !hasObject && listErr != nil && !errors.Is(listErr, errStopListing) {
+			err = listErr
+		}
+	}
+	advanceObject()
+	row, hasRow, rowErr := nextRow()
+	for err == nil && rowErr == nil && ctx.Err() == nil && (hasObject || hasRow) {
+		switch {
+		case hasObject && hasRow && object.Key == row.Path:
+			reconciler.matched(report, repairs, row, object)
+			advanceObject()
+			row, hasRow, rowErr = nextRow()
+		case hasObject && (!hasRow || object.Key < row.Path):
+			reconciler.orphan(report, repairs, object, cutoff)
+			advanceObject()
+		default:
+			reconciler.missing(report, repairs, row, cutoff)
+			row, hasRow, rowErr = nextRow()
+		}
+	}
+	stop()
+	report.FinishedAt = time.Now()
+
+	if err == nil {
+		err = rowErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return report, err
+}
+
+// errStopListing ends an object listing early
+var errStopListing = errors.New("listing stopped")
+
+// rows pages through the images under the prefix in the order objects are listed
+func (reconciler *Reconciler) rows(prefix string) func() (entities.Image, bool, error) {
+	var (
+		page  []entities.Image
+		after *image.PathCursor
+		done  bool
+	)
+	return func() (entities.Image, bool, error) {
+		if len(page) == 0 && !done {
+			var err error
+			page, err = reconciler.ImageService.ImageStore.ListImagesByPath(prefix, after, reconcileBatchSize)
+			if err != nil {
+				return entities.Image{}, false, err
+			}
+			done = len(page) < reconcileBatchSize
+		}
+		if len(page) == 0 {
+			return entities.Image{}, false, nil
+		}
+		row := page[0]
+		page = page[1:]
+		after = &image.PathCursor{Path: row.Path, Id: row.Base.Id}
+		return row, true, nil
+	}
+}
+
+func (reconciler *Reconciler) matched(report *ReconcileReport, repairs ReconcileRepairs, row entities.Image, object storage.ObjectInfo) {
+	report.ScannedRows++
+	report.ScannedObjects++
+	rowSize := int64(row.ImageMetaData.FileSize)
+	if row.DateTimeDeleted.Valid || rowSize == object.Size {
+		return
+	}
+	discrepancy := rowDiscrepancy(DiscrepancySizeMismatch, row)
+	discrepancy.ObjectSize = &object.Size
+	if repairs.MarkBroken && row.BrokenAt == nil {
+		reconciler.markBroken(&discrepancy, fmt.Sprintf("object is %d bytes, expected %d", object.Size, rowSize))
+	}
+	reconciler.add(report, discrepancy)
+}
+
+// missing reports an image without an object. Soft-deleted images are skipped, the purger removes them anyway, and so
+// are images confirmed after the listing may have gone past their key.
+func (reconciler *Reconciler) missing(report *ReconcileReport, repairs ReconcileRepairs, row entities.Image, cutoff time.Time) {
+	report.ScannedRows++
+	if row.DateTimeDeleted.Valid {
+		return
+	}
+	if row.Base.DateTimeCreated.After(cutoff) {
+		report.Counts[DiscrepancyInFlight]++
+		return
+	}
+	discrepancy := rowDiscrepancy(DiscrepancyMissingObject, row)
+	if repairs.MarkBroken && row.BrokenAt == nil {
+		reconciler.markBroken(&discrepancy, "object is missing")
+	}
+	reconciler.add(report, discrepancy)
+}
+
+func (reconciler *Reconciler) orphan(report *ReconcileReport, repairs ReconcileRepairs, object storage.ObjectInfo, cutoff time.Time) {
+	report.ScannedObjects++
+	ownerId, imageId, parseErr := parsePermanentKey(object.Key)
+	if object.LastModified.After(cutoff) || (parseErr == nil && reconciler.uploadMoving(ownerId, imageId)) {
+		report.Counts[DiscrepancyInFlight]++
+		return
+	}
+
+	discrepancy := Discrepancy{Kind: DiscrepancyOrphanObject, Key: object.Key, ObjectSize: &object.Size}
+	if parseErr == nil {
+		discrepancy.ImageId, discrepancy.OwnerId = &imageId, ownerId
+	}
+
+	var repairErr error
+	if repairs.Recreate {
+		repairErr = parseErr
+		if repairErr == nil {
+			repairErr = reconciler.recreate(ownerId, imageId, object)
+		}
+		if repairErr == nil {
+			discrepancy.Action = RepairRecreate
+		}
+	}
+	if discrepancy.Action == "" && repairs.Quarantine {
+		if repairErr = reconciler.quarantine(object.Key); repairErr == nil {
+			discrepancy.Action = RepairQuarantine
+		}
+	}
+	if repairErr != nil && discrepancy.Action == "" {
+		discrepancy.Error = repairErr.Error()
+	}
+	reconciler.add(report, discrepancy)
+}
+
+// uploadMoving reports whether the object belongs to an upload that is still being moved, the UploadRecovery deals
+// with those
+func (reconciler *Reconciler) uploadMoving(ownerId string, imageId uuid.UUID) bool {
+	pendingUpload, err := reconciler.ImageService.UploadStore.GetUpload(imageId, ownerId)
+	return err == nil && pendingUpload.State == entities.UploadMoving
+}
+
+// recreate records an orphan object as a private image of the owner in its key, named after its id, the way a
+// confirmation would have
+func (reconciler *Reconciler) recreate(ownerId string, imageId uuid.UUID, object storage.ObjectInfo) error {
+	svc := reconciler.ImageService
+	exists, err := svc.ImageStore.ImageExists(imageId)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("image %s is stored elsewhere", imageId.String())
+	}
+
+	objectInfo, err := svc.S3Handler.HeadObject(object.Key)
+	if err != nil {
+		return err
+	}
+	if err = svc.checkObjectSize(objectInfo); err != nil {
+		return err
+	}
+	body, err := svc.S3Handler.GetObject(object.Key)
+	if err != nil {
+		return err
+	}
+	properties, err := inspectImage(body, objectInfo.ChecksumSHA256 == "", svc.MaxImagePixels)
+	body.Close()
+	if err != nil {
+		return err
+	}
+	checksum := objectInfo.ChecksumSHA256
+	if checksum == "" {
+		checksum = properties.ChecksumSHA256
+	}
+
+	newImage := entities.Image{
+		Base:      common.Base{Id: imageId, DateTimeCreated: object.LastModified},
+		OwnerId:   ownerId,
+		Name:      imageId.String(),
+		IsPrivate: true,
+		Path:      object.Key,
+		ImageMetaData: common.ImageMetaData{
+			Hash:       checksum,
+			FileSize:   float64(objectInfo.Size),
+			Format:     properties.Format,
+			MimeType:   properties.MimeType,
+			Width:      properties.Width,
+			Height:     properties.Height,
+			ColorModel: properties.ColorModel,
+		},
+	}
+	imageExif, err := svc.extractMetadata(&newImage, object.Key)
+	if err != nil {
+		return err
+	}
+
+	tx, commit, rollback, err := svc.ImageStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	if err = svc.insertImageWithTransaction(tx, newImage, imageExif); err == nil {
+		err = commit()
+	}
+	if err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return err
+	}
+	svc.scheduleConfirmed(imageId)
+	return nil
+}
+
+// quarantine moves an orphan object under QUARANTINE_STORAGE, keeping the rest of its key
+func (reconciler *Reconciler) quarantine(key string) error {
+	file := common.File{Id: strings.TrimPrefix(key, common.PERMANENT_STORAGE_FOLDER+"/")}
+	return reconciler.ImageService.S3Handler.MoveFileToFolder(file, common.PERMANENT_STORAGE_FOLDER, common.QUARANTINE_STORAGE_FOLDER)
+}
+
+func (reconciler *Reconciler) markBroken(discrepancy *Discrepancy, reason string) {
+	if err := reconciler.ImageService.ImageStore.MarkImageBroken(*discrepancy.ImageId, reason); err != nil {
+		discrepancy.Error = err.Error()
+		return
+	}
+	discrepancy.Action = RepairMarkBroken
+}
+
+func (reconciler *Reconciler) add(report *ReconcileReport, discrepancy Discrepancy) {
+	report.Counts[discrepancy.Kind]++
+	if discrepancy.Action != "" {
+		report.Repaired[discrepancy.Action]++
+	}
+	if len(report.Discrepancies) < reconciler.MaxReported {
+		report.Discrepancies = append(report.Discrepancies, discrepancy)
+	} else {
+		report.Truncated = true
+	}
+}
+
+func rowDiscrepancy(kind string, row entities.Image) Discrepancy {
+	rowSize := int64(row.ImageMetaData.FileSize)
+	return Discrepancy{
+		Kind:    kind,
+		Key:     row.Path,
+		ImageId: &row.Base.Id,
+		OwnerId: row.OwnerId,
+		RowSize: &rowSize,
+		Broken:  row.BrokenAt != nil,
+	}
+}
+
+// parsePermanentKey splits PERMANENT_STORAGE/<ownerId>/<imageId>
+func parsePermanentKey(key string) (string, uuid.UUID, error) {
+	ownerId, id, found := strings.Cut(strings.TrimPrefix(key, common.PERMANENT_STORAGE_FOLDER+"/"), "/")
+	if !found || ownerId == "" {
+		return "", uuid.Nil, fmt.Errorf("key %s isn't <owner>/<image id>", key)
+	}
+	imageId, err := uuid.Parse(id)
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("key %s isn't <owner>/<image id>", key)
+	}
+	return ownerId, imageId, nil
+}
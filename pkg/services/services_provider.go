@@ -3,4 +3,4 @@ package services
 import "github.com/google/wire"
 
 // ProviderSet for ImageService
-var ProviderSet = wire.NewSet(NewImageService)
+var ProviderSet = wire.NewSet(NewImageService, NewImagePurger, NewUploadReaper, NewDerivativeGenerator, NewAutoLabeler, NewLabeler, NewFingerprintIndex, NewOutboxDispatcher, NewEventPublisher, NewWebhookService, NewWebhookDispatcher, NewUserService, NewUploadRecovery, NewReconciler)
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"bit-image/internal/s3"
+	"bit-image/pkg/common"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage"
+	"bit-image/pkg/storage/upload"
+	"context"
+	"log"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadReaper deletes objects left in TEMP_STORAGE by uploads that were never confirmed. An object is abandoned
+// once it is older than the presigned upload TTL plus a grace period for slow confirmations, unless its upload is
+// uploaded or moving: the confirmation is then being retried or recovered from that object. The records of uploads
+// that expired are dropped at the same time.
+type UploadReaper struct {
+	S3Handler   *s3.Handler
+	UploadStore *upload.UploadStore
+	// UploadURLTTL is how long upload urls stay valid, nothing is abandoned before they expire
+	UploadURLTTL time.Duration
+	GracePeriod  time.Duration
+	Interval     time.Duration
+}
+
+// ReapReport summarizes a single pass of the reaper
+type ReapReport struct {
+	Scanned        int       `json:"scanned"`
+	Deleted        []string  `json:"deleted"`
+	Failed         []string  `json:"failed"`
+	DeletedBytes   int64     `json:"deleted_bytes"`
+	ExpiredUploads int64     `json:"expired_uploads"`
+	Cutoff         time.Time `json:"cutoff"`
+}
+
+func NewUploadReaper(s3Handler *s3.Handler, uploadStore *upload.UploadStore, cfg *config.Config) *UploadReaper {
+	return &UploadReaper{
+		S3Handler:    s3Handler,
+		UploadStore:  uploadStore,
+		UploadURLTTL: cfg.Presign.UploadURLTTL,
+		GracePeriod:  cfg.Uploads.ReaperGracePeriod,
+		Interval:     cfg.Uploads.ReaperInterval,
+	}
+}
+
+// Run reaps on every tick until the context is cancelled
+func (reaper *UploadReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(reaper.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := reaper.ReapAbandonedUploads()
+		if err != nil {
+			log.Printf("upload reaper failed: %v", err)
+		}
+		if report != nil && (len(report.Deleted) > 0 || len(report.Failed) > 0) {
+			log.Printf("upload reaper scanned %d objects, deleted %d (%d bytes), failed %d",
+				report.Scanned, len(report.Deleted), report.DeletedBytes, len(report.Failed))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReapAbandonedUploads pages through TEMP_STORAGE and deletes every object older than the cutoff whose upload isn't
+// being confirmed
+func (reaper *UploadReaper) ReapAbandonedUploads() (*ReapReport, error) {
+	report := &ReapReport{
+		Deleted: []string{},
+		Failed:  []string{},
+		Cutoff:  time.Now().Add(-(reaper.UploadURLTTL + reaper.GracePeriod)),
+	}
+
+	prefix := common.TEMPORARY_STORAGE_FOLDER + "/"
+	var batch []string
+	sizes := make(map[string]int64)
+	flush := func() error {
+		abandoned, err := reaper.skipConfirming(batch)
+		if err != nil {
+			return err
+		}
+		if len(abandoned) == 0 {
+			return nil
+		}
+		failedIds, err := reaper.S3Handler.DeleteFilesFromFolder(abandoned, common.TEMPORARY_STORAGE_FOLDER)
+		if err != nil {
+			log.Printf("upload reaper failed to delete %d objects: %v", len(failedIds), err)
+		}
+
+		failed := make(map[string]struct{}, len(failedIds))
+		for _, fileId := range failedIds {
+			failed[fileId] = struct{}{}
+			report.Failed = append(report.Failed, prefix+fileId)
+		}
+		for _, fileId := range abandoned {
+			if _, ok := failed[fileId]; !ok {
+				report.Deleted = append(report.Deleted, prefix+fileId)
+				report.DeletedBytes += sizes[fileId]
+			}
+		}
+		return nil
+	}
+
+	err := reaper.S3Handler.ListObjects(prefix, func(object storage.ObjectInfo) error {
+		report.Scanned++
+		if !object.LastModified.Before(report.Cutoff) {
+			return nil
+		}
+
+		fileId := strings.TrimPrefix(object.Key, prefix)
+		batch = append(batch, fileId)
+		sizes[fileId] = object.Size
+		if len(batch) < purgeBatchSize {
+			return nil
+		}
+		err := flush()
+		batch = batch[:0]
+		clear(sizes)
+		return err
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return report, err
+	}
+
+	// the presigned url of an upload expires TTL after it was issued, the same grace period applies
+	report.ExpiredUploads, err = reaper.UploadStore.DeleteExpiredUploads(time.Now().Add(-reaper.GracePeriod))
+	return report, err
+}
+
+// skipConfirming drops the objects of uploads that are uploaded or moving from a batch of temporary objects. Objects
+// not named after an upload are kept in the batch.
+func (reaper *UploadReaper) skipConfirming(fileIds []string) ([]string, error) {
+	imageIds := make([]uuid.UUID, 0, len(fileIds))
+	for _, fileId := range fileIds {
+		if imageId, err := uuid.Parse(path.Base(fileId)); err == nil {
+			imageIds = append(imageIds, imageId)
+		}
+	}
+	if len(imageIds) == 0 {
+		return fileIds, nil
+	}
+	confirming, err := reaper.UploadStore.ListConfirmingUploads(imageIds)
+	if err != nil || len(confirming) == 0 {
+		return fileIds, err
+	}
+	return slices.DeleteFunc(slices.Clone(fileIds), func(fileId string) bool {
+		imageId, err := uuid.Parse(path.Base(fileId))
+		return err == nil && slices.Contains(confirming, imageId)
+	}), nil
+}
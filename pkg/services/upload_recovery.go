@@ -0,0 +1,229 @@
+package services
+
+import (
+	"bit-image/internal/s3"
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage"
+	"bit-image/pkg/storage/upload"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const recoveryBatchSize = 100
+
+// errUploadContentLost is returned when the content of an interrupted confirmation is neither where it was uploaded
+// nor where it was being moved to
+var errUploadContentLost = errors.New("uploaded content is gone")
+
+// UploadRecovery finishes confirmations that were interrupted, typically by a crash between moving the content of an
+// upload and committing its image. An upload left moving for longer than StaleAfter has its content put back where it
+// was uploaded and is confirmed again from there with the request it was confirmed with. After MaxAttempts
+// recoveries the upload is failed and its content left to the reaper, so storage and metadata always converge.
+type UploadRecovery struct {
+	ImageService *ImageService
+	UploadStore  *upload.UploadStore
+	S3Handler    *s3.Handler
+	Interval     time.Duration
+	// StaleAfter has to outlast a confirmation, a confirmation still running is taken for an interrupted one otherwise
+	StaleAfter  time.Duration
+	MaxAttempts int
+}
+
+// RecoveryReport summarizes a single pass of the recovery
+type RecoveryReport struct {
+	Claimed   int              `json:"claimed"`
+	Confirmed []string         `json:"confirmed"`
+	Retrying  []string         `json:"retrying"`
+	Failed    []string         `json:"failed"`
+	States    map[string]int64 `json:"states"`
+}
+
+func NewUploadRecovery(imageService *ImageService, cfg *config.Config) *UploadRecovery {
+	return &UploadRecovery{
+		ImageService: imageService,
+		UploadStore:  imageService.UploadStore,
+		S3Handler:    imageService.S3Handler,
+		Interval:     cfg.Uploads.RecoveryInterval,
+		StaleAfter:   cfg.Uploads.RecoveryStaleAfter,
+		MaxAttempts:  cfg.Uploads.RecoveryMaxAttempts,
+	}
+}
+
+// Run recovers interrupted confirmations on every tick until the context is cancelled
+func (recovery *UploadRecovery) Run(ctx context.Context) {
+	ticker := time.NewTicker(recovery.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := recovery.RecoverUploads(ctx)
+		if err != nil {
+			log.Printf("upload recovery failed: %v", err)
+		}
+		if report != nil && report.Claimed > 0 {
+			log.Printf("upload recovery confirmed %d uploads, %d retrying, %d failed",
+				len(report.Confirmed), len(report.Retrying), len(report.Failed))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RecoverUploads claims every interrupted confirmation and resumes it
+func (recovery *UploadRecovery) RecoverUploads(ctx context.Context) (*RecoveryReport, error) {
+	report := &RecoveryReport{Confirmed: []string{}, Retrying: []string{}, Failed: []string{}}
+	for ctx.Err() == nil {
+		claimed, err := recovery.UploadStore.ClaimInterruptedUploads(recoveryBatchSize, time.Now().Add(-recovery.StaleAfter))
+		if err != nil {
+			return report, err
+		}
+		if len(claimed) == 0 {
+			break
+		}
+		report.Claimed += len(claimed)
+		for _, interrupted := range claimed {
+			id := interrupted.Base.Id.String()
+			switch state, err := recovery.recover(interrupted); state {
+			case entities.UploadCommitted:
+				report.Confirmed = append(report.Confirmed, id)
+			case entities.UploadFailed:
+				log.Printf("failed to recover upload %s: %v", id, err)
+				report.Failed = append(report.Failed, id)
+			default:
+				log.Printf("failed to recover upload %s, it will be retried: %v", id, err)
+				report.Retrying = append(report.Retrying, id)
+			}
+		}
+	}
+
+	states, err := recovery.UploadStore.CountUploadsByState()
+	if err != nil {
+		return report, err
+	}
+	report.States = states
+	return report, ctx.Err()
+}
+
+// recover resumes an interrupted confirmation and returns the state it left the upload in
+func (recovery *UploadRecovery) recover(interrupted entities.Upload) (string, error) {
+	if interrupted.State == entities.UploadMoving {
+		err := recovery.ImageService.restoreUploadContent(interrupted.OwnerId, interrupted.Base.Id, interrupted.DestinationKey)
+		if errors.Is(err, errUploadContentLost) {
+			return recovery.fail(interrupted, err)
+		}
+		if err != nil {
+			return entities.UploadMoving, err
+		}
+		if err = recovery.UploadStore.AbortUploadMove(interrupted.Base.Id, "confirmation was interrupted"); err != nil {
+			return entities.UploadMoving, err
+		}
+	}
+	if interrupted.Attempts > recovery.MaxAttempts {
+		return recovery.fail(interrupted, fmt.Errorf("gave up after %d recoveries: %s", recovery.MaxAttempts, interrupted.LastError))
+	}
+
+	err := recovery.ImageService.ConfirmImage(ConfirmUploadRequest{
+		Id:        interrupted.Base.Id.String(),
+		Name:      interrupted.Name,
+		Caption:   interrupted.Caption,
+		IsPrivate: interrupted.IsPrivate,
+	}, interrupted.OwnerId)
+	switch {
+	case err == nil:
+		return entities.UploadCommitted, nil
+	case errors.Is(err, ErrChecksumMismatch), errors.Is(err, ErrInvalidImage), errors.Is(err, ErrQuotaExceeded):
+		// rejected content already failed the upload, going over the quota won't go away by retrying either
+		return recovery.fail(interrupted, err)
+	case errors.Is(err, storage.ErrObjectNotFound):
+		return recovery.fail(interrupted, errUploadContentLost)
+	default:
+		return entities.UploadUploaded, err
+	}
+}
+
+// fail gives up on an upload, dropping the public copy its confirmation may have left behind
+func (recovery *UploadRecovery) fail(interrupted entities.Upload, cause error) (string, error) {
+	if err := recovery.UploadStore.MarkUploadFailed(interrupted.Base.Id, cause.Error()); err != nil {
+		return interrupted.State, err
+	}
+	prefix := derivedPrefix(interrupted.OwnerId, interrupted.Base.Id)
+	err := recovery.S3Handler.ListObjects(prefix, func(object storage.ObjectInfo) error {
+		return recovery.S3Handler.DeleteObject(object.Key)
+	})
+	if err != nil {
+		log.Printf("failed to delete the public copy of upload %s: %v", interrupted.Base.Id.String(), err)
+	}
+	return entities.UploadFailed, cause
+}
+
+// abortUploadMove undoes the move of a confirmation that failed before its image was committed. The upload only goes
+// back to uploaded once its content is back in temporary storage, it is left moving for the recovery otherwise.
+func (svc *ImageService) abortUploadMove(ownerId string, imageId uuid.UUID, destinationKey string, cause error) {
+	if err := svc.restoreUploadContent(ownerId, imageId, destinationKey); err != nil {
+		log.Printf("failed to restore upload %s, it will be recovered: %v", imageId.String(), err)
+		return
+	}
+	if err := svc.UploadStore.AbortUploadMove(imageId, cause.Error()); err != nil {
+		log.Printf("failed to reset upload %s, it will be recovered: %v", imageId.String(), err)
+	}
+}
+
+// restoreUploadContent puts the content of an upload that was being moved to destinationKey back in temporary storage
+// and removes what the move left at the destination. Uploads that turn out to be committed are left alone, the
+// commit may have gone through even though it reported an error.
+func (svc *ImageService) restoreUploadContent(ownerId string, imageId uuid.UUID, destinationKey string) error {
+	current, err := svc.UploadStore.GetUpload(imageId, ownerId)
+	if err != nil {
+		return err
+	}
+	if current.State == entities.UploadCommitted {
+		return nil
+	}
+
+	tempKey := common.TEMPORARY_STORAGE_FOLDER + "/" + ownerId + "/" + imageId.String()
+	inTemp, err := svc.objectExists(tempKey)
+	if err != nil {
+		return err
+	}
+	atDestination := false
+	if destinationKey != "" {
+		if atDestination, err = svc.objectExists(destinationKey); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case inTemp && atDestination:
+		// the copy went through but the upload wasn't deleted yet
+		return svc.S3Handler.DeleteObject(destinationKey)
+	case atDestination:
+		if err = svc.S3Handler.CopyObject(destinationKey, tempKey); err != nil {
+			return fmt.Errorf("failed to move %s back to temporary storage: %w", destinationKey, err)
+		}
+		return svc.S3Handler.DeleteObject(destinationKey)
+	case inTemp:
+		return nil
+	default:
+		return errUploadContentLost
+	}
+}
+
+func (svc *ImageService) objectExists(key string) (bool, error) {
+	if _, err := svc.S3Handler.HeadObject(key); err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
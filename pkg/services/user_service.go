@@ -1,7 +1,281 @@
 package services
 
-import "bit-image/pkg/storage"
+import (
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage"
+	"bit-image/pkg/storage/image"
+	"bit-image/pkg/storage/webhook"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
+)
+
+// maxSuspensionReasonLength bounds the reason given for a suspension, in characters
+const maxSuspensionReasonLength = 500
+
+// UserService provisions the users vouched for by the auth service and lets admins manage them
 type UserService struct {
-	UserStore storage.UserStore
+	UserStore *storage.UserStore
+	// ImageService gives new users their default quota and deletes the images of deleted users
+	ImageService *ImageService
+	WebhookStore *webhook.WebhookStore
+	// provisioned maps the users found provisioned and not deleted to when they were, their requests skip the lookup
+	// for ProvisionedTTL
+	provisioned    sync.Map
+	ProvisionedTTL time.Duration
+}
+
+func NewUserService(userStore *storage.UserStore, imageService *ImageService, webhookStore *webhook.WebhookStore, cfg *config.Config) *UserService {
+	return &UserService{
+		UserStore:      userStore,
+		ImageService:   imageService,
+		WebhookStore:   webhookStore,
+		ProvisionedTTL: cfg.Auth.ProvisionedTTL,
+	}
+}
+
+// UserDetails is the quota of a user and how much of it is used
+type UserDetails struct {
+	Id               uuid.UUID  `json:"id"`
+	ImageUploadLimit int        `json:"image_upload_limit"`
+	ImageUploadCount int        `json:"image_upload_count"`
+	ByteUploadLimit  int64      `json:"byte_upload_limit"`
+	ByteUploadCount  int64      `json:"byte_upload_count"`
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty"`
+	SuspendedReason  string     `json:"suspended_reason,omitempty"`
+	DateTimeCreated  time.Time  `json:"date_time_created"`
+}
+
+// UserProfile is the account of the calling user, with what is left of their quota
+type UserProfile struct {
+	UserDetails
+	IsAdmin         bool  `json:"is_admin"`
+	ImagesRemaining int   `json:"images_remaining"`
+	BytesRemaining  int64 `json:"bytes_remaining"`
+}
+
+// UserList is a page of users, NextCursor is empty on the last page
+type UserList struct {
+	Users      []UserDetails `json:"users"`
+	NextCursor string        `json:"next_cursor"`
+}
+
+// UserDeletion is what deleting a user removed. Their images are soft-deleted and purged along with the others once
+// the retention window has passed.
+type UserDeletion struct {
+	Id            uuid.UUID `json:"id"`
+	DeletedImages int       `json:"deleted_images"`
+}
+
+func userDetails(user entities.User) UserDetails {
+	return UserDetails{
+		Id:               user.Base.Id,
+		ImageUploadLimit: user.ImageUploadLimit,
+		ImageUploadCount: user.ImageUploadCount,
+		ByteUploadLimit:  user.ByteUploadLimit,
+		ByteUploadCount:  user.ByteUploadCount,
+		SuspendedAt:      user.SuspendedAt,
+		SuspendedReason:  user.SuspendedReason,
+		DateTimeCreated:  user.Base.DateTimeCreated,
+	}
+}
+
+// ProvisionUser creates the row of a user with the default quota the first time their token is seen. Deleted users
+// are refused with ErrUserDeleted, suspended ones are let through and only refused upload urls. Users provisioned
+// within ProvisionedTTL are let through without a lookup.
+func (svc *UserService) ProvisionUser(UserId string) error {
+	userId, err := parseUserId(UserId)
+	if err != nil {
+		return err
+	}
+	if provisionedAt, found := svc.provisioned.Load(userId); found && time.Since(provisionedAt.(time.Time)) < svc.ProvisionedTTL {
+		return nil
+	}
+
+	user, err := svc.UserStore.GetUserIncludingDeleted(userId)
+	if errors.Is(err, ErrUserNotFound) {
+		err = svc.UserStore.EnsureUser(svc.ImageService.newUser(userId))
+	} else if err == nil && user.DateTimeDeleted.Valid {
+		svc.provisioned.Delete(userId)
+		return ErrUserDeleted
+	}
+	if err != nil {
+		return err
+	}
+	svc.provisioned.Store(userId, time.Now())
+	return nil
+}
+
+// GetProfile returns the account of the calling user
+func (svc *UserService) GetProfile(UserId string, isAdmin bool) (*UserProfile, error) {
+	details, err := svc.GetUser(UserId)
+	if err != nil {
+		return nil, err
+	}
+	return &UserProfile{
+		UserDetails:     *details,
+		IsAdmin:         isAdmin,
+		ImagesRemaining: max(details.ImageUploadLimit-details.ImageUploadCount, 0),
+		BytesRemaining:  max(details.ByteUploadLimit-details.ByteUploadCount, 0),
+	}, nil
+}
+
+// ListUsers returns a page of users ordered by id, the cursor is the id of the last user of the previous page
+func (svc *UserService) ListUsers(cursor string, limit int) (*UserList, error) {
+	var after *uuid.UUID
+	if cursor != "" {
+		lastId, err := uuid.Parse(cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		after = &lastId
+	}
+
+	limit = pageSize(limit)
+	users, err := svc.UserStore.ListUsers(after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &UserList{Users: make([]UserDetails, 0, len(users))}
+	for _, user := range users {
+		list.Users = append(list.Users, userDetails(user))
+	}
+	if len(users) == limit {
+		list.NextCursor = users[len(users)-1].Base.Id.String()
+	}
+	return list, nil
+}
+
+// GetUser returns the quota and usage of a user
+func (svc *UserService) GetUser(UserId string) (*UserDetails, error) {
+	userId, err := parseUserId(UserId)
+	if err != nil {
+		return nil, err
+	}
+	user, err := svc.UserStore.GetUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	details := userDetails(*user)
+	return &details, nil
+}
+
+// SetQuota changes the limits of a user, nil limits are left as they are
+func (svc *UserService) SetQuota(UserId string, imageLimit *int, byteLimit *int64) (*UserDetails, error) {
+	userId, err := parseUserId(UserId)
+	if err != nil {
+		return nil, err
+	}
+	if (imageLimit != nil && *imageLimit < 0) || (byteLimit != nil && *byteLimit < 0) {
+		return nil, ErrInvalidQuota
+	}
+	user, err := svc.UserStore.SetQuota(userId, imageLimit, byteLimit)
+	if err != nil {
+		return nil, err
+	}
+	details := userDetails(*user)
+	return &details, nil
+}
+
+// SuspendUser stops a user from getting upload urls until the suspension is lifted, their images stay as they are.
+// Suspending a suspended user again only replaces the reason.
+func (svc *UserService) SuspendUser(UserId string, reason string) (*UserDetails, error) {
+	userId, err := parseUserId(UserId)
+	if err != nil {
+		return nil, err
+	}
+	reason = strings.TrimSpace(reason)
+	if len([]rune(reason)) > maxSuspensionReasonLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrInvalidSuspension, maxSuspensionReasonLength)
+	}
+
+	suspendedAt := time.Now()
+	user, err := svc.UserStore.GetUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.SuspendedAt != nil {
+		suspendedAt = *user.SuspendedAt
+	}
+	if user, err = svc.UserStore.SetSuspension(userId, &suspendedAt, reason); err != nil {
+		return nil, err
+	}
+	details := userDetails(*user)
+	return &details, nil
+}
+
+// UnsuspendUser lifts the suspension of a user
+func (svc *UserService) UnsuspendUser(UserId string) (*UserDetails, error) {
+	userId, err := parseUserId(UserId)
+	if err != nil {
+		return nil, err
+	}
+	user, err := svc.UserStore.SetSuspension(userId, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	details := userDetails(*user)
+	return &details, nil
+}
+
+// DeleteUser deletes a user along with their webhook subscriptions and soft-deletes their images. The row of the user
+// is kept so their tokens are refused from then on. Deleting a deleted user again finishes a deletion that was
+// interrupted.
+func (svc *UserService) DeleteUser(UserId string) (*UserDeletion, error) {
+	userId, err := parseUserId(UserId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = svc.UserStore.GetUserIncludingDeleted(userId); err != nil {
+		return nil, err
+	}
+	ownerId := userId.String()
+
+	tx, commit, rollback, err := svc.UserStore.DBHandler.OpenTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	_, err = svc.UserStore.SoftDeleteUserWithTransaction(tx, userId)
+	if err == nil {
+		err = svc.WebhookStore.DeleteOwnerSubscriptionsWithTransaction(tx, ownerId)
+	}
+	if err == nil {
+		err = commit()
+	}
+	if err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return nil, err
+	}
+	// tokens of the user are refused right away on this instance, the others refuse them once ProvisionedTTL passed
+	svc.provisioned.Delete(userId)
+
+	// the user can't upload anymore, so every page of their live images is deleted until there are none left
+	deletion := &UserDeletion{Id: userId}
+	for {
+		storedImages, err := svc.ImageService.ImageStore.ListAllImages(image.AdminImageFilter{OwnerId: ownerId}, nil, maxPageSize)
+		if err != nil {
+			return deletion, err
+		}
+		if len(storedImages) == 0 {
+			return deletion, nil
+		}
+		imageIds := make([]uuid.UUID, 0, len(storedImages))
+		for _, storedImage := range storedImages {
+			imageIds = append(imageIds, storedImage.Base.Id)
+		}
+		deletedIds, err := svc.ImageService.ForceDeleteImages(imageIds)
+		if err != nil {
+			return deletion, err
+		}
+		deletion.DeletedImages += len(deletedIds)
+	}
 }
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestProvisionUserSkipsLookupWithinTTL(t *testing.T) {
+	userId := uuid.New()
+	// no store: any lookup would panic
+	svc := &UserService{ProvisionedTTL: time.Minute}
+	svc.provisioned.Store(userId, time.Now())
+
+	for i := 0; i < 3; i++ {
+		if err := svc.ProvisionUser(userId.String()); err != nil {
+			t.Fatalf("ProvisionUser() error = %v", err)
+		}
+	}
+	if err := svc.ProvisionUser("alice"); !errors.Is(err, ErrInvalidUserId) {
+		t.Errorf("ProvisionUser(%q) error = %v, want %v", "alice", err, ErrInvalidUserId)
+	}
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage/webhook"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	webhookBatchSize = 100
+	// webhookLease is how long a claimed delivery is left alone before another dispatcher may send it again, it has to
+	// outlast a request
+	webhookLease      = 2 * time.Minute
+	webhookBaseDelay  = 30 * time.Second
+	webhookMaxBackoff = 6 * time.Hour
+)
+
+// WebhookDispatcher sends image events to the webhook subscriptions of their owners. Every event is queued once per
+// matching subscription when the OutboxDispatcher publishes it, deliveries are retried with exponential backoff and
+// dead-lettered after MaxAttempts. Payloads are signed with the secret of the subscription: X-Webhook-Signature is
+// sha256= followed by the hex HMAC-SHA256 of X-Webhook-Timestamp, a dot and the body.
+type WebhookDispatcher struct {
+	WebhookStore *webhook.WebhookStore
+	Client       *http.Client
+	Interval     time.Duration
+	Retention    time.Duration
+	MaxAttempts  int
+	Workers      int
+	// AllowPrivateNetworks lets webhooks reach loopback and private addresses, for development
+	AllowPrivateNetworks bool
+	// wake cuts the wait for the next tick short after deliveries were queued
+	wake chan struct{}
+}
+
+func NewWebhookDispatcher(webhookStore *webhook.WebhookStore, cfg *config.Config) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		WebhookStore: webhookStore,
+		Client: &http.Client{
+			Timeout:   cfg.Webhooks.Timeout,
+			Transport: webhookTransport(cfg.Webhooks.AllowPrivateNetworks),
+			// a redirect fails the attempt, endpoints are expected to answer at the url they subscribed with
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Interval:             cfg.Webhooks.Interval,
+		Retention:            cfg.Webhooks.DeliveryRetention,
+		MaxAttempts:          cfg.Webhooks.MaxAttempts,
+		Workers:              cfg.Webhooks.Workers,
+		AllowPrivateNetworks: cfg.Webhooks.AllowPrivateNetworks,
+		wake:                 make(chan struct{}, 1),
+	}
+}
+
+// Enqueue queues an event for the active subscriptions of the owner of its image that want it. It is safe to call
+// again for the same event, a subscription gets every event once.
+func (dispatcher *WebhookDispatcher) Enqueue(envelope EventEnvelope) error {
+	var data struct {
+		OwnerId string `json:"owner_id"`
+	}
+	if err := json.Unmarshal(envelope.Data, &data); err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", envelope.Type, err)
+	}
+	if data.OwnerId == "" {
+		return nil
+	}
+
+	subscriptions, err := dispatcher.WebhookStore.ListActiveSubscriptions(data.OwnerId)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	now := time.Now()
+	var deliveries []entities.WebhookDelivery
+	for _, subscription := range subscriptions {
+		if eventTypes := subscribedEventTypes(subscription); len(eventTypes) > 0 && !slices.Contains(eventTypes, envelope.Type) {
+			continue
+		}
+		deliveries = append(deliveries, entities.WebhookDelivery{
+			SubscriptionId: subscription.Base.Id,
+			EventId:        envelope.Id,
+			EventType:      envelope.Type,
+			ImageId:        envelope.ImageId,
+			Payload:        string(payload),
+			Status:         entities.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+		})
+	}
+	return dispatcher.WebhookStore.AddDeliveries(deliveries)
+}
+
+// Notify tells a running dispatcher that deliveries were queued, they are then sent without waiting for the tick
+func (dispatcher *WebhookDispatcher) Notify() {
+	select {
+	case dispatcher.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries on every tick and whenever notified, until the context is cancelled
+func (dispatcher *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(dispatcher.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := dispatcher.DeliverDue(ctx); err != nil {
+			log.Printf("webhook dispatch failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if deleted, err := dispatcher.WebhookStore.DeleteFinishedDeliveries(time.Now().Add(-dispatcher.Retention)); err != nil {
+				log.Printf("failed to delete finished webhook deliveries: %v", err)
+			} else if deleted > 0 {
+				log.Printf("deleted %d finished webhook deliveries", deleted)
+			}
+		case <-dispatcher.wake:
+		}
+	}
+}
+
+// DeliverDue sends every delivery that is due and returns how many were acknowledged
+func (dispatcher *WebhookDispatcher) DeliverDue(ctx context.Context) (int, error) {
+	delivered := 0
+	for ctx.Err() == nil {
+		claimed, err := dispatcher.WebhookStore.ClaimDeliveries(webhookBatchSize, time.Now().Add(webhookLease))
+		if err != nil {
+			return delivered, err
+		}
+		if len(claimed) == 0 {
+			return delivered, nil
+		}
+		count, err := dispatcher.send(ctx, claimed)
+		delivered += count
+		if err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, ctx.Err()
+}
+
+// send delivers claimed deliveries concurrently
+func (dispatcher *WebhookDispatcher) send(ctx context.Context, claimed []entities.WebhookDelivery) (int, error) {
+	subscriptionIds := make([]uuid.UUID, 0, len(claimed))
+	for _, delivery := range claimed {
+		subscriptionIds = append(subscriptionIds, delivery.SubscriptionId)
+	}
+	found, err := dispatcher.WebhookStore.GetSubscriptionsByIds(subscriptionIds)
+	if err != nil {
+		return 0, err
+	}
+	subscriptions := make(map[uuid.UUID]entities.WebhookSubscription, len(found))
+	for _, subscription := range found {
+		subscriptions[subscription.Base.Id] = subscription
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		delivered int
+	)
+	workers := make(chan struct{}, dispatcher.Workers)
+	for _, delivery := range claimed {
+		// deliveries of a deleted subscription are deleted with it
+		subscription, ok := subscriptions[delivery.SubscriptionId]
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(delivery entities.WebhookDelivery) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			if dispatcher.deliver(ctx, delivery, subscription) {
+				mu.Lock()
+				delivered++
+				mu.Unlock()
+			}
+		}(delivery)
+	}
+	wg.Wait()
+	return delivered, nil
+}
+
+func (dispatcher *WebhookDispatcher) deliver(ctx context.Context, delivery entities.WebhookDelivery, subscription entities.WebhookSubscription) bool {
+	if !subscription.Active {
+		// dead-lettered so the log shows why, it can be redelivered once the subscription is active again
+		if err := dispatcher.WebhookStore.MarkDeliveryFailed(delivery.Base.Id, 0, "subscription is inactive", nil); err != nil {
+			log.Printf("failed to record failure of webhook delivery %s: %v", delivery.Base.Id.String(), err)
+		}
+		return false
+	}
+
+	responseStatus, err := dispatcher.post(ctx, delivery, subscription)
+	if err != nil {
+		var retryAt *time.Time
+		if delivery.Attempts < dispatcher.MaxAttempts {
+			next := time.Now().Add(min(webhookBaseDelay<<min(delivery.Attempts-1, 10), webhookMaxBackoff))
+			retryAt = &next
+		}
+		if markErr := dispatcher.WebhookStore.MarkDeliveryFailed(delivery.Base.Id, responseStatus, err.Error(), retryAt); markErr != nil {
+			log.Printf("failed to record failure of webhook delivery %s: %v", delivery.Base.Id.String(), markErr)
+		}
+		return false
+	}
+	// when this fails the delivery is sent again once its lease runs out, endpoints dedupe on X-Webhook-Id
+	if err := dispatcher.WebhookStore.MarkDeliveryDelivered(delivery.Base.Id, responseStatus); err != nil {
+		log.Printf("failed to record webhook delivery %s: %v", delivery.Base.Id.String(), err)
+		return false
+	}
+	return true
+}
+
+// post sends a delivery and returns the status of the response, 0 when there was none
+func (dispatcher *WebhookDispatcher) post(ctx context.Context, delivery entities.WebhookDelivery, subscription entities.WebhookSubscription) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "bit-image-webhooks")
+	request.Header.Set("X-Webhook-Id", delivery.EventId.String())
+	request.Header.Set("X-Webhook-Delivery", delivery.Base.Id.String())
+	request.Header.Set("X-Webhook-Event", delivery.EventType)
+	request.Header.Set("X-Webhook-Timestamp", timestamp)
+	request.Header.Set("X-Webhook-Signature", "sha256="+SignWebhook(subscription.Secret, timestamp, body))
+
+	response, err := dispatcher.Client.Do(request)
+	if errors.Is(err, errWebhookAddressForbidden) {
+		// nothing about the address or what answers there is kept, the log is readable by the owner
+		return 0, errWebhookAddressForbidden
+	}
+	if err != nil {
+		log.Printf("webhook delivery %s failed: %v", delivery.Base.Id.String(), err)
+		var netErr net.Error
+		if (errors.As(err, &netErr) && netErr.Timeout()) || errors.Is(err, context.DeadlineExceeded) {
+			return 0, errWebhookTimeout
+		}
+		return 0, errWebhookConnectionFailed
+	}
+	defer response.Body.Close()
+	// drain the body so the connection can be reused, it isn't kept: the delivery log would reflect whatever the
+	// endpoint answers back to the owner of the subscription
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("webhook endpoint returned %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of a payload sent at the unix timestamp, as sent in X-Webhook-Signature
+func SignWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
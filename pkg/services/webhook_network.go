@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errWebhookAddressForbidden is returned when a webhook endpoint is, or resolves to, an address of the server's own
+// networks. Such endpoints are refused when subscribing and again when connecting, so that a host re-resolving to an
+// internal address after it was checked can't be used to reach internal services.
+var errWebhookAddressForbidden = errors.New("webhook endpoint address is not allowed")
+
+// errWebhookTimeout and errWebhookConnectionFailed are what the delivery log says about other failed requests. Their
+// errors tell resolved addresses and dial details that are only logged on the server.
+var (
+	errWebhookTimeout          = errors.New("webhook request timed out")
+	errWebhookConnectionFailed = errors.New("webhook connection failed")
+)
+
+// webhookResolveTimeout bounds the lookup of the host of an endpoint when subscribing
+const webhookResolveTimeout = 5 * time.Second
+
+// forbiddenWebhookAddress reports whether an address is loopback, link-local, private, unspecified or multicast
+func forbiddenWebhookAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsValid() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsPrivate() || addr.IsUnspecified() ||
+		sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which isn't reachable from the internet either
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// checkWebhookHost resolves the host of an endpoint and fails when any of its addresses is forbidden
+func checkWebhookHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if forbiddenWebhookAddress(addr) {
+			return errWebhookAddressForbidden
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if forbiddenWebhookAddress(addr) {
+			return errWebhookAddressForbidden
+		}
+	}
+	return nil
+}
+
+// webhookTransport connects to public addresses only, checking the address actually dialed rather than the one
+// resolved when subscribing. Proxies from the environment are ignored, they would be dialed instead of the endpoint.
+func webhookTransport(allowPrivateNetworks bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivateNetworks {
+		dialer.Control = func(network string, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || forbiddenWebhookAddress(addrPort.Addr()) {
+				return errWebhookAddressForbidden
+			}
+			return nil
+		}
+	}
+	transport.DialContext = dialer.DialContext
+	return transport
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"bit-image/pkg/common/entities"
+	"bit-image/pkg/config"
+	"bit-image/pkg/storage/image"
+	"bit-image/pkg/storage/webhook"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	minWebhookSecretLength = 16
+	maxWebhookSecretLength = 256
+	maxWebhookURLLength    = 2048
+)
+
+// webhookEventTypes are the events a subscription can filter on
+var webhookEventTypes = []string{EventImageConfirmed, EventImageUpdated, EventImageDeleted}
+
+// WebhookService manages the webhook subscriptions of users and their delivery logs
+type WebhookService struct {
+	WebhookStore *webhook.WebhookStore
+	Dispatcher   *WebhookDispatcher
+	// AllowHTTP accepts plain http endpoints, for development
+	AllowHTTP        bool
+	MaxSubscriptions int64
+}
+
+// CreateWebhookRequest subscribes an endpoint. The secret is generated when it isn't given, no event types means
+// every event.
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+}
+
+// UpdateWebhookRequest changes the fields that are set, RotateSecret replaces the secret with a generated one
+type UpdateWebhookRequest struct {
+	URL          *string   `json:"url"`
+	EventTypes   *[]string `json:"event_types"`
+	Active       *bool     `json:"active"`
+	RotateSecret bool      `json:"rotate_secret"`
+}
+
+// WebhookDetails is a subscription as shown to its owner. Secret is only filled in when it was just set, so it can't be
+// read back later.
+type WebhookDetails struct {
+	Id         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type DeliveryListQuery struct {
+	Status string `form:"status"`
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+}
+
+// WebhookDeliveryDetails is an entry of the delivery log, Payload is the body that was sent
+type WebhookDeliveryDetails struct {
+	Id             uuid.UUID       `json:"id"`
+	EventId        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	ImageId        uuid.UUID       `json:"image_id"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// DeliveryList is a page of the delivery log, NextCursor is empty on the last page
+type DeliveryList struct {
+	Deliveries []WebhookDeliveryDetails `json:"deliveries"`
+	NextCursor string                   `json:"next_cursor"`
+}
+
+func NewWebhookService(webhookStore *webhook.WebhookStore, dispatcher *WebhookDispatcher, cfg *config.Config) *WebhookService {
+	return &WebhookService{
+		WebhookStore:     webhookStore,
+		Dispatcher:       dispatcher,
+		AllowHTTP:        cfg.Webhooks.AllowHTTP,
+		MaxSubscriptions: cfg.Webhooks.MaxSubscriptions,
+	}
+}
+
+func (service *WebhookService) CreateWebhook(userId string, request CreateWebhookRequest) (*WebhookDetails, error) {
+	endpoint, err := service.validateURL(request.URL)
+	if err != nil {
+		return nil, err
+	}
+	eventTypes, err := normalizeEventTypes(request.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	secret := request.Secret
+	if secret == "" {
+		if secret, err = generateWebhookSecret(); err != nil {
+			return nil, err
+		}
+	} else if len(secret) < minWebhookSecretLength || len(secret) > maxWebhookSecretLength {
+		return nil, fmt.Errorf("%w: secret must be %d to %d characters", ErrInvalidWebhook, minWebhookSecretLength, maxWebhookSecretLength)
+	}
+
+	count, err := service.WebhookStore.CountSubscriptions(userId)
+	if err != nil {
+		return nil, err
+	}
+	if count >= service.MaxSubscriptions {
+		return nil, fmt.Errorf("%w: at most %d subscriptions", ErrWebhookLimit, service.MaxSubscriptions)
+	}
+
+	subscription := entities.WebhookSubscription{
+		OwnerId:    userId,
+		URL:        endpoint,
+		Secret:     secret,
+		EventTypes: strings.Join(eventTypes, ","),
+		Active:     true,
+	}
+	if err := service.WebhookStore.AddSubscription(&subscription); err != nil {
+		return nil, err
+	}
+
+	details := webhookDetails(subscription)
+	details.Secret = secret
+	return &details, nil
+}
+
+func (service *WebhookService) ListWebhooks(userId string) ([]WebhookDetails, error) {
+	subscriptions, err := service.WebhookStore.ListSubscriptions(userId)
+	if err != nil {
+		return nil, err
+	}
+	webhooks := make([]WebhookDetails, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		webhooks = append(webhooks, webhookDetails(subscription))
+	}
+	return webhooks, nil
+}
+
+func (service *WebhookService) GetWebhook(userId string, webhookId string) (*WebhookDetails, error) {
+	subscription, err := service.subscription(userId, webhookId)
+	if err != nil {
+		return nil, err
+	}
+	details := webhookDetails(*subscription)
+	return &details, nil
+}
+
+func (service *WebhookService) UpdateWebhook(userId string, webhookId string, request UpdateWebhookRequest) (*WebhookDetails, error) {
+	subscriptionId, err := parseWebhookId(webhookId)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]any{}
+	if request.URL != nil {
+		endpoint, err := service.validateURL(*request.URL)
+		if err != nil {
+			return nil, err
+		}
+		updates["url"] = endpoint
+	}
+	if request.EventTypes != nil {
+		eventTypes, err := normalizeEventTypes(*request.EventTypes)
+		if err != nil {
+			return nil, err
+		}
+		updates["event_types"] = strings.Join(eventTypes, ",")
+	}
+	if request.Active != nil {
+		updates["active"] = *request.Active
+	}
+	var secret string
+	if request.RotateSecret {
+		if secret, err = generateWebhookSecret(); err != nil {
+			return nil, err
+		}
+		updates["secret"] = secret
+	}
+
+	if len(updates) > 0 {
+		if err := service.WebhookStore.UpdateSubscription(subscriptionId, userId, updates); err != nil {
+			return nil, err
+		}
+	}
+	subscription, err := service.WebhookStore.GetSubscription(subscriptionId, userId)
+	if err != nil {
+		return nil, err
+	}
+	details := webhookDetails(*subscription)
+	details.Secret = secret
+	return &details, nil
+}
+
+// DeleteWebhook removes a subscription, its pending deliveries are dropped
+func (service *WebhookService) DeleteWebhook(userId string, webhookId string) error {
+	subscriptionId, err := parseWebhookId(webhookId)
+	if err != nil {
+		return err
+	}
+	return service.WebhookStore.DeleteSubscription(subscriptionId, userId)
+}
+
+// ListDeliveries returns a page of the delivery log of a subscription, newest first
+func (service *WebhookService) ListDeliveries(userId string, webhookId string, query DeliveryListQuery) (*DeliveryList, error) {
+	subscription, err := service.subscription(userId, webhookId)
+	if err != nil {
+		return nil, err
+	}
+	switch query.Status {
+	case "", entities.WebhookDeliveryPending, entities.WebhookDeliveryDelivered, entities.WebhookDeliveryDead:
+	default:
+		return nil, fmt.Errorf("%w: unknown delivery status %q", ErrInvalidWebhook, query.Status)
+	}
+
+	var after *webhook.DeliveryCursor
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = (*webhook.DeliveryCursor)(cursor)
+	}
+
+	limit := pageSize(query.Limit)
+	deliveries, err := service.WebhookStore.ListDeliveries(subscription.Base.Id, query.Status, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	var nextCursor string
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		last := deliveries[limit-1]
+		nextCursor = encodeCursor(image.ImageCursor{DateTimeCreated: last.Base.DateTimeCreated, Id: last.Base.Id})
+	}
+
+	list := &DeliveryList{Deliveries: make([]WebhookDeliveryDetails, 0, len(deliveries)), NextCursor: nextCursor}
+	for _, delivery := range deliveries {
+		list.Deliveries = append(list.Deliveries, deliveryDetails(delivery))
+	}
+	return list, nil
+}
+
+// Redeliver sends a delivery of a subscription again, dead-lettered ones included, with a fresh set of attempts
+func (service *WebhookService) Redeliver(userId string, webhookId string, deliveryId string) error {
+	subscription, err := service.subscription(userId, webhookId)
+	if err != nil {
+		return err
+	}
+	parsedDeliveryId, err := uuid.Parse(deliveryId)
+	if err != nil {
+		return fmt.Errorf("%w: invalid delivery id", ErrInvalidWebhook)
+	}
+	if err := service.WebhookStore.Redeliver(parsedDeliveryId, subscription.Base.Id); err != nil {
+		return err
+	}
+	service.Dispatcher.Notify()
+	return nil
+}
+
+func (service *WebhookService) subscription(userId string, webhookId string) (*entities.WebhookSubscription, error) {
+	subscriptionId, err := parseWebhookId(webhookId)
+	if err != nil {
+		return nil, err
+	}
+	return service.WebhookStore.GetSubscription(subscriptionId, userId)
+}
+
+// validateURL checks that webhooks can be sent to the endpoint, it has to be https unless AllowHTTP is set and its host
+// must not resolve to an internal address unless the dispatcher allows private networks
+func (service *WebhookService) validateURL(endpoint string) (string, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if len(endpoint) > maxWebhookURLLength {
+		return "", fmt.Errorf("%w: url is longer than %d characters", ErrInvalidWebhook, maxWebhookURLLength)
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("%w: url must be absolute", ErrInvalidWebhook)
+	}
+	switch {
+	case parsed.Scheme == "https":
+	case parsed.Scheme == "http" && service.AllowHTTP:
+	default:
+		return "", fmt.Errorf("%w: url must use https", ErrInvalidWebhook)
+	}
+	if parsed.User != nil {
+		return "", fmt.Errorf("%w: url must not contain credentials", ErrInvalidWebhook)
+	}
+	if !service.Dispatcher.AllowPrivateNetworks {
+		err = checkWebhookHost(context.Background(), parsed.Hostname())
+		if errors.Is(err, errWebhookAddressForbidden) {
+			return "", fmt.Errorf("%w: url must point at a public address", ErrInvalidWebhook)
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: host can't be resolved", ErrInvalidWebhook)
+		}
+	}
+	return parsed.String(), nil
+}
+
+// normalizeEventTypes checks the event types and returns them sorted without duplicates
+func normalizeEventTypes(eventTypes []string) ([]string, error) {
+	normalized := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if !slices.Contains(webhookEventTypes, eventType) {
+			return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, eventType)
+		}
+		normalized = append(normalized, eventType)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
+
+func parseWebhookId(webhookId string) (uuid.UUID, error) {
+	subscriptionId, err := uuid.Parse(webhookId)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: invalid webhook id", ErrInvalidWebhook)
+	}
+	return subscriptionId, nil
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// subscribedEventTypes returns the event types a subscription filters on, empty for every event
+func subscribedEventTypes(subscription entities.WebhookSubscription) []string {
+	if subscription.EventTypes == "" {
+		return []string{}
+	}
+	return strings.Split(subscription.EventTypes, ",")
+}
+
+func webhookDetails(subscription entities.WebhookSubscription) WebhookDetails {
+	return WebhookDetails{
+		Id:         subscription.Base.Id,
+		URL:        subscription.URL,
+		EventTypes: subscribedEventTypes(subscription),
+		Active:     subscription.Active,
+		CreatedAt:  subscription.Base.DateTimeCreated,
+		UpdatedAt:  subscription.Base.DateTimeUpdated,
+	}
+}
+
+func deliveryDetails(delivery entities.WebhookDelivery) WebhookDeliveryDetails {
+	details := WebhookDeliveryDetails{
+		Id:             delivery.Base.Id,
+		EventId:        delivery.EventId,
+		EventType:      delivery.EventType,
+		ImageId:        delivery.ImageId,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus,
+		LastError:      delivery.LastError,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.Base.DateTimeCreated,
+		Payload:        json.RawMessage(delivery.Payload),
+	}
+	if delivery.Status == entities.WebhookDeliveryPending {
+		details.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	return details
+}
@@ -0,0 +1,8 @@
+package blob
+
+import (
+	"github.com/google/wire"
+)
+
+// ProviderSet for the blob store package
+var ProviderSet = wire.NewSet(NewBlobStore)
@@ -0,0 +1,107 @@
+package blob
+
+import (
+	"bit-image/internal/postrges"
+	"bit-image/pkg/common/entities"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrBlobNotFound is returned when the owner has no live blob with the given hash
+var ErrBlobNotFound = errors.New("blob not found")
+
+type BlobStore struct {
+	DBHandler *postrges.ConnectionHandler
+}
+
+func NewBlobStore(dbHandler *postrges.ConnectionHandler) *BlobStore {
+	return &BlobStore{
+		DBHandler: dbHandler,
+	}
+}
+
+// FindBlob returns the live blob of the owner with the given content hash
+func (store *BlobStore) FindBlob(ownerId, hash string) (*entities.Blob, error) {
+	var blob entities.Blob
+	err := store.DBHandler.DB.First(&blob, "owner_id = ? AND hash = ? AND ref_count > 0", ownerId, hash).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, fmt.Errorf("failed to find blob: %w", err)
+	}
+	return &blob, nil
+}
+
+// ExistingHashes returns which of the hashes the owner already has a live blob for
+func (store *BlobStore) ExistingHashes(ownerId string, hashes []string) ([]string, error) {
+	existing := []string{}
+	err := store.DBHandler.DB.Model(&entities.Blob{}).
+		Where("owner_id = ? AND hash IN ? AND ref_count > 0", ownerId, hashes).
+		Pluck("hash", &existing).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up blob hashes: %w", err)
+	}
+	return existing, nil
+}
+
+// AcquireBlobWithTransaction adds a reference to a live blob. It reports false when the blob has been released
+// in the meantime, the caller then has to store a new one.
+func (store *BlobStore) AcquireBlobWithTransaction(tx *gorm.DB, blobId uuid.UUID) (bool, error) {
+	result := tx.Model(&entities.Blob{}).
+		Where("id = ? AND ref_count > 0", blobId).
+		Update("ref_count", gorm.Expr("ref_count + 1"))
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to acquire blob %s: %w", blobId.String(), result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// InsertBlobWithTransaction stores a new blob with a single reference. It reports false when a concurrent upload
+// of the same content already created a live blob for the owner.
+func (store *BlobStore) InsertBlobWithTransaction(tx *gorm.DB, blob *entities.Blob) (bool, error) {
+	blob.RefCount = 1
+	result := tx.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "owner_id"}, {Name: "hash"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "ref_count > 0"}}},
+		DoNothing:   true,
+	}).Create(blob)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to insert blob: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReleaseBlobsWithTransaction drops one reference per id, an id may appear several times
+func (store *BlobStore) ReleaseBlobsWithTransaction(tx *gorm.DB, blobIds []uuid.UUID) error {
+	for _, blobId := range blobIds {
+		err := tx.Model(&entities.Blob{}).
+			Where("id = ? AND ref_count > 0", blobId).
+			Update("ref_count", gorm.Expr("ref_count - 1")).Error
+		if err != nil {
+			return fmt.Errorf("failed to release blob %s: %w", blobId.String(), err)
+		}
+	}
+	return nil
+}
+
+// ListReleasedBlobs returns up to limit blobs without references whose object still has to be deleted
+func (store *BlobStore) ListReleasedBlobs(limit int) ([]entities.Blob, error) {
+	var blobs []entities.Blob
+	if err := store.DBHandler.DB.Where("ref_count = 0").Limit(limit).Find(&blobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list released blobs: %w", err)
+	}
+	return blobs, nil
+}
+
+// DeleteReleasedBlobs removes the rows of released blobs once their objects are gone
+func (store *BlobStore) DeleteReleasedBlobs(blobIds []uuid.UUID) error {
+	err := store.DBHandler.DB.Where("id IN ? AND ref_count = 0", blobIds).Delete(&entities.Blob{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete released blobs: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+package derivative
+
+import (
+	"github.com/google/wire"
+)
+
+// ProviderSet for the derivative store package
+var ProviderSet = wire.NewSet(NewDerivativeStore)
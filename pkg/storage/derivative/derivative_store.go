@@ -0,0 +1,166 @@
+package derivative
+
+import (
+	"bit-image/internal/postrges"
+	"bit-image/pkg/common/entities"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type DerivativeStore struct {
+	DBHandler *postrges.ConnectionHandler
+}
+
+func NewDerivativeStore(dbHandler *postrges.ConnectionHandler) *DerivativeStore {
+	return &DerivativeStore{
+		DBHandler: dbHandler,
+	}
+}
+
+// AddDerivativesWithTransaction queues derivatives for generation, the ones that already exist are left as they are
+func (store *DerivativeStore) AddDerivativesWithTransaction(tx *gorm.DB, derivatives []entities.Derivative) error {
+	if len(derivatives) == 0 {
+		return nil
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "image_id"}, {Name: "size"}, {Name: "format"}},
+		DoNothing: true,
+	}).Create(&derivatives).Error
+	if err != nil {
+		return fmt.Errorf("failed to insert derivatives: %w", err)
+	}
+	return nil
+}
+
+// ClaimDerivatives takes up to limit pending derivatives that are due, only those of the image when imageId is set.
+// Claimed rows count an attempt and aren't due again before leaseUntil, so a generator that dies while holding them
+// doesn't keep them from being retried. Rows claimed by someone else are skipped.
+func (store *DerivativeStore) ClaimDerivatives(imageId *uuid.UUID, limit int, leaseUntil time.Time) ([]entities.Derivative, error) {
+	due := store.DBHandler.DB.Model(&entities.Derivative{}).
+		Select("id").
+		Where("status = ? AND next_attempt_at <= ?", entities.DerivativePending, time.Now())
+	if imageId != nil {
+		due = due.Where("image_id = ?", *imageId)
+	}
+	due = due.Order("next_attempt_at").Limit(limit).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+
+	var claimed []entities.Derivative
+	err := store.DBHandler.DB.Model(&claimed).
+		Clauses(clause.Returning{}).
+		Where("id IN (?)", due).
+		Updates(map[string]any{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": leaseUntil,
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim derivatives: %w", err)
+	}
+	return claimed, nil
+}
+
+// MarkDerivativeReady records a generated derivative
+func (store *DerivativeStore) MarkDerivativeReady(derivativeId uuid.UUID, width, height int, fileSize int64) error {
+	err := store.DBHandler.DB.Model(&entities.Derivative{}).
+		Where("id = ?", derivativeId).
+		Updates(map[string]any{
+			"status":     entities.DerivativeReady,
+			"width":      width,
+			"height":     height,
+			"file_size":  fileSize,
+			"last_error": "",
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update derivative %s: %w", derivativeId.String(), err)
+	}
+	return nil
+}
+
+// MarkDerivativeFailed records a failed generation. The derivative is retried at retryAt, or given up on when
+// retryAt is nil.
+func (store *DerivativeStore) MarkDerivativeFailed(derivativeId uuid.UUID, reason string, retryAt *time.Time) error {
+	updates := map[string]any{"last_error": reason}
+	if retryAt != nil {
+		updates["next_attempt_at"] = *retryAt
+	} else {
+		updates["status"] = entities.DerivativeFailed
+	}
+	err := store.DBHandler.DB.Model(&entities.Derivative{}).Where("id = ?", derivativeId).Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("failed to update derivative %s: %w", derivativeId.String(), err)
+	}
+	return nil
+}
+
+// RetryFailedDerivatives puts every derivative that ran out of attempts back in the queue and returns how many there were
+func (store *DerivativeStore) RetryFailedDerivatives() (int64, error) {
+	result := store.DBHandler.DB.Model(&entities.Derivative{}).
+		Where("status = ?", entities.DerivativeFailed).
+		Updates(map[string]any{
+			"status":          entities.DerivativePending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to retry derivatives: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ListReadyDerivatives returns the generated derivatives of the images, smallest first
+func (store *DerivativeStore) ListReadyDerivatives(imageIds []uuid.UUID) ([]entities.Derivative, error) {
+	var derivatives []entities.Derivative
+	err := store.DBHandler.DB.
+		Where("image_id IN ? AND status = ?", imageIds, entities.DerivativeReady).
+		Order("size, format").
+		Find(&derivatives).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list derivatives: %w", err)
+	}
+	return derivatives, nil
+}
+
+// ReplaceDerivativesWithTransaction drops the derivative rows of an image and queues the given ones instead, so they
+// are generated again from scratch
+func (store *DerivativeStore) ReplaceDerivativesWithTransaction(tx *gorm.DB, imageId uuid.UUID, derivatives []entities.Derivative) error {
+	if err := tx.Where("image_id = ?", imageId).Delete(&entities.Derivative{}).Error; err != nil {
+		return fmt.Errorf("failed to delete derivatives of image %s: %w", imageId.String(), err)
+	}
+	return store.AddDerivativesWithTransaction(tx, derivatives)
+}
+
+// ListDerivatives returns every derivative row of an image whatever its status, smallest first
+func (store *DerivativeStore) ListDerivatives(imageId uuid.UUID) ([]entities.Derivative, error) {
+	var derivatives []entities.Derivative
+	if err := store.DBHandler.DB.Where("image_id = ?", imageId).Order("size, format").Find(&derivatives).Error; err != nil {
+		return nil, fmt.Errorf("failed to list derivatives of image %s: %w", imageId.String(), err)
+	}
+	return derivatives, nil
+}
+
+// CountDerivativesByStatus returns how many derivatives are in each status
+func (store *DerivativeStore) CountDerivativesByStatus() (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := store.DBHandler.DB.Model(&entities.Derivative{}).Select("status, count(*) AS count").Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count derivatives: %w", err)
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// DeleteDerivatives removes the derivative rows of the images once their objects are gone
+func (store *DerivativeStore) DeleteDerivatives(imageIds []uuid.UUID) error {
+	if err := store.DBHandler.DB.Where("image_id IN ?", imageIds).Delete(&entities.Derivative{}).Error; err != nil {
+		return fmt.Errorf("failed to delete derivatives: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+package exif
+
+import (
+	"github.com/google/wire"
+)
+
+// ProviderSet for the exif store package
+var ProviderSet = wire.NewSet(NewExifStore)
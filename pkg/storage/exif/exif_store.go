@@ -0,0 +1,49 @@
+package exif
+
+import (
+	"bit-image/internal/postrges"
+	"bit-image/pkg/common/entities"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrExifNotFound is returned when no metadata was recorded for the image
+var ErrExifNotFound = errors.New("exif metadata not found")
+
+type ExifStore struct {
+	DBHandler *postrges.ConnectionHandler
+}
+
+func NewExifStore(dbHandler *postrges.ConnectionHandler) *ExifStore {
+	return &ExifStore{
+		DBHandler: dbHandler,
+	}
+}
+
+func (store *ExifStore) AddExifWithTransaction(tx *gorm.DB, imageExif *entities.ImageExif) error {
+	if err := tx.Create(imageExif).Error; err != nil {
+		return fmt.Errorf("failed to insert exif metadata: %w", err)
+	}
+	return nil
+}
+
+func (store *ExifStore) GetExif(imageId uuid.UUID) (*entities.ImageExif, error) {
+	var imageExif entities.ImageExif
+	if err := store.DBHandler.DB.First(&imageExif, "id = ?", imageId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExifNotFound
+		}
+		return nil, fmt.Errorf("failed to get exif metadata of image %s: %w", imageId.String(), err)
+	}
+	return &imageExif, nil
+}
+
+// DeleteExifs removes the metadata of purged images
+func (store *ExifStore) DeleteExifs(imageIds []uuid.UUID) error {
+	if err := store.DBHandler.DB.Where("id IN ?", imageIds).Delete(&entities.ImageExif{}).Error; err != nil {
+		return fmt.Errorf("failed to delete exif metadata: %w", err)
+	}
+	return nil
+}
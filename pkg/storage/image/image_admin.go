@@ -0,0 +1,104 @@
+package image
+
+import (
+	"bit-image/pkg/common/entities"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AdminImageFilter narrows down a listing of every user's images. Only live images are listed unless Deleted is set,
+// which lists the soft-deleted ones instead.
+type AdminImageFilter struct {
+	OwnerId string
+	Deleted bool
+	Broken  bool
+}
+
+// ImageCounts is how many images there are and how much they weigh, soft-deleted ones counted apart
+type ImageCounts struct {
+	Live         int64 `json:"live"`
+	LiveBytes    int64 `json:"live_bytes"`
+	Deleted      int64 `json:"deleted"`
+	DeletedBytes int64 `json:"deleted_bytes"`
+	Broken       int64 `json:"broken"`
+}
+
+// ListAllImages returns up to limit images of any owner matching the filter, newest first, starting after the cursor
+// when one is given
+func (store *ImageStore) ListAllImages(filter AdminImageFilter, after *ImageCursor, limit int) ([]entities.Image, error) {
+	query := store.DBHandler.DB.Model(&entities.Image{})
+	if filter.Deleted {
+		query = query.Unscoped().Where("date_time_deleted IS NOT NULL")
+	}
+	if filter.OwnerId != "" {
+		query = query.Where("owner_id = ?", filter.OwnerId)
+	}
+	if filter.Broken {
+		query = query.Where("broken_at IS NOT NULL")
+	}
+	if after != nil {
+		query = query.Where("(date_time_created, id) < (?, ?)", after.DateTimeCreated, after.Id)
+	}
+
+	var images []entities.Image
+	err := query.Order("date_time_created DESC, id DESC").Limit(limit).Find(&images).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	return images, nil
+}
+
+// GetImageIncludingDeleted returns the image whether it was soft-deleted or not
+func (store *ImageStore) GetImageIncludingDeleted(imageId uuid.UUID) (*entities.Image, error) {
+	var image entities.Image
+	if err := store.DBHandler.DB.Unscoped().First(&image, "id = ?", imageId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImageNotFound
+		}
+		return nil, fmt.Errorf("failed to get image %s: %w", imageId.String(), err)
+	}
+	return &image, nil
+}
+
+// GetDeletedImagesByIds returns the images among the ids that are soft-deleted
+func (store *ImageStore) GetDeletedImagesByIds(imageIds []uuid.UUID) ([]entities.Image, error) {
+	var images []entities.Image
+	err := store.DBHandler.DB.Unscoped().Where("id IN ? AND date_time_deleted IS NOT NULL", imageIds).Find(&images).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deleted images: %w", err)
+	}
+	return images, nil
+}
+
+// RestoreImageWithTransaction brings a soft-deleted image back, it reports false when the image isn't deleted or has
+// been purged in the meantime
+func (store *ImageStore) RestoreImageWithTransaction(tx *gorm.DB, imageId uuid.UUID) (bool, error) {
+	result := tx.Unscoped().Model(&entities.Image{}).
+		Where("id = ? AND date_time_deleted IS NOT NULL", imageId).
+		Updates(map[string]any{"date_time_deleted": nil, "blob_released": false})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to restore image %s: %w", imageId.String(), result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, store.RefreshSearchDocumentsWithTransaction(tx, []uuid.UUID{imageId})
+}
+
+// CountImages counts the images of every user
+func (store *ImageStore) CountImages() (*ImageCounts, error) {
+	var counts ImageCounts
+	err := store.DBHandler.DB.Unscoped().Model(&entities.Image{}).
+		Select(`COUNT(*) FILTER (WHERE date_time_deleted IS NULL) AS live,
+			COALESCE(SUM(file_size) FILTER (WHERE date_time_deleted IS NULL), 0)::bigint AS live_bytes,
+			COUNT(*) FILTER (WHERE date_time_deleted IS NOT NULL) AS deleted,
+			COALESCE(SUM(file_size) FILTER (WHERE date_time_deleted IS NOT NULL), 0)::bigint AS deleted_bytes,
+			COUNT(*) FILTER (WHERE broken_at IS NOT NULL) AS broken`).
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count images: %w", err)
+	}
+	return &counts, nil
+}
@@ -0,0 +1,49 @@
+package image
+
+import (
+	"bit-image/pkg/common/entities"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// PathCursor is the position of the last image of a page when paging through images by path
+type PathCursor struct {
+	Path string
+	Id   uuid.UUID
+}
+
+// ListImagesByPath returns a page of the images stored under the prefix, soft-deleted ones included, ordered by path
+// bytewise so that they line up with an object listing of the same prefix
+func (store *ImageStore) ListImagesByPath(prefix string, after *PathCursor, limit int) ([]entities.Image, error) {
+	query := store.DBHandler.DB.Unscoped().Where("starts_with(path, ?)", prefix)
+	if after != nil {
+		query = query.Where(`(path COLLATE "C", id) > (?, ?)`, after.Path, after.Id)
+	}
+
+	var images []entities.Image
+	if err := query.Order(`path COLLATE "C", id`).Limit(limit).Find(&images).Error; err != nil {
+		return nil, fmt.Errorf("failed to list images under %s: %w", prefix, err)
+	}
+	return images, nil
+}
+
+// ImageExists reports whether there is a row for the image, soft-deleted or not
+func (store *ImageStore) ImageExists(imageId uuid.UUID) (bool, error) {
+	var count int64
+	if err := store.DBHandler.DB.Unscoped().Model(&entities.Image{}).Where("id = ?", imageId).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to look up image %s: %w", imageId.String(), err)
+	}
+	return count > 0, nil
+}
+
+// MarkImageBroken flags an image whose stored object is missing or doesn't match it
+func (store *ImageStore) MarkImageBroken(imageId uuid.UUID, reason string) error {
+	err := store.DBHandler.DB.Unscoped().Model(&entities.Image{}).
+		Where("id = ?", imageId).
+		Updates(map[string]any{"broken_at": time.Now(), "broken_reason": reason}).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark image %s broken: %w", imageId.String(), err)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package image
+
+import (
+	"bit-image/pkg/common/entities"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// searchLanguage is the text search configuration documents and queries are parsed with, it stems English words so
+// "beaches" finds "beach"
+const searchLanguage = "english"
+
+// SnippetStart and SnippetStop mark the matched words in snippets. They are control characters that can't be part of
+// the searched text, so callers can escape the snippet before turning them into markup.
+const (
+	SnippetStart = "\x02"
+	SnippetStop  = "\x03"
+)
+
+// refreshSearchDocumentsSQL rebuilds the search documents of live images from their name, caption, tags and labels.
+// Names are split on the separators of file names, so "beach_sunset.jpg" is found by "sunset". Name and tags weigh
+// most, then the caption, then labels.
+const refreshSearchDocumentsSQL = `
+INSERT INTO image_search_documents (image_id, owner_id, is_private, format, year, content, tag_content, document, owner_document)
+SELECT images.id, images.owner_id, images.is_private, images.format, EXTRACT(YEAR FROM images.date_time_created)::int,
+	content.text, coalesce(tag_names.names, ''), content.document,
+	content.document || setweight(to_tsvector('` + searchLanguage + `', coalesce(tag_names.names, '')), 'A')
+FROM images
+LEFT JOIN LATERAL (
+	SELECT string_agg(tags.name, ' ' ORDER BY tags.name) AS names
+	FROM image_tags JOIN tags ON tags.id = image_tags.tag_id
+	WHERE image_tags.image_id = images.id
+) tag_names ON true
+LEFT JOIN LATERAL (
+	SELECT string_agg(DISTINCT labels.name, ' ') AS names
+	FROM image_labels JOIN labels ON labels.id = image_labels.label_id
+	WHERE image_labels.image_id = images.id
+) label_names ON true
+CROSS JOIN LATERAL (
+	SELECT regexp_replace(images.name, '[_.-]+', ' ', 'g') AS words
+) file_name
+CROSS JOIN LATERAL (
+	SELECT concat_ws(' ', file_name.words, nullif(images.caption, ''), label_names.names) AS text,
+		setweight(to_tsvector('` + searchLanguage + `', file_name.words), 'A') ||
+		setweight(to_tsvector('` + searchLanguage + `', images.caption), 'B') ||
+		setweight(to_tsvector('` + searchLanguage + `', coalesce(label_names.names, '')), 'C') AS document
+) content
+WHERE images.id IN @ids AND images.date_time_deleted IS NULL
+ON CONFLICT (image_id) DO UPDATE SET
+	owner_id = EXCLUDED.owner_id, is_private = EXCLUDED.is_private, format = EXCLUDED.format, year = EXCLUDED.year,
+	content = EXCLUDED.content, tag_content = EXCLUDED.tag_content,
+	document = EXCLUDED.document, owner_document = EXCLUDED.owner_document`
+
+// searchMatchesSQL selects the documents matching the query that the user may see: all of their own, searched along
+// with their tags, and the public ones of other users unless only owned images are searched. It is the common table
+// expression of every search query.
+const searchMatchesSQL = `
+WITH query AS (
+	SELECT websearch_to_tsquery('` + searchLanguage + `', @q) AS q
+), matches AS (
+	SELECT documents.image_id, documents.format, documents.year, documents.is_private,
+		CASE WHEN documents.owner_id = @user THEN documents.owner_document ELSE documents.document END AS searched,
+		CASE WHEN documents.owner_id = @user THEN concat_ws(' ', documents.content, nullif(documents.tag_content, ''))
+			ELSE documents.content END AS text
+	FROM image_search_documents documents, query
+	WHERE (documents.owner_id = @user AND documents.owner_document @@ query.q)
+		OR (NOT @owned AND documents.owner_id <> @user AND NOT documents.is_private AND documents.document @@ query.q)
+)`
+
+// SearchFilter is a full-text search over the images a user may see. Format, Year and IsPrivate narrow down the hits
+// but not the facets, so clients can offer the other values.
+type SearchFilter struct {
+	UserId string
+	// Query uses web search syntax: quoted phrases, "or" and "-" to exclude words
+	Query string
+	// OnlyOwned leaves out the public images of other users
+	OnlyOwned bool
+	Format    string
+	Year      *int
+	IsPrivate *bool
+}
+
+// SearchHit is a matching image, Snippet the matched text with the matched words between SnippetStart and SnippetStop
+type SearchHit struct {
+	ImageId uuid.UUID
+	Rank    float64
+	Snippet string
+}
+
+// FacetCount is how many matching images have a value
+type FacetCount struct {
+	Value string
+	Count int64
+}
+
+// SearchResult is a page of hits, best first, along with the number of hits and facets over every match
+type SearchResult struct {
+	Hits    []SearchHit
+	Total   int64
+	Formats []FacetCount
+	Years   []FacetCount
+	Privacy []FacetCount
+}
+
+// RefreshSearchDocumentsWithTransaction rebuilds the search documents of the images, in the transaction changing them
+func (store *ImageStore) RefreshSearchDocumentsWithTransaction(tx *gorm.DB, imageIds []uuid.UUID) error {
+	if len(imageIds) == 0 {
+		return nil
+	}
+	if err := tx.Exec(refreshSearchDocumentsSQL, map[string]any{"ids": imageIds}).Error; err != nil {
+		return fmt.Errorf("failed to refresh search documents: %w", err)
+	}
+	return nil
+}
+
+// RefreshMissingSearchDocuments builds the search documents of up to limit live images that have none, e.g. from
+// before search existed, and returns how many were built
+func (store *ImageStore) RefreshMissingSearchDocuments(limit int) (int, error) {
+	var imageIds []uuid.UUID
+	err := store.DBHandler.DB.Model(&entities.Image{}).
+		Where("NOT EXISTS (SELECT 1 FROM image_search_documents WHERE image_search_documents.image_id = images.id)").
+		Order("id").
+		Limit(limit).
+		Pluck("id", &imageIds).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to list images without search documents: %w", err)
+	}
+	if err = store.RefreshSearchDocumentsWithTransaction(store.DBHandler.DB, imageIds); err != nil {
+		return 0, err
+	}
+	return len(imageIds), nil
+}
+
+func deleteSearchDocumentsWithTransaction(tx *gorm.DB, imageIds []uuid.UUID) error {
+	if err := tx.Where("image_id IN ?", imageIds).Delete(&entities.ImageSearchDocument{}).Error; err != nil {
+		return fmt.Errorf("failed to delete search documents: %w", err)
+	}
+	return nil
+}
+
+// SearchImages ranks the images matching the filter and returns a page of them along with the facets of every match
+func (store *ImageStore) SearchImages(filter SearchFilter, limit, offset int) (*SearchResult, error) {
+	params := map[string]any{"q": filter.Query, "user": filter.UserId, "owned": filter.OnlyOwned}
+
+	narrowed := "TRUE"
+	if filter.Format != "" {
+		narrowed += " AND format = @format"
+		params["format"] = filter.Format
+	}
+	if filter.Year != nil {
+		narrowed += " AND year = @year"
+		params["year"] = *filter.Year
+	}
+	if filter.IsPrivate != nil {
+		narrowed += " AND is_private = @private"
+		params["private"] = *filter.IsPrivate
+	}
+
+	result := &SearchResult{}
+	err := store.DBHandler.DB.Raw(searchMatchesSQL+`
+SELECT matches.image_id, ts_rank_cd(matches.searched, query.q) AS rank,
+	ts_headline('`+searchLanguage+`', matches.text, query.q,
+		'StartSel=`+SnippetStart+`, StopSel=`+SnippetStop+`, MaxWords=20, MinWords=5, MaxFragments=2') AS snippet
+FROM matches, query
+WHERE `+narrowed+`
+ORDER BY rank DESC, matches.image_id
+LIMIT @limit OFFSET @offset`, withParams(params, map[string]any{"limit": limit, "offset": offset})).
+		Scan(&result.Hits).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to search images: %w", err)
+	}
+
+	err = store.DBHandler.DB.Raw(searchMatchesSQL+`
+SELECT count(*) FROM matches WHERE `+narrowed, params).Scan(&result.Total).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	// GROUPING tells the sets apart, its bits are set for the columns a row is not grouped by
+	var facets []struct {
+		Format     *string
+		Year       *int
+		IsPrivate  *bool
+		GroupingId int
+		Count      int64
+	}
+	err = store.DBHandler.DB.Raw(searchMatchesSQL+`
+SELECT format, year, is_private, GROUPING(format, year, is_private) AS grouping_id, count(*) AS count
+FROM matches
+GROUP BY GROUPING SETS ((format), (year), (is_private))
+ORDER BY count DESC, format, year, is_private`, params).Scan(&facets).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count search facets: %w", err)
+	}
+	for _, facet := range facets {
+		switch {
+		case facet.GroupingId == 0b011 && facet.Format != nil:
+			result.Formats = append(result.Formats, FacetCount{Value: *facet.Format, Count: facet.Count})
+		case facet.GroupingId == 0b101 && facet.Year != nil:
+			result.Years = append(result.Years, FacetCount{Value: fmt.Sprint(*facet.Year), Count: facet.Count})
+		case facet.GroupingId == 0b110 && facet.IsPrivate != nil:
+			result.Privacy = append(result.Privacy, FacetCount{Value: fmt.Sprint(*facet.IsPrivate), Count: facet.Count})
+		}
+	}
+	return result, nil
+}
+
+// withParams merges named parameters into a copy of params
+func withParams(params map[string]any, extra map[string]any) map[string]any {
+	merged := make(map[string]any, len(params)+len(extra))
+	for name, value := range params {
+		merged[name] = value
+	}
+	for name, value := range extra {
+		merged[name] = value
+	}
+	return merged
+}
@@ -3,14 +3,50 @@ package image
 import (
 	"bit-image/internal/postrges"
 	"bit-image/pkg/common/entities"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"time"
 )
 
+// ErrImageNotFound is returned when no image row matches the lookup
+var ErrImageNotFound = errors.New("image not found")
+
 type ImageStore struct {
 	DBHandler *postrges.ConnectionHandler
 }
 
+// ImageFilter narrows down a listing of images, nil and empty fields are not filtered on
+type ImageFilter struct {
+	OwnerId       string
+	Format        string
+	MinSize       *float64
+	MaxSize       *float64
+	IsPrivate     *bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// Tags keeps images carrying any of the owner's tags with these names, or all of them with MatchAllTags
+	Tags         []string
+	MatchAllTags bool
+}
+
+// Fingerprint is what the similarity index keeps of an image
+type Fingerprint struct {
+	Id              uuid.UUID
+	OwnerId         string
+	IsPrivate       bool
+	DHash           int64
+	PHash           int64
+	DateTimeCreated time.Time
+}
+
+// ImageCursor is the position of the last image of a page, listings are ordered newest first
+type ImageCursor struct {
+	DateTimeCreated time.Time
+	Id              uuid.UUID
+}
+
 func NewImageStore(dbHandler *postrges.ConnectionHandler) *ImageStore {
 	return &ImageStore{
 		DBHandler: dbHandler,
@@ -24,8 +60,8 @@ func (store *ImageStore) AddImage(image entities.Image) error {
 		return err
 	}
 
-	// Insert the entity within the transaction
-	if err := tx.Create(&image).Error; err != nil {
+	// Insert the entity and its search document within the transaction
+	if err := store.AddImageWithTransaction(tx, image); err != nil {
 		if rollbackErr := rollback(); rollbackErr != nil {
 			return fmt.Errorf("insert error: %v, rollback error: %v", err, rollbackErr)
 		}
@@ -39,9 +75,187 @@ func (store *ImageStore) AddImage(image entities.Image) error {
 	return nil
 }
 
+// AddImageWithTransaction inserts an image along with its search document
 func (store *ImageStore) AddImageWithTransaction(tx *gorm.DB, image entities.Image) error {
 	if err := tx.Create(&image).Error; err != nil {
 		return fmt.Errorf("failed to insert image: %w", err)
 	}
+	return store.RefreshSearchDocumentsWithTransaction(tx, []uuid.UUID{image.Base.Id})
+}
+
+func (store *ImageStore) GetImageById(imageId uuid.UUID) (*entities.Image, error) {
+	var image entities.Image
+	if err := store.DBHandler.DB.First(&image, "id = ?", imageId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImageNotFound
+		}
+		return nil, fmt.Errorf("failed to get image %s: %w", imageId.String(), err)
+	}
+	return &image, nil
+}
+
+// ListImages returns up to limit images matching the filter, starting after the cursor when one is given
+func (store *ImageStore) ListImages(filter ImageFilter, after *ImageCursor, limit int) ([]entities.Image, error) {
+	query := store.DBHandler.DB.Model(&entities.Image{}).Where("owner_id = ?", filter.OwnerId)
+
+	if filter.Format != "" {
+		query = query.Where("format = ?", filter.Format)
+	}
+	if filter.MinSize != nil {
+		query = query.Where("file_size >= ?", *filter.MinSize)
+	}
+	if filter.MaxSize != nil {
+		query = query.Where("file_size <= ?", *filter.MaxSize)
+	}
+	if filter.IsPrivate != nil {
+		query = query.Where("is_private = ?", *filter.IsPrivate)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("date_time_created >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("date_time_created < ?", *filter.CreatedBefore)
+	}
+	if len(filter.Tags) > 0 {
+		tagged := store.DBHandler.DB.Table("image_tags").
+			Select("image_tags.image_id").
+			Joins("JOIN tags ON tags.id = image_tags.tag_id").
+			Where("tags.owner_id = ? AND tags.name IN ?", filter.OwnerId, filter.Tags)
+		if filter.MatchAllTags {
+			tagged = tagged.Group("image_tags.image_id").Having("COUNT(*) = ?", len(filter.Tags))
+		}
+		query = query.Where("id IN (?)", tagged)
+	}
+	if after != nil {
+		query = query.Where("(date_time_created, id) < (?, ?)", after.DateTimeCreated, after.Id)
+	}
+
+	var images []entities.Image
+	err := query.Order("date_time_created DESC, id DESC").Limit(limit).Find(&images).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	return images, nil
+}
+
+// GetImageByBlobId returns any live image stored in the given blob
+func (store *ImageStore) GetImageByBlobId(blobId uuid.UUID) (*entities.Image, error) {
+	var image entities.Image
+	if err := store.DBHandler.DB.First(&image, "blob_id = ?", blobId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImageNotFound
+		}
+		return nil, fmt.Errorf("failed to get image of blob %s: %w", blobId.String(), err)
+	}
+	return &image, nil
+}
+
+// GetImagesByIds returns the images matching the ids, missing and soft-deleted ids are left out
+func (store *ImageStore) GetImagesByIds(imageIds []uuid.UUID) ([]entities.Image, error) {
+	var images []entities.Image
+	if err := store.DBHandler.DB.Where("id IN ?", imageIds).Find(&images).Error; err != nil {
+		return nil, fmt.Errorf("failed to get images: %w", err)
+	}
+	return images, nil
+}
+
+// ListFingerprints pages through the fingerprints of live images by id, starting after the given id
+func (store *ImageStore) ListFingerprints(after uuid.UUID, limit int) ([]Fingerprint, error) {
+	var fingerprints []Fingerprint
+	err := store.DBHandler.DB.Model(&entities.Image{}).
+		Select("id, owner_id, is_private, d_hash, p_hash, date_time_created").
+		Where("id > ? AND d_hash IS NOT NULL AND p_hash IS NOT NULL", after).
+		Order("id").
+		Limit(limit).
+		Scan(&fingerprints).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fingerprints: %w", err)
+	}
+	return fingerprints, nil
+}
+
+// ListUnfingerprintedImages returns up to limit live images without fingerprints, e.g. from before they were computed
+func (store *ImageStore) ListUnfingerprintedImages(limit int) ([]entities.Image, error) {
+	var images []entities.Image
+	err := store.DBHandler.DB.
+		Where("d_hash IS NULL OR p_hash IS NULL").
+		Order("date_time_created").
+		Limit(limit).
+		Find(&images).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unfingerprinted images: %w", err)
+	}
+	return images, nil
+}
+
+// SetFingerprint stores the fingerprints of an image
+func (store *ImageStore) SetFingerprint(imageId uuid.UUID, dHash, pHash int64) error {
+	err := store.DBHandler.DB.Model(&entities.Image{}).
+		Where("id = ?", imageId).
+		Updates(map[string]any{"d_hash": dHash, "p_hash": pHash}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update fingerprint of image %s: %w", imageId.String(), err)
+	}
 	return nil
 }
+
+// SoftDeleteImages marks the images as deleted, they stop showing up in queries but stay in the table until purged
+func (store *ImageStore) SoftDeleteImages(imageIds []uuid.UUID) error {
+	return store.SoftDeleteImagesWithTransaction(store.DBHandler.DB, imageIds)
+}
+
+func (store *ImageStore) SoftDeleteImagesWithTransaction(tx *gorm.DB, imageIds []uuid.UUID) error {
+	if err := tx.Where("id IN ?", imageIds).Delete(&entities.Image{}).Error; err != nil {
+		return fmt.Errorf("failed to delete images: %w", err)
+	}
+	// deleted images drop out of search right away
+	return deleteSearchDocumentsWithTransaction(tx, imageIds)
+}
+
+// ListPurgeableImages returns up to limit images that were soft-deleted before the given time
+func (store *ImageStore) ListPurgeableImages(deletedBefore time.Time, limit int) ([]entities.Image, error) {
+	var images []entities.Image
+	err := store.DBHandler.DB.Unscoped().
+		Where("date_time_deleted IS NOT NULL AND date_time_deleted < ?", deletedBefore).
+		Order("date_time_deleted").
+		Limit(limit).
+		Find(&images).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list purgeable images: %w", err)
+	}
+	return images, nil
+}
+
+// MarkBlobsReleasedWithTransaction records that the deduplicated images among the given ones gave up their blob
+// reference
+func (store *ImageStore) MarkBlobsReleasedWithTransaction(tx *gorm.DB, imageIds []uuid.UUID) error {
+	err := tx.Unscoped().Model(&entities.Image{}).
+		Where("id IN ? AND blob_id IS NOT NULL", imageIds).
+		Update("blob_released", true).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark blobs released: %w", err)
+	}
+	return nil
+}
+
+// PurgeImagesWithTransaction permanently removes soft-deleted images. It returns the blobs still referenced by the
+// purged images, one id per reference, which the caller has to release.
+func (store *ImageStore) PurgeImagesWithTransaction(tx *gorm.DB, imageIds []uuid.UUID) ([]uuid.UUID, error) {
+	// the references are taken from the rows actually deleted, so purging the same image twice releases nothing
+	var purged []struct {
+		BlobId       *uuid.UUID
+		BlobReleased bool
+	}
+	err := tx.Raw("DELETE FROM images WHERE id IN ? AND date_time_deleted IS NOT NULL RETURNING blob_id, blob_released", imageIds).
+		Scan(&purged).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge images: %w", err)
+	}
+	var heldBlobIds []uuid.UUID
+	for _, purgedImage := range purged {
+		if purgedImage.BlobId != nil && !purgedImage.BlobReleased {
+			heldBlobIds = append(heldBlobIds, *purgedImage.BlobId)
+		}
+	}
+	return heldBlobIds, deleteSearchDocumentsWithTransaction(tx, imageIds)
+}
@@ -0,0 +1,107 @@
+package image
+
+import (
+	"bit-image/internal/postrges"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// statementRecorder keeps the SQL of every statement, which a dry run builds without sending
+type statementRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (recorder *statementRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	statement, _ := fc()
+	recorder.statements = append(recorder.statements, statement)
+}
+
+// dryRunStore returns a store whose statements are recorded instead of being run against a database
+func dryRunStore(t *testing.T) (*ImageStore, *statementRecorder) {
+	t.Helper()
+	recorder := &statementRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               recorder,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	return NewImageStore(&postrges.ConnectionHandler{DB: db}), recorder
+}
+
+func TestListImagesKeysetPagination(t *testing.T) {
+	cursorId := uuid.MustParse("7f1c4a52-9d3e-4b8a-a2f6-0c5e8d9b1a34")
+	tests := []struct {
+		name    string
+		filter  ImageFilter
+		after   *ImageCursor
+		limit   int
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "first page",
+			filter: ImageFilter{OwnerId: "owner"},
+			limit:  51,
+			want: []string{
+				`WHERE owner_id = 'owner'`,
+				`"images"."date_time_deleted" IS NULL`,
+				`ORDER BY date_time_created DESC, id DESC LIMIT 51`,
+			},
+			notWant: []string{"(date_time_created, id) <"},
+		},
+		{
+			name:   "next page starts after the cursor",
+			filter: ImageFilter{OwnerId: "owner"},
+			after:  &ImageCursor{DateTimeCreated: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), Id: cursorId},
+			limit:  11,
+			want: []string{
+				`(date_time_created, id) < ('2024-03-01 12:30:00', '7f1c4a52-9d3e-4b8a-a2f6-0c5e8d9b1a34')`,
+				`ORDER BY date_time_created DESC, id DESC LIMIT 11`,
+			},
+		},
+		{
+			name:   "filters are kept on later pages",
+			filter: ImageFilter{OwnerId: "owner", Format: "png", Tags: []string{"cat"}, MatchAllTags: true},
+			after:  &ImageCursor{DateTimeCreated: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), Id: cursorId},
+			limit:  2,
+			want: []string{
+				`format = 'png'`,
+				`HAVING COUNT(*) = 1`,
+				`(date_time_created, id) <`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, recorder := dryRunStore(t)
+			if _, err := store.ListImages(test.filter, test.after, test.limit); err != nil {
+				t.Fatalf("ListImages() error = %v", err)
+			}
+			if len(recorder.statements) != 1 {
+				t.Fatalf("ListImages() ran %d statements, want 1", len(recorder.statements))
+			}
+			statement := recorder.statements[0]
+			for _, want := range test.want {
+				if !strings.Contains(statement, want) {
+					t.Errorf("ListImages() statement %q doesn't contain %q", statement, want)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if strings.Contains(statement, notWant) {
+					t.Errorf("ListImages() statement %q contains %q", statement, notWant)
+				}
+			}
+		})
+	}
+}
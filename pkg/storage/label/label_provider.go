@@ -0,0 +1,8 @@
+package label
+
+import (
+	"github.com/google/wire"
+)
+
+// ProviderSet for the label store package
+var ProviderSet = wire.NewSet(NewLabelStore)
@@ -0,0 +1,234 @@
+package label
+
+import (
+	"bit-image/internal/postrges"
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type LabelStore struct {
+	DBHandler *postrges.ConnectionHandler
+}
+
+// LabelEntry is a label applied to an image
+type LabelEntry struct {
+	ImageId    uuid.UUID
+	Name       string
+	Source     string
+	Confidence float64
+}
+
+func NewLabelStore(dbHandler *postrges.ConnectionHandler) *LabelStore {
+	return &LabelStore{
+		DBHandler: dbHandler,
+	}
+}
+
+// EnsureLabelsWithTransaction returns the labels with the given names, creating the ones that don't exist yet
+func (store *LabelStore) EnsureLabelsWithTransaction(tx *gorm.DB, names []string) ([]entities.Label, error) {
+	newLabels := make([]entities.Label, 0, len(names))
+	for _, name := range names {
+		newLabels = append(newLabels, entities.Label{Base: common.Base{Id: uuid.New()}, Name: name})
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoNothing: true,
+	}).Create(&newLabels).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert labels: %w", err)
+	}
+
+	var labels []entities.Label
+	if err = tx.Where("name IN ?", names).Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("failed to get labels: %w", err)
+	}
+	return labels, nil
+}
+
+// SetImageLabelsWithTransaction applies labels to an image, the confidence of labels it already has from the same
+// source is replaced
+func (store *LabelStore) SetImageLabelsWithTransaction(tx *gorm.DB, imageLabels []entities.ImageLabel) error {
+	if len(imageLabels) == 0 {
+		return nil
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "image_id"}, {Name: "label_id"}, {Name: "source"}},
+		DoUpdates: clause.AssignmentColumns([]string{"confidence"}),
+	}).Create(&imageLabels).Error
+	if err != nil {
+		return fmt.Errorf("failed to label image: %w", err)
+	}
+	return nil
+}
+
+// RemoveImageLabelsWithTransaction takes the labels with the given names and source off the image
+func (store *LabelStore) RemoveImageLabelsWithTransaction(tx *gorm.DB, imageId uuid.UUID, source string, names []string) error {
+	err := tx.Where("image_id = ? AND source = ? AND label_id IN (?)", imageId, source,
+		tx.Model(&entities.Label{}).Select("id").Where("name IN ?", names),
+	).Delete(&entities.ImageLabel{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to remove image labels: %w", err)
+	}
+	return nil
+}
+
+// ClearImageLabelsWithTransaction takes every label from the source off the image
+func (store *LabelStore) ClearImageLabelsWithTransaction(tx *gorm.DB, imageId uuid.UUID, source string) error {
+	if err := tx.Where("image_id = ? AND source = ?", imageId, source).Delete(&entities.ImageLabel{}).Error; err != nil {
+		return fmt.Errorf("failed to clear image labels: %w", err)
+	}
+	return nil
+}
+
+// ListImageLabels returns the labels of the images, most confident first
+func (store *LabelStore) ListImageLabels(imageIds []uuid.UUID) ([]LabelEntry, error) {
+	var entries []LabelEntry
+	err := store.DBHandler.DB.Table("image_labels").
+		Select("image_labels.image_id AS image_id, labels.name AS name, image_labels.source AS source, image_labels.confidence AS confidence").
+		Joins("JOIN labels ON labels.id = image_labels.label_id").
+		Where("image_labels.image_id IN ?", imageIds).
+		Order("image_labels.confidence DESC, labels.name").
+		Scan(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list image labels: %w", err)
+	}
+	return entries, nil
+}
+
+// DeleteImageLabels removes every label and labeling job of purged images
+func (store *LabelStore) DeleteImageLabels(imageIds []uuid.UUID) error {
+	if err := store.DBHandler.DB.Where("image_id IN ?", imageIds).Delete(&entities.ImageLabel{}).Error; err != nil {
+		return fmt.Errorf("failed to delete image labels: %w", err)
+	}
+	if err := store.DBHandler.DB.Where("image_id IN ?", imageIds).Delete(&entities.LabelingJob{}).Error; err != nil {
+		return fmt.Errorf("failed to delete labeling jobs: %w", err)
+	}
+	return nil
+}
+
+// AddLabelingJobWithTransaction queues an image for machine labeling, an image that is already queued is left as it is
+func (store *LabelStore) AddLabelingJobWithTransaction(tx *gorm.DB, imageId uuid.UUID) error {
+	job := entities.LabelingJob{ImageId: imageId, Status: entities.LabelingPending, NextAttemptAt: time.Now()}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&job).Error; err != nil {
+		return fmt.Errorf("failed to queue labeling of image %s: %w", imageId.String(), err)
+	}
+	return nil
+}
+
+// RequeueLabelingJobWithTransaction queues an image for machine labeling again, whatever became of its last job
+func (store *LabelStore) RequeueLabelingJobWithTransaction(tx *gorm.DB, imageId uuid.UUID) error {
+	job := entities.LabelingJob{ImageId: imageId, Status: entities.LabelingPending, NextAttemptAt: time.Now()}
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "image_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"status":          entities.LabelingPending,
+			"attempts":        0,
+			"last_error":      "",
+			"next_attempt_at": job.NextAttemptAt,
+		}),
+	}).Create(&job).Error
+	if err != nil {
+		return fmt.Errorf("failed to queue labeling of image %s: %w", imageId.String(), err)
+	}
+	return nil
+}
+
+// GetLabelingJob returns the labeling job of an image, nil when it was never queued
+func (store *LabelStore) GetLabelingJob(imageId uuid.UUID) (*entities.LabelingJob, error) {
+	var jobs []entities.LabelingJob
+	if err := store.DBHandler.DB.Where("image_id = ?", imageId).Limit(1).Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get labeling job of image %s: %w", imageId.String(), err)
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0], nil
+}
+
+// CountLabelingJobsByStatus returns how many labeling jobs are in each status
+func (store *LabelStore) CountLabelingJobsByStatus() (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := store.DBHandler.DB.Model(&entities.LabelingJob{}).Select("status, count(*) AS count").Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count labeling jobs: %w", err)
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// ClaimLabelingJobs takes up to limit pending jobs that are due, only the one of the image when imageId is set.
+// Claimed jobs count an attempt and aren't due again before leaseUntil, jobs claimed by someone else are skipped.
+func (store *LabelStore) ClaimLabelingJobs(imageId *uuid.UUID, limit int, leaseUntil time.Time) ([]entities.LabelingJob, error) {
+	due := store.DBHandler.DB.Model(&entities.LabelingJob{}).
+		Select("image_id").
+		Where("status = ? AND next_attempt_at <= ?", entities.LabelingPending, time.Now())
+	if imageId != nil {
+		due = due.Where("image_id = ?", *imageId)
+	}
+	due = due.Order("next_attempt_at").Limit(limit).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+
+	var claimed []entities.LabelingJob
+	err := store.DBHandler.DB.Model(&claimed).
+		Clauses(clause.Returning{}).
+		Where("image_id IN (?)", due).
+		Updates(map[string]any{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": leaseUntil,
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim labeling jobs: %w", err)
+	}
+	return claimed, nil
+}
+
+// MarkLabelingJobDoneWithTransaction records a finished labeling, in the transaction storing its labels
+func (store *LabelStore) MarkLabelingJobDoneWithTransaction(tx *gorm.DB, imageId uuid.UUID) error {
+	err := tx.Model(&entities.LabelingJob{}).
+		Where("image_id = ?", imageId).
+		Updates(map[string]any{"status": entities.LabelingDone, "last_error": ""}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update labeling job of image %s: %w", imageId.String(), err)
+	}
+	return nil
+}
+
+// MarkLabelingJobFailed records a failed labeling. The job is retried at retryAt, or given up on when retryAt is nil.
+func (store *LabelStore) MarkLabelingJobFailed(imageId uuid.UUID, reason string, retryAt *time.Time) error {
+	updates := map[string]any{"last_error": reason}
+	if retryAt != nil {
+		updates["next_attempt_at"] = *retryAt
+	} else {
+		updates["status"] = entities.LabelingFailed
+	}
+	err := store.DBHandler.DB.Model(&entities.LabelingJob{}).Where("image_id = ?", imageId).Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("failed to update labeling job of image %s: %w", imageId.String(), err)
+	}
+	return nil
+}
+
+// RetryFailedLabelingJobs puts every job that ran out of attempts back in the queue and returns how many there were
+func (store *LabelStore) RetryFailedLabelingJobs() (int64, error) {
+	result := store.DBHandler.DB.Model(&entities.LabelingJob{}).
+		Where("status = ?", entities.LabelingFailed).
+		Updates(map[string]any{
+			"status":          entities.LabelingPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to retry labeling jobs: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
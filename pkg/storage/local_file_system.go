@@ -0,0 +1,411 @@
+package storage
+
+import (
+	"bit-image/pkg/common"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSignature is returned when a local storage url has been tampered with or has expired
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// ErrChecksumMismatch is returned when uploaded content doesn't match the checksum it was declared with
+var ErrChecksumMismatch = errors.New("content does not match the declared checksum")
+
+// LocalFileSystem is an ObjectStore that keeps objects on disk under rootDir/<bucket>/<key>.
+// Presigned urls point back at the gin router (see common.LOCAL_STORAGE_ROUTE), signed with an HMAC
+// so the upload flow behaves the same way it does against S3.
+type LocalFileSystem struct {
+	rootDir string
+	baseURL string
+	secret  []byte
+	bucket  string
+}
+
+// localObjectMeta is persisted next to every object, S3 keeps the same fields as object metadata
+type localObjectMeta struct {
+	ContentType    string `json:"content_type"`
+	ChecksumSHA256 string `json:"checksum_sha256"`
+}
+
+func NewLocalFileSystem(rootDir, baseURL string, secret []byte, bucket string) (*LocalFileSystem, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("local storage secret is not set")
+	}
+	if err := os.MkdirAll(rootDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create local storage root %s: %w", rootDir, err)
+	}
+	return &LocalFileSystem{
+		rootDir: rootDir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  secret,
+		bucket:  bucket,
+	}, nil
+}
+
+// Bucket is the directory under rootDir that plays the role of the S3 bucket
+func (fs *LocalFileSystem) Bucket() string {
+	return fs.bucket
+}
+
+// objectPath resolves a key to a file under the bucket directory, refusing keys that escape it
+func (fs *LocalFileSystem) objectPath(bucket, key string) (string, error) {
+	bucketDir := filepath.Join(fs.rootDir, bucket)
+	path := filepath.Join(bucketDir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, bucketDir+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return path, nil
+}
+
+// metaPath is where the metadata of an object lives, kept in a separate tree so it never shows up as an object
+func (fs *LocalFileSystem) metaPath(bucket, key string) (string, error) {
+	metaDir := filepath.Join(fs.rootDir, ".meta", bucket)
+	path := filepath.Join(metaDir, filepath.FromSlash(key)+".json")
+	if !strings.HasPrefix(path, metaDir+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return path, nil
+}
+
+// sign covers the method, the key and every query parameter of the url except the signature itself
+func (fs *LocalFileSystem) sign(method, key string, params url.Values) string {
+	mac := hmac.New(sha256.New, fs.secret)
+	mac.Write([]byte(method + "\n" + key + "\n" + params.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (fs *LocalFileSystem) signedURL(method, key string, expiry time.Duration, params url.Values) string {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("expires", strconv.FormatInt(time.Now().Add(expiry).Unix(), 10))
+	params.Set("signature", fs.sign(method, key, params))
+	return fs.baseURL + common.LOCAL_STORAGE_ROUTE + "/" + key + "?" + params.Encode()
+}
+
+// VerifySignature checks a url issued by this store for the given method and key
+func (fs *LocalFileSystem) VerifySignature(method, key string, query url.Values) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expires {
+		return ErrInvalidSignature
+	}
+
+	params := url.Values{}
+	for name, values := range query {
+		if name != "signature" {
+			params[name] = values
+		}
+	}
+	expected := fs.sign(method, key, params)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (fs *LocalFileSystem) GeneratePresignedURL(expiry time.Duration, UserId string, checksumSHA256 string) (string, uuid.UUID, error) {
+	checksum, err := hex.DecodeString(checksumSHA256)
+	if err != nil || len(checksum) != sha256.Size {
+		return "", uuid.UUID{}, fmt.Errorf("invalid sha256 checksum %q", checksumSHA256)
+	}
+
+	imageId := uuid.New()
+	key := common.TEMPORARY_STORAGE_FOLDER + "/" + UserId + "/" + imageId.String()
+	params := url.Values{}
+	params.Set("checksum_sha256", hex.EncodeToString(checksum))
+	return fs.signedURL("PUT", key, expiry, params), imageId, nil
+}
+
+func (fs *LocalFileSystem) GeneratePresignedGetURL(key string, expiry time.Duration, contentDisposition string) (string, error) {
+	params := url.Values{}
+	if contentDisposition != "" {
+		params.Set("response-content-disposition", contentDisposition)
+	}
+	return fs.signedURL("GET", key, expiry, params), nil
+}
+
+func (fs *LocalFileSystem) GetObjectMetadata(key, bucket string) (int64, string, error) {
+	path, err := fs.objectPath(bucket, key)
+	if err != nil {
+		return 0, "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, "", fmt.Errorf("failed to get metadata for object %s in bucket %s: %w", key, bucket, ErrObjectNotFound)
+		}
+		return 0, "", fmt.Errorf("failed to get metadata for object %s in bucket %s: %w", key, bucket, err)
+	}
+
+	meta, err := fs.readMeta(bucket, key)
+	if err != nil {
+		return 0, "", err
+	}
+	return info.Size(), meta.ContentType, nil
+}
+
+func (fs *LocalFileSystem) HeadObject(key string) (ObjectInfo, error) {
+	path, err := fs.objectPath(fs.Bucket(), key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ObjectInfo{}, fmt.Errorf("failed to head object %s: %w", key, ErrObjectNotFound)
+		}
+		return ObjectInfo{}, fmt.Errorf("failed to head object %s: %w", key, err)
+	}
+
+	meta, err := fs.readMeta(fs.Bucket(), key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{
+		Key:            key,
+		Size:           stat.Size(),
+		ContentType:    meta.ContentType,
+		ChecksumSHA256: meta.ChecksumSHA256,
+		LastModified:   stat.ModTime(),
+	}, nil
+}
+
+func (fs *LocalFileSystem) readMeta(bucket, key string) (localObjectMeta, error) {
+	var meta localObjectMeta
+	path, err := fs.metaPath(bucket, key)
+	if err != nil {
+		return meta, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return meta, nil
+		}
+		return meta, fmt.Errorf("failed to read metadata for object %s: %w", key, err)
+	}
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("failed to decode metadata for object %s: %w", key, err)
+	}
+	return meta, nil
+}
+
+func (fs *LocalFileSystem) writeMeta(bucket, key string, meta localObjectMeta) error {
+	path, err := fs.metaPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (fs *LocalFileSystem) CopyObject(srcKey, destKey string) error {
+	bucket := fs.Bucket()
+	src, err := fs.GetObject(srcKey)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	meta, err := fs.readMeta(bucket, srcKey)
+	if err != nil {
+		return err
+	}
+	if err = fs.PutObject(destKey, src, meta.ContentType); err != nil {
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+	return nil
+}
+
+func (fs *LocalFileSystem) MoveFileToFolder(file common.File, srcFolderName, destFolderName string) error {
+	srcKey := fmt.Sprintf("%s/%s", srcFolderName, file.Id)
+	destKey := fmt.Sprintf("%s/%s", destFolderName, file.Id)
+	bucket := fs.Bucket()
+
+	srcPath, err := fs.objectPath(bucket, srcKey)
+	if err != nil {
+		return err
+	}
+	destPath, err := fs.objectPath(bucket, destKey)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return err
+	}
+	// a link fails when the destination exists, unlike a rename which would silently replace it, and checking first
+	// would leave room for a concurrent move in between
+	if err = os.Link(srcPath, destPath); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("cannot move file to %s. Object with id %s already exists", destFolderName, file.Id)
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to move object %s: %w", srcKey, ErrObjectNotFound)
+		}
+		return fmt.Errorf("failed to move object %s: %w", srcKey, err)
+	}
+
+	meta, err := fs.readMeta(bucket, srcKey)
+	if err != nil {
+		return err
+	}
+	if err = fs.writeMeta(bucket, destKey, meta); err != nil {
+		return err
+	}
+	if err = os.Remove(srcPath); err != nil {
+		return fmt.Errorf("failed to move object %s: %w", srcKey, err)
+	}
+	return fs.removeMeta(bucket, srcKey)
+}
+
+func (fs *LocalFileSystem) GetObject(key string) (io.ReadCloser, error) {
+	path, err := fs.objectPath(fs.Bucket(), key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to get object %s: %w", key, ErrObjectNotFound)
+		}
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	return file, nil
+}
+
+func (fs *LocalFileSystem) PutObject(key string, body io.Reader, contentType string) error {
+	return fs.PutObjectWithChecksum(key, body, contentType, "")
+}
+
+// PutObjectWithChecksum stores the object only when its content hashes to the declared hex SHA-256, like S3 with a
+// signed checksum header. On a mismatch nothing is stored and an object already at the key is left as it is.
+func (fs *LocalFileSystem) PutObjectWithChecksum(key string, body io.Reader, contentType string, checksumSHA256 string) error {
+	bucket := fs.Bucket()
+	path, err := fs.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so readers never observe a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(tmp, hasher), body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	if checksumSHA256 != "" && checksum != checksumSHA256 {
+		return fmt.Errorf("failed to put object %s: %w", key, ErrChecksumMismatch)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+
+	return fs.writeMeta(bucket, key, localObjectMeta{
+		ContentType:    contentType,
+		ChecksumSHA256: checksum,
+	})
+}
+
+func (fs *LocalFileSystem) DeleteObject(key string) error {
+	bucket := fs.Bucket()
+	path, err := fs.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	// S3 deletes are idempotent, keep the same semantics
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return fs.removeMeta(bucket, key)
+}
+
+func (fs *LocalFileSystem) ListObjects(prefix string, fn func(ObjectInfo) error) error {
+	bucketDir := filepath.Join(fs.rootDir, fs.Bucket())
+	err := filepath.WalkDir(bucketDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+
+		relative, err := filepath.Rel(bucketDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+	}
+	return nil
+}
+
+func (fs *LocalFileSystem) DeleteFilesFromFolder(fileIDs []string, folderName string) ([]string, error) {
+	var failed []string
+	var lastErr error
+	for _, fileID := range fileIDs {
+		if err := fs.DeleteObject(fmt.Sprintf("%s/%s", folderName, fileID)); err != nil {
+			failed = append(failed, fileID)
+			lastErr = err
+		}
+	}
+	return failed, lastErr
+}
+
+func (fs *LocalFileSystem) removeMeta(bucket, key string) error {
+	path, err := fs.metaPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete metadata for object %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"bit-image/pkg/common"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func localStore(t *testing.T) *LocalFileSystem {
+	t.Helper()
+	fs, err := NewLocalFileSystem(t.TempDir(), "http://localhost:8080", []byte("secret"), "images")
+	if err != nil {
+		t.Fatalf("NewLocalFileSystem() error = %v", err)
+	}
+	return fs
+}
+
+func objectContent(t *testing.T, fs *LocalFileSystem, key string) string {
+	t.Helper()
+	body, err := fs.GetObject(key)
+	if err != nil {
+		t.Fatalf("GetObject(%s) error = %v", key, err)
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", key, err)
+	}
+	return string(content)
+}
+
+func TestMoveFileToFolder(t *testing.T) {
+	file := common.File{Id: "3b9e6f1d-2c4a-4e8b-9a7d-5f0c1e2d3a4b"}
+
+	t.Run("moves the object and its metadata", func(t *testing.T) {
+		fs := localStore(t)
+		if err := fs.PutObject("tmp/"+file.Id, strings.NewReader("new"), "image/png"); err != nil {
+			t.Fatalf("PutObject() error = %v", err)
+		}
+		if err := fs.MoveFileToFolder(file, "tmp", "images"); err != nil {
+			t.Fatalf("MoveFileToFolder() error = %v", err)
+		}
+		if got := objectContent(t, fs, "images/"+file.Id); got != "new" {
+			t.Errorf("moved object = %q, want %q", got, "new")
+		}
+		if _, contentType, err := fs.GetObjectMetadata("images/"+file.Id, fs.Bucket()); err != nil || contentType != "image/png" {
+			t.Errorf("GetObjectMetadata() = %q, %v, want image/png", contentType, err)
+		}
+		if _, err := fs.GetObject("tmp/" + file.Id); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("GetObject() of the source error = %v, want ErrObjectNotFound", err)
+		}
+	})
+
+	t.Run("leaves an existing destination alone", func(t *testing.T) {
+		fs := localStore(t)
+		if err := fs.PutObject("images/"+file.Id, strings.NewReader("old"), "image/png"); err != nil {
+			t.Fatalf("PutObject() error = %v", err)
+		}
+		if err := fs.PutObject("tmp/"+file.Id, strings.NewReader("new"), "image/png"); err != nil {
+			t.Fatalf("PutObject() error = %v", err)
+		}
+		if err := fs.MoveFileToFolder(file, "tmp", "images"); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("MoveFileToFolder() error = %v, want the destination to exist", err)
+		}
+		if got := objectContent(t, fs, "images/"+file.Id); got != "old" {
+			t.Errorf("destination = %q, want it untouched", got)
+		}
+		if got := objectContent(t, fs, "tmp/"+file.Id); got != "new" {
+			t.Errorf("source = %q, want it kept", got)
+		}
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		fs := localStore(t)
+		if err := fs.MoveFileToFolder(file, "tmp", "images"); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("MoveFileToFolder() error = %v, want ErrObjectNotFound", err)
+		}
+	})
+}
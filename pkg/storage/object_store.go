@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"bit-image/pkg/common"
+	"errors"
+	"github.com/google/uuid"
+	"io"
+	"time"
+)
+
+// ErrObjectNotFound is returned by an ObjectStore when the requested key does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object. ChecksumSHA256 is hex-encoded and empty when the backend has no full-object
+// checksum for it.
+type ObjectInfo struct {
+	Key            string
+	Size           int64
+	ContentType    string
+	ChecksumSHA256 string
+	LastModified   time.Time
+}
+
+// ObjectStore is the blob storage used by the upload flow. S3FileSystem is the production
+// implementation, LocalFileSystem keeps everything on disk for development and CI.
+type ObjectStore interface {
+	// GeneratePresignedURL issues a PUT url for a new image under TEMP_STORAGE/<UserId>/<imageId>. The upload is only
+	// accepted when its content matches checksumSHA256, the hex-encoded SHA-256 declared by the client.
+	GeneratePresignedURL(expiry time.Duration, UserId string, checksumSHA256 string) (string, uuid.UUID, error)
+	// GeneratePresignedGetURL issues a GET url for an existing object, contentDisposition overrides the
+	// Content-Disposition header of the response when set
+	GeneratePresignedGetURL(key string, expiry time.Duration, contentDisposition string) (string, error)
+	// GetObjectMetadata returns the size and content type of an object
+	GetObjectMetadata(key, bucket string) (int64, string, error)
+	// HeadObject returns everything known about an object without fetching its content
+	HeadObject(key string) (ObjectInfo, error)
+	CopyObject(srcKey, destKey string) error
+	MoveFileToFolder(file common.File, srcFolderName, destFolderName string) error
+	GetObject(key string) (io.ReadCloser, error)
+	PutObject(key string, body io.Reader, contentType string) error
+	DeleteObject(key string) error
+	// ListObjects calls fn for every object under the prefix, paging through the listing as it goes.
+	// An error returned by fn stops the listing.
+	ListObjects(prefix string, fn func(ObjectInfo) error) error
+	// DeleteFilesFromFolder removes <folderName>/<fileID> for each id and returns the ids that could not be removed
+	DeleteFilesFromFolder(fileIDs []string, folderName string) ([]string, error)
+}
@@ -0,0 +1,8 @@
+package outbox
+
+import (
+	"github.com/google/wire"
+)
+
+// ProviderSet for the outbox store package
+var ProviderSet = wire.NewSet(NewOutboxStore)
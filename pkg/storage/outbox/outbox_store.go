@@ -0,0 +1,106 @@
+package outbox
+
+import (
+	"bit-image/internal/postrges"
+	"bit-image/pkg/common/entities"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type OutboxStore struct {
+	DBHandler *postrges.ConnectionHandler
+}
+
+func NewOutboxStore(dbHandler *postrges.ConnectionHandler) *OutboxStore {
+	return &OutboxStore{
+		DBHandler: dbHandler,
+	}
+}
+
+// AddEventsWithTransaction records events in the transaction of the change they describe, so they are published if and
+// only if the change is committed
+func (store *OutboxStore) AddEventsWithTransaction(tx *gorm.DB, events []entities.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := tx.Create(&events).Error; err != nil {
+		return fmt.Errorf("failed to insert outbox events: %w", err)
+	}
+	return nil
+}
+
+// ClaimEvents takes up to limit due events, oldest first. Only the oldest pending event of an image can be claimed, so
+// the events of an image are delivered one at a time and in order. Claimed events count an attempt and aren't due
+// again before leaseUntil, a dispatcher that dies while holding them doesn't keep them from being retried. Events
+// claimed by someone else are skipped.
+func (store *OutboxStore) ClaimEvents(limit int, leaseUntil time.Time) ([]entities.OutboxEvent, error) {
+	due := store.DBHandler.DB.Model(&entities.OutboxEvent{}).
+		Select("sequence").
+		Where("status = ? AND next_attempt_at <= ?", entities.OutboxPending, time.Now()).
+		Where("NOT EXISTS (SELECT 1 FROM outbox earlier WHERE earlier.image_id = outbox.image_id AND earlier.status = ? AND earlier.sequence < outbox.sequence)", entities.OutboxPending).
+		Order("sequence").
+		Limit(limit).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+
+	var claimed []entities.OutboxEvent
+	err := store.DBHandler.DB.Model(&claimed).
+		Clauses(clause.Returning{}).
+		Where("sequence IN (?)", due).
+		Updates(map[string]any{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": leaseUntil,
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	return claimed, nil
+}
+
+// MarkEventDelivered records a delivered event, which releases the next event of its image
+func (store *OutboxStore) MarkEventDelivered(sequence int64) error {
+	err := store.DBHandler.DB.Model(&entities.OutboxEvent{}).
+		Where("sequence = ?", sequence).
+		Updates(map[string]any{
+			"status":       entities.OutboxDelivered,
+			"last_error":   "",
+			"delivered_at": time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update outbox event %d: %w", sequence, err)
+	}
+	return nil
+}
+
+// MarkEventFailed records a failed delivery, the event is retried at retryAt
+func (store *OutboxStore) MarkEventFailed(sequence int64, reason string, retryAt time.Time) error {
+	err := store.DBHandler.DB.Model(&entities.OutboxEvent{}).
+		Where("sequence = ?", sequence).
+		Updates(map[string]any{"last_error": reason, "next_attempt_at": retryAt}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update outbox event %d: %w", sequence, err)
+	}
+	return nil
+}
+
+// DeleteDeliveredEvents removes the events delivered before the cutoff and returns how many there were
+func (store *OutboxStore) DeleteDeliveredEvents(cutoff time.Time) (int64, error) {
+	result := store.DBHandler.DB.
+		Where("status = ? AND delivered_at < ?", entities.OutboxDelivered, cutoff).
+		Delete(&entities.OutboxEvent{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete delivered outbox events: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// CountPendingEvents returns how many events wait for delivery
+func (store *OutboxStore) CountPendingEvents() (int64, error) {
+	var count int64
+	err := store.DBHandler.DB.Model(&entities.OutboxEvent{}).Where("status = ?", entities.OutboxPending).Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pending outbox events: %w", err)
+	}
+	return count, nil
+}
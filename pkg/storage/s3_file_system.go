@@ -3,6 +3,9 @@ package storage
 import (
 	"bit-image/pkg/common"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -11,24 +14,29 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
 	"github.com/google/uuid"
+	"io"
 	"net/url"
-	"os"
 	"sync"
 	"time"
 )
 
+// deleteObjectsBatchSize is the maximum number of keys S3 accepts in a single DeleteObjects request
+const deleteObjectsBatchSize = 1000
+
 type S3FileSystem struct {
 	s3Client          *s3.Client
 	s3TransferManager *manager.Uploader
 	resourceLocks     sync.Map
 	urlTTL            int
 	lockStripeCount   int
+	bucket            string
 }
 
-func NewS3FileSystem(s3Client *s3.Client, s3TransferManager *manager.Uploader) *S3FileSystem {
+func NewS3FileSystem(s3Client *s3.Client, s3TransferManager *manager.Uploader, bucket string) *S3FileSystem {
 	return &S3FileSystem{
 		s3Client:          s3Client,
 		s3TransferManager: s3TransferManager,
+		bucket:            bucket,
 		urlTTL:            120000, // TTL in milliseconds
 		lockStripeCount:   10,
 	}
@@ -65,8 +73,7 @@ func (fs *S3FileSystem) fileExists(bucketName string, destKey string) (bool, err
 		Key:    aws.String(destKey),
 	})
 	if err != nil {
-		var apiErr smithy.APIError
-		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "404" {
+		if isNotFound(err) {
 			return false, nil
 		}
 		return false, err
@@ -74,16 +81,35 @@ func (fs *S3FileSystem) fileExists(bucketName string, destKey string) (bool, err
 	return true, nil
 }
 
-func (fs *S3FileSystem) GeneratePresignedURL(expiry time.Duration, UserId string) (string, uuid.UUID, error) {
+// isNotFound reports whether an S3 error means the bucket or key does not exist
+func isNotFound(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "404", "NotFound", "NoSuchKey":
+		return true
+	}
+	return false
+}
+
+func (fs *S3FileSystem) GeneratePresignedURL(expiry time.Duration, UserId string, checksumSHA256 string) (string, uuid.UUID, error) {
 	presigner := s3.NewPresignClient(fs.s3Client)
 
+	checksum, err := hex.DecodeString(checksumSHA256)
+	if err != nil || len(checksum) != sha256.Size {
+		return "", uuid.UUID{}, fmt.Errorf("invalid sha256 checksum %q", checksumSHA256)
+	}
+
 	imageId := uuid.New()
 	imageIdString := UserId + "/" + imageId.String()
-	defaultBucketName := os.Getenv("DEFAULT_BUCKET_NAME")
 
+	// the checksum header becomes part of the signature, S3 rejects uploads whose content doesn't match it
 	putObjectInput := &s3.PutObjectInput{
-		Bucket: aws.String(defaultBucketName),
-		Key:    aws.String(common.TEMPORARY_STORAGE_FOLDER + "/" + imageIdString),
+		Bucket:         aws.String(fs.bucket),
+		Key:            aws.String(common.TEMPORARY_STORAGE_FOLDER + "/" + imageIdString),
+		ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(checksum)),
 	}
 
 	presignedURL, err := presigner.PresignPutObject(context.TODO(), putObjectInput, s3.WithPresignExpires(expiry))
@@ -94,22 +120,33 @@ func (fs *S3FileSystem) GeneratePresignedURL(expiry time.Duration, UserId string
 	return presignedURL.URL, imageId, nil
 }
 
+func (fs *S3FileSystem) GeneratePresignedGetURL(key string, expiry time.Duration, contentDisposition string) (string, error) {
+	presigner := s3.NewPresignClient(fs.s3Client)
+
+	getObjectInput := &s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+	}
+	if contentDisposition != "" {
+		getObjectInput.ResponseContentDisposition = aws.String(contentDisposition)
+	}
+
+	presignedURL, err := presigner.PresignGetObject(context.TODO(), getObjectInput, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", fmt.Errorf("error presigning request: %w", err)
+	}
+
+	return presignedURL.URL, nil
+}
+
 func (fs *S3FileSystem) MoveFileToFolder(file common.File, srcFolderName, destFolderName string) error {
 	// Assuming `file` has a `Name` field that represents the file name
 	srcKey := fmt.Sprintf("%s/%s", srcFolderName, file.Id)
 	destKey := fmt.Sprintf("%s/%s", destFolderName, file.Id)
-	bucket := os.Getenv("DEFAULT_BUCKET_NAME")
-
-	if bucket == "" {
-		return fmt.Errorf("environment variable DEFAULT_BUCKET_NAME is not set")
-	}
-
-	ctx := context.TODO()
 
 	// Step 0: Check if the destination file already exists
-	found, err := fs.fileExists(bucket, destKey)
-	if err == nil {
-		fmt.Println(err)
+	found, err := fs.fileExists(fs.bucket, destKey)
+	if err != nil {
 		return fmt.Errorf("failed to check if destination file exists: %w", err)
 	}
 
@@ -118,28 +155,72 @@ func (fs *S3FileSystem) MoveFileToFolder(file common.File, srcFolderName, destFo
 	}
 
 	// Step 1: Copy the object to the new location
-	copySource := fmt.Sprintf("%s/%s", bucket, srcKey)
+	if err = fs.CopyObject(srcKey, destKey); err != nil {
+		return err
+	}
+
+	// Step 2: Delete the original object
+	if err = fs.DeleteObject(srcKey); err != nil {
+		return fmt.Errorf("failed to delete original object: %w", err)
+	}
+
+	return nil
+}
+
+func (fs *S3FileSystem) CopyObject(srcKey, destKey string) error {
+	copySource := fmt.Sprintf("%s/%s", fs.bucket, srcKey)
 	copySource = url.PathEscape(copySource) // Ensure proper URL encoding
 
-	_, err = fs.s3Client.CopyObject(ctx, &s3.CopyObjectInput{
-		Bucket:     aws.String(bucket),
+	_, err := fs.s3Client.CopyObject(context.TODO(), &s3.CopyObjectInput{
+		Bucket:     aws.String(fs.bucket),
 		CopySource: aws.String(copySource),
 		Key:        aws.String(destKey),
 		ACL:        types.ObjectCannedACLPrivate, // Or other ACL as needed
 	})
 	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("failed to copy object %s: %w", srcKey, ErrObjectNotFound)
+		}
 		return fmt.Errorf("failed to copy object: %w", err)
 	}
+	return nil
+}
 
-	// Step 2: Delete the original object
-	_, err = fs.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(srcKey),
+func (fs *S3FileSystem) GetObject(key string) (io.ReadCloser, error) {
+	result, err := fs.s3Client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete original object: %w", err)
+		if isNotFound(err) {
+			return nil, fmt.Errorf("failed to get object %s: %w", key, ErrObjectNotFound)
+		}
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
 	}
+	return result.Body, nil
+}
 
+func (fs *S3FileSystem) PutObject(key string, body io.Reader, contentType string) error {
+	_, err := fs.s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:      aws.String(fs.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (fs *S3FileSystem) DeleteObject(key string) error {
+	_, err := fs.s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
 	return nil
 }
 
@@ -153,6 +234,34 @@ func (fs *S3FileSystem) moveFilesToFolder(files []common.File, srcFolderName, de
 	return nil
 }
 
+func (fs *S3FileSystem) HeadObject(key string) (ObjectInfo, error) {
+	result, err := fs.s3Client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket:       aws.String(fs.bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return ObjectInfo{}, fmt.Errorf("failed to head object %s: %w", key, ErrObjectNotFound)
+		}
+		return ObjectInfo{}, fmt.Errorf("failed to head object %s: %w", key, err)
+	}
+
+	info := ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(result.ContentLength),
+		ContentType:  aws.ToString(result.ContentType),
+		LastModified: aws.ToTime(result.LastModified),
+	}
+
+	// multipart uploads carry a checksum of the part checksums ("<base64>-<parts>"), which can't be compared
+	// against a full-object SHA-256, so it is left empty for callers to re-hash the content instead
+	if checksum, err := base64.StdEncoding.DecodeString(aws.ToString(result.ChecksumSHA256)); err == nil && len(checksum) == sha256.Size {
+		info.ChecksumSHA256 = hex.EncodeToString(checksum)
+	}
+	return info, nil
+}
+
 func (fs *S3FileSystem) GetObjectMetadata(key, bucket string) (int64, string, error) {
 	ctx := context.TODO()
 
@@ -161,6 +270,9 @@ func (fs *S3FileSystem) GetObjectMetadata(key, bucket string) (int64, string, er
 		Key:    aws.String(key),
 	})
 	if err != nil {
+		if isNotFound(err) {
+			return 0, "", fmt.Errorf("failed to get metadata for object %s in bucket %s: %w", key, bucket, ErrObjectNotFound)
+		}
 		return 0, "", fmt.Errorf("failed to get metadata for object %s in bucket %s: %w", key, bucket, err)
 	}
 
@@ -171,7 +283,63 @@ func (fs *S3FileSystem) GetObjectMetadata(key, bucket string) (int64, string, er
 	return *size, contentType, nil
 }
 
-func (fs *S3FileSystem) deleteFilesFromFolder(fileIDs []string, folderName string) error {
-	// Placeholder: Implement logic for deleting files from a folder
+func (fs *S3FileSystem) ListObjects(prefix string, fn func(ObjectInfo) error) error {
+	paginator := s3.NewListObjectsV2Paginator(fs.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(fs.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+		}
+		for _, object := range page.Contents {
+			info := ObjectInfo{
+				Key:          aws.ToString(object.Key),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+			}
+			if err = fn(info); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
+
+// DeleteFilesFromFolder removes <folderName>/<fileID> for every id, batching requests by the DeleteObjects limit.
+// The ids that could not be deleted are returned so callers can retry them later.
+func (fs *S3FileSystem) DeleteFilesFromFolder(fileIDs []string, folderName string) ([]string, error) {
+	var failed []string
+	var lastErr error
+	for start := 0; start < len(fileIDs); start += deleteObjectsBatchSize {
+		batch := fileIDs[start:min(start+deleteObjectsBatchSize, len(fileIDs))]
+
+		objects := make([]types.ObjectIdentifier, 0, len(batch))
+		keyToId := make(map[string]string, len(batch))
+		for _, fileID := range batch {
+			key := fmt.Sprintf("%s/%s", folderName, fileID)
+			keyToId[key] = fileID
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+
+		result, err := fs.s3Client.DeleteObjects(context.TODO(), &s3.DeleteObjectsInput{
+			Bucket: aws.String(fs.bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			// the whole batch failed, keep going so one bad batch doesn't block the rest
+			failed = append(failed, batch...)
+			lastErr = fmt.Errorf("failed to delete objects from %s: %w", folderName, err)
+			continue
+		}
+
+		for _, deleteErr := range result.Errors {
+			failed = append(failed, keyToId[aws.ToString(deleteErr.Key)])
+			lastErr = fmt.Errorf("failed to delete object %s: %s", aws.ToString(deleteErr.Key), aws.ToString(deleteErr.Message))
+		}
+	}
+
+	return failed, lastErr
+}
@@ -0,0 +1,6 @@
+package storage
+
+import "github.com/google/wire"
+
+// ProviderSet for the storage package
+var ProviderSet = wire.NewSet(NewUserStore)
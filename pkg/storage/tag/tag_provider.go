@@ -0,0 +1,8 @@
+package tag
+
+import (
+	"github.com/google/wire"
+)
+
+// ProviderSet for the tag store package
+var ProviderSet = wire.NewSet(NewTagStore)
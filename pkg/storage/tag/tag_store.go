@@ -0,0 +1,122 @@
+package tag
+
+import (
+	"bit-image/internal/postrges"
+	"bit-image/pkg/common"
+	"bit-image/pkg/common/entities"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TagStore struct {
+	DBHandler *postrges.ConnectionHandler
+}
+
+// TagCount is a tag along with how many live images carry it
+type TagCount struct {
+	Name  string
+	Count int64
+}
+
+// ImageTagName is a tag of an image
+type ImageTagName struct {
+	ImageId uuid.UUID
+	Name    string
+}
+
+func NewTagStore(dbHandler *postrges.ConnectionHandler) *TagStore {
+	return &TagStore{
+		DBHandler: dbHandler,
+	}
+}
+
+// EnsureTagsWithTransaction returns the owner's tags with the given names, creating the ones that don't exist yet
+func (store *TagStore) EnsureTagsWithTransaction(tx *gorm.DB, ownerId string, names []string) ([]entities.Tag, error) {
+	newTags := make([]entities.Tag, 0, len(names))
+	for _, name := range names {
+		newTags = append(newTags, entities.Tag{Base: common.Base{Id: uuid.New()}, OwnerId: ownerId, Name: name})
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "owner_id"}, {Name: "name"}},
+		DoNothing: true,
+	}).Create(&newTags).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert tags: %w", err)
+	}
+
+	var tags []entities.Tag
+	if err = tx.Where("owner_id = ? AND name IN ?", ownerId, names).Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	return tags, nil
+}
+
+// AddImageTagsWithTransaction puts every tag on every image, tags an image already has are left alone
+func (store *TagStore) AddImageTagsWithTransaction(tx *gorm.DB, imageIds []uuid.UUID, tags []entities.Tag) error {
+	imageTags := make([]entities.ImageTag, 0, len(imageIds)*len(tags))
+	for _, imageId := range imageIds {
+		for _, imageTag := range tags {
+			imageTags = append(imageTags, entities.ImageTag{ImageId: imageId, TagId: imageTag.Base.Id})
+		}
+	}
+	if len(imageTags) == 0 {
+		return nil
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&imageTags).Error; err != nil {
+		return fmt.Errorf("failed to tag images: %w", err)
+	}
+	return nil
+}
+
+// RemoveImageTagsWithTransaction takes the owner's tags with the given names off the images
+func (store *TagStore) RemoveImageTagsWithTransaction(tx *gorm.DB, imageIds []uuid.UUID, ownerId string, names []string) error {
+	err := tx.Where("image_id IN ? AND tag_id IN (?)", imageIds,
+		tx.Model(&entities.Tag{}).Select("id").Where("owner_id = ? AND name IN ?", ownerId, names),
+	).Delete(&entities.ImageTag{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to untag images: %w", err)
+	}
+	return nil
+}
+
+// ListTagCounts returns the owner's tags that are on at least one live image, most used first
+func (store *TagStore) ListTagCounts(ownerId string) ([]TagCount, error) {
+	counts := []TagCount{}
+	err := store.DBHandler.DB.Table("tags").
+		Select("tags.name AS name, COUNT(*) AS count").
+		Joins("JOIN image_tags ON image_tags.tag_id = tags.id").
+		Joins("JOIN images ON images.id = image_tags.image_id AND images.date_time_deleted IS NULL").
+		Where("tags.owner_id = ?", ownerId).
+		Group("tags.name").
+		Order("count DESC, name").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tags: %w", err)
+	}
+	return counts, nil
+}
+
+// ListImageTags returns the tags of the images, sorted by name
+func (store *TagStore) ListImageTags(imageIds []uuid.UUID) ([]ImageTagName, error) {
+	var names []ImageTagName
+	err := store.DBHandler.DB.Table("image_tags").
+		Select("image_tags.image_id AS image_id, tags.name AS name").
+		Joins("JOIN tags ON tags.id = image_tags.tag_id").
+		Where("image_tags.image_id IN ?", imageIds).
+		Order("tags.name").
+		Scan(&names).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list image tags: %w", err)
+	}
+	return names, nil
+}
+
+// DeleteImageTags removes every tag of purged images
+func (store *TagStore) DeleteImageTags(imageIds []uuid.UUID) error {
+	if err := store.DBHandler.DB.Where("image_id IN ?", imageIds).Delete(&entities.ImageTag{}).Error; err != nil {
+		return fmt.Errorf("failed to delete image tags: %w", err)
+	}
+	return nil
+}
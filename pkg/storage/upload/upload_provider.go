@@ -0,0 +1,8 @@
+package upload
+
+import (
+	"github.com/google/wire"
+)
+
+// ProviderSet for the upload store package
+var ProviderSet = wire.NewSet(NewUploadStore)
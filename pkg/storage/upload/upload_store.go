@@ -0,0 +1,174 @@
+package upload
+
+import (
+	"bit-image/internal/postrges"
+	"bit-image/pkg/common/entities"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// ErrUploadNotFound is returned when no upload matches the lookup
+var ErrUploadNotFound = errors.New("upload not found")
+
+type UploadStore struct {
+	DBHandler *postrges.ConnectionHandler
+}
+
+func NewUploadStore(dbHandler *postrges.ConnectionHandler) *UploadStore {
+	return &UploadStore{
+		DBHandler: dbHandler,
+	}
+}
+
+func (store *UploadStore) AddUploads(uploads []entities.Upload) error {
+	if len(uploads) == 0 {
+		return nil
+	}
+	if err := store.DBHandler.DB.Create(&uploads).Error; err != nil {
+		return fmt.Errorf("failed to insert uploads: %w", err)
+	}
+	return nil
+}
+
+// GetUpload returns the upload of the image in whatever state it is, only if it was issued to the given owner
+func (store *UploadStore) GetUpload(imageId uuid.UUID, ownerId string) (*entities.Upload, error) {
+	var upload entities.Upload
+	err := store.DBHandler.DB.First(&upload, "id = ? AND owner_id = ?", imageId, ownerId).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("failed to get upload %s: %w", imageId.String(), err)
+	}
+	return &upload, nil
+}
+
+// MarkUploadVerified moves an issued or uploaded upload to uploaded and keeps the confirmation request with it. It
+// reports false when the upload is in another state, such as being moved by a concurrent confirmation.
+func (store *UploadStore) MarkUploadVerified(imageId uuid.UUID, name string, caption string, isPrivate bool) (bool, error) {
+	return store.transition(store.DBHandler.DB, imageId, []string{entities.UploadIssued, entities.UploadUploaded}, map[string]any{
+		"state":      entities.UploadUploaded,
+		"name":       name,
+		"caption":    caption,
+		"is_private": isPrivate,
+	})
+}
+
+// BeginUploadMove moves an uploaded upload to moving before its content is copied to destinationKey. It reports false
+// when the upload isn't uploaded, only one confirmation can move the content.
+func (store *UploadStore) BeginUploadMove(imageId uuid.UUID, destinationKey string) (bool, error) {
+	return store.transition(store.DBHandler.DB, imageId, []string{entities.UploadUploaded}, map[string]any{
+		"state":           entities.UploadMoving,
+		"destination_key": destinationKey,
+	})
+}
+
+// AbortUploadMove moves a moving upload back to uploaded, once its content is back where it was before the move
+func (store *UploadStore) AbortUploadMove(imageId uuid.UUID, reason string) error {
+	_, err := store.transition(store.DBHandler.DB, imageId, []string{entities.UploadMoving}, map[string]any{
+		"state":           entities.UploadUploaded,
+		"destination_key": "",
+		"last_error":      reason,
+	})
+	return err
+}
+
+// CommitUploadWithTransaction marks an upload committed in the transaction inserting its image. It reports false when
+// the upload was already committed or failed.
+func (store *UploadStore) CommitUploadWithTransaction(tx *gorm.DB, imageId uuid.UUID) (bool, error) {
+	return store.transition(tx, imageId, []string{entities.UploadIssued, entities.UploadUploaded, entities.UploadMoving}, map[string]any{
+		"state":      entities.UploadCommitted,
+		"last_error": "",
+	})
+}
+
+// MarkUploadFailed fails an upload that wasn't committed, it can't be confirmed anymore
+func (store *UploadStore) MarkUploadFailed(imageId uuid.UUID, reason string) error {
+	_, err := store.transition(store.DBHandler.DB, imageId, []string{entities.UploadIssued, entities.UploadUploaded, entities.UploadMoving}, map[string]any{
+		"state":      entities.UploadFailed,
+		"last_error": reason,
+	})
+	return err
+}
+
+// transition changes the state of an upload only if it is in one of the from states, and reports whether it was
+func (store *UploadStore) transition(tx *gorm.DB, imageId uuid.UUID, from []string, updates map[string]any) (bool, error) {
+	updates["state_changed_at"] = time.Now()
+	result := tx.Model(&entities.Upload{}).Where("id = ? AND state IN ?", imageId, from).Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update upload %s: %w", imageId.String(), result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ClaimInterruptedUploads takes up to limit uploads whose confirmation was interrupted: those left moving since before
+// staleBefore, and those a recovery already put back to uploaded that weren't confirmed since. Claimed rows count an
+// attempt and aren't stale again before a full staleness period. Rows claimed by someone else are skipped.
+func (store *UploadStore) ClaimInterruptedUploads(limit int, staleBefore time.Time) ([]entities.Upload, error) {
+	stale := store.DBHandler.DB.Model(&entities.Upload{}).
+		Select("id").
+		Where("(state = ? OR (state = ? AND attempts > 0)) AND state_changed_at < ?", entities.UploadMoving, entities.UploadUploaded, staleBefore).
+		Order("state_changed_at").
+		Limit(limit).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+
+	var claimed []entities.Upload
+	err := store.DBHandler.DB.Model(&claimed).
+		Clauses(clause.Returning{}).
+		Where("id IN (?)", stale).
+		Updates(map[string]any{
+			"attempts":         gorm.Expr("attempts + 1"),
+			"state_changed_at": time.Now(),
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim interrupted uploads: %w", err)
+	}
+	return claimed, nil
+}
+
+// ListConfirmingUploads returns which of the uploads are uploaded or moving, their temporary object is still needed
+// to finish the confirmation
+func (store *UploadStore) ListConfirmingUploads(imageIds []uuid.UUID) ([]uuid.UUID, error) {
+	confirming := []uuid.UUID{}
+	err := store.DBHandler.DB.Model(&entities.Upload{}).
+		Where("id IN ? AND state IN ?", imageIds, []string{entities.UploadUploaded, entities.UploadMoving}).
+		Pluck("id", &confirming).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up confirming uploads: %w", err)
+	}
+	return confirming, nil
+}
+
+// CountUploadsByState returns how many uploads are in each state
+func (store *UploadStore) CountUploadsByState() (map[string]int64, error) {
+	var rows []struct {
+		State string
+		Count int64
+	}
+	err := store.DBHandler.DB.Model(&entities.Upload{}).Select("state, count(*) AS count").Group("state").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count uploads: %w", err)
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.State] = row.Count
+	}
+	return counts, nil
+}
+
+// DeleteExpiredUploads drops uploads whose presigned url expired before the given time and returns how many were
+// removed. Uploads still being recovered are kept until the recovery is done with them.
+func (store *UploadStore) DeleteExpiredUploads(expiredBefore time.Time) (int64, error) {
+	result := store.DBHandler.DB.
+		Where("expires_at < ?", expiredBefore).
+		Where("NOT (state = ? OR (state = ? AND attempts > 0))", entities.UploadMoving, entities.UploadUploaded).
+		Delete(&entities.Upload{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired uploads: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,181 @@
+package upload
+
+import (
+	"bit-image/internal/postrges"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// statementRecorder keeps the SQL of every statement, which a dry run builds without sending
+type statementRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (recorder *statementRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	statement, _ := fc()
+	recorder.statements = append(recorder.statements, statement)
+}
+
+// dryRunStore returns a store whose statements are recorded instead of being run against a database. Updates skip
+// the default transaction, beginning one would need a connection.
+func dryRunStore(t *testing.T) (*UploadStore, *statementRecorder) {
+	t.Helper()
+	recorder := &statementRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1"}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+		Logger:                 recorder,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	return NewUploadStore(&postrges.ConnectionHandler{DB: db}), recorder
+}
+
+// onlyStatement returns the single statement the recorder holds
+func onlyStatement(t *testing.T, recorder *statementRecorder) string {
+	t.Helper()
+	if len(recorder.statements) != 1 {
+		t.Fatalf("got %d statements, want 1: %v", len(recorder.statements), recorder.statements)
+	}
+	return recorder.statements[0]
+}
+
+func TestUploadStateTransitions(t *testing.T) {
+	imageId := uuid.MustParse("3b9e6f1d-2c4a-4e8b-9a7d-5f0c1e2d3a4b")
+	tests := []struct {
+		name       string
+		transition func(store *UploadStore) error
+		from       string
+		set        []string
+	}{
+		{
+			name: "verify",
+			transition: func(store *UploadStore) error {
+				_, err := store.MarkUploadVerified(imageId, "cat", "a cat", true)
+				return err
+			},
+			from: `state IN ('issued','uploaded')`,
+			set:  []string{`"state"='uploaded'`, `"name"='cat'`, `"caption"='a cat'`, `"is_private"=true`},
+		},
+		{
+			name: "begin move",
+			transition: func(store *UploadStore) error {
+				_, err := store.BeginUploadMove(imageId, "images/cat")
+				return err
+			},
+			from: `state IN ('uploaded')`,
+			set:  []string{`"state"='moving'`, `"destination_key"='images/cat'`},
+		},
+		{
+			name: "abort move",
+			transition: func(store *UploadStore) error {
+				return store.AbortUploadMove(imageId, "copy failed")
+			},
+			from: `state IN ('moving')`,
+			set:  []string{`"state"='uploaded'`, `"destination_key"=''`, `"last_error"='copy failed'`},
+		},
+		{
+			name: "commit",
+			transition: func(store *UploadStore) error {
+				_, err := store.CommitUploadWithTransaction(store.DBHandler.DB, imageId)
+				return err
+			},
+			from: `state IN ('issued','uploaded','moving')`,
+			set:  []string{`"state"='committed'`, `"last_error"=''`},
+		},
+		{
+			name: "fail",
+			transition: func(store *UploadStore) error {
+				return store.MarkUploadFailed(imageId, "not an image")
+			},
+			from: `state IN ('issued','uploaded','moving')`,
+			set:  []string{`"state"='failed'`, `"last_error"='not an image'`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, recorder := dryRunStore(t)
+			if err := tt.transition(store); err != nil {
+				t.Fatalf("transition error = %v", err)
+			}
+			statement := onlyStatement(t, recorder)
+			want := append([]string{
+				`UPDATE "uploads" SET`,
+				`"state_changed_at"=`,
+				"WHERE id = '" + imageId.String() + "' AND " + tt.from,
+			}, tt.set...)
+			for _, fragment := range want {
+				if !strings.Contains(statement, fragment) {
+					t.Errorf("statement %q does not contain %q", statement, fragment)
+				}
+			}
+		})
+	}
+}
+
+func TestInterruptedUploadsAreKept(t *testing.T) {
+	const interrupted = `(state = 'moving' OR (state = 'uploaded' AND attempts > 0))`
+	tests := []struct {
+		name  string
+		query func(store *UploadStore) error
+		want  []string
+	}{
+		{
+			name: "claim",
+			query: func(store *UploadStore) error {
+				_, err := store.ClaimInterruptedUploads(5, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+				return err
+			},
+			want: []string{
+				`"attempts"=attempts + 1`,
+				"WHERE " + interrupted + " AND state_changed_at < '2024-01-02 03:04:05'",
+				"ORDER BY state_changed_at LIMIT 5 FOR UPDATE SKIP LOCKED",
+				"RETURNING *",
+			},
+		},
+		{
+			name: "delete expired",
+			query: func(store *UploadStore) error {
+				_, err := store.DeleteExpiredUploads(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+				return err
+			},
+			want: []string{
+				`DELETE FROM "uploads"`,
+				"expires_at < '2024-01-02 03:04:05'",
+				"NOT " + interrupted,
+			},
+		},
+		{
+			name: "list confirming",
+			query: func(store *UploadStore) error {
+				_, err := store.ListConfirmingUploads([]uuid.UUID{uuid.Nil})
+				return err
+			},
+			want: []string{"state IN ('uploaded','moving')"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, recorder := dryRunStore(t)
+			if err := tt.query(store); err != nil {
+				t.Fatalf("query error = %v", err)
+			}
+			statement := onlyStatement(t, recorder)
+			for _, fragment := range tt.want {
+				if !strings.Contains(statement, fragment) {
+					t.Errorf("statement %q does not contain %q", statement, fragment)
+				}
+			}
+		})
+	}
+}
@@ -1,23 +1,35 @@
 package storage
 
 import (
+	"bit-image/internal/postrges"
 	"bit-image/pkg/common/entities"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUserDeleted   = errors.New("user was deleted")
+	ErrQuotaExceeded = errors.New("upload quota exceeded")
 )
 
 type UserStore struct {
-	db *gorm.DB
+	DBHandler *postrges.ConnectionHandler
 }
 
-func NewUserStore(db *gorm.DB) *UserStore {
+func NewUserStore(dbHandler *postrges.ConnectionHandler) *UserStore {
 	return &UserStore{
-		db: db,
+		DBHandler: dbHandler,
 	}
 }
 
 // AddUser adds a user to the database
 func (s *UserStore) AddUser(user entities.User) error {
-	tx := s.db.Begin()
+	tx := s.DBHandler.DB.Begin()
 
 	if err := tx.Create(&user).Error; err != nil {
 		tx.Rollback()
@@ -31,9 +43,155 @@ func (s *UserStore) AddUser(user entities.User) error {
 	return nil
 }
 
+// EnsureUser adds the user unless a row with the same id already exists
+func (s *UserStore) EnsureUser(user entities.User) error {
+	return s.EnsureUserWithTransaction(s.DBHandler.DB, user)
+}
+
+func (s *UserStore) EnsureUserWithTransaction(tx *gorm.DB, user entities.User) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&user).Error; err != nil {
+		return fmt.Errorf("failed to ensure user %s: %w", user.Base.Id.String(), err)
+	}
+	return nil
+}
+
+// GetUser returns the user with the given id
+func (s *UserStore) GetUser(userID uuid.UUID) (*entities.User, error) {
+	var user entities.User
+	if err := s.DBHandler.DB.First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user %s: %w", userID.String(), err)
+	}
+	return &user, nil
+}
+
+// GetUserIncludingDeleted returns a user even when it was deleted
+func (s *UserStore) GetUserIncludingDeleted(userID uuid.UUID) (*entities.User, error) {
+	var user entities.User
+	if err := s.DBHandler.DB.Unscoped().First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user %s: %w", userID.String(), err)
+	}
+	return &user, nil
+}
+
+// ListUsers returns up to limit users ordered by id, starting after the given id when one is given
+func (s *UserStore) ListUsers(after *uuid.UUID, limit int) ([]entities.User, error) {
+	query := s.DBHandler.DB.Model(&entities.User{})
+	if after != nil {
+		query = query.Where("id > ?", *after)
+	}
+	var users []entities.User
+	if err := query.Order("id").Limit(limit).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// CountUsers returns how many users there are
+func (s *UserStore) CountUsers() (int64, error) {
+	var count int64
+	if err := s.DBHandler.DB.Model(&entities.User{}).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+// SetQuota changes the limits of a user, nil limits are left as they are. Usage already over a lowered limit is kept,
+// the user just can't upload until it is back under.
+func (s *UserStore) SetQuota(userID uuid.UUID, imageLimit *int, byteLimit *int64) (*entities.User, error) {
+	updates := map[string]interface{}{}
+	if imageLimit != nil {
+		updates["image_upload_limit"] = *imageLimit
+	}
+	if byteLimit != nil {
+		updates["byte_upload_limit"] = *byteLimit
+	}
+	if len(updates) > 0 {
+		result := s.DBHandler.DB.Model(&entities.User{}).Where("id = ?", userID).Updates(updates)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to update quota of user %s: %w", userID.String(), result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil, ErrUserNotFound
+		}
+	}
+	return s.GetUser(userID)
+}
+
+// SetSuspension suspends a user when suspendedAt is set and lifts the suspension otherwise
+func (s *UserStore) SetSuspension(userID uuid.UUID, suspendedAt *time.Time, reason string) (*entities.User, error) {
+	result := s.DBHandler.DB.Model(&entities.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"suspended_at": suspendedAt, "suspended_reason": reason})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update suspension of user %s: %w", userID.String(), result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrUserNotFound
+	}
+	return s.GetUser(userID)
+}
+
+// SoftDeleteUserWithTransaction marks a user as deleted, it returns false when the user doesn't exist or was already
+// deleted
+func (s *UserStore) SoftDeleteUserWithTransaction(tx *gorm.DB, userID uuid.UUID) (bool, error) {
+	result := tx.Where("id = ?", userID).Delete(&entities.User{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete user %s: %w", userID.String(), result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ConsumeQuotaWithTransaction adds images and bytes to the usage of the user, failing with ErrQuotaExceeded when
+// either limit would be crossed and with ErrUserDeleted when the user was deleted. The check and the update are a
+// single statement, so concurrent confirmations can't overshoot the limits.
+func (s *UserStore) ConsumeQuotaWithTransaction(tx *gorm.DB, userID uuid.UUID, images int, bytes int64) error {
+	result := tx.Model(&entities.User{}).
+		Where("id = ? AND image_upload_count + ? <= image_upload_limit AND byte_upload_count + ? <= byte_upload_limit", userID, images, bytes).
+		Updates(map[string]interface{}{
+			"image_upload_count": gorm.Expr("image_upload_count + ?", images),
+			"byte_upload_count":  gorm.Expr("byte_upload_count + ?", bytes),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update usage of user %s: %w", userID.String(), result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	// the update skips deleted users as well as the ones over their limits
+	var deleted int64
+	err := tx.Unscoped().Model(&entities.User{}).Where("id = ? AND date_time_deleted IS NOT NULL", userID).Count(&deleted).Error
+	if err != nil {
+		return fmt.Errorf("failed to get user %s: %w", userID.String(), err)
+	}
+	if deleted > 0 {
+		return ErrUserDeleted
+	}
+	return ErrQuotaExceeded
+}
+
+// ReleaseQuotaWithTransaction gives back images and bytes after a deletion
+func (s *UserStore) ReleaseQuotaWithTransaction(tx *gorm.DB, userID uuid.UUID, images int, bytes int64) error {
+	err := tx.Model(&entities.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"image_upload_count": gorm.Expr("GREATEST(image_upload_count - ?, 0)", images),
+			"byte_upload_count":  gorm.Expr("GREATEST(byte_upload_count - ?, 0)", bytes),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update usage of user %s: %w", userID.String(), err)
+	}
+	return nil
+}
+
 // DeleteUserByID deletes a user by ID
 func (s *UserStore) DeleteUserByID(userID string) error {
-	tx := s.db.Begin()
+	tx := s.DBHandler.DB.Begin()
 
 	if err := tx.Delete(&entities.User{}, "id = ?", userID).Error; err != nil {
 		tx.Rollback()
@@ -50,7 +208,7 @@ func (s *UserStore) DeleteUserByID(userID string) error {
 // DoesUserExist checks if a user exists in the database
 func (s *UserStore) DoesUserExist(userID string) (bool, error) {
 	var user entities.User
-	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+	if err := s.DBHandler.DB.First(&user, "id = ?", userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return false, nil
 		}
@@ -0,0 +1,8 @@
+package webhook
+
+import (
+	"github.com/google/wire"
+)
+
+// ProviderSet for the webhook store package
+var ProviderSet = wire.NewSet(NewWebhookStore)
@@ -0,0 +1,277 @@
+package webhook
+
+import (
+	"bit-image/internal/postrges"
+	"bit-image/pkg/common/entities"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+)
+
+// DeliveryCursor is the position of the last delivery of a page, the delivery log is ordered newest first
+type DeliveryCursor struct {
+	DateTimeCreated time.Time
+	Id              uuid.UUID
+}
+
+type WebhookStore struct {
+	DBHandler *postrges.ConnectionHandler
+}
+
+func NewWebhookStore(dbHandler *postrges.ConnectionHandler) *WebhookStore {
+	return &WebhookStore{
+		DBHandler: dbHandler,
+	}
+}
+
+func (store *WebhookStore) AddSubscription(subscription *entities.WebhookSubscription) error {
+	if err := store.DBHandler.DB.Create(subscription).Error; err != nil {
+		return fmt.Errorf("failed to insert webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// CountSubscriptions returns how many subscriptions a user has
+func (store *WebhookStore) CountSubscriptions(ownerId string) (int64, error) {
+	var count int64
+	err := store.DBHandler.DB.Model(&entities.WebhookSubscription{}).Where("owner_id = ?", ownerId).Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count webhook subscriptions: %w", err)
+	}
+	return count, nil
+}
+
+// GetSubscription returns a subscription of a user, ErrSubscriptionNotFound when it doesn't exist or belongs to
+// someone else
+func (store *WebhookStore) GetSubscription(subscriptionId uuid.UUID, ownerId string) (*entities.WebhookSubscription, error) {
+	var subscription entities.WebhookSubscription
+	err := store.DBHandler.DB.First(&subscription, "id = ? AND owner_id = ?", subscriptionId, ownerId).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+	return &subscription, nil
+}
+
+// ListSubscriptions returns the subscriptions of a user, oldest first
+func (store *WebhookStore) ListSubscriptions(ownerId string) ([]entities.WebhookSubscription, error) {
+	var subscriptions []entities.WebhookSubscription
+	err := store.DBHandler.DB.Where("owner_id = ?", ownerId).Order("date_time_created, id").Find(&subscriptions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// ListActiveSubscriptions returns the subscriptions events of a user are sent to
+func (store *WebhookStore) ListActiveSubscriptions(ownerId string) ([]entities.WebhookSubscription, error) {
+	var subscriptions []entities.WebhookSubscription
+	err := store.DBHandler.DB.Where("owner_id = ? AND active", ownerId).Find(&subscriptions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active webhook subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// GetSubscriptionsByIds returns the subscriptions that still exist among the ids
+func (store *WebhookStore) GetSubscriptionsByIds(subscriptionIds []uuid.UUID) ([]entities.WebhookSubscription, error) {
+	var subscriptions []entities.WebhookSubscription
+	if len(subscriptionIds) == 0 {
+		return subscriptions, nil
+	}
+	if err := store.DBHandler.DB.Where("id IN ?", subscriptionIds).Find(&subscriptions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// UpdateSubscription changes the given columns of a subscription of a user
+func (store *WebhookStore) UpdateSubscription(subscriptionId uuid.UUID, ownerId string, updates map[string]any) error {
+	result := store.DBHandler.DB.Model(&entities.WebhookSubscription{}).
+		Where("id = ? AND owner_id = ?", subscriptionId, ownerId).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update webhook subscription: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// DeleteSubscription removes a subscription of a user along with its delivery log
+func (store *WebhookStore) DeleteSubscription(subscriptionId uuid.UUID, ownerId string) error {
+	tx, commit, rollback, err := store.DBHandler.OpenTransaction()
+	if err != nil {
+		return err
+	}
+
+	if err := deleteSubscriptionWithTransaction(tx, subscriptionId, ownerId); err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			return fmt.Errorf("delete error: %v, rollback error: %v", err, rollbackErr)
+		}
+		return err
+	}
+
+	if err := commit(); err != nil {
+		return fmt.Errorf("commit error: %v", err)
+	}
+	return nil
+}
+
+func deleteSubscriptionWithTransaction(tx *gorm.DB, subscriptionId uuid.UUID, ownerId string) error {
+	result := tx.Where("id = ? AND owner_id = ?", subscriptionId, ownerId).Delete(&entities.WebhookSubscription{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSubscriptionNotFound
+	}
+	if err := tx.Where("subscription_id = ?", subscriptionId).Delete(&entities.WebhookDelivery{}).Error; err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// DeleteOwnerSubscriptionsWithTransaction removes every subscription of a user along with their delivery logs
+func (store *WebhookStore) DeleteOwnerSubscriptionsWithTransaction(tx *gorm.DB, ownerId string) error {
+	subscriptions := tx.Model(&entities.WebhookSubscription{}).Select("id").Where("owner_id = ?", ownerId)
+	if err := tx.Where("subscription_id IN (?)", subscriptions).Delete(&entities.WebhookDelivery{}).Error; err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	if err := tx.Where("owner_id = ?", ownerId).Delete(&entities.WebhookSubscription{}).Error; err != nil {
+		return fmt.Errorf("failed to delete webhook subscriptions: %w", err)
+	}
+	return nil
+}
+
+// AddDeliveries queues deliveries, an event already queued for a subscription is left as it is so that fanning out an
+// event more than once sends it once
+func (store *WebhookStore) AddDeliveries(deliveries []entities.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	err := store.DBHandler.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subscription_id"}, {Name: "event_id"}},
+		DoNothing: true,
+	}).Create(&deliveries).Error
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// ClaimDeliveries takes up to limit pending deliveries that are due. Claimed rows count an attempt and aren't due again
+// before leaseUntil, so a dispatcher that dies while holding them doesn't keep them from being retried. Rows claimed by
+// someone else are skipped.
+func (store *WebhookStore) ClaimDeliveries(limit int, leaseUntil time.Time) ([]entities.WebhookDelivery, error) {
+	due := store.DBHandler.DB.Model(&entities.WebhookDelivery{}).
+		Select("id").
+		Where("status = ? AND next_attempt_at <= ?", entities.WebhookDeliveryPending, time.Now()).
+		Order("next_attempt_at").
+		Limit(limit).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+
+	var claimed []entities.WebhookDelivery
+	err := store.DBHandler.DB.Model(&claimed).
+		Clauses(clause.Returning{}).
+		Where("id IN (?)", due).
+		Updates(map[string]any{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": leaseUntil,
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	return claimed, nil
+}
+
+// MarkDeliveryDelivered records an acknowledged delivery
+func (store *WebhookStore) MarkDeliveryDelivered(deliveryId uuid.UUID, responseStatus int) error {
+	err := store.DBHandler.DB.Model(&entities.WebhookDelivery{}).
+		Where("id = ?", deliveryId).
+		Updates(map[string]any{
+			"status":          entities.WebhookDeliveryDelivered,
+			"response_status": responseStatus,
+			"last_error":      "",
+			"delivered_at":    time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery %s: %w", deliveryId.String(), err)
+	}
+	return nil
+}
+
+// MarkDeliveryFailed records a failed attempt. The delivery is retried at retryAt, or dead-lettered when retryAt is nil.
+func (store *WebhookStore) MarkDeliveryFailed(deliveryId uuid.UUID, responseStatus int, reason string, retryAt *time.Time) error {
+	updates := map[string]any{"response_status": responseStatus, "last_error": reason}
+	if retryAt != nil {
+		updates["next_attempt_at"] = *retryAt
+	} else {
+		updates["status"] = entities.WebhookDeliveryDead
+	}
+	err := store.DBHandler.DB.Model(&entities.WebhookDelivery{}).Where("id = ?", deliveryId).Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery %s: %w", deliveryId.String(), err)
+	}
+	return nil
+}
+
+// ListDeliveries returns a page of the delivery log of a subscription, newest first, only those with the status when
+// it is set
+func (store *WebhookStore) ListDeliveries(subscriptionId uuid.UUID, status string, after *DeliveryCursor, limit int) ([]entities.WebhookDelivery, error) {
+	query := store.DBHandler.DB.Where("subscription_id = ?", subscriptionId)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if after != nil {
+		query = query.Where("(date_time_created, id) < (?, ?)", after.DateTimeCreated, after.Id)
+	}
+
+	var deliveries []entities.WebhookDelivery
+	err := query.Order("date_time_created DESC, id DESC").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a delivery of a subscription again with a fresh set of attempts, whatever its status
+func (store *WebhookStore) Redeliver(deliveryId uuid.UUID, subscriptionId uuid.UUID) error {
+	result := store.DBHandler.DB.Model(&entities.WebhookDelivery{}).
+		Where("id = ? AND subscription_id = ?", deliveryId, subscriptionId).
+		Updates(map[string]any{
+			"status":          entities.WebhookDeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to redeliver webhook delivery %s: %w", deliveryId.String(), result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeliveryNotFound
+	}
+	return nil
+}
+
+// DeleteFinishedDeliveries removes the delivered and dead deliveries created before the cutoff and returns how many
+// there were
+func (store *WebhookStore) DeleteFinishedDeliveries(cutoff time.Time) (int64, error) {
+	result := store.DBHandler.DB.
+		Where("status IN ? AND date_time_created < ?", []string{entities.WebhookDeliveryDelivered, entities.WebhookDeliveryDead}, cutoff).
+		Delete(&entities.WebhookDelivery{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete finished webhook deliveries: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
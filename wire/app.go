@@ -0,0 +1,41 @@
+package wire
+
+import (
+	"bit-image/internal/postrges"
+	"bit-image/pkg/config"
+	"bit-image/pkg/handlers"
+	"bit-image/pkg/middleware"
+	"bit-image/pkg/services"
+	"bit-image/pkg/storage"
+)
+
+// App holds every component of the server and the maintenance commands. They are built by one injector, so they share
+// the configuration, the connection pool and the object store, and the background services are the same instances as
+// the ones the image service wakes up.
+type App struct {
+	Config      *config.Config
+	DB          *postrges.ConnectionHandler
+	ObjectStore storage.ObjectStore
+	Migrator    *postrges.Migrator
+
+	ImageHandler        *handlers.ImageHandler
+	LocalStorageHandler *handlers.LocalStorageHandler
+	WebhookHandler      *handlers.WebhookHandler
+	UserHandler         *handlers.UserHandler
+	Authenticator       *middleware.Authenticator
+
+	ImagePurger         *services.ImagePurger
+	UploadReaper        *services.UploadReaper
+	UploadRecovery      *services.UploadRecovery
+	Reconciler          *services.Reconciler
+	DerivativeGenerator *services.DerivativeGenerator
+	AutoLabeler         *services.AutoLabeler
+	Fingerprints        *services.FingerprintIndex
+	Events              *services.OutboxDispatcher
+	Webhooks            *services.WebhookDispatcher
+}
+
+// Close releases the connection pool
+func (app *App) Close() {
+	app.DB.Close()
+}
@@ -1,36 +1,74 @@
+//go:build wireinject
+// +build wireinject
+
 package wire
 
-//// Provider sets for different components
-//var DataStoreProviderSet = wire.NewSet(
-//	postrges.ProviderSet,
-//	image.ProviderSet,
-//	s3.ProviderSet,
-//)
-//
-//var ServiceProviderSet = wire.NewSet(
-//	services.ProviderSet,
-//)
-//
-//var HandlerProviderSet = wire.NewSet(
-//	handlers.ProviderSet,
-//)
-//
-//// Aggregate provider set
-//var AppProviderSet = wire.NewSet(
-//	DataStoreProviderSet,
-//	ServiceProviderSet,
-//	HandlerProviderSet,
-//)
-//
-//// Injector functions
-//// InitializeImageHandler initializes the ImageHandler.
-//func InitializeImageHandler() (*handlers.ImageHandler, error) {
-//	wire.Build(AppProviderSet)
-//	return nil, nil
-//}
-
-// InitializeUserHandler initializes the UserHandler.
-//func InitializeUserHandler() (*handlers.UserHandler, error) {
-//	wire.Build(AppProviderSet)
-//	return nil, nil
-//}
+import (
+	"bit-image/internal/postrges"
+	"bit-image/internal/s3"
+	"bit-image/pkg/config"
+	"bit-image/pkg/handlers"
+	"bit-image/pkg/middleware"
+	"bit-image/pkg/services"
+	"bit-image/pkg/storage"
+	"bit-image/pkg/storage/blob"
+	"bit-image/pkg/storage/derivative"
+	"bit-image/pkg/storage/exif"
+	"bit-image/pkg/storage/image"
+	"bit-image/pkg/storage/label"
+	"bit-image/pkg/storage/outbox"
+	"bit-image/pkg/storage/tag"
+	"bit-image/pkg/storage/upload"
+	"bit-image/pkg/storage/webhook"
+	"github.com/google/wire"
+)
+
+// Provider sets for different components
+var DataStoreProviderSet = wire.NewSet(
+	postrges.ProviderSet,
+	image.ProviderSet,
+	upload.ProviderSet,
+	blob.ProviderSet,
+	derivative.ProviderSet,
+	exif.ProviderSet,
+	tag.ProviderSet,
+	label.ProviderSet,
+	outbox.ProviderSet,
+	webhook.ProviderSet,
+	storage.ProviderSet,
+	s3.ProviderSet,
+)
+
+var ServiceProviderSet = wire.NewSet(
+	services.ProviderSet,
+)
+
+var HandlerProviderSet = wire.NewSet(
+	handlers.ProviderSet,
+)
+
+// Aggregate provider set
+var AppProviderSet = wire.NewSet(
+	DataStoreProviderSet,
+	ServiceProviderSet,
+	HandlerProviderSet,
+)
+
+// Injector functions
+// InitializeConfig loads and validates the configuration.
+func InitializeConfig() (*config.Config, error) {
+	wire.Build(config.ProviderSet)
+	return nil, nil
+}
+
+// InitializeMigrator initializes the schema migrations, without building the rest of the app.
+func InitializeMigrator(cfg *config.Config) (*postrges.Migrator, error) {
+	wire.Build(postrges.ProviderSet)
+	return nil, nil
+}
+
+// InitializeApp builds every component of the app from one configuration, connection pool and object store.
+func InitializeApp(cfg *config.Config) (*App, error) {
+	wire.Build(AppProviderSet, middleware.ProviderSet, wire.Struct(new(App), "*"))
+	return nil, nil
+}
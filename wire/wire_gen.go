@@ -9,37 +9,128 @@ package wire
 import (
 	"bit-image/internal/postrges"
 	"bit-image/internal/s3"
+	"bit-image/pkg/config"
 	"bit-image/pkg/handlers"
+	"bit-image/pkg/middleware"
 	"bit-image/pkg/services"
+	"bit-image/pkg/storage"
+	"bit-image/pkg/storage/blob"
+	"bit-image/pkg/storage/derivative"
+	"bit-image/pkg/storage/exif"
 	"bit-image/pkg/storage/image"
+	"bit-image/pkg/storage/label"
+	"bit-image/pkg/storage/outbox"
+	"bit-image/pkg/storage/tag"
+	"bit-image/pkg/storage/upload"
+	"bit-image/pkg/storage/webhook"
 	"github.com/google/wire"
 )
 
 // Injectors from wire.go:
 
 // Injector functions
-// InitializeImageHandler initializes the ImageHandler.
-func InitializeImageHandler() (*handlers.ImageHandler, error) {
-	connectionHandler, err := postrges.NewConnectionHandler()
+// InitializeConfig loads and validates the configuration.
+func InitializeConfig() (*config.Config, error) {
+	configConfig, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	return configConfig, nil
+}
+
+// InitializeMigrator initializes the schema migrations, without building the rest of the app.
+func InitializeMigrator(cfg *config.Config) (*postrges.Migrator, error) {
+	connectionHandler, err := postrges.NewConnectionHandler(cfg)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := postrges.NewMigrator(connectionHandler)
+	if err != nil {
+		return nil, err
+	}
+	return migrator, nil
+}
+
+// InitializeApp builds every component of the app from one configuration, connection pool and object store.
+func InitializeApp(cfg *config.Config) (*App, error) {
+	connectionHandler, err := postrges.NewConnectionHandler(cfg)
+	if err != nil {
+		return nil, err
+	}
+	objectStore, err := s3.NewObjectStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := postrges.NewMigrator(connectionHandler)
 	if err != nil {
 		return nil, err
 	}
 	imageStore := image.NewImageStore(connectionHandler)
-	client, err := s3.NewS3Client()
+	handler := s3.NewHandler(objectStore)
+	uploadStore := upload.NewUploadStore(connectionHandler)
+	blobStore := blob.NewBlobStore(connectionHandler)
+	userStore := storage.NewUserStore(connectionHandler)
+	derivativeStore := derivative.NewDerivativeStore(connectionHandler)
+	derivativeGenerator := services.NewDerivativeGenerator(imageStore, handler, derivativeStore, cfg)
+	exifStore := exif.NewExifStore(connectionHandler)
+	tagStore := tag.NewTagStore(connectionHandler)
+	labelStore := label.NewLabelStore(connectionHandler)
+	outboxStore := outbox.NewOutboxStore(connectionHandler)
+	labeler, err := services.NewLabeler(cfg)
 	if err != nil {
 		return nil, err
 	}
-	s3FileSystem := s3.NewS3FileSystem(client)
-	handler := s3.NewHandler(s3FileSystem)
-	imageService := services.NewImageService(imageStore, handler)
+	autoLabeler := services.NewAutoLabeler(imageStore, handler, exifStore, labelStore, outboxStore, labeler, cfg)
+	fingerprintIndex := services.NewFingerprintIndex(imageStore, handler, cfg)
+	eventPublisher, err := services.NewEventPublisher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	webhookStore := webhook.NewWebhookStore(connectionHandler)
+	webhookDispatcher := services.NewWebhookDispatcher(webhookStore, cfg)
+	outboxDispatcher := services.NewOutboxDispatcher(outboxStore, eventPublisher, webhookDispatcher, cfg)
+	imageService := services.NewImageService(imageStore, handler, uploadStore, blobStore, userStore, derivativeStore, derivativeGenerator, exifStore, tagStore, labelStore, autoLabeler, fingerprintIndex, outboxStore, outboxDispatcher, cfg)
 	imageHandler := handlers.NewImageHandler(imageService)
-	return imageHandler, nil
+	localStorageHandler := handlers.NewLocalStorageHandler(objectStore, cfg)
+	webhookService := services.NewWebhookService(webhookStore, webhookDispatcher, cfg)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	userService := services.NewUserService(userStore, imageService, webhookStore, cfg)
+	userHandler := handlers.NewUserHandler(userService)
+	authenticator := middleware.NewAuthenticator(cfg, userService)
+	imagePurger := services.NewImagePurger(imageStore, handler, blobStore, derivativeStore, exifStore, tagStore, labelStore, cfg)
+	uploadReaper := services.NewUploadReaper(handler, uploadStore, cfg)
+	uploadRecovery := services.NewUploadRecovery(imageService, cfg)
+	reconciler, err := services.NewReconciler(imageService, cfg)
+	if err != nil {
+		return nil, err
+	}
+	app := &App{
+		Config:              cfg,
+		DB:                  connectionHandler,
+		ObjectStore:         objectStore,
+		Migrator:            migrator,
+		ImageHandler:        imageHandler,
+		LocalStorageHandler: localStorageHandler,
+		WebhookHandler:      webhookHandler,
+		UserHandler:         userHandler,
+		Authenticator:       authenticator,
+		ImagePurger:         imagePurger,
+		UploadReaper:        uploadReaper,
+		UploadRecovery:      uploadRecovery,
+		Reconciler:          reconciler,
+		DerivativeGenerator: derivativeGenerator,
+		AutoLabeler:         autoLabeler,
+		Fingerprints:        fingerprintIndex,
+		Events:              outboxDispatcher,
+		Webhooks:            webhookDispatcher,
+	}
+	return app, nil
 }
 
 // wire.go:
 
 // Provider sets for different components
-var DataStoreProviderSet = wire.NewSet(postrges.ProviderSet, image.ProviderSet, s3.ProviderSet)
+var DataStoreProviderSet = wire.NewSet(postrges.ProviderSet, image.ProviderSet, upload.ProviderSet, blob.ProviderSet, derivative.ProviderSet, exif.ProviderSet, tag.ProviderSet, label.ProviderSet, outbox.ProviderSet, webhook.ProviderSet, storage.ProviderSet, s3.ProviderSet)
 
 var ServiceProviderSet = wire.NewSet(services.ProviderSet)
 